@@ -0,0 +1,118 @@
+package rtree
+
+// Adjust changes the bounding box of the item with the given data index. If
+// the new bounding box still fits within the region covered by the item's
+// leaf node's parent, then the item stays in its existing leaf and only the
+// bounding boxes of its ancestors are adjusted. Otherwise, the item is
+// deleted and reinserted using the insertion policy. This makes Adjust much
+// cheaper than a delete followed by an insert for small changes. If lookup
+// tracking has been turned on by EnableLookup, then the item is found
+// without scanning the whole tree. It panics if the insertion policy rejects
+// the new bounding box.
+//
+// The return value indicates if an item with the data index was found (items
+// marked as deleted by MarkDeleted aren't found).
+func (t *RTree) Adjust(dataIndex int, newBB BBox, policy InsertionPolicy) bool {
+	if t.isTombstoned(dataIndex) {
+		return false
+	}
+	path, ok := t.locateEntry(dataIndex)
+	if !ok {
+		return false
+	}
+	leaf, pos := path[len(path)-1].node, path[len(path)-1].entry
+
+	// Bounding boxes that need special handling always take the slow path.
+	if !isFinite(newBB) && policy.nonFinite != NonFiniteAllow {
+		old := t.Nodes[leaf].Entries[pos]
+		if policy.nonFinite == NonFiniteReject {
+			panic(nonFiniteError(newBB))
+		}
+		t.reinsert(old, newBB, policy)
+		return true
+	}
+
+	// The box is snapped up front, so that it's stored the same way whether
+	// or not the item stays in its leaf.
+	newBB = policy.snap(newBB)
+	if len(path) == 1 || contains(t.nodeBound(path[:len(path)-1]), newBB) {
+		t.generation++
+		t.hookMove(t.Nodes[leaf].Entries[pos], newBB)
+		t.Nodes[leaf].Entries[pos].BBox = newBB
+		t.setLookup(dataIndex, newBB)
+		t.tightenAncestors(path)
+		return true
+	}
+
+	t.reinsert(t.Nodes[leaf].Entries[pos], newBB, policy)
+	return true
+}
+
+// reinsert deletes the leaf entry and inserts it again with a new bounding
+// box, keeping its payload, tags and weight.
+func (t *RTree) reinsert(old Entry, newBB BBox, policy InsertionPolicy) {
+	defer t.suspendJournal()()
+	t.mutations.Reinsertions++
+	tags, weight := t.tags[old.Index], t.weights[old.Index]
+	t.deleteOne(old)
+	t.setTags(old.Index, tags)
+	t.setWeight(old.Index, weight)
+	e := old
+	e.BBox = newBB
+	if err := t.insertEntry(e, policy); err != nil {
+		panic(err)
+	}
+}
+
+// deleteOne deletes a single leaf entry that is equal to the given entry.
+func (t *RTree) deleteOne(target Entry) {
+	var done bool
+	t.deleteEntries(target.BBox, func(e Entry) bool {
+		if done || e != target {
+			return false
+		}
+		done = true
+		return true
+	}, DeletionPolicy{})
+}
+
+// findEntry finds the path to the item with the given data index. The last
+// step on the path is the item's leaf and its entry position in the leaf.
+func (t *RTree) findEntry(dataIndex int) ([]nodeStep, bool) {
+	if len(t.Nodes) == 0 {
+		return nil, false
+	}
+	var path []nodeStep
+	var recurse func(n int) bool
+	recurse = func(n int) bool {
+		path = append(path, nodeStep{node: n, entry: -1})
+		node := &t.Nodes[n]
+		for i, e := range node.Entries {
+			path[len(path)-1].entry = i
+			if node.IsLeaf {
+				if e.Index == dataIndex {
+					return true
+				}
+			} else if recurse(e.Index) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	ok := recurse(t.RootIndex)
+	return path, ok
+}
+
+// tightenAncestors recalculates the bounding boxes in the ancestors of the
+// last node on the path, stopping once a bounding box is unchanged.
+func (t *RTree) tightenAncestors(path []nodeStep) {
+	for ; len(path) > 1; path = path[:len(path)-1] {
+		e := t.parentEntry(path)
+		bb := t.calculateBound(path[len(path)-1].node)
+		if e.BBox == bb {
+			return
+		}
+		e.BBox = bb
+	}
+}
@@ -0,0 +1,124 @@
+package rtree
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAdjust(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes := make([]BBox, 100)
+	var rt RTree
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Insert(boxes[i], i, ins)
+	}
+
+	for i := 0; i < 200; i++ {
+		idx := rnd.Intn(len(boxes))
+		if i%2 == 0 {
+			// Small jitter.
+			bb := boxes[idx]
+			d := (rnd.Float64() - 0.5) * 0.02
+			boxes[idx] = BBox{bb.MinX + d, bb.MinY - d, bb.MaxX + d, bb.MaxY - d}
+		} else {
+			boxes[idx] = randomBox(rnd, 0.9, 0.1)
+		}
+		if !rt.Adjust(idx, boxes[idx], ins) {
+			t.Fatalf("item %d not found", idx)
+		}
+		checkInvariants(t, rt)
+	}
+	checkSearch(t, rt, boxes, rnd)
+
+	if rt.Adjust(len(boxes), BBox{}, ins) {
+		t.Errorf("expected adjust of missing item to fail")
+	}
+}
+
+func TestAdjustWithinParent(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var boxes []BBox
+	var items []InsertItem
+	for i := 0; i < 64; i++ {
+		x, y := float64(i%8), float64(i/8)
+		boxes = append(boxes, BBox{x, y, x + 0.5, y + 0.5})
+		items = append(items, InsertItem{BBox: boxes[i], DataIndex: i})
+	}
+	rt := BulkLoadWithPolicy(items, ins)
+	rt.EnableLookup()
+	if rt.height() < 2 {
+		t.Fatal("expected leaves to have a parent")
+	}
+
+	// Growing an item beyond its leaf, but within the leaf's parent, keeps
+	// the item in the same leaf.
+	for idx := range boxes {
+		path, _ := rt.findEntry(idx)
+		leaf := path[len(path)-1].node
+		parent := rt.nodeBound(path[:len(path)-1])
+		if rt.calculateBound(leaf) == parent {
+			continue
+		}
+		before := rt.MutationCounts().Reinsertions
+		boxes[idx] = parent
+		if !rt.Adjust(idx, parent, ins) {
+			t.Fatalf("item %d not found", idx)
+		}
+		if rt.MutationCounts().Reinsertions != before {
+			t.Fatalf("item %d was reinserted", idx)
+		}
+		if path, _ := rt.findEntry(idx); path[len(path)-1].node != leaf {
+			t.Fatalf("item %d moved out of its leaf", idx)
+		}
+		checkInvariants(t, rt)
+		break
+	}
+	checkSearch(t, rt, boxes, rand.New(rand.NewSource(0)))
+
+	defer func() {
+		if !errors.Is(recover().(error), ErrInvalidBBox) {
+			t.Fatal("expected a non-finite panic")
+		}
+	}()
+	rt.Adjust(0, BBox{0, 0, math.Inf(1), 1}, ins.WithNonFiniteHandling(NonFiniteReject))
+}
+
+func TestAdjustAndMoveSnapToGrid(t *testing.T) {
+	policy, err := mustPolicy(t, 2, 4).WithGridSnapping(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, update := range map[string]func(*RTree, int, BBox, InsertionPolicy) bool{
+		"adjust": (*RTree).Adjust,
+		"move":   (*RTree).Move,
+	} {
+		var rt RTree
+		for i := 0; i < 50; i++ {
+			rt.Insert(BBox{float64(i), 0, float64(i) + 0.5, 0.5}, i, policy)
+		}
+
+		// A small change stays within the item's leaf, and a large one
+		// moves it elsewhere. Both must be snapped.
+		update(&rt, 10, BBox{10.2, 0.2, 10.7, 0.7}, policy)
+		update(&rt, 20, BBox{100.2, 100.2, 100.7, 100.7}, policy)
+		checkInvariants(t, rt)
+		for idx, want := range map[int]BBox{
+			10: {10, 0, 11, 1},
+			20: {100, 100, 101, 101},
+		} {
+			if got, _ := rt.BBoxOf(idx); got != want {
+				t.Errorf("%s: item %d has bbox %v, want %v", name, idx, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package rtree
+
+import "math"
+
+// Aggregate summarises the weights of a set of items, along with the number
+// of items in the set.
+type Aggregate struct {
+	Sum, Min, Max float64
+	Count         int
+}
+
+func weightAggregate(w float64) Aggregate {
+	return Aggregate{Sum: w, Min: w, Max: w, Count: 1}
+}
+
+func (a Aggregate) combine(b Aggregate) Aggregate {
+	return Aggregate{
+		Sum:   a.Sum + b.Sum,
+		Min:   math.Min(a.Min, b.Min),
+		Max:   math.Max(a.Max, b.Max),
+		Count: a.Count + b.Count,
+	}
+}
+
+// calculateAggregate gives the aggregate of the weights under node n, based
+// on its leaf entries or the aggregates of its children. The aggregate of an
+// empty node is the zero value.
+func (t *RTree) calculateAggregate(n int) Aggregate {
+	node := &t.Nodes[n]
+	var agg Aggregate
+	for i, e := range node.Entries {
+		a := weightAggregate(t.weights[e.Index])
+		if !node.IsLeaf {
+			a = t.Nodes[e.Index].Aggregate
+		}
+		if i == 0 {
+			agg = a
+		} else {
+			agg = agg.combine(a)
+		}
+	}
+	return agg
+}
+
+// calculateAggregates recalculates the aggregates and tags of node n and all
+// nodes under it.
+func (t *RTree) calculateAggregates(n int) {
+	if !t.Nodes[n].IsLeaf {
+		for _, e := range t.Nodes[n].Entries {
+			t.calculateAggregates(e.Index)
+		}
+	}
+	t.summarise(n)
+}
+
+// summarise recalculates the aggregate and tags of node n from its entries.
+func (t *RTree) summarise(n int) {
+	t.Nodes[n].Aggregate = t.calculateAggregate(n)
+	t.Nodes[n].Tags = t.calculateTags(n)
+}
+
+// InsertWithWeight adds a new data item to the RTree, along with a weight
+// that is aggregated by SumWithin, MinWithin and MaxWithin. It panics if the
+// insertion policy rejects the bounding box.
+//
+// Like tags (see InsertWithTags), weights are stored by data index and are
+// dropped when the item is deleted.
+func (t *RTree) InsertWithWeight(bb BBox, dataIndex int, weight float64, policy InsertionPolicy) {
+	old := t.weights[dataIndex]
+	t.setWeight(dataIndex, weight)
+	if err := t.insertEntry(Entry{BBox: bb, Index: dataIndex}, policy); err != nil {
+		t.setWeight(dataIndex, old)
+		panic(err)
+	}
+}
+
+// WeightOf gives the weight of the item with the given data index, as given
+// to InsertWithWeight. It's zero if the item was inserted without a weight.
+func (t *RTree) WeightOf(dataIndex int) float64 {
+	return t.weights[dataIndex]
+}
+
+// setWeight records the weight of the item with the given data index. The
+// map holding weights is only allocated once an item has a non-zero weight.
+func (t *RTree) setWeight(dataIndex int, weight float64) {
+	if weight == 0 {
+		delete(t.weights, dataIndex)
+		return
+	}
+	if t.weights == nil {
+		t.weights = make(map[int]float64)
+	}
+	t.weights[dataIndex] = weight
+}
+
+// SumWithin gives the sum of the weights of the items that overlap with the
+// given bounding box.
+func (t *RTree) SumWithin(bb BBox) float64 {
+	agg, _ := t.aggregateWithin(bb)
+	return agg.Sum
+}
+
+// MinWithin gives the smallest weight of the items that overlap with the
+// given bounding box. The return value ok is false if there are no such
+// items.
+func (t *RTree) MinWithin(bb BBox) (min float64, ok bool) {
+	agg, ok := t.aggregateWithin(bb)
+	return agg.Min, ok
+}
+
+// MaxWithin gives the largest weight of the items that overlap with the given
+// bounding box. The return value ok is false if there are no such items.
+func (t *RTree) MaxWithin(bb BBox) (max float64, ok bool) {
+	agg, ok := t.aggregateWithin(bb)
+	return agg.Max, ok
+}
+
+// aggregateWithin aggregates the weights of the items that overlap with the
+// bounding box. Subtrees whose bounding boxes are entirely inside the
+// bounding box contribute their precomputed aggregates without being
+// traversed, unless there are tombstoned items (which the precomputed
+// aggregates still include). The return value ok is false if there are no
+// such items.
+func (t *RTree) aggregateWithin(bb BBox) (agg Aggregate, ok bool) {
+	if len(t.Nodes) == 0 {
+		return Aggregate{}, false
+	}
+	add := func(a Aggregate) {
+		if ok {
+			agg = agg.combine(a)
+		} else {
+			agg, ok = a, true
+		}
+	}
+	var recurse func(int)
+	recurse = func(n int) {
+		node := &t.Nodes[n]
+		for _, e := range node.Entries {
+			switch {
+			case !overlap(e.BBox, bb):
+			case node.IsLeaf:
+				if !t.isTombstoned(e.Index) {
+					add(weightAggregate(t.weights[e.Index]))
+				}
+			case contains(bb, e.BBox) && len(t.tombstones) == 0:
+				add(t.Nodes[e.Index].Aggregate)
+			default:
+				recurse(e.Index)
+			}
+		}
+	}
+	recurse(t.RootIndex)
+	return agg, ok
+}
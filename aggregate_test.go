@@ -0,0 +1,105 @@
+package rtree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestAggregates(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	if _, ok := rt.MaxWithin(BBox{0, 0, 1, 1}); ok {
+		t.Fatal("expected no max for empty tree")
+	}
+	boxes := make(map[int]BBox)
+	weights := make(map[int]float64)
+	for i := 0; i < 300; i++ {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		weights[i] = float64(rnd.Intn(1000)) - 500
+		rt.InsertWithWeight(boxes[i], i, weights[i], ins)
+	}
+	checkInvariants(t, rt)
+
+	for i := 0; i < 300; i += 5 {
+		rt.DeleteByIndex(i)
+		delete(boxes, i)
+	}
+	for i := 1; i < 300; i += 5 {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Adjust(i, boxes[i], ins)
+	}
+	checkInvariants(t, rt)
+
+	for i := 0; i < 20; i++ {
+		query := randomBox(rnd, 0.5, 0.5)
+		var sum float64
+		min, max := math.Inf(1), math.Inf(-1)
+		for j, bb := range boxes {
+			if overlap(bb, query) {
+				sum += weights[j]
+				min = math.Min(min, weights[j])
+				max = math.Max(max, weights[j])
+			}
+		}
+		if got := rt.SumWithin(query); got != sum {
+			t.Errorf("sum: got %v want %v", got, sum)
+		}
+		gotMin, ok := rt.MinWithin(query)
+		if ok != !math.IsInf(min, 1) || (ok && gotMin != min) {
+			t.Errorf("min: got %v %t want %v", gotMin, ok, min)
+		}
+		gotMax, ok := rt.MaxWithin(query)
+		if ok != !math.IsInf(max, -1) || (ok && gotMax != max) {
+			t.Errorf("max: got %v %t want %v", gotMax, ok, max)
+		}
+	}
+}
+
+func TestWeightsFollowItems(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	rt.EnableJournal()
+	for i := 0; i < 40; i++ {
+		rt.InsertWithWeight(BBox{float64(i), 0, float64(i) + 1, 1}, i, float64(i), ins)
+	}
+	check := func(when string, idx int, weight, sum float64) {
+		t.Helper()
+		checkInvariants(t, rt)
+		if got := rt.WeightOf(idx); got != weight {
+			t.Errorf("%s: item %d has weight %v, want %v", when, idx, got, weight)
+		}
+		if got := rt.SumWithin(everywhere); got != sum {
+			t.Errorf("%s: got sum %v, want %v", when, got, sum)
+		}
+	}
+	check("inserted", 5, 5, 780)
+
+	// Moving an item far enough to be reinserted keeps its weight.
+	rt.Move(5, BBox{-100, -100, -99, -99}, ins)
+	check("moved", 5, 5, 780)
+
+	// Deleting an item drops it, and undoing the deletion restores it.
+	rt.DeleteByIndex(5)
+	check("deleted", 5, 0, 775)
+	rt.Undo(1, ins)
+	check("undone", 5, 5, 780)
+
+	repacked := rt.Repack(ins)
+	checkInvariants(t, repacked)
+	if got := repacked.SumWithin(everywhere); got != 780 {
+		t.Errorf("got sum %v after repacking, want 780", got)
+	}
+
+	rt.Clear()
+	if rt.weights != nil {
+		t.Errorf("expected weights to be discarded by Clear")
+	}
+}
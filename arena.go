@@ -0,0 +1,22 @@
+package rtree
+
+// arenaChunkNodes is the number of nodes worth of entries allocated at a time
+// for the arena.
+const arenaChunkNodes = 64
+
+// allocEntries gives an empty entries slice with the given capacity. Rather
+// than allocating each slice individually, slices are carved from larger
+// contiguous chunks. This greatly reduces the number of heap objects that
+// the garbage collector has to track for large trees, and improves locality.
+//
+// The capacity of the slice is limited (using a full slice expression) so
+// that appending beyond it never overwrites a neighbouring node's entries.
+func (t *RTree) allocEntries(capacity int) []Entry {
+	if cap(t.arena)-len(t.arena) < capacity {
+		t.arena = make([]Entry, 0, capacity*arenaChunkNodes)
+		t.Metrics.countEntryGrowth(0, cap(t.arena))
+	}
+	used := len(t.arena)
+	t.arena = t.arena[:used+capacity]
+	return t.arena[used : used : used+capacity]
+}
@@ -0,0 +1,193 @@
+package rtree
+
+// Batch accumulates insertions, deletions and updates so that they can be
+// applied to an RTree together. The zero value is an empty batch.
+type Batch struct {
+	inserts []InsertItem
+	deletes []InsertItem
+}
+
+// Insert adds an insertion of a new data item to the batch.
+func (b *Batch) Insert(bb BBox, dataIndex int) {
+	b.inserts = append(b.inserts, InsertItem{BBox: bb, DataIndex: dataIndex})
+}
+
+// Delete adds a deletion of an existing data item to the batch. The bounding
+// box must match the bounding box the item was inserted with (but the item's
+// payload doesn't need to match).
+func (b *Batch) Delete(bb BBox, dataIndex int) {
+	b.deletes = append(b.deletes, InsertItem{BBox: bb, DataIndex: dataIndex})
+}
+
+// InsertItem adds an insertion of a new data item (including its payload) to
+// the batch.
+func (b *Batch) InsertItem(item InsertItem) {
+	b.inserts = append(b.inserts, item)
+}
+
+// Update adds a change of bounding box for an existing data item to the
+// batch. The item's payload is reset to zero.
+func (b *Batch) Update(oldBB, newBB BBox, dataIndex int) {
+	b.Delete(oldBB, dataIndex)
+	b.Insert(newBB, dataIndex)
+}
+
+// Len gives the number of insertions and deletions in the batch. Each update
+// counts as both an insertion and a deletion.
+func (b *Batch) Len() int {
+	return len(b.inserts) + len(b.deletes)
+}
+
+// Apply applies all mutations in a batch to the tree. All deletions are
+// applied before any insertions, so a batch can't delete an item that it
+// also inserts. Deletions of items that aren't in the tree are ignored.
+//
+// Rather than condensing and splitting nodes after each mutation, all
+// deletions are performed in a single pass followed by a single
+// condensation, and overfull nodes resulting from the insertions are only
+// split once all of the insertions have been placed (with each overfull
+// node's entries divided between as few nodes as possible, in the same way
+// as BulkLoadSTR). This gives much higher throughput than applying each
+// mutation individually.
+//
+// Like Insert, Apply panics if the insertion policy is the zero value, or if
+// it rejects the bounding box of an inserted item. In the latter case, the
+// batch may have been partially applied.
+func (t *RTree) Apply(batch Batch, policy InsertionPolicy) {
+	if len(batch.inserts) > 0 {
+		if err := policy.check(); err != nil {
+			panic(err)
+		}
+	}
+	defer t.groupJournal()()
+	if len(batch.deletes) > 0 {
+		// Deletions are matched regardless of the items' payloads.
+		type key struct {
+			bb    BBox
+			index int
+		}
+		pending := make(map[key]int, len(batch.deletes))
+		region := batch.deletes[0].BBox
+		for _, d := range batch.deletes {
+			pending[key{d.BBox, d.DataIndex}]++
+			region = combine(region, d.BBox)
+		}
+		t.deleteEntries(region, func(e Entry) bool {
+			k := key{e.BBox, e.Index}
+			if pending[k] == 0 {
+				return false
+			}
+			pending[k]--
+			return true
+		}, DeletionPolicy{})
+	}
+
+	// Rather than splitting overfull leaves straight away, the nodes on the
+	// paths to them are marked so that a single pass can split them later.
+	dirty := make(map[int]bool)
+	for _, item := range batch.inserts {
+		entry := Entry{BBox: item.BBox, Index: item.DataIndex, Payload: item.Payload}
+		place, err := t.admit(&entry, policy)
+		if err != nil {
+			panic(err)
+		}
+		if !place {
+			continue
+		}
+		path := t.placeEntry(entry, policy)
+		leaf := path[len(path)-1].node
+		if len(t.Nodes[leaf].Entries) == policy.forNode(true).maxChildren+1 {
+			for _, step := range path {
+				dirty[step.node] = true
+			}
+		}
+	}
+	if len(dirty) == 0 {
+		return
+	}
+
+	for {
+		t.splitChildren(t.RootIndex, dirty, policy)
+		root := t.RootIndex
+		if len(t.Nodes[root].Entries) <= policy.forNode(t.Nodes[root].IsLeaf).maxChildren {
+			return
+		}
+		packed := t.packNode(root, policy)
+		t.joinRoots(root, packed[0], policy)
+		for _, nn := range packed[1:] {
+			t.Nodes[t.RootIndex].Entries = append(t.Nodes[t.RootIndex].Entries, Entry{
+				BBox:  t.calculateBound(nn),
+				Index: nn,
+			})
+		}
+		t.summarise(t.RootIndex)
+	}
+}
+
+// splitChildren splits the children of node n until none of them are
+// overfull, after first doing the same for the children of any dirty
+// nodes under n. The entries for nodes split from n's children are added to
+// n, which may leave n overfull.
+func (t *RTree) splitChildren(n int, dirty map[int]bool, policy InsertionPolicy) {
+	if t.Nodes[n].IsLeaf {
+		return
+	}
+	// Entries may be appended during the loop, so the length must be
+	// checked on each iteration.
+	for i := 0; i < len(t.Nodes[n].Entries); i++ {
+		child := t.Nodes[n].Entries[i].Index
+		if dirty[child] {
+			delete(dirty, child)
+			t.splitChildren(child, dirty, policy)
+		}
+		maxChildren := policy.forNode(t.Nodes[child].IsLeaf).maxChildren
+		if len(t.Nodes[child].Entries) <= maxChildren {
+			continue
+		}
+		for _, nn := range t.packNode(child, policy) {
+			t.Nodes[n].Entries = append(t.Nodes[n].Entries, Entry{
+				BBox:  t.calculateBound(nn),
+				Index: nn,
+			})
+		}
+		t.Nodes[n].Entries[i].BBox = t.calculateBound(child)
+	}
+}
+
+// packNode divides the entries of the overfull node n between the fewest
+// nodes that can hold them, by tiling them in the same way as BulkLoadSTR.
+// This takes O(k log k) time for k entries, rather than the O(k^2) time of
+// repeatedly splitting the node. Node n keeps the entries of the first tile,
+// and the indices of the new nodes holding the others are returned. Each new
+// node is reported as having been split from n.
+func (t *RTree) packNode(n int, policy InsertionPolicy) []int {
+	maxChildren := policy.forNode(t.Nodes[n].IsLeaf).maxChildren
+	entries := append([]Entry(nil), t.Nodes[n].Entries...)
+	groups := (len(entries) + maxChildren - 1) / maxChildren
+	var tiles [][]Entry
+	strSort(entries, true)
+	for _, slice := range splitEvenly(entries, strSlices(entries, groups, 0)) {
+		strSort(slice, false)
+		tiles = append(tiles, splitEvenly(slice, (len(slice)+maxChildren-1)/maxChildren)...)
+	}
+
+	t.Nodes[n].Entries = append(t.Nodes[n].Entries[:0], tiles[0]...)
+	t.summarise(n)
+	var packed []int
+	for _, tile := range tiles[1:] {
+		if t.Metrics.Enabled {
+			t.Metrics.Splits++
+		}
+		t.mutations.Splits++
+		nn := t.appendNode(Node{IsLeaf: t.Nodes[n].IsLeaf, Entries: tile}, policy)
+		t.summarise(nn)
+		if t.Tracer != nil {
+			t.Tracer.SplitNode(n, nn, t.Nodes[n].Entries, tile)
+		}
+		if t.Hooks.OnSplit != nil {
+			t.Hooks.OnSplit(n, nn)
+		}
+		packed = append(packed, nn)
+	}
+	return packed
+}
@@ -0,0 +1,96 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestApplyBatch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	for _, maxCapacity := range []int{2, 4, 10, 30} {
+		ins, err := NewInsertionPolicy(maxCapacity/2, maxCapacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var rt RTree
+		var boxes []BBox
+		for round := 0; round < 10; round++ {
+			var batch Batch
+			for i := 0; i < 40; i++ {
+				bb := randomBox(rnd, 0.9, 0.1)
+				batch.Insert(bb, len(boxes))
+				boxes = append(boxes, bb)
+			}
+			var perm []int
+			if round > 0 {
+				perm = rnd.Perm(len(boxes) - 40)[:10]
+			}
+			for _, idx := range perm {
+				if rnd.Intn(2) == 0 {
+					batch.Delete(boxes[idx], idx)
+					boxes[idx] = BBox{-2, -2, -1, -1}
+				} else {
+					bb := randomBox(rnd, 0.9, 0.1)
+					batch.Update(boxes[idx], bb, idx)
+					boxes[idx] = bb
+				}
+			}
+			rt.Apply(batch, ins)
+			checkInvariants(t, rt)
+			for _, n := range rt.Nodes {
+				if len(n.Entries) > maxCapacity {
+					t.Fatalf("node has %d entries, max is %d", len(n.Entries), maxCapacity)
+				}
+			}
+			checkSearch(t, rt, boxes, rnd)
+		}
+	}
+}
+
+func TestApplyLargeBatch(t *testing.T) {
+	// All of the items land in the root leaf, which must be divided without
+	// repeatedly splitting it (which would take quadratic time).
+	rnd := rand.New(rand.NewSource(0))
+	for _, maxCapacity := range []int{4, 9} {
+		ins, err := NewInsertionPolicy(maxCapacity/2, maxCapacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rt RTree
+		var batch Batch
+		boxes := make([]BBox, 20000)
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.01)
+			batch.Insert(boxes[i], i)
+		}
+		rt.Apply(batch, ins)
+		checkInvariants(t, rt)
+		checkSearch(t, rt, boxes, rnd)
+	}
+}
+
+func TestApplyUpdateWithPayload(t *testing.T) {
+	policy := mustPolicy(t, 2, 4)
+	var rt RTree
+	rt.InsertWithPayload(BBox{0, 0, 1, 1}, 7, 42, policy)
+
+	var batch Batch
+	batch.Update(BBox{0, 0, 1, 1}, BBox{2, 2, 3, 3}, 7)
+	rt.Apply(batch, policy)
+	checkInvariants(t, rt)
+
+	var found int
+	rt.SearchWithPayload(everywhere, func(idx int, payload uint64) {
+		found++
+		if idx != 7 || payload != 0 {
+			t.Errorf("got item %d with payload %d", idx, payload)
+		}
+	})
+	if found != 1 {
+		t.Errorf("expected a single item after the update, got %d", found)
+	}
+	if bb, ok := rt.BBoxOf(7); !ok || bb != (BBox{2, 2, 3, 3}) {
+		t.Errorf("got bbox %v for updated item", bb)
+	}
+}
@@ -1,16 +1,79 @@
 package rtree
 
-import "math"
+import (
+	"fmt"
+	"math"
+)
 
 // BBox is an axis-aligned bounding box.
 type BBox struct {
 	MinX, MinY, MaxX, MaxY float64
 }
 
-// calculate bound calculates the smallest bounding box that fits a node.
+// EmptyBBox is a bounding box that contains nothing. It has inverted
+// infinite bounds, so that it is the identity element for Union (i.e.
+// combining it with any other bounding box gives that other bounding box).
+// It has zero area, and doesn't overlap with anything.
+var EmptyBBox = BBox{
+	MinX: math.Inf(+1),
+	MinY: math.Inf(+1),
+	MaxX: math.Inf(-1),
+	MaxY: math.Inf(-1),
+}
+
+// UniverseBBox is a bounding box that covers everything. It has infinite
+// bounds, so it overlaps with every bounding box other than EmptyBBox, and
+// is the absorbing element for Union. It can be used for items that should
+// match every search. Its area is infinite, but (unlike naive calculations
+// with infinite coordinates) never NaN.
+var UniverseBBox = BBox{
+	MinX: math.Inf(-1),
+	MinY: math.Inf(-1),
+	MaxX: math.Inf(+1),
+	MaxY: math.Inf(+1),
+}
+
+// IsEmpty checks if the bounding box is EmptyBBox.
+func (b BBox) IsEmpty() bool {
+	return b == EmptyBBox
+}
+
+// NewBBox creates a bounding box with (x1, y1) and (x2, y2) as opposite
+// corners. The corners may be given in any order, i.e. the coordinates are
+// swapped if required so that the minimums are less than the maximums.
+func NewBBox(x1, y1, x2, y2 float64) BBox {
+	return BBox{
+		MinX: math.Min(x1, x2),
+		MinY: math.Min(y1, y2),
+		MaxX: math.Max(x1, x2),
+		MaxY: math.Max(y1, y2),
+	}
+}
+
+// Validate checks that the bounding box is well formed, i.e. that it doesn't
+// contain any NaNs, and that its minimums are not greater than its maximums.
+// EmptyBBox is considered to be valid.
+func (b BBox) Validate() error {
+	if b.IsEmpty() {
+		return nil
+	}
+	if math.IsNaN(b.MinX) || math.IsNaN(b.MinY) || math.IsNaN(b.MaxX) || math.IsNaN(b.MaxY) {
+		return fmt.Errorf("%w: contains NaN", ErrInvalidBBox)
+	}
+	if b.MinX > b.MaxX {
+		return fmt.Errorf("%w: MinX is greater than MaxX", ErrInvalidBBox)
+	}
+	if b.MinY > b.MaxY {
+		return fmt.Errorf("%w: MinY is greater than MaxY", ErrInvalidBBox)
+	}
+	return nil
+}
+
+// calculate bound calculates the smallest bounding box that fits a node. If
+// the node has no entries, then EmptyBBox is returned.
 func (t *RTree) calculateBound(n int) BBox {
-	bb := t.Nodes[n].Entries[0].BBox
-	for _, entry := range t.Nodes[n].Entries[1:] {
+	bb := EmptyBBox
+	for _, entry := range t.Nodes[n].Entries {
 		bb = combine(bb, entry.BBox)
 	}
 	return bb
@@ -29,15 +92,119 @@ func combine(bbox1, bbox2 BBox) BBox {
 // enlargment returns how much additional area the existing BBox would have to
 // enlarge by to accomodate the additional BBox.
 func enlargement(existing, additional BBox) float64 {
-	return area(combine(existing, additional)) - area(existing)
+	return areaSub(area(combine(existing, additional)), area(existing))
 }
 
+// area gives the area of the bounding box. Bounding boxes with infinite
+// extents have infinite area, unless they have zero width or height (in
+// which case the area is zero rather than NaN).
 func area(bb BBox) float64 {
-	return (bb.MaxX - bb.MinX) * (bb.MaxY - bb.MinY)
+	if bb.IsEmpty() {
+		return 0
+	}
+	w, h := extentWidth(bb.MinX, bb.MaxX), extentWidth(bb.MinY, bb.MaxY)
+	if w == 0 || h == 0 {
+		return 0
+	}
+	return w * h
+}
+
+// extentWidth gives the width of the extent [lo, hi]. It's zero if lo and hi
+// are the same, even if they're infinite.
+func extentWidth(lo, hi float64) float64 {
+	if lo == hi {
+		return 0
+	}
+	return hi - lo
+}
+
+// midpoint gives the centre of the extent [lo, hi]. Unlike (lo+hi)/2, it's
+// never NaN: an extent that's infinite in both directions (such as those of
+// UniverseBBox) has its centre at zero. Halving each end first also avoids
+// overflow for extents near the limits of float64.
+func midpoint(lo, hi float64) float64 {
+	if m := lo/2 + hi/2; m == m {
+		return m
+	}
+	return 0
+}
+
+// areaSub subtracts area b from area a. Equal areas give zero, even if
+// they're both infinite (rather than NaN, as Inf-Inf would).
+func areaSub(a, b float64) float64 {
+	if a == b {
+		return 0
+	}
+	return a - b
 }
 
 func overlap(bbox1, bbox2 BBox) bool {
+	if bbox1.IsEmpty() || bbox2.IsEmpty() {
+		return false
+	}
 	return true &&
 		(bbox1.MinX <= bbox2.MaxX) && (bbox1.MaxX >= bbox2.MinX) &&
 		(bbox1.MinY <= bbox2.MaxY) && (bbox1.MaxY >= bbox2.MinY)
 }
+
+// contains checks if the outer bounding box fully contains the inner bounding
+// box.
+func contains(outer, inner BBox) bool {
+	return true &&
+		(outer.MinX <= inner.MinX) && (outer.MaxX >= inner.MaxX) &&
+		(outer.MinY <= inner.MinY) && (outer.MaxY >= inner.MaxY)
+}
+
+// Union gives the smallest bounding box containing both b and o.
+func (b BBox) Union(o BBox) BBox {
+	return combine(b, o)
+}
+
+// Intersect gives the bounding box covering the region common to both b and
+// o. If b and o don't overlap, then false is returned.
+func (b BBox) Intersect(o BBox) (BBox, bool) {
+	if !overlap(b, o) {
+		return EmptyBBox, false
+	}
+	return BBox{
+		MinX: math.Max(b.MinX, o.MinX),
+		MinY: math.Max(b.MinY, o.MinY),
+		MaxX: math.Min(b.MaxX, o.MaxX),
+		MaxY: math.Min(b.MaxY, o.MaxY),
+	}, true
+}
+
+// ExpandBy gives a bounding box that is larger than b by d in each
+// direction. A negative d shrinks the bounding box.
+func (b BBox) ExpandBy(d float64) BBox {
+	return BBox{
+		MinX: b.MinX - d,
+		MinY: b.MinY - d,
+		MaxX: b.MaxX + d,
+		MaxY: b.MaxY + d,
+	}
+}
+
+// Center gives the center point of the bounding box. Extents that are
+// infinite in both directions (such as those of UniverseBBox) are centred at
+// zero.
+func (b BBox) Center() (x, y float64) {
+	return midpoint(b.MinX, b.MaxX), midpoint(b.MinY, b.MaxY)
+}
+
+// Contains checks if the point (x, y) is inside the bounding box (including
+// its boundary).
+func (b BBox) Contains(x, y float64) bool {
+	return b.MinX <= x && x <= b.MaxX && b.MinY <= y && y <= b.MaxY
+}
+
+// ContainsBox checks if the bounding box o is entirely inside b.
+func (b BBox) ContainsBox(o BBox) bool {
+	return contains(b, o)
+}
+
+// Overlaps checks if b and o have at least one point in common (including
+// their boundaries).
+func (b BBox) Overlaps(o BBox) bool {
+	return overlap(b, o)
+}
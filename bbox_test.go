@@ -0,0 +1,461 @@
+package rtree
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestNewBBox(t *testing.T) {
+	want := BBox{1, 2, 3, 4}
+	for _, got := range []BBox{
+		NewBBox(1, 2, 3, 4),
+		NewBBox(3, 4, 1, 2),
+		NewBBox(1, 4, 3, 2),
+		NewBBox(3, 2, 1, 4),
+	} {
+		if got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+		if err := got.Validate(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestBBoxValidate(t *testing.T) {
+	nan := math.NaN()
+	for _, bb := range []BBox{
+		{1, 0, 0, 1},
+		{0, 1, 1, 0},
+		{nan, 0, 1, 1},
+		{0, nan, 1, 1},
+		{0, 0, nan, 1},
+		{0, 0, 1, nan},
+	} {
+		if err := bb.Validate(); err == nil {
+			t.Errorf("expected error for %v", bb)
+		}
+	}
+	for _, bb := range []BBox{
+		{0, 0, 0, 0},
+		{0, 0, 1, 1},
+		{math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(1)},
+	} {
+		if err := bb.Validate(); err != nil {
+			t.Errorf("unexpected error for %v: %v", bb, err)
+		}
+	}
+}
+
+func TestBBoxMethods(t *testing.T) {
+	a := BBox{0, 0, 2, 2}
+	b := BBox{1, 1, 3, 4}
+	c := BBox{5, 5, 6, 6}
+
+	if got := a.Union(b); got != (BBox{0, 0, 3, 4}) {
+		t.Errorf("union: got %v", got)
+	}
+	if got, ok := a.Intersect(b); !ok || got != (BBox{1, 1, 2, 2}) {
+		t.Errorf("intersect: got %v %v", got, ok)
+	}
+	if _, ok := a.Intersect(c); ok {
+		t.Errorf("intersect: expected no intersection")
+	}
+	if got := a.ExpandBy(1); got != (BBox{-1, -1, 3, 3}) {
+		t.Errorf("expand: got %v", got)
+	}
+	if x, y := b.Center(); x != 2 || y != 2.5 {
+		t.Errorf("center: got %v %v", x, y)
+	}
+	if !a.Contains(2, 0) || a.Contains(2.1, 0) {
+		t.Errorf("contains: unexpected result")
+	}
+	if !a.ContainsBox(BBox{0, 1, 1, 2}) || a.ContainsBox(b) {
+		t.Errorf("contains box: unexpected result")
+	}
+	if !a.Overlaps(b) || a.Overlaps(c) || !a.Overlaps(BBox{2, 2, 3, 3}) {
+		t.Errorf("overlaps: unexpected result")
+	}
+}
+
+func TestNonFiniteHandling(t *testing.T) {
+	base, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nan, inf := math.NaN(), math.Inf(1)
+	bad := []BBox{
+		{nan, 0, 1, 1},
+		{0, 0, inf, 1},
+		{-inf, -inf, inf, inf},
+	}
+	insertAll := func(rt *RTree, policy InsertionPolicy) []error {
+		var errs []error
+		for i := 0; i < 20; i++ {
+			rt.Insert(BBox{float64(i), 0, float64(i) + 1, 1}, i, policy)
+		}
+		for i, bb := range bad {
+			errs = append(errs, rt.TryInsert(bb, 100+i, policy))
+		}
+		return errs
+	}
+	count := func(rt *RTree, bb BBox) int {
+		var n int
+		rt.Search(bb, func(int) { n++ })
+		return n
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		var rt RTree
+		for _, err := range insertAll(&rt, base.WithNonFiniteHandling(NonFiniteReject)) {
+			if err == nil {
+				t.Errorf("expected error")
+			}
+		}
+		checkInvariants(t, rt)
+		if got := count(&rt, BBox{0, 0, 30, 1}); got != 20 {
+			t.Errorf("found %d items", got)
+		}
+	})
+
+	t.Run("clamp", func(t *testing.T) {
+		var rt RTree
+		for _, err := range insertAll(&rt, base.WithNonFiniteHandling(NonFiniteClamp)) {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+		checkInvariants(t, rt)
+		if got := count(&rt, BBox{5.5, 0.5, 5.5, 0.5}); got != 3 {
+			t.Errorf("found %d items", got)
+		}
+	})
+
+	t.Run("quarantine", func(t *testing.T) {
+		var rt RTree
+		for _, err := range insertAll(&rt, base.WithNonFiniteHandling(NonFiniteQuarantine)) {
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+		checkInvariants(t, rt)
+		if got := count(&rt, BBox{0, 0, 30, 1}); got != 20 {
+			t.Errorf("found %d items", got)
+		}
+		if got := len(rt.Quarantined()); got != len(bad) {
+			t.Errorf("quarantined %d items", got)
+		}
+	})
+}
+
+func TestGridSnapping(t *testing.T) {
+	base, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cell := range []float64{-1, math.NaN(), math.Inf(1)} {
+		if _, err := base.WithGridSnapping(cell); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("cell %v: expected ErrInvalidPolicy, got %v", cell, err)
+		}
+	}
+	policy, err := base.WithGridSnapping(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy = policy.WithNonFiniteHandling(NonFiniteClamp)
+
+	var rt RTree
+	for i := 0; i < 20; i++ {
+		x := float64(i) / 2
+		rt.Insert(BBox{x + 1e-9, 0.26, x + 0.5 - 1e-9, 1.74}, i, policy)
+	}
+	rt.Insert(BBox{math.Inf(-1), 0, 1.1, math.NaN()}, 20, policy)
+	checkInvariants(t, rt)
+	for idx, got := range rt.All() {
+		want := BBox{float64(idx) / 2, 0, float64(idx)/2 + 0.5, 2}
+		if idx == 20 {
+			want = BBox{-math.MaxFloat64, 0, 1.5, math.MaxFloat64}
+		}
+		if got != want {
+			t.Errorf("item %d: got %v want %v", idx, got, want)
+		}
+	}
+
+	// A cell size of zero turns snapping off again.
+	policy, err = policy.WithGridSnapping(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb := BBox{0.26, 0.26, 0.74, 0.74}
+	rt.Insert(bb, 21, policy)
+	for idx, got := range rt.All() {
+		if idx == 21 && got != bb {
+			t.Errorf("got %v want %v", got, bb)
+		}
+	}
+}
+
+func TestGridSnappingContainsOriginal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	for _, cell := range []float64{1, 0.1, 0.03, 7} {
+		policy, err := mustPolicy(t, 2, 4).WithGridSnapping(cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rt RTree
+		var boxes []BBox
+		for i := 0; i < 200; i++ {
+			x, y := (rnd.Float64()-0.5)*20, (rnd.Float64()-0.5)*20
+			bb := BBox{x, y, x + rnd.Float64()*2, y + rnd.Float64()*2}
+			boxes = append(boxes, bb)
+			rt.Insert(bb, i, policy)
+		}
+		rt.Insert(BBox{0.6, 0.6, 1.4, 1.4}, len(boxes), policy)
+		boxes = append(boxes, BBox{0.6, 0.6, 1.4, 1.4})
+		checkInvariants(t, rt)
+
+		for idx, got := range rt.All() {
+			if !contains(got, boxes[idx]) {
+				t.Fatalf("cell %v: item %d snapped to %v, which doesn't contain %v", cell, idx, got, boxes[idx])
+			}
+		}
+
+		// Any query overlapping the original box must find the item.
+		for i, bb := range boxes {
+			queries := []BBox{
+				bb,
+				{bb.MinX, bb.MinY, bb.MinX, bb.MinY},
+				{bb.MaxX, bb.MaxY, bb.MaxX, bb.MaxY},
+				{(bb.MinX + bb.MaxX) / 2, (bb.MinY + bb.MaxY) / 2, bb.MaxX, bb.MaxY},
+			}
+			for _, q := range queries {
+				var found bool
+				rt.Search(q, func(idx int) { found = found || idx == i })
+				if !found {
+					t.Fatalf("cell %v: query %v overlaps item %d (%v) but didn't find it", cell, q, i, bb)
+				}
+			}
+		}
+	}
+}
+
+func TestTryInsertValidation(t *testing.T) {
+	policy, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	for i := 0; i < 20; i++ {
+		rt.Insert(BBox{float64(i), 0, float64(i) + 1, 1}, i, policy)
+	}
+	nan := math.NaN()
+	for _, tc := range []struct {
+		bb     BBox
+		policy InsertionPolicy
+		want   error
+	}{
+		{BBox{0, 0, 1, 1}, InsertionPolicy{}, ErrInvalidPolicy},
+		{BBox{1, 0, 0, 1}, policy, ErrInvalidBBox},
+		{BBox{0, 1, 1, 0}, policy, ErrInvalidBBox},
+		{BBox{nan, 0, 1, 1}, policy, ErrInvalidBBox},
+		{BBox{nan, 0, 1, 1}, policy.WithNonFiniteHandling(NonFiniteQuarantine), nil},
+		{BBox{0, 0, math.Inf(1), 1}, policy, nil},
+		{BBox{2, 2, 3, 3}, policy, nil},
+	} {
+		gen := rt.Generation()
+		err := rt.TryInsert(tc.bb, 100, tc.policy)
+		if !errors.Is(err, tc.want) || (err == nil) != (tc.want == nil) {
+			t.Errorf("%v: got %v, want %v", tc.bb, err, tc.want)
+		}
+		if err != nil && rt.Generation() != gen {
+			t.Errorf("%v: tree modified despite error", tc.bb)
+		}
+	}
+	checkInvariants(t, rt)
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("expected panic with ErrInvalidPolicy, got %v", err)
+		}
+	}()
+	rt.Insert(BBox{0, 0, 1, 1}, 0, InsertionPolicy{})
+}
+
+func TestEmptyBBox(t *testing.T) {
+	bb := BBox{1, 2, 3, 4}
+	if got := EmptyBBox.Union(bb); got != bb {
+		t.Errorf("union: got %v", got)
+	}
+	if got := bb.Union(EmptyBBox); got != bb {
+		t.Errorf("union: got %v", got)
+	}
+	if got := area(EmptyBBox); got != 0 {
+		t.Errorf("area: got %v", got)
+	}
+	universe := BBox{math.Inf(-1), math.Inf(-1), math.Inf(1), math.Inf(1)}
+	for _, o := range []BBox{bb, universe, EmptyBBox} {
+		if EmptyBBox.Overlaps(o) || o.Overlaps(EmptyBBox) {
+			t.Errorf("expected empty box not to overlap %v", o)
+		}
+	}
+	if err := EmptyBBox.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !EmptyBBox.IsEmpty() || bb.IsEmpty() {
+		t.Errorf("unexpected IsEmpty result")
+	}
+
+	var rt RTree
+	rt.Nodes = []Node{{IsLeaf: true}}
+	if got := rt.calculateBound(0); !got.IsEmpty() {
+		t.Errorf("expected empty node to have empty bound, got %v", got)
+	}
+}
+
+func TestUniverseBBox(t *testing.T) {
+	inf := math.Inf(1)
+	finite := BBox{1, 2, 3, 4}
+	for _, tc := range []struct {
+		bb   BBox
+		want float64
+	}{
+		{UniverseBBox, inf},
+		{BBox{-inf, 0, inf, 0}, 0},
+		{BBox{inf, 0, inf, 1}, 0},
+		{BBox{0, 0, inf, 1}, inf},
+		{finite, 4},
+	} {
+		if got := area(tc.bb); got != tc.want {
+			t.Errorf("area(%v): got %v want %v", tc.bb, got, tc.want)
+		}
+	}
+	if got := enlargement(UniverseBBox, finite); got != 0 {
+		t.Errorf("enlargement of universe: got %v want 0", got)
+	}
+	if got := enlargement(finite, UniverseBBox); got != inf {
+		t.Errorf("enlargement to universe: got %v want +Inf", got)
+	}
+	if got := overlapFraction(UniverseBBox, finite); got != 1 {
+		t.Errorf("overlap fraction with finite query: got %v want 1", got)
+	}
+	if got := overlapFraction(UniverseBBox, UniverseBBox); got != 1 {
+		t.Errorf("overlap fraction with universe: got %v want 1", got)
+	}
+	if !UniverseBBox.Overlaps(finite) || UniverseBBox.Overlaps(EmptyBBox) {
+		t.Error("universe should overlap everything other than the empty box")
+	}
+	if got, ok := UniverseBBox.Intersect(finite); !ok || got != finite {
+		t.Errorf("intersection: got %v, %t", got, ok)
+	}
+	if got := finite.Union(UniverseBBox); got != UniverseBBox {
+		t.Errorf("union: got %v", got)
+	}
+	if err := UniverseBBox.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Items covering everywhere can be stored alongside ordinary items, and
+	// are found by every search.
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	policy := mustPolicy(t, 2, 4)
+	for i := 0; i < 100; i++ {
+		if i%10 == 0 {
+			rt.Insert(UniverseBBox, i, policy)
+		} else {
+			rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+		}
+	}
+	checkInvariants(t, rt)
+	for i := 0; i < 20; i++ {
+		var universal int
+		rt.Search(randomBox(rnd, 10, 1), func(idx int) {
+			if idx%10 == 0 {
+				universal++
+			}
+		})
+		if universal != 10 {
+			t.Fatalf("found %d universal items, want 10", universal)
+		}
+	}
+	for i := range rt.Nodes {
+		for _, e := range rt.Nodes[i].Entries {
+			if math.IsNaN(area(e.BBox)) {
+				t.Fatalf("node %d has entry with NaN area: %v", i, e.BBox)
+			}
+		}
+	}
+}
+
+func TestUniverseBBoxCentres(t *testing.T) {
+	for _, tc := range []struct {
+		bb   BBox
+		x, y float64
+	}{
+		{UniverseBBox, 0, 0},
+		{BBox{math.Inf(-1), 1, 3, math.Inf(1)}, math.Inf(-1), math.Inf(1)},
+		{BBox{-math.MaxFloat64, 2, math.MaxFloat64, math.MaxFloat64}, 0, math.MaxFloat64/2 + 1},
+		{BBox{1, 2, 3, 6}, 2, 4},
+	} {
+		if x, y := tc.bb.Center(); x != tc.x || y != tc.y {
+			t.Errorf("centre of %v: got (%v, %v), want (%v, %v)", tc.bb, x, y, tc.x, tc.y)
+		}
+	}
+}
+
+func TestUniverseBBoxBulkLoad(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	var items []InsertItem
+	for i := 0; i < 200; i++ {
+		bb := randomBox(rnd, 0.9, 0.1)
+		if i%20 == 0 {
+			bb = UniverseBBox
+		}
+		items = append(items, InsertItem{BBox: bb, DataIndex: i})
+	}
+	policy := mustPolicy(t, 2, 4)
+	for name, load := range map[string]func([]InsertItem) RTree{
+		"BulkLoadWithPolicy": func(items []InsertItem) RTree { return BulkLoadWithPolicy(items, policy) },
+		"BulkLoadTGS":        func(items []InsertItem) RTree { return BulkLoadTGS(items, policy) },
+		"BulkLoadKD":         func(items []InsertItem) RTree { return BulkLoadKD(items, policy) },
+		"BulkLoadMorton":     func(items []InsertItem) RTree { return BulkLoadMorton(items, policy) },
+		"BulkLoadSTR":        func(items []InsertItem) RTree { return BulkLoadSTR(items, policy, STROptions{}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			// NaN keys would make the order of the items (and so the tree)
+			// depend on the order that they're given in.
+			rt := load(append([]InsertItem(nil), items...))
+			shuffled := append([]InsertItem(nil), items...)
+			rnd.Shuffle(len(shuffled), func(i, j int) {
+				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+			})
+			if !reflect.DeepEqual(rt.Nodes, load(shuffled).Nodes) {
+				t.Error("tree depends on the order of the items")
+			}
+
+			checkInvariants(t, rt)
+			var universal int
+			rt.Search(BBox{0.5, 0.5, 0.5, 0.5}, func(idx int) {
+				if idx%20 == 0 {
+					universal++
+				}
+			})
+			if universal != 10 {
+				t.Errorf("found %d universal items, want 10", universal)
+			}
+
+			q := rt.Quality()
+			if math.IsNaN(q.Score) || q.Score < 0 || q.Score > 1 {
+				t.Errorf("invalid score: %v", q.Score)
+			}
+			for i, lq := range q.Levels {
+				if math.IsNaN(lq.Coverage) || math.IsNaN(lq.DeadSpace) {
+					t.Errorf("level %d has NaN quality: %+v", i, lq)
+				}
+			}
+		})
+	}
+}
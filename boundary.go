@@ -0,0 +1,44 @@
+package rtree
+
+// Boundary controls whether bounding boxes that only touch each other (at
+// their edges or corners) count as overlapping when searching.
+type Boundary int
+
+const (
+	// BoundaryClosed treats bounding boxes as including their boundaries, so
+	// boxes that only touch each other overlap. This is the behaviour of
+	// Search.
+	BoundaryClosed Boundary = iota
+
+	// BoundaryOpen only counts bounding boxes as overlapping if their
+	// interiors intersect, so boxes that only touch each other don't
+	// overlap. This suits datasets where neighbouring items share edges
+	// (e.g. cadastral parcels), where searching with an item's bounding box
+	// would otherwise find all of its neighbours. Along an axis where a
+	// bounding box is degenerate (has zero width), it only counts as
+	// overlapping if it lies strictly inside the other box's extent along
+	// that axis. So a point is only found if it's strictly inside the query,
+	// but a line crossing the query is found even though its ends are
+	// outside. The same applies to degenerate queries.
+	BoundaryOpen
+)
+
+// SearchWithBoundary is like Search, but uses the given boundary semantics to
+// decide if items overlap with the bounding box.
+func (t *RTree) SearchWithBoundary(bb BBox, boundary Boundary, callback func(index int)) {
+	t.search(bb, 0, boundary, func(e Entry) { callback(e.Index) })
+}
+
+// overlaps checks if the bounding boxes overlap, according to the boundary
+// semantics. Nodes can be pruned using the same test as items, since a
+// node's bounding box contains the bounding boxes of its entries.
+func (b Boundary) overlaps(bbox1, bbox2 BBox) bool {
+	if b != BoundaryOpen {
+		return overlap(bbox1, bbox2)
+	}
+	if bbox1.IsEmpty() || bbox2.IsEmpty() {
+		return false
+	}
+	return bbox1.MinX < bbox2.MaxX && bbox1.MaxX > bbox2.MinX &&
+		bbox1.MinY < bbox2.MaxY && bbox1.MaxY > bbox2.MinY
+}
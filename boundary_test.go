@@ -0,0 +1,77 @@
+package rtree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestSearchWithBoundary(t *testing.T) {
+	policy := mustPolicy(t, 2, 5)
+	search := func(rt *RTree, bb BBox, boundary Boundary) []int {
+		var got []int
+		rt.SearchWithBoundary(bb, boundary, func(idx int) { got = append(got, idx) })
+		sort.Ints(got)
+		return got
+	}
+
+	t.Run("parcels", func(t *testing.T) {
+		// A 10x10 grid of unit parcels sharing edges with their neighbours.
+		var rt RTree
+		for i := 0; i < 100; i++ {
+			x, y := float64(i%10), float64(i/10)
+			rt.Insert(BBox{x, y, x + 1, y + 1}, i, policy)
+		}
+		parcel := BBox{4, 4, 5, 5}
+		if got := search(&rt, parcel, BoundaryClosed); len(got) != 9 {
+			t.Errorf("closed: got %v, want the parcel and its 8 neighbours", got)
+		}
+		if got := search(&rt, parcel, BoundaryOpen); len(got) != 1 || got[0] != 44 {
+			t.Errorf("open: got %v, want only parcel 44", got)
+		}
+		if got := search(&rt, BBox{4.5, 4.5, 4.5, 4.5}, BoundaryOpen); len(got) != 1 || got[0] != 44 {
+			t.Errorf("open point query: got %v, want only parcel 44", got)
+		}
+		if got := search(&rt, BBox{5, 4.5, 5, 4.5}, BoundaryOpen); len(got) != 0 {
+			t.Errorf("open point query on edge: got %v, want none", got)
+		}
+		if got := search(&rt, BBox{4.5, -1, 4.5, 1.5}, BoundaryOpen); len(got) != 2 || got[0] != 4 || got[1] != 14 {
+			t.Errorf("open line query: got %v, want parcels 4 and 14", got)
+		}
+		if got := search(&rt, BBox{5, -1, 5, 1.5}, BoundaryOpen); len(got) != 0 {
+			t.Errorf("open line query on edge: got %v, want none", got)
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		rnd := rand.New(rand.NewSource(0))
+		var rt RTree
+		var boxes []BBox
+		for i := 0; i < 300; i++ {
+			bb := randomBox(rnd, 0.9, 0.1)
+			boxes = append(boxes, bb)
+			rt.Insert(bb, i, policy)
+		}
+		checkInvariants(t, rt)
+		for i := 0; i < 50; i++ {
+			query := randomBox(rnd, 0.9, 0.2)
+			for _, boundary := range []Boundary{BoundaryClosed, BoundaryOpen} {
+				var want []int
+				for idx, bb := range boxes {
+					if boundary.overlaps(bb, query) {
+						want = append(want, idx)
+					}
+				}
+				got := search(&rt, query, boundary)
+				if len(got) != len(want) {
+					t.Fatalf("boundary %d query %v: got %v want %v", boundary, query, got, want)
+				}
+				for j := range got {
+					if got[j] != want[j] {
+						t.Fatalf("boundary %d query %v: got %v want %v", boundary, query, got, want)
+					}
+				}
+			}
+		}
+	})
+}
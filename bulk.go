@@ -6,11 +6,16 @@ import "sort"
 type InsertItem struct {
 	BBox      BBox
 	DataIndex int
+	Payload   uint64
 }
 
 // BulkLoad bulk loads multiple items into a new R-Tree. The bulk load
 // operation is optimised for creating R-Trees with minimal node overlap. This
-// allows for fast searching.
+// allows for fast searching. For datasets too large to sort in memory, see
+// BulkLoadExternal.
+//
+// The resulting tree is deterministic: the same set of items always produces
+// an identical tree, regardless of the order in which they're given.
 func BulkLoad(inserts []InsertItem) RTree {
 	var tr RTree
 	// Find any existing entries, and add them to the new list.
@@ -22,55 +27,274 @@ func BulkLoad(inserts []InsertItem) RTree {
 		}
 		for _, entry := range node.Entries {
 			items = append(items, InsertItem{
-				entry.BBox, entry.Index,
+				entry.BBox, entry.Index, entry.Payload,
 			})
 		}
 	}
 
-	n := tr.bulkInsert(items)
-	tr.RootIndex = n
+	tr.RootIndex = tr.bulkKD(items, 2, 2)
+	tr.calculateAggregates(tr.RootIndex)
 	return tr
 }
 
-func (t *RTree) bulkInsert(items []InsertItem) int {
-	if len(items) <= 2 {
-		node := Node{IsLeaf: true, Parent: -1}
+// BulkLoadWithPolicy is like BulkLoad, but packs up to the policy's maximum
+// number of children into each node (rather than building a binary tree).
+// This gives much shallower trees that use less memory. Nodes other than the
+// root are filled to at least half of the maximum, so the tree can continue
+// to be modified using the same policy. Like BulkLoad, the resulting tree is
+// deterministic. It panics if the policy is the zero value.
+func BulkLoadWithPolicy(inserts []InsertItem, policy InsertionPolicy) RTree {
+	return bulkLoadPacked(inserts, policy, bulkPartition)
+}
+
+// bulkLoadPacked builds a tree of the smallest height that can hold the
+// items, with nodes packed by bulkPack using the given partition function.
+// It panics if the policy is the zero value.
+func bulkLoadPacked(inserts []InsertItem, policy InsertionPolicy, partition func([]InsertItem, int) [][]InsertItem) RTree {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	var tr RTree
+	if len(inserts) == 0 {
+		return tr
+	}
+	items := make([]InsertItem, len(inserts))
+	copy(items, inserts)
+
+	// Find the height of the smallest tree that can hold all of the items.
+	leafMax := policy.forNode(true).maxChildren
+	height, capacity := 1, leafMax
+	for capacity < len(items) {
+		height++
+		capacity *= policy.maxChildren
+	}
+	if height == 1 {
+		// The items aren't partitioned, so they're sorted to make the order
+		// of the root's entries deterministic.
+		sort.Slice(items, func(i, j int) bool {
+			return bulkLess(items[i], items[j], true)
+		})
+	}
+	tr.RootIndex = tr.bulkPack(items, height, leafMax, policy.maxChildren, partition)
+	tr.calculateAggregates(tr.RootIndex)
+	return tr
+}
+
+// bulkPack builds a subtree of the given height containing the items, and
+// gives the index of its root node. Leaves hold up to leafMax entries, and
+// other nodes hold up to maxChildren entries. The partition function divides
+// the items into the given number of groups of (almost) equal size, one for
+// each child.
+func (t *RTree) bulkPack(items []InsertItem, height, leafMax, maxChildren int, partition func([]InsertItem, int) [][]InsertItem) int {
+	if height == 1 {
+		node := Node{IsLeaf: true}
 		for _, item := range items {
 			node.Entries = append(node.Entries, Entry{
-				BBox:  item.BBox,
-				Index: item.DataIndex,
+				BBox:    item.BBox,
+				Index:   item.DataIndex,
+				Payload: item.Payload,
 			})
 		}
 		t.Nodes = append(t.Nodes, node)
 		return len(t.Nodes) - 1
 	}
 
+	// Each child subtree holds at most subtreeCap items. Using the fewest
+	// possible children means that each child is more than half full.
+	subtreeCap := leafMax
+	for i := 2; i < height; i++ {
+		subtreeCap *= maxChildren
+	}
+	groups := (len(items) + subtreeCap - 1) / subtreeCap
+
+	node := Node{IsLeaf: false}
+	for _, group := range partition(items, groups) {
+		child := t.bulkPack(group, height-1, leafMax, maxChildren, partition)
+		node.Entries = append(node.Entries, Entry{BBox: t.calculateBound(child), Index: child})
+	}
+	t.Nodes = append(t.Nodes, node)
+	return len(t.Nodes) - 1
+}
+
+// bulkPartition splits the items into the given number of groups of (almost)
+// equal size, by repeatedly halving them along their longest axis.
+func bulkPartition(items []InsertItem, groups int) [][]InsertItem {
+	if groups == 1 {
+		return [][]InsertItem{items}
+	}
 	bbox := items[0].BBox
 	for _, item := range items[1:] {
 		bbox = combine(bbox, item.BBox)
 	}
-
 	horizontal := bbox.MaxX-bbox.MinX > bbox.MaxY-bbox.MinY
 	sort.Slice(items, func(i, j int) bool {
-		bi := items[i].BBox
-		bj := items[j].BBox
-		if horizontal {
-			return bi.MinX+bi.MaxX < bj.MinX+bj.MaxX
-		} else {
-			return bi.MinY+bi.MaxY < bj.MinY+bj.MaxY
-		}
+		return bulkLess(items[i], items[j], horizontal)
 	})
+	left := groups / 2
+	split := len(items) * left / groups
+	return append(
+		bulkPartition(items[:split], left),
+		bulkPartition(items[split:], groups-left)...,
+	)
+}
 
-	split := len(items) / 2
-	n1 := t.bulkInsert(items[:split])
-	n2 := t.bulkInsert(items[split:])
+// BulkLoadKD bulk loads multiple items into a new R-Tree, recursively
+// dividing the items into groups by splitting on the median of the longer
+// axis, with up to the policy's maximum number of children per node. Items
+// are divided until each group fits into a single leaf. BulkLoad is the
+// special case where nodes have 2 children.
+//
+// Unlike BulkLoadWithPolicy, leaves aren't guaranteed to all be at the same
+// depth, and nodes may be less than half full. In return, each node divides
+// its items more evenly, giving tighter bounding boxes. Like BulkLoad, the
+// resulting tree is deterministic. It panics if the policy is the zero
+// value.
+func BulkLoadKD(inserts []InsertItem, policy InsertionPolicy) RTree {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	var tr RTree
+	items := make([]InsertItem, len(inserts))
+	copy(items, inserts)
+	tr.RootIndex = tr.bulkKD(items, policy.forNode(true).maxChildren, policy.maxChildren)
+	tr.calculateAggregates(tr.RootIndex)
+	return tr
+}
 
-	parent := Node{IsLeaf: false, Parent: -1, Entries: []Entry{
-		Entry{BBox: t.calculateBound(n1), Index: n1},
-		Entry{BBox: t.calculateBound(n2), Index: n2},
-	}}
+// bulkKD builds a subtree containing the items, with up to leafMax entries in
+// each leaf and up to fanOut entries in each other node, and gives the index
+// of its root node.
+func (t *RTree) bulkKD(items []InsertItem, leafMax, fanOut int) int {
+	if len(items) <= leafMax {
+		node := Node{IsLeaf: true}
+		for _, item := range items {
+			node.Entries = append(node.Entries, Entry{
+				BBox:    item.BBox,
+				Index:   item.DataIndex,
+				Payload: item.Payload,
+			})
+		}
+		t.Nodes = append(t.Nodes, node)
+		return len(t.Nodes) - 1
+	}
+
+	// Use only as many groups as needed for them to fit in leaves, so that
+	// nodes near the bottom of the tree aren't left almost empty.
+	groups := (len(items) + leafMax - 1) / leafMax
+	if groups > fanOut {
+		groups = fanOut
+	}
+	parent := Node{IsLeaf: false}
+	for _, group := range bulkPartition(items, groups) {
+		child := t.bulkKD(group, leafMax, fanOut)
+		parent.Entries = append(parent.Entries, Entry{BBox: t.calculateBound(child), Index: child})
+	}
 	t.Nodes = append(t.Nodes, parent)
-	t.Nodes[n1].Parent = len(t.Nodes) - 1
-	t.Nodes[n2].Parent = len(t.Nodes) - 1
 	return len(t.Nodes) - 1
 }
+
+// BulkLoadSorted bulk loads items that are already in a spatially coherent
+// order (e.g. sorted along a Hilbert or Morton curve), without sorting them.
+// The tree is built bottom up, by packing consecutive runs of items into
+// leaves, then consecutive runs of those leaves into the nodes above them,
+// and so on. The entries at each level are spread evenly between its nodes,
+// so nodes hold up to the policy's maximum number of children and (other
+// than the root) at least half of the maximum.
+//
+// Since no sorting is needed, it's the cheapest of the bulk loaders, which
+// suits pipelines that already keep their data in curve order. The tree is
+// valid whatever order the items are in, but searches are only fast if
+// items that are near each other in the order are also near each other in
+// space. It panics if the policy is the zero value.
+func BulkLoadSorted(items []InsertItem, policy InsertionPolicy) RTree {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	var tr RTree
+	if len(items) == 0 {
+		return tr
+	}
+	entries := make([]Entry, len(items))
+	for i, item := range items {
+		entries[i] = Entry{BBox: item.BBox, Index: item.DataIndex, Payload: item.Payload}
+	}
+	leafMax := policy.forNode(true).maxChildren
+	var level []Entry
+	for _, group := range splitEvenly(entries, (len(entries)+leafMax-1)/leafMax) {
+		tr.Nodes = append(tr.Nodes, Node{IsLeaf: true, Entries: append([]Entry(nil), group...)})
+		n := len(tr.Nodes) - 1
+		level = append(level, Entry{BBox: tr.calculateBound(n), Index: n})
+	}
+	tr.packLevels(level, policy)
+	tr.calculateAggregates(tr.RootIndex)
+	return tr
+}
+
+// BulkLoadMorton bulk loads items into a new R-Tree by sorting them by the
+// positions of their centres along a Z-order (Morton) curve over their
+// extent (see MortonOf), then packing them bottom up in the same way as
+// BulkLoadSorted. Z-order positions are simpler to calculate than Hilbert
+// positions, so this is faster than the other in-memory bulk loaders, at the
+// cost of nodes that aren't quite as tight (the curve occasionally jumps
+// between distant parts of the space). For Hilbert packing, sort the items
+// by HilbertOf and use BulkLoadSorted. Like BulkLoad, the resulting tree is
+// deterministic. It panics if the policy is the zero value.
+func BulkLoadMorton(inserts []InsertItem, policy InsertionPolicy) RTree {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	extent := EmptyBBox
+	for _, item := range inserts {
+		extent = combine(extent, item.BBox)
+	}
+	type keyed struct {
+		key  uint64
+		item InsertItem
+	}
+	keys := make([]keyed, len(inserts))
+	for i, item := range inserts {
+		keys[i] = keyed{MortonOf(item.BBox, extent), item}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].key != keys[j].key {
+			return keys[i].key < keys[j].key
+		}
+		return bulkLess(keys[i].item, keys[j].item, true)
+	})
+	items := make([]InsertItem, len(keys))
+	for i, k := range keys {
+		items[i] = k.item
+	}
+	return BulkLoadSorted(items, policy)
+}
+
+// splitEvenly divides the entries into the given number of groups of (almost)
+// equal size, keeping their order.
+func splitEvenly(entries []Entry, groups int) [][]Entry {
+	split := make([][]Entry, groups)
+	for i := range split {
+		split[i] = entries[len(entries)*i/groups : len(entries)*(i+1)/groups]
+	}
+	return split
+}
+
+// bulkLess orders items by the centre of their bounding boxes along one axis.
+// Ties are broken using the remaining fields of the items, giving a total
+// order so that the result of sorting doesn't depend on the input order.
+func bulkLess(a, b InsertItem, horizontal bool) bool {
+	ka := [...]float64{midpoint(a.BBox.MinY, a.BBox.MaxY), a.BBox.MinX, a.BBox.MinY, a.BBox.MaxX, a.BBox.MaxY}
+	kb := [...]float64{midpoint(b.BBox.MinY, b.BBox.MaxY), b.BBox.MinX, b.BBox.MinY, b.BBox.MaxX, b.BBox.MaxY}
+	if horizontal {
+		ka[0] = midpoint(a.BBox.MinX, a.BBox.MaxX)
+		kb[0] = midpoint(b.BBox.MinX, b.BBox.MaxX)
+	}
+	for i := range ka {
+		if ka[i] != kb[i] {
+			return ka[i] < kb[i]
+		}
+	}
+	if a.DataIndex != b.DataIndex {
+		return a.DataIndex < b.DataIndex
+	}
+	return a.Payload < b.Payload
+}
@@ -0,0 +1,87 @@
+package rtree
+
+import "container/list"
+
+// CacheStats holds hit and miss counts for the node cache of a PagedRTree.
+type CacheStats struct {
+	Hits   int
+	Misses int
+}
+
+// SetCacheCapacity sets the maximum number of decoded nodes that are kept in
+// memory, so that frequently accessed regions of the tree don't need to be
+// read through the pager. The least recently used nodes are evicted first. A
+// capacity of 0 (the default) disables caching. Changing the capacity
+// empties the cache and resets its statistics.
+func (t *PagedRTree) SetCacheCapacity(capacity int) {
+	if capacity <= 0 {
+		t.cache = nil
+		return
+	}
+	t.cache = &nodeCache{
+		capacity: capacity,
+		lru:      list.New(),
+		elems:    make(map[int]*list.Element),
+	}
+}
+
+// CacheStats gives the hit and miss counts for the node cache.
+func (t *PagedRTree) CacheStats() CacheStats {
+	if t.cache == nil {
+		return CacheStats{}
+	}
+	return t.cache.stats
+}
+
+// nodeCache is an LRU cache of decoded nodes, keyed by page id.
+type nodeCache struct {
+	capacity int
+	lru      *list.List // front is most recently used
+	elems    map[int]*list.Element
+	stats    CacheStats
+}
+
+type cachedNode struct {
+	id   int
+	node Node
+}
+
+// get looks up a node in the cache. The returned node has its own copy of
+// the entries, so may be freely modified.
+func (c *nodeCache) get(id int) (Node, bool) {
+	elem, ok := c.elems[id]
+	if !ok {
+		c.stats.Misses++
+		return Node{}, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+	return copyNode(elem.Value.(*cachedNode).node), true
+}
+
+// put adds or replaces a node in the cache, evicting the least recently used
+// node if the cache is full.
+func (c *nodeCache) put(id int, node Node) {
+	node = copyNode(node)
+	if elem, ok := c.elems[id]; ok {
+		elem.Value.(*cachedNode).node = node
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.elems[id] = c.lru.PushFront(&cachedNode{id, node})
+	if c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.elems, oldest.Value.(*cachedNode).id)
+	}
+}
+
+func (c *nodeCache) clear() {
+	c.lru.Init()
+	c.elems = make(map[int]*list.Element)
+}
+
+func copyNode(node Node) Node {
+	node.Entries = append([]Entry(nil), node.Entries...)
+	return node
+}
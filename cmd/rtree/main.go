@@ -0,0 +1,247 @@
+// Command rtree inspects R-Trees. It loads a tree that was serialised using
+// RTree.WriteTo, or bulk loads a tree from a CSV file of bounding boxes, and
+// then runs a subcommand against it.
+//
+// Usage:
+//
+//	rtree stats FILE
+//	rtree search FILE MINX MINY MAXX MAXY
+//	rtree nearest FILE X Y K
+//	rtree validate FILE
+//	rtree svg FILE
+//	rtree dot FILE
+//
+// Files with a .csv extension are read as CSV, with one bounding box per
+// record (MINX, MINY, MAXX, MAXY) and an optional fifth column holding the
+// data index. If the data index is omitted, then the record number (starting
+// at 0) is used instead. Other files are read as serialised trees.
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/peterstace/rtree"
+)
+
+const usage = `usage:
+	rtree stats FILE
+	rtree search FILE MINX MINY MAXX MAXY
+	rtree nearest FILE X Y K
+	rtree validate FILE
+	rtree svg FILE
+	rtree dot FILE`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, w io.Writer) error {
+	if len(args) < 2 {
+		return errors.New(usage)
+	}
+	cmd, path, rest := args[0], args[1], args[2:]
+	wantArgs := map[string]int{"stats": 0, "search": 4, "nearest": 3, "validate": 0, "svg": 0, "dot": 0}
+	n, ok := wantArgs[cmd]
+	if !ok || len(rest) != n {
+		return errors.New(usage)
+	}
+	tr, err := load(path)
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case "stats":
+		return stats(w, &tr)
+	case "search":
+		fs, err := parseFloats(rest)
+		if err != nil {
+			return err
+		}
+		tr.Search(rtree.NewBBox(fs[0], fs[1], fs[2], fs[3]), func(idx int) {
+			fmt.Fprintln(w, idx)
+		})
+		return nil
+	case "nearest":
+		fs, err := parseFloats(rest[:2])
+		if err != nil {
+			return err
+		}
+		k, err := strconv.Atoi(rest[2])
+		if err != nil {
+			return fmt.Errorf("invalid K: %v", err)
+		}
+		for _, idx := range tr.Nearest(fs[0], fs[1], k) {
+			fmt.Fprintln(w, idx)
+		}
+		return nil
+	case "validate":
+		if err := tr.ValidateDeep(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, "ok")
+		return nil
+	case "svg":
+		return writeSVG(w, &tr)
+	default:
+		return writeDOT(w, &tr)
+	}
+}
+
+func parseFloats(ss []string) ([]float64, error) {
+	fs := make([]float64, len(ss))
+	for i, s := range ss {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %v", err)
+		}
+		fs[i] = f
+	}
+	return fs, nil
+}
+
+// load reads a tree from a file, either as CSV or as a serialised tree
+// depending on its extension.
+func load(path string) (rtree.RTree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return rtree.RTree{}, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return loadCSV(f)
+	}
+	return rtree.ReadRTree(f)
+}
+
+func loadCSV(r io.Reader) (rtree.RTree, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var items []rtree.InsertItem
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rtree.RTree{}, err
+		}
+		if len(rec) != 4 && len(rec) != 5 {
+			return rtree.RTree{}, fmt.Errorf("CSV record %d has %d fields, expected 4 or 5", len(items), len(rec))
+		}
+		for i := range rec {
+			rec[i] = strings.TrimSpace(rec[i])
+		}
+		fs, err := parseFloats(rec[:4])
+		if err != nil {
+			return rtree.RTree{}, fmt.Errorf("CSV record %d: %v", len(items), err)
+		}
+		idx := len(items)
+		if len(rec) == 5 {
+			if idx, err = strconv.Atoi(rec[4]); err != nil {
+				return rtree.RTree{}, fmt.Errorf("CSV record %d: invalid data index: %v", len(items), err)
+			}
+		}
+		items = append(items, rtree.InsertItem{BBox: rtree.NewBBox(fs[0], fs[1], fs[2], fs[3]), DataIndex: idx})
+	}
+	return rtree.BulkLoad(items), nil
+}
+
+func stats(w io.Writer, tr *rtree.RTree) error {
+	var items, leaves int
+	for _, n := range tr.Nodes {
+		if n.IsLeaf {
+			leaves++
+			items += len(n.Entries)
+		}
+	}
+	q := tr.Quality()
+	fmt.Fprintf(w, "items:        %d\n", items)
+	fmt.Fprintf(w, "nodes:        %d (%d leaves)\n", len(tr.Nodes), leaves)
+	fmt.Fprintf(w, "height:       %d\n", len(q.Levels))
+	fmt.Fprintf(w, "memory:       %d bytes\n", tr.MemoryUsage())
+	fmt.Fprintf(w, "overlap:      %g\n", q.Overlap)
+	fmt.Fprintf(w, "score:        %.4f\n", q.Score)
+	for i, lq := range q.Levels {
+		fmt.Fprintf(w, "level %d:      %d nodes, coverage %g, dead space %g\n", i, lq.Nodes, lq.Coverage, lq.DeadSpace)
+	}
+	return nil
+}
+
+// writeSVG draws the bounding boxes of the nodes (coloured by level) and
+// items (in black).
+func writeSVG(w io.Writer, tr *rtree.RTree) error {
+	colours := []string{"#e41a1c", "#377eb8", "#4daf4a", "#984ea3", "#ff7f00", "#a65628"}
+	var bound rtree.BBox
+	if len(tr.Nodes) > 0 {
+		bound = rtree.EmptyBBox
+		for _, e := range tr.Nodes[tr.RootIndex].Entries {
+			bound = bound.Union(e.BBox)
+		}
+	}
+	if bound.IsEmpty() {
+		bound = rtree.BBox{}
+	}
+	width := bound.MaxX - bound.MinX
+	height := bound.MaxY - bound.MinY
+	stroke := (width + height) / 1000
+	if stroke == 0 {
+		stroke = 1
+	}
+	// Flip the Y axis so that Y increases upwards.
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="%g %g %g %g">`+"\n",
+		bound.MinX-stroke, -bound.MaxY-stroke, width+2*stroke, height+2*stroke)
+	fmt.Fprintf(w, `<g transform="scale(1,-1)" fill="none" stroke-width="%g">`+"\n", stroke)
+	var draw func(n, level int)
+	draw = func(n, level int) {
+		node := &tr.Nodes[n]
+		colour := "#000000"
+		if !node.IsLeaf {
+			colour = colours[level%len(colours)]
+		}
+		for _, e := range node.Entries {
+			fmt.Fprintf(w, `<rect x="%g" y="%g" width="%g" height="%g" stroke="%s"/>`+"\n",
+				e.BBox.MinX, e.BBox.MinY, e.BBox.MaxX-e.BBox.MinX, e.BBox.MaxY-e.BBox.MinY, colour)
+			if !node.IsLeaf {
+				draw(e.Index, level+1)
+			}
+		}
+	}
+	if len(tr.Nodes) > 0 {
+		draw(tr.RootIndex, 0)
+	}
+	_, err := fmt.Fprintln(w, "</g>\n</svg>")
+	return err
+}
+
+// writeDOT writes the structure of the tree as a Graphviz graph.
+func writeDOT(w io.Writer, tr *rtree.RTree) error {
+	fmt.Fprintln(w, "digraph rtree {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for i, n := range tr.Nodes {
+		if n.IsLeaf {
+			var idxs []string
+			for _, e := range n.Entries {
+				idxs = append(idxs, strconv.Itoa(e.Index))
+			}
+			fmt.Fprintf(w, "\tn%d [label=\"leaf %d\\n%s\"];\n", i, i, strings.Join(idxs, " "))
+			continue
+		}
+		fmt.Fprintf(w, "\tn%d [label=\"node %d\"];\n", i, i)
+		for _, e := range n.Entries {
+			fmt.Fprintf(w, "\tn%d -> n%d;\n", i, e.Index)
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
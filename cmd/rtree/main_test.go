@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peterstace/rtree"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "boxes.csv")
+	if err := os.WriteFile(csvPath, []byte("0,0,1,1\n2,2,3,3,10\n5, 5, 4, 4\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := load(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	treePath := filepath.Join(dir, "boxes.rtree")
+	f, err := os.Create(treePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	for _, path := range []string{csvPath, treePath} {
+		for _, tc := range []struct {
+			args []string
+			want string
+		}{
+			{[]string{"search", path, "2", "2", "4.5", "4.5"}, "10\n2\n"},
+			{[]string{"nearest", path, "0", "0", "2"}, "0\n10\n"},
+			{[]string{"validate", path}, "ok\n"},
+			{[]string{"stats", path}, "items:        3\n"},
+			{[]string{"svg", path}, "<svg"},
+			{[]string{"dot", path}, "digraph rtree {"},
+		} {
+			var buf bytes.Buffer
+			if err := run(tc.args, &buf); err != nil {
+				t.Fatalf("%v: %v", tc.args, err)
+			}
+			got := buf.String()
+			if tc.args[0] == "search" {
+				got = sortLines(got)
+				tc.want = sortLines(tc.want)
+			}
+			if !strings.HasPrefix(got, tc.want) {
+				t.Errorf("%v: got %q want prefix %q", tc.args, got, tc.want)
+			}
+		}
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"stats"},
+		{"unknown", csvPath},
+		{"search", csvPath, "1"},
+		{"nearest", csvPath, "0", "0", "x"},
+		{"stats", filepath.Join(dir, "missing.csv")},
+	} {
+		if err := run(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func sortLines(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i := 1; i < len(lines); i++ {
+		for j := i; j > 0 && lines[j] < lines[j-1]; j-- {
+			lines[j], lines[j-1] = lines[j-1], lines[j]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestValidate(t *testing.T) {
+	tr := rtree.BulkLoad([]rtree.InsertItem{
+		{BBox: rtree.BBox{MinX: 0, MinY: 0, MaxX: 1, MaxY: 1}},
+		{BBox: rtree.BBox{MinX: 2, MinY: 2, MaxX: 3, MaxY: 3}, DataIndex: 1},
+		{BBox: rtree.BBox{MinX: 4, MinY: 4, MaxX: 5, MaxY: 5}, DataIndex: 2},
+	})
+	tr.Nodes[tr.RootIndex].Entries[0].BBox.MaxX += 1
+	path := filepath.Join(t.TempDir(), "loose.rtree")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := run([]string{"validate", path}, &bytes.Buffer{}); !errors.Is(err, rtree.ErrCorruptTree) {
+		t.Errorf("expected ErrCorruptTree for loose bounding box, got %v", err)
+	}
+}
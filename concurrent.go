@@ -0,0 +1,60 @@
+package rtree
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentRTree is an R-Tree that may be searched concurrently with
+// modification. Each modification is staged in a new version of the tree
+// that shares unmodified nodes with the previous version, and is then
+// published atomically. A search sees either the state before a modification
+// or the state after it, but never a partially applied modification.
+//
+// Modifications are serialised with respect to each other. Searches never
+// block.
+type ConcurrentRTree struct {
+	policy  InsertionPolicy
+	mu      sync.Mutex   // serialises writers
+	current atomic.Value // holds a PersistentRTree
+}
+
+// NewConcurrentRTree creates a new empty tree that uses the given insertion
+// policy.
+func NewConcurrentRTree(policy InsertionPolicy) *ConcurrentRTree {
+	t := &ConcurrentRTree{policy: policy}
+	t.current.Store(PersistentRTree{})
+	return t
+}
+
+// Snapshot gives the current version of the tree. The snapshot is unaffected
+// by later modifications.
+func (t *ConcurrentRTree) Snapshot() PersistentRTree {
+	return t.current.Load().(PersistentRTree)
+}
+
+// Search looks for any items in the current version of the tree that overlap
+// with the given bounding box. The callback is called with the item index
+// for each found item.
+func (t *ConcurrentRTree) Search(bb BBox, callback func(index int)) {
+	t.Snapshot().Search(bb, callback)
+}
+
+// Insert adds a new data item to the tree.
+func (t *ConcurrentRTree) Insert(bb BBox, dataIndex int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current.Store(t.Snapshot().Insert(bb, dataIndex, t.policy))
+}
+
+// Delete removes a single data item matching the bounding box and data index
+// from the tree. It returns false if no item matched.
+func (t *ConcurrentRTree) Delete(bb BBox, dataIndex int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	next, ok := t.Snapshot().Delete(bb, dataIndex)
+	if ok {
+		t.current.Store(next)
+	}
+	return ok
+}
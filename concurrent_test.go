@@ -0,0 +1,65 @@
+package rtree
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentRTreeSnapshotIsolation(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewConcurrentRTree(ins)
+
+	const population = 500
+	rnd := rand.New(rand.NewSource(0))
+	boxes := make([]BBox, population)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// Items are inserted in order, so each search must see
+				// exactly the items 0..n-1 for some n.
+				seen := make([]bool, population)
+				var count int
+				tr.Search(BBox{0, 0, 1, 1}, func(idx int) {
+					seen[idx] = true
+					count++
+				})
+				for i := 0; i < count; i++ {
+					if !seen[i] {
+						t.Errorf("torn read: saw %d items but not item %d", count, i)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i, bb := range boxes {
+		tr.Insert(bb, i)
+	}
+	close(done)
+	wg.Wait()
+
+	if got := tr.Snapshot().Len(); got != population {
+		t.Errorf("expected %d items, got %d", population, got)
+	}
+	if !tr.Delete(boxes[0], 0) || tr.Delete(boxes[0], 0) {
+		t.Errorf("unexpected delete result")
+	}
+}
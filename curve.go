@@ -0,0 +1,35 @@
+package rtree
+
+// HilbertOf gives the position of the centre of the bounding box along a
+// Hilbert curve over the extent, which is divided into a 2^32 by 2^32 grid.
+// Items that are near each other in space tend to be near each other along
+// the curve, so the positions are suitable for pre-sorting items for
+// BulkLoadSorted, for sharding data, or as keys in other stores. Centres
+// outside of the extent are clamped to its boundary.
+//
+// If the extent is EmptyBBox, the curve instead covers the entire range of
+// float64 values, so the extent doesn't need to be known in advance. This is
+// the order used by HilbertRTree and BatchSearch.
+func HilbertOf(bb, extent BBox) uint64 {
+	return hilbert(curveCell(bb, extent))
+}
+
+// MortonOf is like HilbertOf, but gives the position along a Z-order (Morton)
+// curve. Z-order positions are cheaper to calculate than Hilbert positions,
+// but the curve has larger jumps, so they preserve locality less well. If
+// the extent is EmptyBBox, the order matches that used by BulkLoadFrom and
+// BulkLoadExternal.
+func MortonOf(bb, extent BBox) uint64 {
+	return interleave(curveCell(bb, extent))
+}
+
+// curveCell gives the cell containing the centre of the bounding box, in a
+// 2^32 by 2^32 grid covering the extent (or the entire range of float64
+// values, if the extent is EmptyBBox).
+func curveCell(bb, extent BBox) (uint32, uint32) {
+	x, y := bb.Center()
+	if extent.IsEmpty() {
+		return uint32(sortableFloatBits(x) >> 32), uint32(sortableFloatBits(y) >> 32)
+	}
+	return gridCoord(x, extent.MinX, extent.MaxX), gridCoord(y, extent.MinY, extent.MaxY)
+}
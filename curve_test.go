@@ -0,0 +1,80 @@
+package rtree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestHilbertOfLocality(t *testing.T) {
+	// Consecutive cells of a Hilbert curve are always adjacent.
+	extent := BBox{0, 0, 8, 8}
+	type cell struct{ x, y int }
+	var cells []cell
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			cells = append(cells, cell{x, y})
+		}
+	}
+	key := func(c cell) uint64 {
+		return HilbertOf(BBox{float64(c.x) + 0.4, float64(c.y) + 0.4, float64(c.x) + 0.6, float64(c.y) + 0.6}, extent)
+	}
+	sort.Slice(cells, func(i, j int) bool { return key(cells[i]) < key(cells[j]) })
+	for i := 1; i < len(cells); i++ {
+		dx, dy := cells[i].x-cells[i-1].x, cells[i].y-cells[i-1].y
+		if dx*dx+dy*dy != 1 {
+			t.Fatalf("cells %v and %v are consecutive but not adjacent", cells[i-1], cells[i])
+		}
+	}
+}
+
+func TestMortonOf(t *testing.T) {
+	extent := BBox{0, 0, 2, 2}
+	quadrant := func(x, y float64) uint64 {
+		return MortonOf(BBox{x + 0.5, y + 0.5, x + 0.5, y + 0.5}, extent) >> 62
+	}
+	for _, tc := range []struct {
+		x, y float64
+		want uint64
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{1, 0, 2},
+		{1, 1, 3},
+	} {
+		if got := quadrant(tc.x, tc.y); got != tc.want {
+			t.Errorf("quadrant (%v, %v): got %d want %d", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestCurveExtent(t *testing.T) {
+	extent := BBox{0, 0, 1, 1}
+	for name, curve := range map[string]func(BBox, BBox) uint64{
+		"hilbert": HilbertOf,
+		"morton":  MortonOf,
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Centres outside of the extent are clamped to its boundary.
+			if got, want := curve(BBox{-5, -5, -3, -3}, extent), curve(BBox{0, 0, 0, 0}, extent); got != want {
+				t.Errorf("below extent: got %d want %d", got, want)
+			}
+			if got, want := curve(BBox{3, 3, 5, 5}, extent), curve(BBox{1, 1, 1, 1}, extent); got != want {
+				t.Errorf("above extent: got %d want %d", got, want)
+			}
+
+			// Without an extent, the curve covers all float64 values, so
+			// distant points are still distinguished.
+			rnd := rand.New(rand.NewSource(0))
+			seen := make(map[uint64]bool)
+			for i := 0; i < 100; i++ {
+				x, y := (rnd.Float64()-0.5)*1e12, (rnd.Float64()-0.5)*1e12
+				k := curve(BBox{x, y, x, y}, EmptyBBox)
+				if seen[k] {
+					t.Fatalf("duplicate key %d", k)
+				}
+				seen[k] = true
+			}
+		})
+	}
+}
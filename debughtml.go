@@ -0,0 +1,190 @@
+package rtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DebugHTML writes a standalone HTML page that visualises the structure of
+// the tree. The page draws the bounding box of each node, and allows the
+// view to be panned (by dragging) and zoomed (with the mouse wheel). Each
+// level of the tree can be shown or hidden, and clicking on a node lists its
+// entries. This is useful when diagnosing poor splits or unexpected node
+// overlap.
+//
+// The page doesn't load any external resources, so it can be viewed offline.
+func (t *RTree) DebugHTML(w io.Writer) error {
+	type debugEntry struct {
+		BBox  [4]float64 `json:"bbox"`
+		Index int        `json:"index"`
+	}
+	type debugNode struct {
+		ID      int          `json:"id"`
+		Level   int          `json:"level"`
+		Leaf    bool         `json:"leaf"`
+		BBox    [4]float64   `json:"bbox"`
+		Entries []debugEntry `json:"entries"`
+	}
+	coords := func(bb BBox) [4]float64 {
+		// JSON can't represent non-finite numbers.
+		return [4]float64{
+			clampFloat(bb.MinX, 0),
+			clampFloat(bb.MinY, 0),
+			clampFloat(bb.MaxX, 0),
+			clampFloat(bb.MaxY, 0),
+		}
+	}
+
+	nodes := []debugNode{}
+	var recurse func(n, level int)
+	recurse = func(n, level int) {
+		node := &t.Nodes[n]
+		dn := debugNode{
+			ID:      n,
+			Level:   level,
+			Leaf:    node.IsLeaf,
+			BBox:    coords(t.calculateBound(n)),
+			Entries: []debugEntry{},
+		}
+		for _, e := range node.Entries {
+			dn.Entries = append(dn.Entries, debugEntry{coords(e.BBox), e.Index})
+		}
+		nodes = append(nodes, dn)
+		if !node.IsLeaf {
+			for _, e := range node.Entries {
+				recurse(e.Index, level+1)
+			}
+		}
+	}
+	if len(t.Nodes) > 0 && len(t.Nodes[t.RootIndex].Entries) > 0 {
+		recurse(t.RootIndex, 0)
+	}
+
+	// The JSON encoding escapes <, > and &, so it's safe to embed in a
+	// script element.
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, debugHTMLPage, data)
+	return err
+}
+
+const debugHTMLPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>R-Tree</title>
+<style>
+body { margin: 0; display: flex; height: 100vh; font: 13px sans-serif; }
+canvas { flex: 1; cursor: grab; }
+#side { width: 280px; overflow: auto; padding: 8px; border-left: 1px solid #ccc; }
+pre { font-size: 11px; }
+</style>
+</head>
+<body>
+<canvas id="c"></canvas>
+<div id="side"><div id="levels"></div><pre id="info">Click a node to list its entries.</pre></div>
+<script>
+var nodes = %s;
+var colours = ["#e41a1c", "#377eb8", "#4daf4a", "#984ea3", "#ff7f00", "#a65628", "#f781bf"];
+var canvas = document.getElementById("c");
+var ctx = canvas.getContext("2d");
+var maxLevel = -1, shown = {}, selected = null;
+var minX = Infinity, minY = Infinity, maxX = -Infinity, maxY = -Infinity;
+nodes.forEach(function(n) {
+	maxLevel = Math.max(maxLevel, n.level);
+	minX = Math.min(minX, n.bbox[0]); minY = Math.min(minY, n.bbox[1]);
+	maxX = Math.max(maxX, n.bbox[2]); maxY = Math.max(maxY, n.bbox[3]);
+});
+var levels = document.getElementById("levels");
+for (var l = 0; l <= maxLevel; l++) {
+	shown[l] = true;
+	var label = document.createElement("label");
+	label.style.color = colours[l %% colours.length];
+	label.innerHTML = '<input type="checkbox" checked data-level="' + l + '"> level ' + l + "<br>";
+	levels.appendChild(label);
+}
+levels.addEventListener("change", function(ev) {
+	shown[ev.target.dataset.level] = ev.target.checked;
+	draw();
+});
+var scale = 1, offX = 0, offY = 0;
+function fit() {
+	canvas.width = canvas.clientWidth;
+	canvas.height = canvas.clientHeight;
+	var w = Math.max(maxX - minX, 1e-9), h = Math.max(maxY - minY, 1e-9);
+	scale = 0.9 * Math.min(canvas.width / w, canvas.height / h);
+	offX = canvas.width / 2 - scale * (minX + maxX) / 2;
+	offY = canvas.height / 2 + scale * (minY + maxY) / 2;
+}
+function toScreen(x, y) { return [offX + scale * x, offY - scale * y]; }
+function toWorld(sx, sy) { return [(sx - offX) / scale, (offY - sy) / scale]; }
+function rect(bb) {
+	var a = toScreen(bb[0], bb[3]), b = toScreen(bb[2], bb[1]);
+	ctx.strokeRect(a[0], a[1], Math.max(b[0] - a[0], 1), Math.max(b[1] - a[1], 1));
+}
+function draw() {
+	ctx.clearRect(0, 0, canvas.width, canvas.height);
+	nodes.forEach(function(n) {
+		if (!shown[n.level]) return;
+		ctx.strokeStyle = colours[n.level %% colours.length];
+		ctx.lineWidth = n === selected ? 3 : 1;
+		rect(n.bbox);
+	});
+	if (selected && selected.leaf) {
+		ctx.strokeStyle = "#000";
+		ctx.lineWidth = 1;
+		selected.entries.forEach(function(e) { rect(e.bbox); });
+	}
+}
+var drag = null;
+canvas.addEventListener("mousedown", function(ev) { drag = {x: ev.offsetX, y: ev.offsetY, moved: false}; });
+canvas.addEventListener("mousemove", function(ev) {
+	if (!drag) return;
+	offX += ev.offsetX - drag.x; offY += ev.offsetY - drag.y;
+	drag.moved = drag.moved || ev.offsetX !== drag.x || ev.offsetY !== drag.y;
+	drag.x = ev.offsetX; drag.y = ev.offsetY;
+	draw();
+});
+canvas.addEventListener("mouseup", function(ev) {
+	if (drag && !drag.moved) select(ev.offsetX, ev.offsetY);
+	drag = null;
+});
+canvas.addEventListener("wheel", function(ev) {
+	ev.preventDefault();
+	var f = Math.exp(-ev.deltaY / 500);
+	offX = ev.offsetX - f * (ev.offsetX - offX);
+	offY = ev.offsetY - f * (ev.offsetY - offY);
+	scale *= f;
+	draw();
+});
+function select(sx, sy) {
+	// Pick the deepest visible node containing the point.
+	var p = toWorld(sx, sy);
+	selected = null;
+	nodes.forEach(function(n) {
+		if (shown[n.level] && p[0] >= n.bbox[0] && p[0] <= n.bbox[2] && p[1] >= n.bbox[1] && p[1] <= n.bbox[3] &&
+				(!selected || n.level >= selected.level)) {
+			selected = n;
+		}
+	});
+	var info = document.getElementById("info");
+	if (!selected) {
+		info.textContent = "Click a node to list its entries.";
+	} else {
+		info.textContent = "node " + selected.id + " (level " + selected.level + (selected.leaf ? ", leaf" : "") + ")\n" +
+			selected.entries.map(function(e) {
+				return (selected.leaf ? "item " : "node ") + e.index + ": " + e.bbox.join(", ");
+			}).join("\n");
+	}
+	draw();
+}
+window.addEventListener("resize", function() { fit(); draw(); });
+fit();
+draw();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,57 @@
+package rtree
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestDebugHTML(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	for i := 0; i < 50; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, ins)
+	}
+	rt.Insert(BBox{0, 0, math.Inf(1), 1}, 50, ins)
+
+	var buf bytes.Buffer
+	if err := rt.DebugHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	if !strings.HasPrefix(page, "<!DOCTYPE html>") || strings.Contains(page, "%!") {
+		t.Fatalf("malformed page: %s", page)
+	}
+
+	m := regexp.MustCompile(`var nodes = (.*);`).FindStringSubmatch(page)
+	if m == nil {
+		t.Fatal("node data not found")
+	}
+	var nodes []struct {
+		ID      int
+		Entries []struct{ Index int }
+	}
+	if err := json.Unmarshal([]byte(m[1]), &nodes); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != len(rt.Nodes) {
+		t.Errorf("expected %d nodes, got %d", len(rt.Nodes), len(nodes))
+	}
+
+	var empty RTree
+	buf.Reset()
+	if err := empty.DebugHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "var nodes = [];") {
+		t.Errorf("expected empty node data")
+	}
+}
@@ -0,0 +1,306 @@
+package rtree
+
+import "fmt"
+
+// ReinsertLevel controls where the entries of an underflowing node are
+// reinserted after a deletion.
+type ReinsertLevel int
+
+const (
+	// ReinsertAtOriginalLevel reinserts each entry of an underflowing node
+	// into a node at the same level that it was removed from. Entries
+	// leading to subtrees keep their subtrees intact, which makes
+	// condensation cheap.
+	ReinsertAtOriginalLevel ReinsertLevel = iota
+
+	// ReinsertAtLeafLevel dissolves underflowing nodes entirely, reinserting
+	// every item under them individually. This is more expensive, but gives
+	// each item the chance to find a better leaf, which tends to reduce node
+	// overlap for workloads with heavy churn.
+	ReinsertAtLeafLevel
+)
+
+// DeletionPolicy alters the behaviour when deleting data from an RTree. The
+// zero value only removes nodes that become empty, and never reinserts
+// entries.
+type DeletionPolicy struct {
+	minChildren int
+	level       ReinsertLevel
+	insertion   InsertionPolicy
+}
+
+// NewDeletionPolicy creates a new deletion policy. Non-root nodes left with
+// fewer than minChildren entries by a deletion underflow: they are removed
+// from the tree, and their entries are reinserted at the given level using
+// the insertion policy. The minimum number of children must be at least 1
+// and at most half of the insertion policy's maximum number of children.
+func NewDeletionPolicy(minChildren int, level ReinsertLevel, insertion InsertionPolicy) (DeletionPolicy, error) {
+	if minChildren < 1 {
+		return DeletionPolicy{}, fmt.Errorf("%w: min children must be at least 1", ErrInvalidPolicy)
+	}
+	if minChildren > insertion.maxChildren/2 || minChildren > insertion.forNode(true).maxChildren/2 {
+		return DeletionPolicy{}, fmt.Errorf("%w: min children must be less than or equal to half of the insertion policy's max children", ErrInvalidPolicy)
+	}
+	if level != ReinsertAtOriginalLevel && level != ReinsertAtLeafLevel {
+		return DeletionPolicy{}, fmt.Errorf("%w: invalid reinsert level", ErrInvalidPolicy)
+	}
+	return DeletionPolicy{minChildren: minChildren, level: level, insertion: insertion}, nil
+}
+
+// DeleteFunc removes all items overlapping with the given bounding box for
+// which the predicate returns true. The predicate is called with the item
+// index for each candidate item. The number of removed items is returned.
+//
+// Nodes left empty by the deletion are removed, and the bounding boxes of
+// their ancestors are tightened, in a single condensation pass once all
+// matching items have been removed.
+func (t *RTree) DeleteFunc(bb BBox, pred func(index int) bool) int {
+	return t.deleteEntries(bb, func(e Entry) bool { return pred(e.Index) }, DeletionPolicy{})
+}
+
+// DeleteFuncWithPolicy is like DeleteFunc, but handles nodes that underflow
+// as a result of the deletion according to the deletion policy.
+func (t *RTree) DeleteFuncWithPolicy(bb BBox, pred func(index int) bool, policy DeletionPolicy) int {
+	return t.deleteEntries(bb, func(e Entry) bool { return pred(e.Index) }, policy)
+}
+
+// DeleteByIndex removes the item with the given data index, without needing
+// its bounding box. The bounding box is found using BBoxOf, so this is only
+// efficient if lookup tracking has been turned on by EnableLookup. The return
+// value indicates if an item was removed.
+func (t *RTree) DeleteByIndex(dataIndex int) bool {
+	bb, ok := t.BBoxOf(dataIndex)
+	if !ok {
+		return false
+	}
+	var done bool
+	t.deleteEntries(bb, func(e Entry) bool {
+		if done || e.Index != dataIndex {
+			return false
+		}
+		done = true
+		return true
+	}, DeletionPolicy{})
+	return done
+}
+
+// deleteEntries removes all leaf entries overlapping with the given bounding
+// box for which the predicate returns true, and then condenses the tree
+// according to the deletion policy.
+func (t *RTree) deleteEntries(bb BBox, pred func(Entry) bool, policy DeletionPolicy) int {
+	if len(t.Nodes) == 0 {
+		return 0
+	}
+
+	var deleted int
+	var orphans []orphan
+	dead := make([]bool, len(t.Nodes))
+	var recurse func(n, height int) bool
+	recurse = func(n, height int) bool {
+		node := &t.Nodes[n]
+		var changed bool
+		kept := node.Entries[:0]
+		for _, entry := range node.Entries {
+			if !overlap(entry.BBox, bb) {
+				kept = append(kept, entry)
+				continue
+			}
+			if node.IsLeaf {
+				if pred(entry) {
+					if deleted == 0 {
+						t.generation++
+					}
+					t.deleteLookup(entry.Index)
+					t.recordJournal(entry, false)
+					t.hookDelete(entry)
+					t.setTags(entry.Index, 0)
+					t.setWeight(entry.Index, 0)
+					deleted++
+					changed = true
+					continue
+				}
+			} else if recurse(entry.Index, height-1) {
+				changed = true
+				if len(t.Nodes[entry.Index].Entries) == 0 {
+					dead[entry.Index] = true
+					t.mutations.Condensations++
+					continue
+				}
+				if len(t.Nodes[entry.Index].Entries) < policy.minChildren {
+					t.mutations.Condensations++
+					orphans = t.dissolveNode(entry.Index, height-1, policy.level, dead, orphans)
+					continue
+				}
+				entry.BBox = t.calculateBound(entry.Index)
+			}
+			kept = append(kept, entry)
+		}
+		node.Entries = kept
+		if changed {
+			t.summarise(n)
+		}
+		return changed
+	}
+	if !recurse(t.RootIndex, t.height()) {
+		return deleted
+	}
+
+	root := &t.Nodes[t.RootIndex]
+	if len(root.Entries) == 0 {
+		root.IsLeaf = true
+	}
+	t.shortenRoot(dead)
+	if len(orphans) > 0 {
+		t.reinsertOrphans(orphans, dead, policy.insertion)
+		for len(dead) < len(t.Nodes) {
+			dead = append(dead, false)
+		}
+		t.shortenRoot(dead)
+	}
+	t.compactNodes(dead)
+	return deleted
+}
+
+// orphan is an entry removed from an underflowing node, along with the height
+// of the node it was removed from (leaves have height 0).
+type orphan struct {
+	entry  Entry
+	height int
+}
+
+// dissolveNode marks the node n (with the given height) as dead, and adds its
+// entries to the orphans. If reinsertion is at the leaf level, then the
+// node's subtree is dissolved as well.
+func (t *RTree) dissolveNode(n, height int, level ReinsertLevel, dead []bool, orphans []orphan) []orphan {
+	dead[n] = true
+	for _, e := range t.Nodes[n].Entries {
+		if height > 0 && level == ReinsertAtLeafLevel {
+			orphans = t.dissolveNode(e.Index, height-1, level, dead, orphans)
+		} else {
+			orphans = append(orphans, orphan{entry: e, height: height})
+		}
+	}
+	return orphans
+}
+
+// reinsertOrphans inserts each orphan into a node at its original height.
+// Orphans from nodes taller than the (possibly shortened) tree are first
+// broken up into their children.
+func (t *RTree) reinsertOrphans(orphans []orphan, dead []bool, policy InsertionPolicy) {
+	for len(orphans) > 0 {
+		o := orphans[len(orphans)-1]
+		orphans = orphans[:len(orphans)-1]
+		if o.height > t.height() {
+			orphans = t.dissolveNode(o.entry.Index, o.height-1, ReinsertAtOriginalLevel, dead, orphans)
+			continue
+		}
+		path := t.insertAtHeight(o.entry, o.height)
+		t.splitOverfull(path, policy)
+		t.mutations.Reinsertions++
+	}
+}
+
+// insertAtHeight adds an entry to the most suitable node with the given
+// height, and enlarges the bounding boxes of the node's ancestors to fit it.
+// The node isn't split if it becomes overfull. The path to the node is
+// returned.
+func (t *RTree) insertAtHeight(entry Entry, height int) []nodeStep {
+	path := t.rootPath()
+	n := t.RootIndex
+	for h := t.height(); h > height; h-- {
+		entries := t.Nodes[n].Entries
+		best := 0
+		bestDelta := enlargement(entry.BBox, entries[0].BBox)
+		for i, e := range entries[1:] {
+			delta := enlargement(entry.BBox, e.BBox)
+			if delta < bestDelta || (delta == bestDelta && area(e.BBox) < area(entries[best].BBox)) {
+				best, bestDelta = i+1, delta
+			}
+		}
+		n = entries[best].Index
+		path[len(path)-1].entry = best
+		path = append(path, nodeStep{node: n, entry: -1})
+	}
+	t.pathBuf = path[:0]
+
+	agg := weightAggregate(t.weights[entry.Index])
+	if height > 0 {
+		agg = t.Nodes[entry.Index].Aggregate
+	} else {
+		t.setLookup(entry.Index, entry.BBox)
+	}
+	tags := t.entryTags(entry, height == 0)
+	node := &t.Nodes[n]
+	node.Entries = append(node.Entries, entry)
+	if len(node.Entries) == 1 {
+		node.Aggregate = agg
+	} else {
+		node.Aggregate = node.Aggregate.combine(agg)
+	}
+	node.Tags |= tags
+	for i := len(path) - 2; i >= 0; i-- {
+		step := path[i]
+		e := &t.Nodes[step.node].Entries[step.entry]
+		e.BBox = combine(e.BBox, entry.BBox)
+		t.Nodes[step.node].Aggregate = t.Nodes[step.node].Aggregate.combine(agg)
+		t.Nodes[step.node].Tags |= tags
+	}
+	return path
+}
+
+// height gives the number of levels in the tree below the root.
+func (t *RTree) height() int {
+	var h int
+	for n := t.RootIndex; !t.Nodes[n].IsLeaf; n = t.Nodes[n].Entries[0].Index {
+		h++
+	}
+	return h
+}
+
+// shortenRoot replaces the root with its only child for as long as the root
+// is a non-leaf with a single entry. Replaced roots are marked as dead.
+func (t *RTree) shortenRoot(dead []bool) {
+	for {
+		root := &t.Nodes[t.RootIndex]
+		if root.IsLeaf || len(root.Entries) != 1 {
+			return
+		}
+		dead[t.RootIndex] = true
+		t.RootIndex = root.Entries[0].Index
+	}
+}
+
+// compactNodes removes the nodes marked as dead, renumbering the remaining
+// nodes (and all references to them) to fill the gaps.
+func (t *RTree) compactNodes(dead []bool) {
+	newIndex := make([]int, len(t.Nodes))
+	var count int
+	for i := range t.Nodes {
+		if dead[i] {
+			newIndex[i] = -1
+			continue
+		}
+		newIndex[i] = count
+		t.Nodes[count] = t.Nodes[i]
+		count++
+	}
+	if count == len(t.Nodes) {
+		return
+	}
+	t.invalidateHint()
+	for i := count; i < len(t.Nodes); i++ {
+		t.Nodes[i] = Node{}
+	}
+	t.Nodes = t.Nodes[:count]
+
+	t.RootIndex = newIndex[t.RootIndex]
+	for i := range t.Nodes {
+		node := &t.Nodes[i]
+		if node.IsLeaf {
+			continue
+		}
+		for j := range node.Entries {
+			node.Entries[j].Index = newIndex[node.Entries[j].Index]
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package rtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestDeleteFunc(t *testing.T) {
+	for _, population := range []int{0, 1, 2, 5, 20, 100} {
+		t.Run(fmt.Sprintf("pop_%d", population), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(0))
+			boxes := make([]BBox, population)
+			for i := range boxes {
+				boxes[i] = randomBox(rnd, 0.9, 0.1)
+			}
+			ins, err := NewInsertionPolicy(2, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rt RTree
+			for i, bb := range boxes {
+				rt.Insert(bb, i, ins)
+			}
+
+			remaining := make(map[int]BBox)
+			for i, bb := range boxes {
+				remaining[i] = bb
+			}
+			for round := 0; round < 5; round++ {
+				query := randomBox(rnd, 0.5, 0.5)
+				var want int
+				for i, bb := range remaining {
+					if i%3 != 0 && overlap(bb, query) {
+						delete(remaining, i)
+						want++
+					}
+				}
+				got := rt.DeleteFunc(query, func(i int) bool { return i%3 != 0 })
+				if got != want {
+					t.Errorf("deleted %d, want %d", got, want)
+				}
+				checkInvariants(t, rt)
+			}
+
+			var kept []BBox
+			for i := range boxes {
+				if bb, ok := remaining[i]; ok {
+					kept = append(kept, bb)
+				} else {
+					kept = append(kept, BBox{-2, -2, -1, -1})
+				}
+			}
+			checkSearch(t, rt, kept, rnd)
+
+			got := rt.DeleteFunc(BBox{-1, -1, 2, 2}, func(int) bool { return true })
+			if got != len(remaining) {
+				t.Errorf("deleted %d, want %d", got, len(remaining))
+			}
+			rt.Search(BBox{-1, -1, 2, 2}, func(i int) {
+				t.Errorf("unexpected item %d after deleting everything", i)
+			})
+		})
+	}
+}
+
+func TestDeleteByIndex(t *testing.T) {
+	for _, lookup := range []bool{false, true} {
+		rnd := rand.New(rand.NewSource(0))
+		ins, err := NewInsertionPolicy(2, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rt RTree
+		if lookup {
+			rt.EnableLookup()
+		}
+		boxes := make([]BBox, 100)
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Insert(boxes[i], i, ins)
+		}
+		for i := range boxes {
+			if i%2 == 0 {
+				continue
+			}
+			if !rt.DeleteByIndex(i) {
+				t.Fatalf("expected item %d to be deleted", i)
+			}
+			if rt.DeleteByIndex(i) {
+				t.Fatalf("expected item %d to already be deleted", i)
+			}
+			boxes[i] = BBox{-2, -2, -1, -1}
+			checkInvariants(t, rt)
+		}
+		checkSearch(t, rt, boxes, rnd)
+	}
+}
+
+func TestDeleteFuncWithPolicy(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range []ReinsertLevel{ReinsertAtOriginalLevel, ReinsertAtLeafLevel} {
+		for _, minChildren := range []int{1, 2, 3} {
+			t.Run(fmt.Sprintf("level_%d_min_%d", level, minChildren), func(t *testing.T) {
+				del, err := NewDeletionPolicy(minChildren, level, ins)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rnd := rand.New(rand.NewSource(0))
+				var rt RTree
+				rt.EnableLookup()
+				boxes := make([]BBox, 300)
+				for i := range boxes {
+					boxes[i] = randomBox(rnd, 0.9, 0.1)
+					rt.InsertWithWeight(boxes[i], i, float64(i), ins)
+				}
+				remaining := len(boxes)
+				for round := 0; remaining > 0; round++ {
+					query := randomBox(rnd, 0.5, 0.5)
+					if round >= 20 {
+						query = BBox{-1, -1, 2, 2}
+					}
+					var want int
+					for i, bb := range boxes {
+						if i%2 == round%2 && overlap(bb, query) {
+							boxes[i] = BBox{-5, -5, -4, -4}
+							want++
+						}
+					}
+					got := rt.DeleteFuncWithPolicy(query, func(i int) bool { return i%2 == round%2 }, del)
+					if got != want {
+						t.Fatalf("deleted %d, want %d", got, want)
+					}
+					remaining -= got
+					checkInvariants(t, rt)
+					checkSearch(t, rt, boxes, rnd)
+					for i, bb := range boxes {
+						if got, ok := rt.BBoxOf(i); ok != (bb.MinX >= 0) || (ok && got != bb) {
+							t.Fatalf("BBoxOf(%d) = %v, %t", i, got, ok)
+						}
+					}
+
+					// Non-root nodes should have at least the minimum
+					// number of children. Splits only guarantee the
+					// insertion policy's minimum, so this can only be
+					// checked when it's at least as large.
+					for i, n := range rt.Nodes {
+						if minChildren <= 2 && i != rt.RootIndex && len(n.Entries) < minChildren {
+							t.Fatalf("node %d has %d entries", i, len(n.Entries))
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestNewDeletionPolicyValidation(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		minChildren int
+		level       ReinsertLevel
+		ok          bool
+	}{
+		{1, ReinsertAtOriginalLevel, true},
+		{3, ReinsertAtLeafLevel, true},
+		{0, ReinsertAtOriginalLevel, false},
+		{4, ReinsertAtOriginalLevel, false},
+		{2, ReinsertLevel(2), false},
+	} {
+		_, err := NewDeletionPolicy(tc.minChildren, tc.level, ins)
+		if (err == nil) != tc.ok {
+			t.Errorf("min=%d level=%d: unexpected err %v", tc.minChildren, tc.level, err)
+		}
+	}
+}
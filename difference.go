@@ -0,0 +1,132 @@
+package rtree
+
+// Difference finds the items in tree a whose bounding boxes don't overlap
+// with the bounding box of any item in tree b (i.e. an anti-join). The
+// callback is called with the item index (from tree a) for each such item.
+//
+// Both trees are traversed together, so entire subtrees of a that are
+// disjoint from everything in b are reported without examining b any
+// further, and only the parts of b near each node of a are examined. Items
+// marked as deleted by MarkDeleted are ignored in both trees. The periods of
+// the trees are ignored.
+func Difference(a, b *RTree, callback func(index int)) {
+	if len(a.Nodes) == 0 {
+		return
+	}
+	var candidates []dualCandidate
+	if len(b.Nodes) > 0 {
+		candidates = b.expandCandidate(nil, b.RootIndex)
+	}
+	a.difference(b, a.RootIndex, candidates, callback)
+}
+
+// dualCandidate is an entry from the second tree in a dual tree traversal.
+type dualCandidate struct {
+	entry  Entry
+	isLeaf bool
+}
+
+// expandCandidate appends the entries of node n to the candidates, other than
+// tombstoned items.
+func (t *RTree) expandCandidate(candidates []dualCandidate, n int) []dualCandidate {
+	node := &t.Nodes[n]
+	for _, e := range node.Entries {
+		if node.IsLeaf && t.isTombstoned(e.Index) {
+			continue
+		}
+		candidates = append(candidates, dualCandidate{e, node.IsLeaf})
+	}
+	return candidates
+}
+
+// difference reports the items under node n that don't overlap with any of
+// the items under the candidates (which are entries of tree b).
+func (t *RTree) difference(b *RTree, n int, candidates []dualCandidate, callback func(int)) {
+	node := &t.Nodes[n]
+	for _, e := range node.Entries {
+		if node.IsLeaf && t.isTombstoned(e.Index) {
+			continue
+		}
+		var overlapping []dualCandidate
+		for _, c := range candidates {
+			if overlap(c.entry.BBox, e.BBox) {
+				overlapping = append(overlapping, c)
+			}
+		}
+		switch {
+		case len(overlapping) == 0 && node.IsLeaf:
+			callback(e.Index)
+		case len(overlapping) == 0:
+			t.visitAll(e.Index, callback)
+		case node.IsLeaf:
+			if !b.anyOverlap(e.BBox, overlapping) {
+				callback(e.Index)
+			}
+		default:
+			// Descend one level in b for each level descended in a.
+			var next []dualCandidate
+			for _, c := range overlapping {
+				if c.isLeaf {
+					next = append(next, c)
+				} else {
+					next = b.expandCandidate(next, c.entry.Index)
+				}
+			}
+			t.difference(b, e.Index, next, callback)
+		}
+	}
+}
+
+// anyOverlap checks if any item under the candidates overlaps with bb. The
+// candidates must already overlap with bb.
+func (t *RTree) anyOverlap(bb BBox, candidates []dualCandidate) bool {
+	for _, c := range candidates {
+		if c.isLeaf {
+			return true
+		}
+		var found bool
+		t.searchNode(c.entry.Index, bb, func(Entry) bool {
+			found = true
+			return false
+		})
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// searchNode calls the callback for each item under node n that overlaps
+// with bb (other than tombstoned items), until the callback returns false. It
+// gives false if the search was stopped early.
+func (t *RTree) searchNode(n int, bb BBox, callback func(Entry) bool) bool {
+	node := &t.Nodes[n]
+	for _, e := range node.Entries {
+		if !overlap(e.BBox, bb) {
+			continue
+		}
+		if node.IsLeaf {
+			if !t.isTombstoned(e.Index) && !callback(e) {
+				return false
+			}
+		} else if !t.searchNode(e.Index, bb, callback) {
+			return false
+		}
+	}
+	return true
+}
+
+// visitAll calls the callback with the item index of each item under node n,
+// other than tombstoned items.
+func (t *RTree) visitAll(n int, callback func(int)) {
+	node := &t.Nodes[n]
+	for _, e := range node.Entries {
+		if node.IsLeaf {
+			if !t.isTombstoned(e.Index) {
+				callback(e.Index)
+			}
+		} else {
+			t.visitAll(e.Index, callback)
+		}
+	}
+}
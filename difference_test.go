@@ -0,0 +1,47 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDifference(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sizes := range [][2]int{{0, 0}, {0, 10}, {10, 0}, {1, 1}, {300, 50}, {50, 300}, {300, 300}} {
+		rnd := rand.New(rand.NewSource(0))
+		var a, b RTree
+		boxesA := make([]BBox, sizes[0])
+		boxesB := make([]BBox, sizes[1])
+		for i := range boxesA {
+			boxesA[i] = randomBox(rnd, 0.9, 0.05)
+			a.Insert(boxesA[i], i, ins)
+		}
+		for i := range boxesB {
+			boxesB[i] = randomBox(rnd, 0.9, 0.05)
+			b.Insert(boxesB[i], i, ins)
+		}
+
+		var got []int
+		Difference(&a, &b, func(idx int) { got = append(got, idx) })
+		sort.Ints(got)
+
+		var want []int
+	outer:
+		for i, ba := range boxesA {
+			for _, bb := range boxesB {
+				if overlap(ba, bb) {
+					continue outer
+				}
+			}
+			want = append(want, i)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("sizes=%v: got %v want %v", sizes, got, want)
+		}
+	}
+}
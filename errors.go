@@ -0,0 +1,53 @@
+package rtree
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Errors returned by the package. Returned errors wrap these values (adding
+// details about the specific problem), so they should be checked for using
+// errors.Is.
+var (
+	// ErrInvalidPolicy indicates that the parameters for an insertion or
+	// deletion policy are invalid.
+	ErrInvalidPolicy = errors.New("invalid policy")
+
+	// ErrInvalidBBox indicates that a bounding box is malformed, or has
+	// coordinates that aren't accepted.
+	ErrInvalidBBox = errors.New("invalid bounding box")
+
+	// ErrNotFound indicates that a requested item, page or version doesn't
+	// exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrCorruptTree indicates that a tree (either in memory, or being
+	// decoded from an external format) doesn't have a valid structure. It's
+	// also used for malformed geometries given to BBoxFromWKT and
+	// BBoxFromWKB.
+	ErrCorruptTree = errors.New("corrupt tree")
+)
+
+// wrapReadError adds context to an error from reading encoded data. Running
+// out of data means that the encoding is truncated, so in that case the
+// error also wraps ErrCorruptTree.
+func wrapReadError(err error, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %s: %w", ErrCorruptTree, msg, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// wrapJSONError is like wrapReadError, but for errors from decoding JSON.
+// Syntax errors and values of the wrong type also wrap ErrCorruptTree.
+func wrapJSONError(err error, msg string) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return fmt.Errorf("%w: %s: %w", ErrCorruptTree, msg, err)
+	}
+	return wrapReadError(err, "%s", msg)
+}
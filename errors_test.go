@@ -0,0 +1,55 @@
+package rtree
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	_, err := NewInsertionPolicy(0, 4)
+	if !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("NewInsertionPolicy: got %v", err)
+	}
+	policy, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.WithLeafCapacity(3, 4); !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("WithLeafCapacity: got %v", err)
+	}
+	if _, err := NewDeletionPolicy(3, ReinsertAtLeafLevel, policy); !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("NewDeletionPolicy: got %v", err)
+	}
+
+	if err := (BBox{MinX: 1, MaxX: 0}).Validate(); !errors.Is(err, ErrInvalidBBox) {
+		t.Errorf("Validate: got %v", err)
+	}
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, ErrInvalidBBox) {
+				t.Errorf("Insert: got %v", err)
+			}
+		}()
+		var tr RTree
+		tr.Insert(BBox{0, 0, math.Inf(1), 1}, 0, policy.WithNonFiniteHandling(NonFiniteReject))
+	}()
+	if _, err := NewShardedRTree(2, BBox{0, 0, math.Inf(1), 1}, policy); !errors.Is(err, ErrInvalidBBox) {
+		t.Errorf("NewShardedRTree: got %v", err)
+	}
+
+	var pager MemPager
+	if _, err := pager.ReadPage(7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadPage: got %v", err)
+	}
+
+	if _, err := ReadRTree(strings.NewReader(strings.Repeat("x", 28))); !errors.Is(err, ErrCorruptTree) {
+		t.Errorf("ReadRTree: got %v", err)
+	}
+	if _, err := LoadRBush(bytes.NewReader([]byte(`{"children":[{"children":[],"leaf":true}],"leaf":false}`))); !errors.Is(err, ErrCorruptTree) {
+		t.Errorf("LoadRBush: got %v", err)
+	}
+}
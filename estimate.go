@@ -0,0 +1,76 @@
+package rtree
+
+import "math"
+
+// estimateLevels is the number of levels (starting from the root) that
+// EstimateCount visits.
+const estimateLevels = 2
+
+// EstimateCount gives an approximation of the number of items that overlap
+// with the bounding box, without carrying out a full search. Only the top
+// few levels of the tree are visited. The number of items under each
+// subtree that is reached is taken from the subtree's aggregate, and is
+// scaled by the proportion of the subtree's bounding box that overlaps with
+// the query (assuming items are spread uniformly within it).
+//
+// The estimate takes time proportional to the number of nodes in the top
+// levels that overlap with the query, which grows very slowly with the size
+// of the tree, making it suitable for query planning. For trees with only a
+// couple of levels, the count is exact (unless the tree's space is
+// periodic, in which case items overlapping more than one wrapped copy of
+// the query may be counted more than once). Items marked as deleted by
+// MarkDeleted are only left out of the count within the visited levels.
+func (t *RTree) EstimateCount(bb BBox) int {
+	if len(t.Nodes) == 0 {
+		return 0
+	}
+	var count int
+	for _, q := range t.Period.wrappedQueries(bb) {
+		count += t.estimateCount(q)
+	}
+	return count
+}
+
+func (t *RTree) estimateCount(bb BBox) int {
+	var count int
+	var estimate float64
+	var recurse func(n, depth int)
+	recurse = func(n, depth int) {
+		node := &t.Nodes[n]
+		for _, e := range node.Entries {
+			if !overlap(e.BBox, bb) {
+				continue
+			}
+			switch {
+			case node.IsLeaf:
+				if !t.isTombstoned(e.Index) {
+					count++
+				}
+			case depth+1 < estimateLevels:
+				recurse(e.Index, depth+1)
+			default:
+				size := float64(t.Nodes[e.Index].Aggregate.Count)
+				estimate += overlapFraction(e.BBox, bb) * size
+			}
+		}
+	}
+	recurse(t.RootIndex, 0)
+	return count + int(math.Round(estimate))
+}
+
+// overlapFraction gives the proportion of bb that overlaps with the query.
+// Along axes where bb has zero or infinite width, bb counts as entirely
+// overlapping (since it's known to overlap the query, and the distribution
+// of items along an infinite extent can't be assumed to be uniform).
+func overlapFraction(bb, query BBox) float64 {
+	axis := func(lo, hi, qlo, qhi float64) float64 {
+		width := extentWidth(lo, hi)
+		if width <= 0 || math.IsInf(width, 1) {
+			return 1
+		}
+		inter := extentWidth(math.Max(lo, qlo), math.Min(hi, qhi))
+		return inter / width
+	}
+	return axis(bb.MinX, bb.MaxX, query.MinX, query.MaxX) *
+		axis(bb.MinY, bb.MaxY, query.MinY, query.MaxY)
+}
@@ -0,0 +1,97 @@
+package rtree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestEstimateCount(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	countWithin := func(rt *RTree, bb BBox) int {
+		var n int
+		rt.Search(bb, func(int) { n++ })
+		return n
+	}
+
+	t.Run("small_tree_exact", func(t *testing.T) {
+		var rt RTree
+		if got := rt.EstimateCount(everywhere); got != 0 {
+			t.Fatalf("expected 0 for empty tree, got %d", got)
+		}
+		policy := mustPolicy(t, 4, 8)
+		for i := 0; i < 40; i++ {
+			rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+		}
+		if rt.height() > 1 {
+			t.Fatalf("expected tree with 2 levels, got height %d", rt.height())
+		}
+		for i := 0; i < 20; i++ {
+			query := randomBox(rnd, 0.5, 0.5)
+			if got, want := rt.EstimateCount(query), countWithin(&rt, query); got != want {
+				t.Errorf("query %v: got %d want %d", query, got, want)
+			}
+		}
+	})
+
+	t.Run("large_tree_approximate", func(t *testing.T) {
+		policy := mustPolicy(t, 2, 8)
+		const population = 5000
+		var inserted RTree
+		items := make([]InsertItem, population)
+		for i := range items {
+			items[i] = InsertItem{BBox: randomBox(rnd, 1, 0.001), DataIndex: i}
+			inserted.Insert(items[i].BBox, i, policy)
+		}
+		bulk := BulkLoad(items)
+		for name, rt := range map[string]*RTree{"insert": &inserted, "bulk": &bulk} {
+			if rt.height() < 3 {
+				t.Fatalf("%s: expected a tall tree, got height %d", name, rt.height())
+			}
+			var totalErr, total float64
+			for i := 0; i < 50; i++ {
+				query := randomBox(rnd, 0.6, 0.4)
+				got, want := rt.EstimateCount(query), countWithin(rt, query)
+				totalErr += math.Abs(float64(got - want))
+				total += float64(want)
+			}
+			if rel := totalErr / total; rel > 0.15 {
+				t.Errorf("%s: relative error of estimates too large: %.3f", name, rel)
+			}
+
+			// Queries covering whole subtrees are counted exactly.
+			if got := rt.EstimateCount(everywhere); got != population {
+				t.Errorf("%s: estimated %d items in total, want %d", name, got, population)
+			}
+			query := BBox{0, 0, 0.1, 1.1}
+			if got, want := rt.EstimateCount(query), countWithin(rt, query); math.Abs(float64(got-want)) > 0.25*float64(want) {
+				t.Errorf("%s: estimated %d items in %v, want about %d", name, got, query, want)
+			}
+		}
+	})
+	t.Run("universe_items", func(t *testing.T) {
+		// Subtrees with infinite extent count as entirely overlapping the
+		// query, rather than as not overlapping it at all. The query covers
+		// every item, so the count is exact.
+		policy := mustPolicy(t, 2, 8)
+		const population = 500
+		var inserted RTree
+		items := make([]InsertItem, population+1)
+		for i := 0; i < population; i++ {
+			items[i] = InsertItem{BBox: randomBox(rnd, 0.9, 0.1), DataIndex: i}
+			inserted.Insert(items[i].BBox, i, policy)
+		}
+		items[population] = InsertItem{BBox: UniverseBBox, DataIndex: population}
+		inserted.Insert(UniverseBBox, population, policy)
+		bulk := BulkLoad(items)
+		for name, rt := range map[string]*RTree{"insert": &inserted, "bulk": &bulk} {
+			if rt.height() < 2 {
+				t.Fatalf("%s: expected a tall tree, got height %d", name, rt.height())
+			}
+			query := BBox{0, 0, 1, 1}
+			if got, want := rt.EstimateCount(query), countWithin(rt, query); got != want {
+				t.Errorf("%s: estimated %d items in %v, want %d", name, got, query, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package rtree
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExplainResult describes how a search was carried out, in the spirit of a
+// database's EXPLAIN. It's intended for analysing slow queries, e.g. to see
+// which nodes were visited despite not containing any matching items.
+type ExplainResult struct {
+	// Query is the bounding box that was searched for.
+	Query BBox
+
+	// Visited describes each node visited by the search, in the order that
+	// they were visited (depth first, starting with the root).
+	Visited []ExplainNode
+
+	// Candidates gives the data indices of the items found by the search,
+	// in the order that they were found.
+	Candidates []int
+}
+
+// ExplainNode describes a node visited by a search, and the decisions made
+// about its entries.
+type ExplainNode struct {
+	// Node is the index of the node in the tree's Nodes.
+	Node int
+
+	// Level is the level of the node, numbered from the bottom of the tree
+	// (so leaves are at level 0).
+	Level int
+
+	// BBox is the bounding box covering the node's entries.
+	BBox BBox
+
+	// Entries is the number of entries in the node.
+	Entries int
+
+	// Matched is the number of entries that overlap with the query. For
+	// non-leaf nodes, these are the children that were visited. For leaves,
+	// these are the items reported as candidates.
+	Matched int
+
+	// Pruned is the number of entries that were skipped, because they don't
+	// overlap with the query (or, for leaves, because the item was marked as
+	// deleted by MarkDeleted).
+	Pruned int
+}
+
+// Explain carries out a search for items overlapping with the bounding box
+// (like Search), recording the nodes that were visited and the pruning
+// decisions made. It always describes a traversal of the tree, even if
+// Search would scan the items of a small tree directly (see
+// RTree.LinearScanThreshold).
+func (t *RTree) Explain(bb BBox) ExplainResult {
+	res := ExplainResult{Query: bb}
+	if len(t.Nodes) == 0 {
+		return res
+	}
+	queries := t.Period.wrappedQueries(bb)
+	overlapsQuery := func(b BBox) bool {
+		for _, q := range queries {
+			if overlap(b, q) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var recurse func(n, level int, nodeBB BBox)
+	recurse = func(n, level int, nodeBB BBox) {
+		node := &t.Nodes[n]
+		res.Visited = append(res.Visited, ExplainNode{
+			Node:    n,
+			Level:   level,
+			BBox:    nodeBB,
+			Entries: len(node.Entries),
+		})
+		visit := len(res.Visited) - 1
+		for _, entry := range node.Entries {
+			if !overlapsQuery(entry.BBox) || (node.IsLeaf && t.isTombstoned(entry.Index)) {
+				res.Visited[visit].Pruned++
+				continue
+			}
+			res.Visited[visit].Matched++
+			if node.IsLeaf {
+				res.Candidates = append(res.Candidates, entry.Index)
+			} else {
+				recurse(entry.Index, level-1, entry.BBox)
+			}
+		}
+	}
+	recurse(t.RootIndex, t.height(), t.calculateBound(t.RootIndex))
+	return res
+}
+
+// String formats the result as an indented tree of the visited nodes,
+// followed by the number of candidates.
+func (r ExplainResult) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "query %v\n", r.Query)
+	if len(r.Visited) > 0 {
+		top := r.Visited[0].Level
+		for _, v := range r.Visited {
+			fmt.Fprintf(&sb, "%snode %d (level %d) bbox %v: %d entries, %d matched, %d pruned\n",
+				strings.Repeat("  ", top-v.Level+1), v.Node, v.Level, v.BBox, v.Entries, v.Matched, v.Pruned)
+		}
+	}
+	fmt.Fprintf(&sb, "%d candidates", len(r.Candidates))
+	return sb.String()
+}
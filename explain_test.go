@@ -0,0 +1,67 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 4)
+	var rt RTree
+	if res := rt.Explain(everywhere); len(res.Visited) != 0 || len(res.Candidates) != 0 {
+		t.Fatalf("expected nothing visited in empty tree, got %+v", res)
+	}
+	for i := 0; i < 300; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+	}
+	rt.MarkDeleted(1)
+
+	for i := 0; i < 20; i++ {
+		query := randomBox(rnd, 0.5, 0.5)
+		rt.Metrics = Metrics{Enabled: true}
+		var want []int
+		rt.Search(query, func(idx int) { want = append(want, idx) })
+		res := rt.Explain(query)
+
+		got := append([]int(nil), res.Candidates...)
+		sort.Ints(got)
+		sort.Ints(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got candidates %v want %v", got, want)
+		}
+		if len(res.Visited) != rt.Metrics.NodesVisited {
+			t.Errorf("visited %d nodes, search visited %d", len(res.Visited), rt.Metrics.NodesVisited)
+		}
+		if root := res.Visited[0]; root.Node != rt.RootIndex || root.Level != rt.height() {
+			t.Errorf("expected root to be visited first, got %+v", root)
+		}
+
+		// Each matched entry in a non-leaf leads to one visited node, and
+		// each matched entry in a leaf to one candidate.
+		var descents, reported int
+		for _, v := range res.Visited {
+			if v.Matched+v.Pruned != v.Entries {
+				t.Errorf("inconsistent decisions for node %d: %+v", v.Node, v)
+			}
+			if v.Node != rt.RootIndex && !overlap(v.BBox, query) {
+				t.Errorf("visited node %d doesn't overlap with the query", v.Node)
+			}
+			if v.Level == 0 {
+				reported += v.Matched
+			} else {
+				descents += v.Matched
+			}
+		}
+		if descents != len(res.Visited)-1 || reported != len(res.Candidates) {
+			t.Errorf("got %d descents and %d reported, for %d nodes and %d candidates",
+				descents, reported, len(res.Visited), len(res.Candidates))
+		}
+		if s := res.String(); !strings.HasPrefix(s, "query ") || strings.Count(s, "\n") != len(res.Visited)+1 {
+			t.Errorf("unexpected explain output:\n%s", s)
+		}
+	}
+}
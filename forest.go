@@ -0,0 +1,140 @@
+package rtree
+
+import (
+	"math"
+	"sort"
+)
+
+// Forest is a collection of named R-Trees (layers) that can be queried
+// together, e.g. the layers of a map. Layers can be individually disabled,
+// in which case they are skipped by queries. The zero value is an empty
+// forest.
+type Forest struct {
+	layers []forestLayer
+}
+
+type forestLayer struct {
+	name     string
+	tree     *RTree
+	disabled bool
+}
+
+// ForestItem identifies an item in a Forest by its layer name and data index.
+type ForestItem struct {
+	Layer string
+	Index int
+}
+
+// Layer gives the tree for the layer with the given name, creating a new
+// (empty and enabled) layer if it doesn't exist yet. The tree can be modified
+// directly.
+func (f *Forest) Layer(name string) *RTree {
+	if l := f.find(name); l != nil {
+		return l.tree
+	}
+	f.layers = append(f.layers, forestLayer{name: name, tree: new(RTree)})
+	return f.layers[len(f.layers)-1].tree
+}
+
+// RemoveLayer removes the layer with the given name. The return value
+// indicates if the layer existed.
+func (f *Forest) RemoveLayer(name string) bool {
+	for i := range f.layers {
+		if f.layers[i].name == name {
+			f.layers = append(f.layers[:i], f.layers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// LayerNames gives the names of the layers, in the order they were created.
+func (f *Forest) LayerNames() []string {
+	names := make([]string, len(f.layers))
+	for i, l := range f.layers {
+		names[i] = l.name
+	}
+	return names
+}
+
+// SetEnabled enables or disables the layer with the given name. Disabled
+// layers are skipped by Search and Nearest. The return value indicates if
+// the layer exists.
+func (f *Forest) SetEnabled(name string, enabled bool) bool {
+	l := f.find(name)
+	if l == nil {
+		return false
+	}
+	l.disabled = !enabled
+	return true
+}
+
+// Enabled checks if the layer with the given name exists and is enabled.
+func (f *Forest) Enabled(name string) bool {
+	l := f.find(name)
+	return l != nil && !l.disabled
+}
+
+func (f *Forest) find(name string) *forestLayer {
+	for i := range f.layers {
+		if f.layers[i].name == name {
+			return &f.layers[i]
+		}
+	}
+	return nil
+}
+
+// Search looks for any items in the enabled layers that overlap with the
+// given bounding box. The callback is called with the layer name and item
+// index for each found item. Layers are searched in the order they were
+// created.
+func (f *Forest) Search(bb BBox, callback func(layer string, index int)) {
+	for _, l := range f.layers {
+		if l.disabled {
+			continue
+		}
+		l.tree.Search(bb, func(index int) {
+			callback(l.name, index)
+		})
+	}
+}
+
+// Nearest gives (up to) the k items nearest to the point (x, y) across all
+// enabled layers, ordered from nearest to farthest. Distances are measured
+// in the same way as RTree.Nearest.
+func (f *Forest) Nearest(x, y float64, k int) []ForestItem {
+	if k <= 0 {
+		return nil
+	}
+	type candidate struct {
+		item  ForestItem
+		dist  float64
+		order int
+	}
+	var candidates []candidate
+	for _, l := range f.layers {
+		if l.disabled {
+			continue
+		}
+		var found int
+		l.tree.nearest(math.Inf(1), func(bb BBox) float64 {
+			return l.tree.pointDistance(x, y, bb)
+		}, nil, func(e Entry, d float64) bool {
+			candidates = append(candidates, candidate{ForestItem{l.name, e.Index}, d, len(candidates)})
+			found++
+			return found < k
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		return ci.dist < cj.dist || (ci.dist == cj.dist && ci.order < cj.order)
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	result := make([]ForestItem, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.item
+	}
+	return result
+}
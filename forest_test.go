@@ -0,0 +1,92 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestForest(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var f Forest
+	layers := []string{"roads", "buildings", "rivers"}
+	boxes := make(map[ForestItem]BBox)
+	for _, name := range layers {
+		tr := f.Layer(name)
+		for i := 0; i < 100; i++ {
+			bb := randomBox(rnd, 0.9, 0.1)
+			boxes[ForestItem{name, i}] = bb
+			tr.Insert(bb, i, ins)
+		}
+	}
+	if got := f.LayerNames(); !reflect.DeepEqual(got, layers) {
+		t.Fatalf("got layers %v", got)
+	}
+	if !f.SetEnabled("buildings", false) || f.Enabled("buildings") || !f.Enabled("roads") {
+		t.Fatal("unexpected enabled state")
+	}
+	if f.SetEnabled("missing", true) || f.Enabled("missing") {
+		t.Fatal("unexpected enabled state for missing layer")
+	}
+
+	less := func(items []ForestItem) func(i, j int) bool {
+		return func(i, j int) bool {
+			if items[i].Layer != items[j].Layer {
+				return items[i].Layer < items[j].Layer
+			}
+			return items[i].Index < items[j].Index
+		}
+	}
+	for i := 0; i < 10; i++ {
+		query := randomBox(rnd, 0.5, 0.5)
+		var got, want []ForestItem
+		f.Search(query, func(layer string, idx int) {
+			got = append(got, ForestItem{layer, idx})
+		})
+		for item, bb := range boxes {
+			if item.Layer != "buildings" && overlap(bb, query) {
+				want = append(want, item)
+			}
+		}
+		sort.Slice(got, less(got))
+		sort.Slice(want, less(want))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+
+	var rt RTree
+	for i := 0; i < 10; i++ {
+		x, y := rnd.Float64(), rnd.Float64()
+		got := f.Nearest(x, y, 7)
+		var all []ForestItem
+		for item := range boxes {
+			if item.Layer != "buildings" {
+				all = append(all, item)
+			}
+		}
+		sort.Slice(all, func(i, j int) bool {
+			return rt.pointDistance(x, y, boxes[all[i]]) < rt.pointDistance(x, y, boxes[all[j]])
+		})
+		if len(got) != 7 {
+			t.Fatalf("expected 7 results, got %v", got)
+		}
+		for j := range got {
+			if rt.pointDistance(x, y, boxes[got[j]]) != rt.pointDistance(x, y, boxes[all[j]]) {
+				t.Fatalf("got %v want %v", got, all[:7])
+			}
+		}
+	}
+
+	if !f.RemoveLayer("roads") || f.RemoveLayer("roads") {
+		t.Fatal("unexpected RemoveLayer result")
+	}
+	if got := f.LayerNames(); !reflect.DeepEqual(got, []string{"buildings", "rivers"}) {
+		t.Fatalf("got layers %v", got)
+	}
+}
@@ -0,0 +1,157 @@
+package rtree
+
+import (
+	"container/heap"
+	"math"
+)
+
+// FrozenRTree is an immutable R-Tree compiled for maximum search throughput.
+// All entries are stored in a single pointer-free slice, with the entries of
+// each node stored next to each other, and nodes laid out in depth-first
+// order so that a node's first child immediately follows it. Each entry
+// leading to a node records where that node's entries are, so searches
+// don't need any other lookups.
+//
+// Compared to PackedRTree (which is optimised for memory usage), a
+// FrozenRTree uses more memory but does less work per entry, and also
+// supports nearest neighbour queries.
+type FrozenRTree struct {
+	entries []frozenEntry
+	root    frozenRange
+	size    int
+}
+
+// frozenEntry is an entry in a FrozenRTree. For leaf entries, ref is the
+// data index and count is zero. Otherwise, the child node's entries are the
+// count entries starting at position ref.
+type frozenEntry struct {
+	bbox  BBox
+	ref   int
+	count int
+}
+
+type frozenRange struct {
+	start, count int
+}
+
+// Freeze compiles the tree into a FrozenRTree containing the same items.
+// Later modifications to the tree are not reflected in the frozen tree.
+// Items marked as deleted by MarkDeleted are left out.
+func (t *RTree) Freeze() *FrozenRTree {
+	f := new(FrozenRTree)
+	if len(t.Nodes) == 0 {
+		return f
+	}
+	f.root = f.emit(t, t.RootIndex)
+	return f
+}
+
+// emit appends the entries of node n (and then the nodes under it) to the
+// frozen tree, giving the range of n's entries. Nodes left empty by
+// tombstoned items aren't emitted.
+func (f *FrozenRTree) emit(t *RTree, n int) frozenRange {
+	node := &t.Nodes[n]
+	r := frozenRange{start: len(f.entries)}
+	for _, e := range node.Entries {
+		if node.IsLeaf && t.isTombstoned(e.Index) {
+			continue
+		}
+		f.entries = append(f.entries, frozenEntry{bbox: e.BBox, ref: e.Index})
+	}
+	r.count = len(f.entries) - r.start
+	if node.IsLeaf {
+		f.size += r.count
+		return r
+	}
+	kept := r.start
+	for i := r.start; i < r.start+r.count; i++ {
+		child := f.emit(t, f.entries[i].ref)
+		if child.count == 0 {
+			continue
+		}
+		f.entries[kept] = frozenEntry{bbox: f.entries[i].bbox, ref: child.start, count: child.count}
+		kept++
+	}
+	// Entries for empty children are dropped, shifting later entries of
+	// this node down. The gap left behind is never referenced.
+	r.count = kept - r.start
+	return r
+}
+
+// Len gives the number of items in the frozen tree.
+func (f *FrozenRTree) Len() int {
+	return f.size
+}
+
+// Search looks for any items in the tree that overlap with the given
+// bounding box. The callback is called with the data index for each found
+// item.
+func (f *FrozenRTree) Search(bb BBox, callback func(index int)) {
+	if f.root.count == 0 || bb.IsEmpty() {
+		return
+	}
+	stack := make([]frozenRange, 1, 32)
+	stack[0] = f.root
+	for len(stack) > 0 {
+		r := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, e := range f.entries[r.start : r.start+r.count] {
+			if e.bbox.MinX > bb.MaxX || e.bbox.MaxX < bb.MinX || e.bbox.MinY > bb.MaxY || e.bbox.MaxY < bb.MinY {
+				continue
+			}
+			if e.count == 0 {
+				callback(e.ref)
+			} else {
+				stack = append(stack, frozenRange{e.ref, e.count})
+			}
+		}
+	}
+}
+
+// Nearest gives the data indices of (up to) the k items nearest to the point
+// (x, y), ordered from nearest to farthest. The distance to an item is the
+// distance from the point to the closest point in the item's bounding box.
+func (f *FrozenRTree) Nearest(x, y float64, k int) []int {
+	if k <= 0 || f.root.count == 0 {
+		return nil
+	}
+	var result []int
+	var queue frozenQueue
+	push := func(r frozenRange) {
+		for _, e := range f.entries[r.start : r.start+r.count] {
+			dx := rangeDistance(x, e.bbox.MinX, e.bbox.MaxX)
+			dy := rangeDistance(y, e.bbox.MinY, e.bbox.MaxY)
+			heap.Push(&queue, frozenCandidate{math.Sqrt(dx*dx + dy*dy), e.ref, e.count})
+		}
+	}
+	push(f.root)
+	for queue.Len() > 0 && len(result) < k {
+		c := heap.Pop(&queue).(frozenCandidate)
+		if c.count == 0 {
+			result = append(result, c.ref)
+		} else {
+			push(frozenRange{c.ref, c.count})
+		}
+	}
+	return result
+}
+
+type frozenCandidate struct {
+	dist  float64
+	ref   int
+	count int
+}
+
+// frozenQueue is a min-heap of candidates ordered by distance.
+type frozenQueue []frozenCandidate
+
+func (q frozenQueue) Len() int            { return len(q) }
+func (q frozenQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q frozenQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *frozenQueue) Push(x interface{}) { *q = append(*q, x.(frozenCandidate)) }
+func (q *frozenQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
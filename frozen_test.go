@@ -0,0 +1,98 @@
+package rtree
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	for _, population := range []int{0, 1, 10, 500} {
+		t.Run(fmt.Sprintf("pop_%d", population), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(0))
+			ins, err := NewInsertionPolicy(2, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rt RTree
+			boxes := make([]BBox, population)
+			for i := range boxes {
+				boxes[i] = randomBox(rnd, 0.9, 0.1)
+				rt.Insert(boxes[i], i, ins)
+			}
+
+			// Tombstone a whole region, so that some nodes are empty.
+			for i, bb := range boxes {
+				if bb.MaxX < 0.3 {
+					rt.MarkDeleted(i)
+				}
+			}
+			f := rt.Freeze()
+			if got, want := f.Len(), population-rt.Tombstones(); got != want {
+				t.Errorf("got len %d, want %d", got, want)
+			}
+
+			// Modifications after freezing aren't reflected.
+			rt.Insert(BBox{0.5, 0.5, 0.5, 0.5}, population, ins)
+
+			for i := 0; i < 20; i++ {
+				query := randomBox(rnd, 0.8, 0.4)
+				var want []int
+				for j, bb := range boxes {
+					if overlap(bb, query) && bb.MaxX >= 0.3 {
+						want = append(want, j)
+					}
+				}
+				var got []int
+				f.Search(query, func(idx int) { got = append(got, idx) })
+				sort.Ints(got)
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("search %v: got %v, want %v", query, got, want)
+				}
+
+				x, y := rnd.Float64(), rnd.Float64()
+				gotNN := f.Nearest(x, y, 5)
+				rt.DeleteFunc(BBox{0.5, 0.5, 0.5, 0.5}, func(idx int) bool { return idx == population })
+				wantNN := rt.Nearest(x, y, 5)
+				rt.Insert(BBox{0.5, 0.5, 0.5, 0.5}, population, ins)
+				if len(gotNN) != len(wantNN) {
+					t.Fatalf("nearest: got %v, want %v", gotNN, wantNN)
+				}
+				for j := range gotNN {
+					gd := rt.pointDistance(x, y, boxes[gotNN[j]])
+					wd := rt.pointDistance(x, y, boxes[wantNN[j]])
+					if gd != wd {
+						t.Fatalf("nearest %d: got distance %v, want %v", j, gd, wd)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFrozenSearch(b *testing.B) {
+	rnd := rand.New(rand.NewSource(0))
+	items := make([]InsertItem, 100000)
+	for i := range items {
+		items[i] = InsertItem{BBox: randomBox(rnd, 0.999, 0.001), DataIndex: i}
+	}
+	policy, err := NewInsertionPolicy(4, 16)
+	if err != nil {
+		b.Fatal(err)
+	}
+	rt := BulkLoadWithPolicy(items, policy)
+	f := rt.Freeze()
+	query := BBox{0.4, 0.4, 0.41, 0.41}
+	b.Run("rtree", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rt.Search(query, func(int) {})
+		}
+	})
+	b.Run("frozen", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			f.Search(query, func(int) {})
+		}
+	})
+}
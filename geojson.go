@@ -0,0 +1,128 @@
+package rtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type geoJSONFeature struct {
+	Type     string           `json:"type"`
+	ID       interface{}      `json:"id"`
+	BBox     []float64        `json:"bbox"`
+	Geometry *geoJSONGeometry `json:"geometry"`
+}
+
+type geoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates interface{}       `json:"coordinates"`
+	Geometries  []geoJSONGeometry `json:"geometries"`
+}
+
+// BulkLoadGeoJSON bulk loads the features of a GeoJSON FeatureCollection (or
+// a single Feature) into a new R-Tree. The data index of each item is the
+// position of its feature within the collection. The bounding box of each
+// feature is given by its "bbox" member if it has one, and is otherwise
+// calculated from its geometry. Features without a geometry (or with an empty
+// geometry) and without a bbox member are skipped.
+//
+// The feature IDs are also returned, indexed by data index. The ID of a
+// feature without an "id" member is nil, otherwise it's a string or float64.
+// Errors caused by malformed input wrap ErrCorruptTree.
+func BulkLoadGeoJSON(r io.Reader) (RTree, []interface{}, error) {
+	var doc struct {
+		geoJSONFeature
+		Features []geoJSONFeature `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return RTree{}, nil, wrapJSONError(err, "decoding GeoJSON")
+	}
+
+	var features []geoJSONFeature
+	switch doc.Type {
+	case "FeatureCollection":
+		features = doc.Features
+	case "Feature":
+		features = []geoJSONFeature{doc.geoJSONFeature}
+	default:
+		return RTree{}, nil, fmt.Errorf("%w: GeoJSON type must be FeatureCollection or Feature, but is %q", ErrCorruptTree, doc.Type)
+	}
+
+	ids := make([]interface{}, len(features))
+	var items []InsertItem
+	for i, f := range features {
+		ids[i] = f.ID
+		bb, ok, err := geoJSONFeatureBBox(f)
+		if err != nil {
+			return RTree{}, nil, fmt.Errorf("GeoJSON feature %d: %w", i, err)
+		}
+		if ok {
+			items = append(items, InsertItem{BBox: bb, DataIndex: i})
+		}
+	}
+	return BulkLoad(items), ids, nil
+}
+
+func geoJSONFeatureBBox(f geoJSONFeature) (BBox, bool, error) {
+	switch len(f.BBox) {
+	case 0:
+	case 4:
+		return BBox{f.BBox[0], f.BBox[1], f.BBox[2], f.BBox[3]}, true, nil
+	case 6:
+		return BBox{f.BBox[0], f.BBox[1], f.BBox[3], f.BBox[4]}, true, nil
+	default:
+		return BBox{}, false, fmt.Errorf("%w: bbox has %d values", ErrCorruptTree, len(f.BBox))
+	}
+	if f.Geometry == nil {
+		return BBox{}, false, nil
+	}
+	bb := EmptyBBox
+	if err := extendByGeometry(&bb, *f.Geometry); err != nil {
+		return BBox{}, false, err
+	}
+	return bb, !bb.IsEmpty(), nil
+}
+
+// extendByGeometry extends the bounding box to cover the geometry.
+func extendByGeometry(bb *BBox, g geoJSONGeometry) error {
+	if g.Type == "GeometryCollection" {
+		for _, child := range g.Geometries {
+			if err := extendByGeometry(bb, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return extendByCoordinates(bb, g.Coordinates)
+}
+
+// extendByCoordinates extends the bounding box to cover a position, or
+// nested arrays of positions.
+func extendByCoordinates(bb *BBox, coords interface{}) error {
+	arr, ok := coords.([]interface{})
+	if !ok {
+		return fmt.Errorf("%w: coordinates must be an array", ErrCorruptTree)
+	}
+	if len(arr) == 0 {
+		return nil
+	}
+	if _, isNumber := arr[0].(float64); !isNumber {
+		for _, child := range arr {
+			if err := extendByCoordinates(bb, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(arr) < 2 {
+		return fmt.Errorf("%w: position must have at least 2 values", ErrCorruptTree)
+	}
+	x, okX := arr[0].(float64)
+	y, okY := arr[1].(float64)
+	if !okX || !okY {
+		return fmt.Errorf("%w: position values must be numbers", ErrCorruptTree)
+	}
+	*bb = combine(*bb, BBox{x, y, x, y})
+	return nil
+}
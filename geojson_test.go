@@ -0,0 +1,83 @@
+package rtree
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBulkLoadGeoJSON(t *testing.T) {
+	const input = `{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "id": "a", "geometry": {"type": "Point", "coordinates": [1, 2]}, "properties": {}},
+			{"type": "Feature", "id": 7, "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [4, 0], [4, 3], [0, 0]]]}},
+			{"type": "Feature", "geometry": null},
+			{"type": "Feature", "bbox": [10, 10, 0, 12, 12, 5], "geometry": {"type": "Point", "coordinates": [11, 11]}},
+			{"type": "Feature", "geometry": {"type": "GeometryCollection", "geometries": [
+				{"type": "Point", "coordinates": [-5, -5, 100]},
+				{"type": "LineString", "coordinates": [[-4, -6], [-3, -3]]}
+			]}},
+			{"type": "Feature", "geometry": {"type": "MultiPoint", "coordinates": []}}
+		]
+	}`
+	rt, ids, err := BulkLoadGeoJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, rt)
+	if want := []interface{}{"a", 7.0, nil, nil, nil, nil}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got ids %v want %v", ids, want)
+	}
+
+	for _, tc := range []struct {
+		query BBox
+		want  []int
+	}{
+		{BBox{-100, -100, 100, 100}, []int{0, 1, 3, 4}},
+		{BBox{1, 2, 1, 2}, []int{0, 1}},
+		{BBox{10, 10, 10, 10}, []int{3}},
+		{BBox{-3, -6, -3, -6}, []int{4}},
+	} {
+		var got []int
+		rt.Search(tc.query, func(idx int) { got = append(got, idx) })
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %v want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestBulkLoadGeoJSONSingleFeature(t *testing.T) {
+	rt, ids, err := BulkLoadGeoJSON(strings.NewReader(
+		`{"type": "Feature", "id": "x", "geometry": {"type": "Point", "coordinates": [1, 2]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []interface{}{"x"}) {
+		t.Errorf("unexpected ids %v", ids)
+	}
+	var got []int
+	rt.Search(BBox{1, 2, 1, 2}, func(idx int) { got = append(got, idx) })
+	if !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestBulkLoadGeoJSONErrors(t *testing.T) {
+	for _, input := range []string{
+		``,
+		`not json`,
+		`{"type": "Point", "coordinates": [1, 2]}`,
+		`{"type": "Feature", "bbox": [1, 2, 3], "geometry": null}`,
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1]}}`,
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": 1}}`,
+		`{"type": "Feature", "geometry": {"type": "Point", "coordinates": [1, "a"]}}`,
+	} {
+		if _, _, err := BulkLoadGeoJSON(strings.NewReader(input)); !errors.Is(err, ErrCorruptTree) {
+			t.Errorf("%s: expected ErrCorruptTree, got %v", input, err)
+		}
+	}
+}
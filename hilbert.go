@@ -0,0 +1,248 @@
+package rtree
+
+import "sort"
+
+// HilbertRTree is a Hilbert R-Tree. Each item is assigned a position along a
+// Hilbert curve (based on the centre of its bounding box), and the entries
+// of each node are kept ordered by that position. Rather than splitting an
+// overfull node in two, its entries are first redistributed with a
+// neighbouring sibling, and only once both are full are the two nodes split
+// into three. This gives nodes with higher utilisation and tighter bounds
+// than a regular R-Tree when items are continuously inserted.
+//
+// Only the maximum number of children of the insertion policy is used.
+//
+// The zero value is an empty tree.
+type HilbertRTree struct {
+	nodes []hilbertNode
+	root  int
+	size  int
+}
+
+type hilbertNode struct {
+	isLeaf  bool
+	entries []hilbertEntry
+}
+
+// hilbertEntry is an entry in a HilbertRTree node. For leaf entries, key is
+// the Hilbert value of the item. For non-leaf entries, key is the largest
+// Hilbert value in the child node's subtree.
+type hilbertEntry struct {
+	bbox  BBox
+	index int
+	key   uint64
+}
+
+// Len gives the number of items in the tree.
+func (t *HilbertRTree) Len() int {
+	return t.size
+}
+
+// Insert adds a new data item to the tree. It panics if the insertion policy
+// is the zero value.
+func (t *HilbertRTree) Insert(bb BBox, dataIndex int, policy InsertionPolicy) {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	t.size++
+	entry := hilbertEntry{bb, dataIndex, HilbertOf(bb, EmptyBBox)}
+	if len(t.nodes) == 0 {
+		t.nodes = append(t.nodes, hilbertNode{isLeaf: true})
+		t.root = 0
+	}
+
+	// Find the leaf containing the first entry with a larger Hilbert value,
+	// remembering the path taken.
+	path := []int{t.root}
+	for n := t.root; !t.nodes[n].isLeaf; {
+		entries := t.nodes[n].entries
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].key >= entry.key
+		})
+		if i == len(entries) {
+			i--
+		}
+		n = entries[i].index
+		path = append(path, n)
+	}
+
+	leaf := path[len(path)-1]
+	t.nodes[leaf].entries = insertHilbertEntry(t.nodes[leaf].entries, entry)
+	t.handleOverflow(path, policy)
+}
+
+// insertHilbertEntry inserts an entry into a slice of entries that are
+// ordered by key, keeping the order.
+func insertHilbertEntry(entries []hilbertEntry, e hilbertEntry) []hilbertEntry {
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].key > e.key
+	})
+	entries = append(entries, hilbertEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = e
+	return entries
+}
+
+// handleOverflow walks up the path from the leaf to the root, resolving
+// overfull nodes and updating the bounding boxes and keys of the parent
+// entries.
+func (t *HilbertRTree) handleOverflow(path []int, policy InsertionPolicy) {
+	for level := len(path) - 1; level >= 0; level-- {
+		n := path[level]
+		overfull := len(t.nodes[n].entries) > policy.maxChildren
+
+		if level == 0 {
+			if overfull {
+				// Split the root in two, and grow a new root above it.
+				entries := t.nodes[n].entries
+				sibling := t.newHilbertNode(t.nodes[n].isLeaf)
+				half := len(entries) / 2
+				t.nodes[sibling].entries = append([]hilbertEntry(nil), entries[half:]...)
+				t.nodes[n].entries = entries[:half:half]
+				t.nodes = append(t.nodes, hilbertNode{entries: []hilbertEntry{
+					t.hilbertParentEntry(n),
+					t.hilbertParentEntry(sibling),
+				}})
+				t.root = len(t.nodes) - 1
+			}
+			return
+		}
+
+		parent := path[level-1]
+		pos := t.childPosition(parent, n)
+		if overfull {
+			t.redistribute(parent, pos, policy)
+		} else {
+			t.nodes[parent].entries[pos] = t.hilbertParentEntry(n)
+		}
+	}
+}
+
+// redistribute resolves the overfull child at position pos of the parent
+// node. The entries of the child and a cooperating sibling are shared evenly
+// between them if they fit, otherwise a new sibling is created and the
+// entries are shared evenly between all three nodes.
+func (t *HilbertRTree) redistribute(parent, pos int, policy InsertionPolicy) {
+	parentEntries := t.nodes[parent].entries
+	first := pos
+	if pos+1 == len(parentEntries) {
+		first--
+	}
+	if first < 0 {
+		// The overfull node is an only child. Split it in two by sharing
+		// with a new, empty sibling.
+		n := parentEntries[pos].index
+		sibling := t.newHilbertNode(t.nodes[n].isLeaf)
+		t.shareHilbertEntries(parent, []int{n}, []int{n, sibling})
+		return
+	}
+
+	a := parentEntries[first].index
+	b := parentEntries[first+1].index
+	nodes := []int{a, b}
+	if len(t.nodes[a].entries)+len(t.nodes[b].entries) > 2*policy.maxChildren {
+		nodes = append(nodes, t.newHilbertNode(t.nodes[a].isLeaf))
+	}
+	t.shareHilbertEntries(parent, []int{a, b}, nodes)
+}
+
+// shareHilbertEntries pools the entries of the old nodes (which must be
+// consecutive children of the parent) and distributes them evenly and in
+// order between the new nodes. The parent's entries are updated to refer to
+// the new nodes.
+func (t *HilbertRTree) shareHilbertEntries(parent int, oldNodes, newNodes []int) {
+	var pool []hilbertEntry
+	for _, n := range oldNodes {
+		pool = append(pool, t.nodes[n].entries...)
+	}
+	for i, n := range newNodes {
+		lo := i * len(pool) / len(newNodes)
+		hi := (i + 1) * len(pool) / len(newNodes)
+		t.nodes[n].entries = append([]hilbertEntry(nil), pool[lo:hi]...)
+	}
+
+	pos := t.childPosition(parent, oldNodes[0])
+	var replacement []hilbertEntry
+	for _, n := range newNodes {
+		replacement = append(replacement, t.hilbertParentEntry(n))
+	}
+	entries := t.nodes[parent].entries
+	updated := append([]hilbertEntry(nil), entries[:pos]...)
+	updated = append(updated, replacement...)
+	updated = append(updated, entries[pos+len(oldNodes):]...)
+	t.nodes[parent].entries = updated
+}
+
+func (t *HilbertRTree) newHilbertNode(isLeaf bool) int {
+	t.nodes = append(t.nodes, hilbertNode{isLeaf: isLeaf})
+	return len(t.nodes) - 1
+}
+
+// childPosition gives the position of the entry in the parent that refers
+// to the child node.
+func (t *HilbertRTree) childPosition(parent, child int) int {
+	for i, e := range t.nodes[parent].entries {
+		if e.index == child {
+			return i
+		}
+	}
+	panic("rtree: child not found in parent")
+}
+
+// hilbertParentEntry gives the entry that a parent node should have for
+// node n.
+func (t *HilbertRTree) hilbertParentEntry(n int) hilbertEntry {
+	entries := t.nodes[n].entries
+	bb := entries[0].bbox
+	for _, e := range entries[1:] {
+		bb = combine(bb, e.bbox)
+	}
+	return hilbertEntry{bb, n, entries[len(entries)-1].key}
+}
+
+// Search looks for any items in the tree that overlap with the given
+// bounding box. The callback is called with the item index for each found
+// item.
+func (t *HilbertRTree) Search(bb BBox, callback func(index int)) {
+	if len(t.nodes) == 0 {
+		return
+	}
+	var recurse func(int)
+	recurse = func(n int) {
+		node := &t.nodes[n]
+		for _, e := range node.entries {
+			if !overlap(e.bbox, bb) {
+				continue
+			}
+			if node.isLeaf {
+				callback(e.index)
+			} else {
+				recurse(e.index)
+			}
+		}
+	}
+	recurse(t.root)
+}
+
+// hilbert gives the distance along a Hilbert curve covering a 2^32 by 2^32
+// grid to the cell at (x, y).
+func hilbert(x, y uint32) uint64 {
+	var d uint64
+	for s := uint32(1) << 31; s > 0; s >>= 1 {
+		var rx, ry uint32
+		if x&s != 0 {
+			rx = 1
+		}
+		if y&s != 0 {
+			ry = 1
+		}
+		d += uint64(s) * uint64(s) * uint64((3*rx)^ry)
+		if ry == 0 {
+			if rx == 1 {
+				x, y = ^x, ^y
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
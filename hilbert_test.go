@@ -0,0 +1,113 @@
+package rtree
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestHilbertCurveIsContinuous(t *testing.T) {
+	// Points on a coarse lattice should be visited in an order where each
+	// point is adjacent to the previous one.
+	const step = 1 << 28
+	type point struct{ x, y uint32 }
+	var pts []point
+	for i := uint32(0); i < 16; i++ {
+		for j := uint32(0); j < 16; j++ {
+			pts = append(pts, point{i * step, j * step})
+		}
+	}
+	sort.Slice(pts, func(i, j int) bool {
+		return hilbert(pts[i].x, pts[i].y) < hilbert(pts[j].x, pts[j].y)
+	})
+	for i := 1; i < len(pts); i++ {
+		dx := int64(pts[i].x) - int64(pts[i-1].x)
+		dy := int64(pts[i].y) - int64(pts[i-1].y)
+		if dx*dx+dy*dy != step*step {
+			t.Fatalf("non-adjacent consecutive points: %v %v", pts[i-1], pts[i])
+		}
+	}
+}
+
+func TestHilbertRTree(t *testing.T) {
+	for _, maxCapacity := range []int{2, 3, 4, 5, 20} {
+		ins, err := NewInsertionPolicy(maxCapacity/2, maxCapacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rnd := rand.New(rand.NewSource(0))
+		var tr HilbertRTree
+		var boxes []BBox
+		for i := 0; i < 500; i++ {
+			bb := randomBox(rnd, 0.9, 0.1)
+			boxes = append(boxes, bb)
+			tr.Insert(bb, i, ins)
+			checkHilbertInvariants(t, &tr, maxCapacity)
+		}
+		if tr.Len() != len(boxes) {
+			t.Fatalf("expected len %d, got %d", len(boxes), tr.Len())
+		}
+		for i := 0; i < 50; i++ {
+			query := randomBox(rnd, 0.5, 0.5)
+			var got, want []int
+			tr.Search(query, func(idx int) { got = append(got, idx) })
+			for j, bb := range boxes {
+				if overlap(bb, query) {
+					want = append(want, j)
+				}
+			}
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("max=%d: got %v want %v", maxCapacity, got, want)
+			}
+		}
+	}
+}
+
+// checkHilbertInvariants checks that node entries are ordered by key, that
+// parent entries have tight bounds and the largest key of their child, that
+// no nodes are overfull, and that all leaves are at the same depth.
+func checkHilbertInvariants(t *testing.T, tr *HilbertRTree, maxCapacity int) {
+	t.Helper()
+	leafDepth := -1
+	var recurse func(n, depth int)
+	recurse = func(n, depth int) {
+		node := tr.nodes[n]
+		if len(node.entries) == 0 || len(node.entries) > maxCapacity {
+			t.Fatalf("node %d has %d entries", n, len(node.entries))
+		}
+		for i := 1; i < len(node.entries); i++ {
+			if node.entries[i-1].key > node.entries[i].key {
+				t.Fatalf("node %d entries out of order", n)
+			}
+		}
+		if node.isLeaf {
+			if leafDepth == -1 {
+				leafDepth = depth
+			}
+			if depth != leafDepth {
+				t.Fatalf("leaves at different depths")
+			}
+			return
+		}
+		for _, e := range node.entries {
+			if want := tr.hilbertParentEntry(e.index); e != want {
+				t.Fatalf("parent entry %v doesn't match child %v", e, want)
+			}
+			recurse(e.index, depth+1)
+		}
+	}
+	recurse(tr.root, 0)
+}
+
+func TestHilbertRTreeZeroPolicy(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("expected panic with ErrInvalidPolicy, got %v", err)
+		}
+	}()
+	var rt HilbertRTree
+	rt.Insert(BBox{0, 0, 1, 1}, 0, InsertionPolicy{})
+}
@@ -0,0 +1,78 @@
+package rtree
+
+import "math"
+
+// DensityHistogram divides the extent into a grid of cols by rows cells, and
+// gives the number of items whose bounding box centres are in each cell.
+// The result is indexed by row then column, with row 0 and column 0 at the
+// minimum corner of the extent. Items with centres outside of the extent
+// aren't counted.
+//
+// The tree is only descended as deep as needed for the resolution of the
+// grid. Once a node's bounding box falls entirely inside a single cell, all
+// of its items must be in that cell, so the number of items under it is
+// taken from the node's aggregate rather than counted one by one. It gives
+// nil if the grid has no cells or the extent is empty.
+func (t *RTree) DensityHistogram(extent BBox, cols, rows int) [][]int {
+	if cols <= 0 || rows <= 0 || extent.MinX > extent.MaxX || extent.MinY > extent.MaxY {
+		return nil
+	}
+	grid := make([][]int, rows)
+	for i := range grid {
+		grid[i] = make([]int, cols)
+	}
+	if len(t.Nodes) == 0 {
+		return grid
+	}
+
+	cell := func(x, y float64) (int, int, bool) {
+		if x < extent.MinX || x > extent.MaxX || y < extent.MinY || y > extent.MaxY {
+			return 0, 0, false
+		}
+		return gridPosition(x, extent.MinX, extent.MaxX, cols),
+			gridPosition(y, extent.MinY, extent.MaxY, rows), true
+	}
+
+	// Aggregates include tombstoned items, so can only be used if there
+	// aren't any.
+	useAggregates := len(t.tombstones) == 0
+	var recurse func(n int)
+	recurse = func(n int) {
+		node := &t.Nodes[n]
+		for _, e := range node.Entries {
+			if !overlap(e.BBox, extent) {
+				continue
+			}
+			if node.IsLeaf {
+				if t.isTombstoned(e.Index) {
+					continue
+				}
+				x, y := e.BBox.Center()
+				if col, row, ok := cell(x, y); ok {
+					grid[row][col]++
+				}
+				continue
+			}
+			c0, r0, ok0 := cell(e.BBox.MinX, e.BBox.MinY)
+			c1, r1, ok1 := cell(e.BBox.MaxX, e.BBox.MaxY)
+			if useAggregates && ok0 && ok1 && c0 == c1 && r0 == r1 {
+				grid[r0][c0] += t.Nodes[e.Index].Aggregate.Count
+			} else {
+				recurse(e.Index)
+			}
+		}
+	}
+	recurse(t.RootIndex)
+	return grid
+}
+
+// gridPosition gives the position of the cell containing v, when the range
+// [lo, hi] is divided into n cells. Values on the boundary between cells
+// are in the higher cell, except for hi itself.
+func gridPosition(v, lo, hi float64, n int) int {
+	if hi <= lo {
+		return 0
+	}
+	i := int(math.Floor((v - lo) / (hi - lo) * float64(n)))
+	return min(max(i, 0), n-1)
+}
@@ -0,0 +1,83 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestDensityHistogram(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 4, 8)
+	var rt RTree
+	if got := rt.DensityHistogram(BBox{0, 0, 1, 1}, 0, 2); got != nil {
+		t.Errorf("expected nil for empty grid, got %v", got)
+	}
+	if got := rt.DensityHistogram(BBox{0, 0, 1, 1}, 2, 2); len(got) != 2 || got[1][1] != 0 {
+		t.Errorf("expected empty grid for empty tree, got %v", got)
+	}
+
+	boxes := make([]BBox, 20000)
+	items := make([]InsertItem, len(boxes))
+	for i := range boxes {
+		// Half of the items are clustered in the bottom left quadrant.
+		maxStart := 1.0
+		if i%2 == 0 {
+			maxStart = 0.5
+		}
+		boxes[i] = randomBox(rnd, maxStart, 0.002)
+		items[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+		rt.Insert(boxes[i], i, policy)
+	}
+	deleted := make(map[int]bool)
+	exact := func(extent BBox, cols, rows int) [][]int {
+		grid := make([][]int, rows)
+		for i := range grid {
+			grid[i] = make([]int, cols)
+		}
+		for i, bb := range boxes {
+			x, y := bb.Center()
+			if deleted[i] || x < extent.MinX || x > extent.MaxX || y < extent.MinY || y > extent.MaxY {
+				continue
+			}
+			col := gridPosition(x, extent.MinX, extent.MaxX, cols)
+			row := gridPosition(y, extent.MinY, extent.MaxY, rows)
+			grid[row][col]++
+		}
+		return grid
+	}
+
+	bulk := BulkLoad(items)
+	for name, tr := range map[string]*RTree{"insert": &rt, "bulk": &bulk} {
+		for _, tc := range []struct {
+			extent     BBox
+			cols, rows int
+		}{
+			{BBox{0, 0, 1.002, 1.002}, 2, 2},
+			{BBox{0, 0, 1.002, 1.002}, 8, 4},
+			{BBox{0.25, 0.25, 0.75, 0.75}, 5, 5},
+			{BBox{0, 0, 1.002, 1.002}, 1000, 1000},
+		} {
+			got := tr.DensityHistogram(tc.extent, tc.cols, tc.rows)
+			if want := exact(tc.extent, tc.cols, tc.rows); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %v %dx%d: got %v want %v", name, tc.extent, tc.cols, tc.rows, got, want)
+			}
+		}
+	}
+
+	// The clustered quadrant should be clearly denser.
+	grid := rt.DensityHistogram(BBox{0, 0, 1.002, 1.002}, 2, 2)
+	if grid[0][0] < 2*grid[1][1] {
+		t.Errorf("expected bottom left quadrant to be denser, got %v", grid)
+	}
+
+	// Items marked as deleted aren't counted.
+	for i := 0; i < len(boxes); i += 3 {
+		rt.MarkDeleted(i)
+		deleted[i] = true
+	}
+	got := rt.DensityHistogram(BBox{0, 0, 1.002, 1.002}, 2, 2)
+	if want := exact(BBox{0, 0, 1.002, 1.002}, 2, 2); !reflect.DeepEqual(got, want) {
+		t.Errorf("with tombstones: got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package rtree
+
+// Hooks holds optional callbacks that are called as an RTree is modified.
+// They allow embedders to maintain derived structures (such as caches,
+// counters or persistent copies) in lockstep with the tree. Any of the
+// callbacks may be nil.
+//
+// The callbacks are called during the modification, so they must not modify
+// the tree (or rely on its nodes being in a consistent state).
+type Hooks struct {
+	// OnInsert is called when an item is added to the tree.
+	OnInsert func(e Entry)
+
+	// OnDelete is called when an item is removed from the tree. Items
+	// marked as deleted by MarkDeleted are reported once they're removed by
+	// Vacuum.
+	//
+	// Modifications that change the bounding box of an existing item (such
+	// as Move, Adjust, Translate and Transform) report the item's deletion
+	// followed by its insertion with the new bounding box.
+	OnDelete func(e Entry)
+
+	// OnSplit is called after an overfull node has been split. The node
+	// keeps some of its entries, and the rest are moved to newNode.
+	OnSplit func(node, newNode int)
+
+	// OnRootGrow is called when the root node is split, and a new root is
+	// created with the two halves of the old root as its children. When
+	// Apply divides an overfull root between more than two nodes, the new
+	// root also gets the remaining nodes as children (each of which is
+	// first reported by OnSplit).
+	OnRootGrow func(oldRoot, sibling, newRoot int)
+}
+
+func (t *RTree) hookInsert(e Entry) {
+	if t.Hooks.OnInsert != nil {
+		t.Hooks.OnInsert(e)
+	}
+}
+
+func (t *RTree) hookDelete(e Entry) {
+	if t.Hooks.OnDelete != nil {
+		t.Hooks.OnDelete(e)
+	}
+}
+
+// hookMove reports that an item has moved to a new bounding box.
+func (t *RTree) hookMove(old Entry, newBB BBox) {
+	t.hookDelete(old)
+	e := old
+	e.BBox = newBB
+	t.hookInsert(e)
+}
+
+// hookAllItems reports that every item in the tree is about to be moved to
+// the bounding box given by fn, or removed if fn is nil.
+func (t *RTree) hookAllItems(fn func(BBox) BBox) {
+	if t.Hooks.OnInsert == nil && t.Hooks.OnDelete == nil {
+		return
+	}
+	for _, node := range t.Nodes {
+		if !node.IsLeaf {
+			continue
+		}
+		for _, e := range node.Entries {
+			if fn == nil {
+				t.hookDelete(e)
+			} else {
+				t.hookMove(e, fn(e.BBox))
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 4)
+	var rt RTree
+
+	// Maintain a copy of the items using only the hooks.
+	derived := make(map[int]BBox)
+	var splits, grows int
+	rt.Hooks = Hooks{
+		OnInsert: func(e Entry) {
+			if _, ok := derived[e.Index]; ok {
+				t.Fatalf("item %d inserted twice", e.Index)
+			}
+			derived[e.Index] = e.BBox
+		},
+		OnDelete: func(e Entry) {
+			if bb, ok := derived[e.Index]; !ok || bb != e.BBox {
+				t.Fatalf("unexpected deletion of item %d %v", e.Index, e.BBox)
+			}
+			delete(derived, e.Index)
+		},
+		OnSplit:    func(node, newNode int) { splits++ },
+		OnRootGrow: func(oldRoot, sibling, newRoot int) { grows++ },
+	}
+	check := func() {
+		t.Helper()
+		items := make(map[int]BBox)
+		for idx, bb := range rt.All() {
+			items[idx] = bb
+		}
+		if !reflect.DeepEqual(items, derived) {
+			t.Fatalf("derived items don't match tree: got %d items want %d", len(derived), len(items))
+		}
+	}
+
+	next := 0
+	for i := 0; i < 500; i++ {
+		switch rnd.Intn(6) {
+		case 0, 1:
+			rt.Insert(randomBox(rnd, 0.9, 0.1), next, policy)
+			next++
+		case 2:
+			rt.DeleteFunc(randomBox(rnd, 0.5, 0.1), func(int) bool { return true })
+		case 3:
+			rt.Move(rnd.Intn(next+1), randomBox(rnd, 0.9, 0.1), policy)
+		case 4:
+			rt.Adjust(rnd.Intn(next+1), randomBox(rnd, 0.9, 0.1), policy)
+		case 5:
+			var b Batch
+			b.Insert(randomBox(rnd, 0.9, 0.1), next)
+			b.Insert(randomBox(rnd, 0.9, 0.1), next+1)
+			next += 2
+			rt.Apply(b, policy)
+		}
+	}
+	check()
+	if splits == 0 || grows == 0 {
+		t.Errorf("expected splits and root growth, got %d splits and %d grows", splits, grows)
+	}
+	if grows < rt.height() {
+		t.Errorf("expected root to grow at least %d times, got %d", rt.height(), grows)
+	}
+
+	rt.MarkDeleted(0)
+	check()
+	rt.Vacuum()
+	check()
+	rt.Translate(1, 2)
+	check()
+	rt.Transform(0, -1, 1, 0, 0, 0)
+	check()
+	rt.Clear()
+	if len(derived) != 0 {
+		t.Errorf("expected clear to delete all items, %d remain", len(derived))
+	}
+}
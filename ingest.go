@@ -0,0 +1,146 @@
+package rtree
+
+import "sync"
+
+// Ingester buffers insertions into an RTree, and applies them in bulk. When
+// the buffer is flushed, the buffered items are divided into spatially
+// compact groups, a subtree is bulk loaded from each group in parallel, and
+// the subtrees are then grafted into the tree at the level matching their
+// height. This sustains much higher insertion rates than inserting items one
+// at a time, at the cost of items not being visible in the tree until the
+// buffer is flushed.
+//
+// An Ingester must not be used concurrently, and the tree must not be
+// modified by anything else until the Ingester has been flushed.
+type Ingester struct {
+	tree      *RTree
+	policy    InsertionPolicy
+	workers   int
+	batchSize int
+	buf       []InsertItem
+}
+
+// NewIngester creates an Ingester that inserts items into the tree using the
+// insertion policy. Subtrees are built using up to the given number of
+// workers (goroutines), and the buffer is flushed automatically whenever it
+// holds batchSize items. If workers or batchSize are less than 1, then 1 is
+// used instead. An error is returned if the policy is the zero value.
+func NewIngester(tree *RTree, policy InsertionPolicy, workers, batchSize int) (*Ingester, error) {
+	if err := policy.check(); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &Ingester{
+		tree:      tree,
+		policy:    policy,
+		workers:   workers,
+		batchSize: batchSize,
+	}, nil
+}
+
+// Insert buffers a new data item for insertion into the tree. Like
+// RTree.Insert, it panics if the insertion policy rejects the bounding box.
+func (in *Ingester) Insert(bb BBox, dataIndex int) {
+	e := Entry{BBox: bb, Index: dataIndex}
+	place, err := in.tree.admit(&e, in.policy)
+	if err != nil {
+		panic(err)
+	}
+	if !place {
+		return
+	}
+	in.buf = append(in.buf, InsertItem{BBox: e.BBox, DataIndex: e.Index})
+	if len(in.buf) >= in.batchSize {
+		in.Flush()
+	}
+}
+
+// Flush inserts all buffered items into the tree.
+func (in *Ingester) Flush() {
+	if len(in.buf) == 0 {
+		return
+	}
+	groups := in.workers
+	leafMax := in.policy.forNode(true).maxChildren
+	if max := (len(in.buf) + leafMax - 1) / leafMax; groups > max {
+		groups = max
+	}
+	parts := bulkPartition(in.buf, groups)
+	subtrees := make([]RTree, len(parts))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func(i int, part []InsertItem) {
+			defer wg.Done()
+			subtrees[i] = BulkLoadWithPolicy(part, in.policy)
+		}(i, part)
+	}
+	wg.Wait()
+	for _, sub := range subtrees {
+		in.tree.graft(sub, in.policy)
+	}
+	in.buf = in.buf[:0]
+}
+
+// graft adds all of the nodes of another tree to this tree. The other tree's
+// root is inserted as an entry at the level matching its height, and is
+// broken up into its children if it's too tall or too small to fit.
+func (t *RTree) graft(sub RTree, policy InsertionPolicy) {
+	if len(sub.Nodes) == 0 || len(sub.Nodes[sub.RootIndex].Entries) == 0 {
+		return
+	}
+	t.generation++
+	for _, node := range sub.Nodes {
+		if node.IsLeaf {
+			for _, e := range node.Entries {
+				t.setLookup(e.Index, e.BBox)
+				t.recordJournal(e, true)
+				t.hookInsert(e)
+			}
+		}
+	}
+	if len(t.Nodes) == 0 || len(t.Nodes[t.RootIndex].Entries) == 0 {
+		t.Nodes = append(t.Nodes[:0], sub.Nodes...)
+		t.RootIndex = sub.RootIndex
+		t.invalidateHint()
+		return
+	}
+
+	// The nodes' entries are shared with the other tree, so its height must
+	// be found before they're renumbered.
+	height := sub.height()
+	offset := len(t.Nodes)
+	for _, node := range sub.Nodes {
+		if !node.IsLeaf {
+			for i := range node.Entries {
+				node.Entries[i].Index += offset
+			}
+		}
+		t.Nodes = append(t.Nodes, node)
+	}
+
+	root := sub.RootIndex + offset
+	dead := make([]bool, len(t.Nodes))
+	var orphans []orphan
+	if len(t.Nodes[root].Entries) < policy.forNode(height == 0).minChildren {
+		orphans = t.dissolveNode(root, height, ReinsertAtOriginalLevel, dead, nil)
+	} else {
+		orphans = []orphan{{
+			entry: Entry{
+				BBox:  t.calculateBound(root),
+				Index: root,
+			},
+			height: height + 1,
+		}}
+	}
+	t.reinsertOrphans(orphans, dead, policy)
+	for len(dead) < len(t.Nodes) {
+		dead = append(dead, false)
+	}
+	t.compactNodes(dead)
+}
@@ -0,0 +1,84 @@
+package rtree
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestIngester(t *testing.T) {
+	for _, existing := range []int{0, 3, 500} {
+		for _, batch := range []int{1, 7, 100, 10000} {
+			t.Run(fmt.Sprintf("existing_%d_batch_%d", existing, batch), func(t *testing.T) {
+				rnd := rand.New(rand.NewSource(0))
+				ins, err := NewInsertionPolicy(2, 6)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var rt RTree
+				rt.EnableLookup()
+				var boxes []BBox
+				for i := 0; i < existing; i++ {
+					bb := randomBox(rnd, 0.9, 0.1)
+					rt.Insert(bb, len(boxes), ins)
+					boxes = append(boxes, bb)
+				}
+
+				in, err := NewIngester(&rt, ins, 4, batch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < 1000; i++ {
+					bb := randomBox(rnd, 0.9, 0.1)
+					in.Insert(bb, len(boxes))
+					boxes = append(boxes, bb)
+				}
+				in.Flush()
+				in.Flush()
+
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+				for i, bb := range boxes {
+					if got, ok := rt.BBoxOf(i); !ok || got != bb {
+						t.Fatalf("BBoxOf(%d) = %v, %t", i, got, ok)
+					}
+				}
+
+				// All leaves should still be at the same depth.
+				leafDepth := -1
+				var recurse func(n, depth int)
+				recurse = func(n, depth int) {
+					if rt.Nodes[n].IsLeaf {
+						if leafDepth != -1 && depth != leafDepth {
+							t.Fatalf("leaves at depths %d and %d", leafDepth, depth)
+						}
+						leafDepth = depth
+						return
+					}
+					for _, e := range rt.Nodes[n].Entries {
+						recurse(e.Index, depth+1)
+					}
+				}
+				recurse(rt.RootIndex, 0)
+
+				// The tree can continue to be modified as usual.
+				rt.DeleteFunc(BBox{0, 0, 0.5, 0.5}, func(int) bool { return true })
+				for i, bb := range boxes {
+					if overlap(bb, BBox{0, 0, 0.5, 0.5}) {
+						boxes[i] = BBox{-5, -5, -4, -4}
+					}
+				}
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+			})
+		}
+	}
+}
+
+func TestIngesterZeroPolicy(t *testing.T) {
+	var rt RTree
+	if _, err := NewIngester(&rt, InsertionPolicy{}, 4, 100); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("got %v, want ErrInvalidPolicy", err)
+	}
+}
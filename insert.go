@@ -1,63 +1,292 @@
 package rtree
 
 import (
-	"errors"
+	"fmt"
 	"math"
 	"math/bits"
 )
 
 // NewInsertionPolicy creates a new insertion policy with the given node size
-// parameters.
+// parameters. The minimum number of children must be at least 1, and the
+// maximum number of children must be at least 2. There is no upper limit on
+// the maximum number of children.
 func NewInsertionPolicy(minChildren, maxChildren int) (InsertionPolicy, error) {
+	if minChildren < 1 {
+		return InsertionPolicy{}, fmt.Errorf("%w: min children must be at least 1", ErrInvalidPolicy)
+	}
+	if maxChildren < 2 {
+		return InsertionPolicy{}, fmt.Errorf("%w: max children must be at least 2", ErrInvalidPolicy)
+	}
 	if minChildren > maxChildren/2 {
-		return InsertionPolicy{}, errors.New("min children must be less than or equal to half of the max children")
+		return InsertionPolicy{}, fmt.Errorf("%w: min children must be less than or equal to half of the max children", ErrInvalidPolicy)
+	}
+	return InsertionPolicy{
+		minChildren: minChildren,
+		maxChildren: maxChildren,
+		leafMin:     minChildren,
+		leafMax:     maxChildren,
+	}, nil
+}
+
+// WithLeafCapacity gives a copy of the policy that uses different node size
+// parameters for leaf nodes. The parameters given to NewInsertionPolicy then
+// only apply to non-leaf nodes. The parameters are validated in the same way
+// as by NewInsertionPolicy.
+//
+// Separate capacities are used by RTree (including its bulk loaders,
+// Ingester and PointTree) and MovingRTree. Other tree types use the non-leaf capacities for
+// all nodes.
+func (p InsertionPolicy) WithLeafCapacity(minChildren, maxChildren int) (InsertionPolicy, error) {
+	leaf, err := NewInsertionPolicy(minChildren, maxChildren)
+	if err != nil {
+		return InsertionPolicy{}, err
+	}
+	p.leafMin, p.leafMax = leaf.minChildren, leaf.maxChildren
+	return p, nil
+}
+
+// check gives an error if the policy is the zero value (rather than having
+// been created by NewInsertionPolicy), which would otherwise cause nodes to
+// be split endlessly.
+func (p InsertionPolicy) check() error {
+	if p.maxChildren == 0 {
+		return fmt.Errorf("%w: zero value insertion policy (use NewInsertionPolicy)", ErrInvalidPolicy)
+	}
+	return nil
+}
+
+// forNode gives a copy of the policy whose minChildren and maxChildren are
+// the parameters for either leaf nodes or non-leaf nodes.
+func (p InsertionPolicy) forNode(isLeaf bool) InsertionPolicy {
+	if isLeaf && p.leafMax != 0 {
+		p.minChildren, p.maxChildren = p.leafMin, p.leafMax
 	}
-	return InsertionPolicy{minChildren, maxChildren}, nil
+	return p
 }
 
 // InsertionPolicy alters the behaviour when inserting new data to an RTree.
 type InsertionPolicy struct {
 	minChildren int
 	maxChildren int
+	leafMin     int
+	leafMax     int
+	nonFinite   NonFiniteHandling
+	gridCell    float64
+}
+
+// WithNonFiniteHandling gives a copy of the policy that handles bounding
+// boxes containing NaN or infinite coordinates in the given way.
+func (p InsertionPolicy) WithNonFiniteHandling(h NonFiniteHandling) InsertionPolicy {
+	p.nonFinite = h
+	return p
+}
+
+// WithGridSnapping gives a copy of the policy that snaps the coordinates of
+// inserted bounding boxes outwards to multiples of the cell size (minimums
+// are rounded down, and maximums up). The snapped box always contains the
+// original, so any search that overlaps the original box still finds the
+// item. This suits data that is nearly grid-aligned anyway, since snapped
+// boxes with identical or adjacent coordinates combine into tighter nodes,
+// and serialize more compactly. A cell size of zero turns snapping off. It
+// returns an error wrapping ErrInvalidPolicy if the cell size is negative,
+// infinite or NaN.
+func (p InsertionPolicy) WithGridSnapping(cell float64) (InsertionPolicy, error) {
+	if !(cell >= 0) || math.IsInf(cell, 1) {
+		return InsertionPolicy{}, fmt.Errorf("%w: grid cell size must be finite and not negative", ErrInvalidPolicy)
+	}
+	p.gridCell = cell
+	return p, nil
+}
+
+// snap expands the bounding box outwards to the policy's grid. Non-finite
+// coordinates (and those that can't be snapped without overflowing) are
+// left as they are.
+func (p InsertionPolicy) snap(bb BBox) BBox {
+	if p.gridCell == 0 {
+		return bb
+	}
+	return BBox{
+		MinX: snapDown(bb.MinX, p.gridCell),
+		MinY: snapDown(bb.MinY, p.gridCell),
+		MaxX: snapUp(bb.MaxX, p.gridCell),
+		MaxY: snapUp(bb.MaxY, p.gridCell),
+	}
+}
+
+// snapDown gives the largest multiple of the cell size that isn't greater
+// than v. The result is checked against v, since rounding errors in v/cell
+// can otherwise give a multiple just above it.
+func snapDown(v, cell float64) float64 {
+	snapped := math.Floor(v/cell) * cell
+	if snapped > v {
+		snapped -= cell
+	}
+	if math.IsInf(snapped, 0) || math.IsNaN(snapped) {
+		return v
+	}
+	return snapped
+}
+
+// snapUp gives the smallest multiple of the cell size that isn't less than
+// v.
+func snapUp(v, cell float64) float64 {
+	snapped := math.Ceil(v/cell) * cell
+	if snapped < v {
+		snapped += cell
+	}
+	if math.IsInf(snapped, 0) || math.IsNaN(snapped) {
+		return v
+	}
+	return snapped
 }
 
-// Insert adds a new data item to the RTree.
+// Insert adds a new data item to the RTree. It panics if the insertion policy
+// is the zero value, or if it rejects the bounding box (TryInsert returns an
+// error instead).
 func (t *RTree) Insert(bb BBox, dataIndex int, policy InsertionPolicy) {
-	if len(t.Nodes) == 0 {
-		t.Nodes = append(t.Nodes, Node{IsLeaf: true, Entries: nil, Parent: -1})
-		t.RootIndex = 0
+	if err := t.insertEntry(Entry{BBox: bb, Index: dataIndex}, policy); err != nil {
+		panic(err)
 	}
+}
 
-	leaf := t.chooseLeafNode(bb)
-	t.Nodes[leaf].Entries = append(t.Nodes[leaf].Entries, Entry{BBox: bb, Index: dataIndex})
+// TryInsert adds a new data item to the RTree. It returns an error wrapping
+// ErrInvalidPolicy if the insertion policy is the zero value, and an error
+// wrapping ErrInvalidBBox if the bounding box is rejected by the insertion
+// policy or is malformed (see BBox.Validate). Bounding boxes with NaN
+// coordinates are only considered malformed if the policy uses
+// NonFiniteAllow, since the other modes deal with them. The tree is left
+// unchanged when an error is returned.
+func (t *RTree) TryInsert(bb BBox, dataIndex int, policy InsertionPolicy) error {
+	if err := checkInsert(bb, policy); err != nil {
+		return err
+	}
+	return t.insertEntry(Entry{BBox: bb, Index: dataIndex}, policy)
+}
 
-	current := leaf
-	for current != t.RootIndex {
-		parent := t.Nodes[current].Parent
-		for i := range t.Nodes[parent].Entries {
-			e := &t.Nodes[parent].Entries[i]
-			if e.Index == current {
-				e.BBox = combine(e.BBox, bb)
-				break
-			}
-		}
-		current = parent
+// checkInsert gives the error that TryInsert returns for the bounding box
+// and insertion policy, if any.
+func checkInsert(bb BBox, policy InsertionPolicy) error {
+	if err := policy.check(); err != nil {
+		return err
 	}
+	if isFinite(bb) || policy.nonFinite == NonFiniteAllow {
+		return bb.Validate()
+	}
+	if policy.nonFinite == NonFiniteReject {
+		return nonFiniteError(bb)
+	}
+	return nil
+}
 
-	if len(t.Nodes[leaf].Entries) <= policy.maxChildren {
+// InsertWithPayload adds a new data item to the RTree, storing the payload
+// alongside it. The payload can be retrieved using SearchWithPayload. It
+// panics if the insertion policy rejects the bounding box.
+func (t *RTree) InsertWithPayload(bb BBox, dataIndex int, payload uint64, policy InsertionPolicy) {
+	if err := t.insertEntry(Entry{BBox: bb, Index: dataIndex, Payload: payload}, policy); err != nil {
+		panic(err)
+	}
+}
+
+func (t *RTree) insertEntry(entry Entry, policy InsertionPolicy) error {
+	if err := policy.check(); err != nil {
+		return err
+	}
+	place, err := t.admit(&entry, policy)
+	if err != nil || !place {
+		return err
+	}
+	path := t.placeEntry(entry, policy)
+	t.splitOverfull(path, policy)
+	return nil
+}
+
+// nodeStep is a node on a path descending from the root, along with the
+// position of the entry in the node that leads to the next node on the path.
+// The entry position is unused for the last node on a path, unless the path
+// leads to a particular leaf entry.
+//
+// Paths are used instead of links from nodes to their parents. They're only
+// valid until the nodes on them are split or renumbered.
+type nodeStep struct {
+	node  int
+	entry int
+}
+
+// rootPath gives a path consisting of only the root. The path's storage is
+// reused by the next call, so only one such path may be in use at a time.
+func (t *RTree) rootPath() []nodeStep {
+	return append(t.pathBuf[:0], nodeStep{node: t.RootIndex, entry: -1})
+}
+
+// parentEntry gives the entry leading to the last node on a path. The path
+// must contain at least 2 nodes.
+func (t *RTree) parentEntry(path []nodeStep) *Entry {
+	step := path[len(path)-2]
+	return &t.Nodes[step.node].Entries[step.entry]
+}
+
+// splitOverfull splits the last node on the path if it has more entries than
+// the policy allows, propagating the split up the path.
+func (t *RTree) splitOverfull(path []nodeStep, policy InsertionPolicy) {
+	n := path[len(path)-1].node
+	if len(t.Nodes[n].Entries) <= policy.forNode(t.Nodes[n].IsLeaf).maxChildren {
 		return
 	}
+	nn := t.splitNode(n, policy)
+	if root1, root2 := t.adjustTree(path, nn, policy); root2 != -1 {
+		t.joinRoots(root1, root2, policy)
+	}
+}
 
-	newNode := t.splitNode(leaf, policy)
-	root1, root2 := t.adjustTree(leaf, newNode, policy)
+// placeEntry adds a new entry to the most suitable leaf, and enlarges the
+// bounding boxes of the leaf's ancestors to fit it. The leaf isn't split if
+// it becomes overfull. The path to the leaf is returned.
+func (t *RTree) placeEntry(entry Entry, policy InsertionPolicy) []nodeStep {
+	t.generation++
+	t.recordJournal(entry, true)
+	t.hookInsert(entry)
+	if len(t.Nodes) == 0 {
+		t.RootIndex = t.appendNode(Node{IsLeaf: true, Entries: nil}, policy)
+	}
+	return t.placeEntryBelow(t.hintStart(entry.BBox), entry)
+}
+
+// placeEntryBelow is like placeEntry, but only considers leaves under the
+// last node on the path (which must start at the root).
+func (t *RTree) placeEntryBelow(path []nodeStep, entry Entry) []nodeStep {
+	path = t.chooseLeafNode(path, entry.BBox)
+	t.pathBuf = path[:0]
+	leaf := path[len(path)-1].node
+	t.recordHint(path)
+	if t.Tracer != nil {
+		t.Tracer.ChoseLeaf(entry.BBox, entry.Index, leaf)
+	}
+	oldCap := cap(t.Nodes[leaf].Entries)
+	t.Nodes[leaf].Entries = append(t.Nodes[leaf].Entries, entry)
+	t.Metrics.countEntryGrowth(oldCap, cap(t.Nodes[leaf].Entries))
+	t.setLookup(entry.Index, entry.BBox)
 
-	if root2 != -1 {
-		t.joinRoots(root1, root2)
+	agg := weightAggregate(t.weights[entry.Index])
+	tags := t.tags[entry.Index]
+	if len(t.Nodes[leaf].Entries) == 1 {
+		t.Nodes[leaf].Aggregate = agg
+	} else {
+		t.Nodes[leaf].Aggregate = t.Nodes[leaf].Aggregate.combine(agg)
+	}
+	t.Nodes[leaf].Tags |= tags
+	for i := len(path) - 2; i >= 0; i-- {
+		step := path[i]
+		e := &t.Nodes[step.node].Entries[step.entry]
+		e.BBox = combine(e.BBox, entry.BBox)
+		t.Nodes[step.node].Aggregate = t.Nodes[step.node].Aggregate.combine(agg)
+		t.Nodes[step.node].Tags |= tags
 	}
+	return path
 }
 
-func (t *RTree) joinRoots(r1, r2 int) {
-	t.Nodes = append(t.Nodes, Node{
+func (t *RTree) joinRoots(r1, r2 int, policy InsertionPolicy) {
+	t.mutations.RootGrowths++
+	t.RootIndex = t.appendNode(Node{
 		IsLeaf: false,
 		Entries: []Entry{
 			Entry{
@@ -69,26 +298,27 @@ func (t *RTree) joinRoots(r1, r2 int) {
 				Index: r2,
 			},
 		},
-		Parent: -1,
-	})
-	t.RootIndex = len(t.Nodes) - 1
-	t.Nodes[r1].Parent = len(t.Nodes) - 1
-	t.Nodes[r2].Parent = len(t.Nodes) - 1
+	}, policy)
+	t.summarise(t.RootIndex)
+	if t.Tracer != nil {
+		t.Tracer.GrewRoot(r1, r2, t.RootIndex)
+	}
+	if t.Hooks.OnRootGrow != nil {
+		t.Hooks.OnRootGrow(r1, r2, t.RootIndex)
+	}
 }
 
-func (t *RTree) adjustTree(n, nn int, policy InsertionPolicy) (int, int) {
-	for {
-		if n == t.RootIndex {
+// adjustTree ascends the path from its last node (which has just had node nn
+// split from it) to the root, adjusting the entries leading to the nodes on
+// the path and propagating splits. The root (and the node split from it, or
+// -1 if the root wasn't split) is returned.
+func (t *RTree) adjustTree(path []nodeStep, nn int, policy InsertionPolicy) (int, int) {
+	for i := len(path) - 1; ; i-- {
+		n := path[i].node
+		if i == 0 {
 			return n, nn
 		}
-		parent := t.Nodes[n].Parent
-		parentEntry := -1
-		for i, entry := range t.Nodes[parent].Entries {
-			if entry.Index == n {
-				parentEntry = i
-				break
-			}
-		}
+		parent, parentEntry := path[i-1].node, path[i-1].entry
 		t.Nodes[parent].Entries[parentEntry].BBox = t.calculateBound(n)
 
 		// AT4
@@ -98,21 +328,86 @@ func (t *RTree) adjustTree(n, nn int, policy InsertionPolicy) (int, int) {
 				BBox:  t.calculateBound(nn),
 				Index: nn,
 			}
+			oldCap := cap(t.Nodes[parent].Entries)
 			t.Nodes[parent].Entries = append(t.Nodes[parent].Entries, newEntry)
-			t.Nodes[nn].Parent = parent
+			t.Metrics.countEntryGrowth(oldCap, cap(t.Nodes[parent].Entries))
 			if len(t.Nodes[parent].Entries) > policy.maxChildren {
 				pp = t.splitNode(parent, policy)
 			}
 		}
 
-		n, nn = parent, pp
+		nn = pp
 	}
 }
 
+// maxExhaustiveSplit is the largest number of entries for which splitNode
+// will try every possible partition. The number of partitions grows
+// exponentially, so larger nodes are split using Guttman's quadratic
+// algorithm instead.
+const maxExhaustiveSplit = 16
+
 // splitNode splits node with index n into two nodes. The first node replaces
 // n, and the second node is newly created. The return value is the index of
 // the new node.
 func (t *RTree) splitNode(n int, policy InsertionPolicy) int {
+	var entriesA, entriesB []Entry
+	nodePolicy := policy.forNode(t.Nodes[n].IsLeaf)
+	if len(t.Nodes[n].Entries) <= maxExhaustiveSplit {
+		entriesA, entriesB = exhaustiveSplit(t.Nodes[n].Entries, nodePolicy)
+	} else {
+		entriesA, entriesB = quadraticSplit(t.Nodes[n].Entries, nodePolicy)
+	}
+
+	if t.Metrics.Enabled {
+		t.Metrics.Splits++
+	}
+	t.mutations.Splits++
+
+	// Use the existing node for A, and create a new node for B.
+	t.Nodes[n].Entries = append(t.Nodes[n].Entries[:0], entriesA...)
+	nn := t.appendNode(Node{
+		IsLeaf:  t.Nodes[n].IsLeaf,
+		Entries: entriesB,
+	}, policy)
+	t.summarise(n)
+	t.summarise(nn)
+	if t.Tracer != nil {
+		t.Tracer.SplitNode(n, nn, entriesA, entriesB)
+	}
+	if t.Hooks.OnSplit != nil {
+		t.Hooks.OnSplit(n, nn)
+	}
+	return nn
+}
+
+// appendNode adds a new node to the tree, returning its index. The node's
+// entries are copied into storage with enough capacity for the node to
+// become overfull without reallocating. If the Nodes slice has spare
+// capacity left over from a previous Clear, then the entries slice of the
+// spare node is reused. Otherwise, the storage is allocated from the arena.
+func (t *RTree) appendNode(node Node, policy InsertionPolicy) int {
+	capacity := policy.forNode(node.IsLeaf).maxChildren + 1
+	if len(node.Entries) > capacity {
+		capacity = len(node.Entries)
+	}
+	var storage []Entry
+	if len(t.Nodes) < cap(t.Nodes) {
+		storage = t.Nodes[:len(t.Nodes)+1][len(t.Nodes)].Entries
+	}
+	if cap(storage) < capacity {
+		storage = t.allocEntries(capacity)
+	}
+	node.Entries = append(storage[:0], node.Entries...)
+
+	oldCap := cap(t.Nodes)
+	t.Nodes = append(t.Nodes, node)
+	t.Metrics.countNodeGrowth(oldCap, cap(t.Nodes))
+	return len(t.Nodes) - 1
+}
+
+// exhaustiveSplit partitions entries into two groups by trying every possible
+// partition, and picking the one with the smallest combined area.
+func exhaustiveSplit(entries []Entry, policy InsertionPolicy) ([]Entry, []Entry) {
 	var (
 		// All zeros would not be valid split, so start at 1.
 		minSplit = uint64(1)
@@ -124,7 +419,7 @@ func (t *RTree) splitNode(n int, policy InsertionPolicy) int {
 		// 0001, 0010, 0011, 0100, 0101, 0110, 0111.
 		//
 		// (1 << (4 - 1)) - 1 == 0111, so the maths checks out.
-		maxSplit = uint64((1 << (len(t.Nodes[n].Entries) - 1)) - 1)
+		maxSplit = uint64((1 << (len(entries) - 1)) - 1)
 	)
 	bestArea := math.Inf(+1)
 	var bestSplit uint64
@@ -134,7 +429,7 @@ func (t *RTree) splitNode(n int, policy InsertionPolicy) int {
 		}
 		var bboxA, bboxB BBox
 		var hasA, hasB bool
-		for i, entry := range t.Nodes[n].Entries {
+		for i, entry := range entries {
 			if split&(1<<i) == 0 {
 				if hasA {
 					bboxA = combine(bboxA, entry.BBox)
@@ -149,43 +444,109 @@ func (t *RTree) splitNode(n int, policy InsertionPolicy) int {
 				}
 			}
 		}
+		// The first valid split is always taken (even if its area is
+		// infinite), since a zero bestSplit would leave group B empty.
 		combinedArea := area(bboxA) + area(bboxB)
-		if combinedArea < bestArea {
+		if combinedArea < bestArea || bestSplit == 0 {
 			bestArea = combinedArea
 			bestSplit = split
 		}
 	}
 
 	var entriesA, entriesB []Entry
-	for i, entry := range t.Nodes[n].Entries {
+	for i, entry := range entries {
 		if bestSplit&(1<<i) == 0 {
 			entriesA = append(entriesA, entry)
 		} else {
 			entriesB = append(entriesB, entry)
 		}
 	}
+	return entriesA, entriesB
+}
 
-	// Use the existing node for A, and create a new node for B.
-	t.Nodes[n].Entries = entriesA
-	t.Nodes = append(t.Nodes, Node{
-		IsLeaf:  t.Nodes[n].IsLeaf,
-		Entries: entriesB,
-		Parent:  -1,
-	})
-	if !t.Nodes[n].IsLeaf {
-		for _, entry := range entriesB {
-			t.Nodes[entry.Index].Parent = len(t.Nodes) - 1
+// quadraticSplit partitions entries into two groups using the quadratic cost
+// algorithm described by Guttman.
+func quadraticSplit(entries []Entry, policy InsertionPolicy) ([]Entry, []Entry) {
+	// QS1: Pick the two seeds that would waste the most area if put in the
+	// same group.
+	seedA, seedB := 0, 1
+	worstWaste := math.Inf(-1)
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			waste := areaSub(areaSub(area(combine(entries[i].BBox, entries[j].BBox)),
+				area(entries[i].BBox)), area(entries[j].BBox))
+			if waste > worstWaste {
+				worstWaste = waste
+				seedA, seedB = i, j
+			}
+		}
+	}
+
+	entriesA := []Entry{entries[seedA]}
+	entriesB := []Entry{entries[seedB]}
+	bboxA := entries[seedA].BBox
+	bboxB := entries[seedB].BBox
+
+	remaining := make([]Entry, 0, len(entries)-2)
+	for i, entry := range entries {
+		if i != seedA && i != seedB {
+			remaining = append(remaining, entry)
 		}
 	}
-	return len(t.Nodes) - 1
-}
 
-func (t *RTree) chooseLeafNode(bb BBox) int {
-	node := t.RootIndex
+	for len(remaining) > 0 {
+		// QS2: If one group needs all remaining entries to reach the minimum
+		// number of children, then assign them all to that group.
+		if len(entriesA)+len(remaining) <= policy.minChildren {
+			entriesA = append(entriesA, remaining...)
+			break
+		}
+		if len(entriesB)+len(remaining) <= policy.minChildren {
+			entriesB = append(entriesB, remaining...)
+			break
+		}
+
+		// PN1: Pick the entry with the greatest preference for one group.
+		next := 0
+		bestDiff := math.Inf(-1)
+		for i, entry := range remaining {
+			diff := math.Abs(enlargement(bboxA, entry.BBox) - enlargement(bboxB, entry.BBox))
+			if diff > bestDiff {
+				bestDiff = diff
+				next = i
+			}
+		}
+		entry := remaining[next]
+		remaining[next] = remaining[len(remaining)-1]
+		remaining = remaining[:len(remaining)-1]
+
+		// QS3: Add it to the group that has to be enlarged least, resolving
+		// ties by smaller area, then by fewer entries.
+		deltaA := enlargement(bboxA, entry.BBox)
+		deltaB := enlargement(bboxB, entry.BBox)
+		useA := deltaA < deltaB
+		if deltaA == deltaB {
+			areaA, areaB := area(bboxA), area(bboxB)
+			useA = areaA < areaB || (areaA == areaB && len(entriesA) <= len(entriesB))
+		}
+		if useA {
+			entriesA = append(entriesA, entry)
+			bboxA = combine(bboxA, entry.BBox)
+		} else {
+			entriesB = append(entriesB, entry)
+			bboxB = combine(bboxB, entry.BBox)
+		}
+	}
+	return entriesA, entriesB
+}
 
+// chooseLeafNode descends from the last node on the path to the most
+// suitable leaf to hold the bounding box, extending the path as it goes.
+func (t *RTree) chooseLeafNode(path []nodeStep, bb BBox) []nodeStep {
 	for {
+		node := path[len(path)-1].node
 		if t.Nodes[node].IsLeaf {
-			return node
+			return path
 		}
 		bestDelta := enlargement(bb, t.Nodes[node].Entries[0].BBox)
 		bestEntry := 0
@@ -199,6 +560,7 @@ func (t *RTree) chooseLeafNode(bb BBox) int {
 				bestEntry = i
 			}
 		}
-		node = t.Nodes[node].Entries[bestEntry].Index
+		path[len(path)-1].entry = bestEntry
+		path = append(path, nodeStep{node: t.Nodes[node].Entries[bestEntry].Index, entry: -1})
 	}
 }
@@ -0,0 +1,42 @@
+package rtree
+
+import "iter"
+
+// All gives an iterator over every item in the tree, yielding the data index
+// and bounding box of each. Items are yielded in depth-first order. This is
+// cheaper than searching with a bounding box covering everything, since no
+// overlap checks are needed. Items marked as deleted by MarkDeleted are
+// skipped, and items quarantined by NonFiniteQuarantine aren't in the tree
+// so aren't yielded.
+//
+// The tree must not be modified while iterating over it (the iterator panics
+// if it detects that it was).
+func (t *RTree) All() iter.Seq2[int, BBox] {
+	return func(yield func(int, BBox) bool) {
+		if len(t.Nodes) == 0 {
+			return
+		}
+		gen := t.generation
+		var recurse func(n int) bool
+		recurse = func(n int) bool {
+			node := &t.Nodes[n]
+			for _, e := range node.Entries {
+				if !node.IsLeaf {
+					if !recurse(e.Index) {
+						return false
+					}
+					continue
+				}
+				if t.isTombstoned(e.Index) {
+					continue
+				}
+				if !yield(e.Index, e.BBox) {
+					return false
+				}
+				t.checkGeneration(gen)
+			}
+			return true
+		}
+		recurse(t.RootIndex)
+	}
+}
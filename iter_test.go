@@ -0,0 +1,68 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	var empty RTree
+	for range empty.All() {
+		t.Error("unexpected item in empty tree")
+	}
+
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	boxes := make([]BBox, 100)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Insert(boxes[i], i, ins)
+	}
+	rt.MarkDeleted(7)
+
+	got := make(map[int]BBox)
+	for idx, bb := range rt.All() {
+		if _, ok := got[idx]; ok {
+			t.Fatalf("item %d yielded twice", idx)
+		}
+		got[idx] = bb
+	}
+	if len(got) != len(boxes)-1 {
+		t.Fatalf("got %d items, want %d", len(got), len(boxes)-1)
+	}
+	for i, bb := range boxes {
+		if i == 7 {
+			if _, ok := got[i]; ok {
+				t.Error("expected tombstoned item to be skipped")
+			}
+		} else if got[i] != bb {
+			t.Errorf("item %d has bbox %v, want %v", i, got[i], bb)
+		}
+	}
+
+	var count int
+	for range rt.All() {
+		count++
+		if count == 10 {
+			break
+		}
+	}
+	if count != 10 {
+		t.Errorf("expected iteration to stop early, got %d items", count)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic after modification")
+			}
+		}()
+		for idx := range rt.All() {
+			rt.MarkDeleted(idx)
+		}
+	}()
+}
@@ -0,0 +1,206 @@
+package rtree
+
+// EnableJournal turns on a journal of insertions and deletions, so that they
+// can be reverted by Undo and reapplied by Redo. This is intended for
+// editor-style applications built on top of the tree.
+//
+// Each call to an insertion or deletion method (or to Apply) is a single
+// step in the journal, no matter how many items it affects. Other
+// modifications (such as Move, Adjust, MarkDeleted and Vacuum) aren't
+// journaled. Undoing an insertion removes the item with the inserted data
+// index (wherever it has since been moved to), so the journal relies on data
+// indices being unique.
+//
+// Modifications that change every item at once (Translate, Transform, Clear
+// and a successful Repair) discard the journal's undo and redo steps, so
+// nothing before them can be undone.
+func (t *RTree) EnableJournal() {
+	if t.journal == nil {
+		t.journal = &journal{}
+	}
+}
+
+// DisableJournal turns off the journal enabled by EnableJournal, and
+// discards its contents.
+func (t *RTree) DisableJournal() {
+	t.journal = nil
+}
+
+// journal holds the steps that can be undone and redone, with the most
+// recent steps last.
+type journal struct {
+	undo, redo []journalStep
+
+	// suspended is non-zero while changes shouldn't be journaled, either
+	// because they're made by an unjournaled modification or because
+	// they're made while undoing or redoing a step.
+	suspended int
+
+	// group is non-zero while the changes made by a modification are
+	// grouped into a single step with the generation groupGen.
+	group    int
+	groupGen uint64
+}
+
+// journalStep is the changes made by a single modification to the tree,
+// which is identified by the generation the changes were recorded in.
+type journalStep struct {
+	gen     uint64
+	changes []journalChange
+}
+
+type journalChange struct {
+	entry    Entry
+	tags     uint64
+	weight   float64
+	inserted bool // otherwise deleted
+}
+
+// recordJournal records that the leaf entry was inserted into or deleted
+// from the tree. Changes made in the same generation are part of the same
+// step. It must be called after the generation has been incremented for the
+// modification.
+func (t *RTree) recordJournal(entry Entry, inserted bool) {
+	j := t.journal
+	if j == nil || j.suspended > 0 {
+		return
+	}
+	gen := t.generation
+	if j.group > 0 {
+		gen = j.groupGen
+	}
+	if n := len(j.undo); n == 0 || j.undo[n-1].gen != gen {
+		j.undo = append(j.undo, journalStep{gen: gen})
+	}
+	step := &j.undo[len(j.undo)-1]
+	step.changes = append(step.changes, journalChange{
+		entry:    entry,
+		tags:     t.tags[entry.Index],
+		weight:   t.weights[entry.Index],
+		inserted: inserted,
+	})
+	j.redo = nil
+}
+
+// suspendJournal stops changes from being journaled until the returned
+// function is called.
+func (t *RTree) suspendJournal() func() {
+	j := t.journal
+	if j == nil {
+		return func() {}
+	}
+	j.suspended++
+	return func() { j.suspended-- }
+}
+
+// groupJournal makes all changes journaled until the returned function is
+// called part of the same step, even if they span multiple generations.
+func (t *RTree) groupJournal() func() {
+	j := t.journal
+	if j == nil {
+		return func() {}
+	}
+	if j.group == 0 {
+		j.groupGen = t.generation + 1
+	}
+	j.group++
+	return func() { j.group-- }
+}
+
+// resetJournal discards the journal's contents.
+func (t *RTree) resetJournal() {
+	if t.journal != nil {
+		t.journal.undo = nil
+		t.journal.redo = nil
+	}
+}
+
+// resetHistory discards the version history and the journal. It's used by
+// modifications that change every item at once, which would be too expensive
+// to record.
+func (t *RTree) resetHistory() {
+	t.resetVersions()
+	t.resetJournal()
+}
+
+// UndoSteps gives the number of steps that can be undone by Undo.
+func (t *RTree) UndoSteps() int {
+	if t.journal == nil {
+		return 0
+	}
+	return len(t.journal.undo)
+}
+
+// RedoSteps gives the number of steps that can be redone by Redo. Steps that
+// have been undone can only be redone until the next journaled insertion or
+// deletion.
+func (t *RTree) RedoSteps() int {
+	if t.journal == nil {
+		return 0
+	}
+	return len(t.journal.redo)
+}
+
+// Undo reverts the n most recent journaled steps (or all of them, if there
+// are fewer than n), giving the number of steps reverted. Deleted items are
+// reinserted using the insertion policy. Like Insert, it panics if the
+// insertion policy is the zero value.
+func (t *RTree) Undo(n int, policy InsertionPolicy) int {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	j := t.journal
+	if j == nil {
+		return 0
+	}
+	defer t.suspendJournal()()
+	var count int
+	for ; count < n && len(j.undo) > 0; count++ {
+		step := j.undo[len(j.undo)-1]
+		j.undo = j.undo[:len(j.undo)-1]
+		for i := len(step.changes) - 1; i >= 0; i-- {
+			c := step.changes[i]
+			t.replayChange(c, !c.inserted, policy)
+		}
+		j.redo = append(j.redo, step)
+	}
+	return count
+}
+
+// Redo reapplies the n most recently undone steps (or all of them, if there
+// are fewer than n), giving the number of steps reapplied. Like Insert, it
+// panics if the insertion policy is the zero value.
+func (t *RTree) Redo(n int, policy InsertionPolicy) int {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	j := t.journal
+	if j == nil {
+		return 0
+	}
+	defer t.suspendJournal()()
+	var count int
+	for ; count < n && len(j.redo) > 0; count++ {
+		step := j.redo[len(j.redo)-1]
+		j.redo = j.redo[:len(j.redo)-1]
+		for _, c := range step.changes {
+			t.replayChange(c, c.inserted, policy)
+		}
+		j.undo = append(j.undo, step)
+	}
+	return count
+}
+
+// replayChange inserts the change's entry (along with its tags and weight),
+// or deletes the item with the entry's data index.
+func (t *RTree) replayChange(c journalChange, insert bool, policy InsertionPolicy) {
+	if insert {
+		t.setTags(c.entry.Index, c.tags)
+		t.setWeight(c.entry.Index, c.weight)
+		if err := t.insertEntry(c.entry, policy); err != nil {
+			panic(err)
+		}
+	} else {
+		t.DeleteByIndex(c.entry.Index)
+	}
+}
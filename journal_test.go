@@ -0,0 +1,129 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestJournalUndoRedo(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 4)
+	var rt RTree
+	for i := 0; i < 20; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+	}
+	rt.EnableJournal()
+
+	items := func() map[int]BBox {
+		m := make(map[int]BBox)
+		for idx, bb := range rt.All() {
+			m[idx] = bb
+		}
+		return m
+	}
+	states := []map[int]BBox{items()}
+	next := 20
+	for i := 0; i < 60; i++ {
+		switch rnd.Intn(4) {
+		case 0, 1:
+			rt.Insert(randomBox(rnd, 0.9, 0.1), next, policy)
+			next++
+		case 2:
+			query := randomBox(rnd, 0.5, 0.2)
+			if rt.DeleteFunc(query, func(int) bool { return true }) == 0 {
+				continue
+			}
+		case 3:
+			var b Batch
+			for j := 0; j < 3; j++ {
+				b.Insert(randomBox(rnd, 0.9, 0.1), next)
+				next++
+			}
+			rt.Apply(b, policy)
+		}
+		states = append(states, items())
+	}
+	if got := rt.UndoSteps(); got != len(states)-1 {
+		t.Fatalf("expected %d undo steps, got %d", len(states)-1, got)
+	}
+
+	for i := len(states) - 2; i >= 0; i-- {
+		if got := rt.Undo(1, policy); got != 1 {
+			t.Fatalf("expected to undo 1 step, got %d", got)
+		}
+		checkInvariants(t, rt)
+		if !reflect.DeepEqual(items(), states[i]) {
+			t.Fatalf("unexpected items after undoing to state %d", i)
+		}
+	}
+	if got := rt.Undo(1, policy); got != 0 {
+		t.Fatalf("expected nothing left to undo, got %d", got)
+	}
+
+	if got := rt.Redo(10, policy); got != 10 {
+		t.Fatalf("expected to redo 10 steps, got %d", got)
+	}
+	checkInvariants(t, rt)
+	if !reflect.DeepEqual(items(), states[10]) {
+		t.Fatal("unexpected items after redo")
+	}
+
+	// A new modification discards the steps that could be redone, and moves
+	// aren't journaled.
+	rt.Move(0, BBox{2, 2, 3, 3}, policy)
+	if rt.RedoSteps() == 0 {
+		t.Fatal("expected move to keep redo steps")
+	}
+	rt.Insert(BBox{0, 0, 1, 1}, next, policy)
+	if rt.RedoSteps() != 0 {
+		t.Fatal("expected insert to discard redo steps")
+	}
+	rt.Undo(1, policy)
+	if rt.Has(next) {
+		t.Error("expected undo to remove inserted item")
+	}
+	if bb, _ := rt.BBoxOf(0); bb != (BBox{2, 2, 3, 3}) {
+		t.Errorf("expected moved item to stay moved, got %v", bb)
+	}
+
+	rt.Clear()
+	if rt.UndoSteps() != 0 || rt.RedoSteps() != 0 {
+		t.Error("expected clear to discard the journal")
+	}
+}
+
+func TestBulkModificationsDiscardHistory(t *testing.T) {
+	for name, modify := range map[string]func(*RTree){
+		"translate": func(rt *RTree) { rt.Translate(1, 2) },
+		"transform": func(rt *RTree) { rt.Transform(0, -1, 1, 0, 0, 0) },
+		"clear":     func(rt *RTree) { rt.Clear() },
+		"repair": func(rt *RTree) {
+			if err := rt.Repair(); err != nil {
+				t.Fatal(err)
+			}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(0))
+			policy := mustPolicy(t, 2, 4)
+			var rt RTree
+			rt.EnableJournal()
+			rt.EnableVersioning()
+			for i := 0; i < 20; i++ {
+				rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+			}
+			rt.Undo(1, policy)
+			if rt.UndoSteps() == 0 || rt.RedoSteps() == 0 {
+				t.Fatal("expected undo and redo steps")
+			}
+			modify(&rt)
+			if rt.UndoSteps() != 0 || rt.RedoSteps() != 0 {
+				t.Error("expected the journal to be discarded")
+			}
+			if got, _ := rt.OldestVersion(); got != rt.Generation() {
+				t.Errorf("expected the version history to be discarded, oldest version is %d", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package rtree
+
+import "iter"
+
+// KeyedRTree is an R-Tree whose items are identified by keys of an arbitrary
+// comparable type (such as string IDs or UUIDs), rather than by dense
+// integer data indices. The tree maintains the mapping between keys and the
+// locations of their items, so callers don't need to keep their own
+// translation table. Each key identifies at most one item.
+//
+// The zero value isn't usable; trees must be created using NewKeyedRTree.
+type KeyedRTree[K comparable] struct {
+	tree   RTree
+	policy InsertionPolicy
+
+	// indices maps each key to the data index used for its item in the
+	// underlying tree, and keys maps the data indices back to keys. Data
+	// indices freed by deletions are reused.
+	indices map[K]int
+	keys    []K
+	free    []int
+}
+
+// NewKeyedRTree creates a new empty tree that uses the insertion policy for
+// all modifications.
+func NewKeyedRTree[K comparable](policy InsertionPolicy) *KeyedRTree[K] {
+	t := &KeyedRTree[K]{
+		policy:  policy,
+		indices: make(map[K]int),
+	}
+	t.tree.EnableLookup()
+	return t
+}
+
+// Len gives the number of items in the tree.
+func (t *KeyedRTree[K]) Len() int {
+	return len(t.indices)
+}
+
+// Insert adds an item with the given key and bounding box to the tree. If
+// the tree already has an item with the key, then that item is moved to the
+// new bounding box instead. In both cases, the bounding box is snapped and
+// checked for non-finite coordinates according to the policy in the same
+// way as by RTree.TryInsert. If the policy keeps the bounding box out of the
+// tree (see NonFiniteQuarantine), then the tree no longer has an item with
+// the key. Errors are reported in the same way as by RTree.TryInsert, in
+// which case the tree is left unchanged.
+func (t *KeyedRTree[K]) Insert(key K, bb BBox) error {
+	if err := checkInsert(bb, t.policy); err != nil {
+		return err
+	}
+	idx, exists := t.indices[key]
+	if !exists {
+		if n := len(t.free); n > 0 {
+			idx = t.free[n-1]
+		} else {
+			idx = len(t.keys)
+		}
+	}
+	e := Entry{BBox: bb, Index: idx}
+	place, err := t.tree.admit(&e, t.policy)
+	if err != nil {
+		return err
+	}
+	if !place {
+		t.Delete(key)
+		return nil
+	}
+	if exists {
+		t.tree.Move(idx, e.BBox, t.policy)
+		return nil
+	}
+
+	t.tree.splitOverfull(t.tree.placeEntry(e, t.policy), t.policy)
+	if n := len(t.free); n > 0 {
+		t.free = t.free[:n-1]
+		t.keys[idx] = key
+	} else {
+		t.keys = append(t.keys, key)
+	}
+	t.indices[key] = idx
+	return nil
+}
+
+// Delete removes the item with the given key. The return value indicates if
+// there was such an item.
+func (t *KeyedRTree[K]) Delete(key K) bool {
+	idx, ok := t.indices[key]
+	if !ok {
+		return false
+	}
+	t.tree.DeleteByIndex(idx)
+	delete(t.indices, key)
+	var zero K
+	t.keys[idx] = zero
+	t.free = append(t.free, idx)
+	return true
+}
+
+// BBoxOf gives the bounding box of the item with the given key, and
+// indicates if there is such an item.
+func (t *KeyedRTree[K]) BBoxOf(key K) (BBox, bool) {
+	idx, ok := t.indices[key]
+	if !ok {
+		return BBox{}, false
+	}
+	return t.tree.BBoxOf(idx)
+}
+
+// Search looks for any items in the tree that overlap with the given
+// bounding box. The callback is called with the key of each found item.
+func (t *KeyedRTree[K]) Search(bb BBox, callback func(key K)) {
+	t.tree.Search(bb, func(idx int) { callback(t.keys[idx]) })
+}
+
+// Nearest gives the keys of the k items nearest to the point (x, y),
+// ordered from nearest to farthest.
+func (t *KeyedRTree[K]) Nearest(x, y float64, k int) []K {
+	indices := t.tree.Nearest(x, y, k)
+	keys := make([]K, len(indices))
+	for i, idx := range indices {
+		keys[i] = t.keys[idx]
+	}
+	return keys
+}
+
+// All gives an iterator over the keys and bounding boxes of all items in
+// the tree.
+func (t *KeyedRTree[K]) All() iter.Seq2[K, BBox] {
+	return func(yield func(K, BBox) bool) {
+		for idx, bb := range t.tree.All() {
+			if !yield(t.keys[idx], bb) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package rtree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestKeyedRTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewKeyedRTree[string](policy)
+	want := make(map[string]BBox)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("item-%d", rnd.Intn(300))
+		switch rnd.Intn(3) {
+		case 0, 1:
+			bb := randomBox(rnd, 0.9, 0.1)
+			if err := tr.Insert(key, bb); err != nil {
+				t.Fatal(err)
+			}
+			want[key] = bb
+		case 2:
+			_, ok := want[key]
+			if got := tr.Delete(key); got != ok {
+				t.Fatalf("delete %q: got %t want %t", key, got, ok)
+			}
+			delete(want, key)
+		}
+	}
+	if tr.Len() != len(want) {
+		t.Fatalf("got len %d want %d", tr.Len(), len(want))
+	}
+	checkInvariants(t, tr.tree)
+
+	for key, bb := range want {
+		if got, ok := tr.BBoxOf(key); !ok || got != bb {
+			t.Fatalf("BBoxOf %q: got %v %t want %v", key, got, ok, bb)
+		}
+	}
+	if _, ok := tr.BBoxOf("missing"); ok {
+		t.Error("expected missing key not to be found")
+	}
+
+	for i := 0; i < 50; i++ {
+		query := randomBox(rnd, 0.5, 0.5)
+		var got, expected []string
+		tr.Search(query, func(key string) { got = append(got, key) })
+		for key, bb := range want {
+			if overlap(bb, query) {
+				expected = append(expected, key)
+			}
+		}
+		sort.Strings(got)
+		sort.Strings(expected)
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("search %v: got %v want %v", query, got, expected)
+		}
+	}
+
+	all := make(map[string]BBox)
+	for key, bb := range tr.All() {
+		all[key] = bb
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Error("All gave unexpected items")
+	}
+
+	nearest := tr.Nearest(0.5, 0.5, 3)
+	if len(nearest) != 3 {
+		t.Fatalf("got %d nearest keys", len(nearest))
+	}
+	for _, key := range nearest {
+		if _, ok := want[key]; !ok {
+			t.Errorf("nearest gave unknown key %q", key)
+		}
+	}
+}
+
+func TestKeyedRTreeErrors(t *testing.T) {
+	policy, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type uuid [16]byte
+	tr := NewKeyedRTree[uuid](policy)
+	key := uuid{1}
+	if err := tr.Insert(key, BBox{1, 0, 0, 1}); !errors.Is(err, ErrInvalidBBox) {
+		t.Errorf("expected ErrInvalidBBox, got %v", err)
+	}
+	if tr.Len() != 0 {
+		t.Errorf("expected failed insert to leave tree empty")
+	}
+	if err := tr.Insert(key, BBox{0, 0, 1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Insert(key, BBox{1, 0, 0, 1}); !errors.Is(err, ErrInvalidBBox) {
+		t.Errorf("expected ErrInvalidBBox, got %v", err)
+	}
+	if bb, _ := tr.BBoxOf(key); bb != (BBox{0, 0, 1, 1}) {
+		t.Errorf("expected failed move to leave item unchanged, got %v", bb)
+	}
+}
+
+func TestKeyedRTreePolicyHandling(t *testing.T) {
+	policy, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy = policy.WithNonFiniteHandling(NonFiniteQuarantine)
+	policy, err = policy.WithGridSnapping(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewKeyedRTree[string](policy)
+	nonFinite := BBox{0, 0, math.Inf(1), 1}
+
+	// Quarantined items don't keep their keys, whether new or existing.
+	for _, key := range []string{"a", "b"} {
+		if err := tr.Insert(key, BBox{0.5, 0.5, 1.5, 1.5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, key := range []string{"a", "c"} {
+		if err := tr.Insert(key, nonFinite); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := tr.BBoxOf(key); ok {
+			t.Errorf("expected %q to be removed", key)
+		}
+	}
+	if tr.Len() != 1 {
+		t.Errorf("got %d items, want 1", tr.Len())
+	}
+	var found []string
+	tr.Search(everywhere, func(key string) { found = append(found, key) })
+	if len(found) != 1 || found[0] != "b" {
+		t.Errorf("found %v, want [b]", found)
+	}
+
+	// Both new items and moved items are snapped.
+	for _, key := range []string{"b", "d"} {
+		if err := tr.Insert(key, BBox{2.5, 2.5, 3.5, 3.5}); err != nil {
+			t.Fatal(err)
+		}
+		if bb, _ := tr.BBoxOf(key); bb != (BBox{2, 2, 4, 4}) {
+			t.Errorf("%q: got %v, want it snapped to {2 2 4 4}", key, bb)
+		}
+	}
+	checkInvariants(t, tr.tree)
+}
@@ -0,0 +1,213 @@
+package rtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// libspatialindex node types.
+const (
+	lsiIndexNode = 1
+	lsiLeafNode  = 2
+)
+
+// lsiPageEntry describes where a stored byte array is in the data file.
+type lsiPageEntry struct {
+	length uint32
+	pages  []int64
+}
+
+// LoadLibSpatialIndex reads an R-Tree that was persisted to disk by
+// libspatialindex (e.g. using Python's rtree package), converting it to this
+// package's in-memory representation. The idx and dat arguments are the
+// contents of the index (.idx) and data (.dat) files written by
+// libspatialindex's disk storage manager.
+//
+// The tree header is read from page ID 0, which is where libspatialindex
+// stores it for newly created indexes. The structure of the tree is kept as
+// is, and the ID of each item is used as its data index. Any data stored
+// alongside the items is ignored. Only 2 dimensional trees are supported,
+// and the files must have been written on a little endian machine.
+func LoadLibSpatialIndex(idx io.Reader, dat io.ReaderAt) (RTree, error) {
+	pageSize, pageIndex, err := readLSIPageIndex(idx)
+	if err != nil {
+		return RTree{}, wrapReadError(err, "reading libspatialindex index file")
+	}
+	load := func(id int64) ([]byte, error) {
+		e, ok := pageIndex[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: page ID %d not found", ErrCorruptTree, id)
+		}
+		buf := make([]byte, 0, len(e.pages)*int(pageSize))
+		page := make([]byte, pageSize)
+		for _, p := range e.pages {
+			n, err := dat.ReadAt(page, p*int64(pageSize))
+			if err != nil && !(err == io.EOF && n > 0) {
+				return nil, wrapReadError(err, "reading page %d", p)
+			}
+			buf = append(buf, page[:n]...)
+		}
+		if int(e.length) > len(buf) {
+			return nil, fmt.Errorf("%w: byte array for ID %d is truncated", ErrCorruptTree, id)
+		}
+		return buf[:e.length], nil
+	}
+
+	header, err := load(0)
+	if err != nil {
+		return RTree{}, fmt.Errorf("reading libspatialindex header: %w", err)
+	}
+	// The header starts with the root ID, tree variant, fill factor, index
+	// capacity, leaf capacity, near minimum overlap factor, split
+	// distribution factor, reinsert factor and dimension.
+	if len(header) < 52 {
+		return RTree{}, fmt.Errorf("%w: libspatialindex header is truncated", ErrCorruptTree)
+	}
+	le := binary.LittleEndian
+	rootID := int64(le.Uint64(header[0:]))
+	if dim := le.Uint32(header[48:]); dim != 2 {
+		return RTree{}, fmt.Errorf("libspatialindex tree has %d dimensions, but only 2 are supported", dim)
+	}
+
+	var t RTree
+	var build func(id int64, depth int) (int, error)
+	build = func(id int64, depth int) (int, error) {
+		if depth > 64 {
+			return 0, fmt.Errorf("%w: libspatialindex tree is too deep (or has a cycle)", ErrCorruptTree)
+		}
+		data, err := load(id)
+		if err != nil {
+			return 0, fmt.Errorf("reading libspatialindex node: %w", err)
+		}
+		nodeType, children, entries, err := decodeLSINode(data)
+		if err != nil {
+			return 0, fmt.Errorf("%w: decoding libspatialindex node %d: %v", ErrCorruptTree, id, err)
+		}
+		t.Nodes = append(t.Nodes, Node{IsLeaf: nodeType == lsiLeafNode})
+		n := len(t.Nodes) - 1
+		if nodeType == lsiIndexNode {
+			for i, childID := range children {
+				c, err := build(childID, depth+1)
+				if err != nil {
+					return 0, err
+				}
+				entries[i].Index = c
+			}
+		}
+		t.Nodes[n].Entries = entries
+		return n, nil
+	}
+	root, err := build(rootID, 0)
+	if err != nil {
+		return RTree{}, err
+	}
+	t.RootIndex = root
+	if len(t.Nodes[root].Entries) == 0 {
+		return RTree{}, nil
+	}
+	t.calculateAggregates(root)
+	return t, nil
+}
+
+// readLSIPageIndex reads the index file of libspatialindex's disk storage
+// manager, which maps the IDs of stored byte arrays to the pages holding
+// them.
+func readLSIPageIndex(r io.Reader) (uint32, map[int64]lsiPageEntry, error) {
+	le := binary.LittleEndian
+	var pageSize uint32
+	var nextPage int64
+	var emptyCount uint32
+	if err := binary.Read(r, le, &pageSize); err != nil {
+		return 0, nil, err
+	}
+	if pageSize == 0 {
+		return 0, nil, fmt.Errorf("%w: page size is zero", ErrCorruptTree)
+	}
+	if err := binary.Read(r, le, &nextPage); err != nil {
+		return 0, nil, err
+	}
+	if err := binary.Read(r, le, &emptyCount); err != nil {
+		return 0, nil, err
+	}
+	for i := uint32(0); i < emptyCount; i++ {
+		var page int64
+		if err := binary.Read(r, le, &page); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var count uint32
+	if err := binary.Read(r, le, &count); err != nil {
+		return 0, nil, err
+	}
+	index := make(map[int64]lsiPageEntry)
+	for i := uint32(0); i < count; i++ {
+		var id int64
+		var e lsiPageEntry
+		var pages uint32
+		if err := binary.Read(r, le, &id); err != nil {
+			return 0, nil, err
+		}
+		if err := binary.Read(r, le, &e.length); err != nil {
+			return 0, nil, err
+		}
+		if err := binary.Read(r, le, &pages); err != nil {
+			return 0, nil, err
+		}
+		for j := uint32(0); j < pages; j++ {
+			var page int64
+			if err := binary.Read(r, le, &page); err != nil {
+				return 0, nil, err
+			}
+			e.pages = append(e.pages, page)
+		}
+		index[id] = e
+	}
+	return pageSize, index, nil
+}
+
+// decodeLSINode decodes a serialised libspatialindex node. For index nodes,
+// the IDs of the child nodes are returned (and the indices of the entries
+// are left unset). For leaf nodes, the indices of the entries are the item
+// IDs.
+func decodeLSINode(data []byte) (nodeType uint32, children []int64, entries []Entry, err error) {
+	le := binary.LittleEndian
+	if len(data) < 12 {
+		return 0, nil, nil, errors.New("truncated")
+	}
+	nodeType = le.Uint32(data[0:])
+	if nodeType != lsiIndexNode && nodeType != lsiLeafNode {
+		return 0, nil, nil, fmt.Errorf("unknown node type %d", nodeType)
+	}
+	count := le.Uint32(data[8:])
+	pos := 12
+	for i := uint32(0); i < count; i++ {
+		// Each child has an MBR (low then high coordinates), an ID, and
+		// a length prefixed byte array.
+		if len(data)-pos < 44 {
+			return 0, nil, nil, errors.New("truncated")
+		}
+		var f [4]float64
+		for j := range f {
+			f[j] = math.Float64frombits(le.Uint64(data[pos+8*j:]))
+		}
+		id := int64(le.Uint64(data[pos+32:]))
+		dataLen := int(le.Uint32(data[pos+40:]))
+		pos += 44
+		if dataLen > len(data)-pos {
+			return 0, nil, nil, errors.New("truncated")
+		}
+		pos += dataLen
+
+		e := Entry{BBox: BBox{MinX: f[0], MinY: f[1], MaxX: f[2], MaxY: f[3]}}
+		if nodeType == lsiLeafNode {
+			e.Index = int(id)
+		}
+		children = append(children, id)
+		entries = append(entries, e)
+	}
+	return nodeType, children, entries, nil
+}
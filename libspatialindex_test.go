@@ -0,0 +1,160 @@
+package rtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// lsiWriter builds the index and data files of libspatialindex's disk
+// storage manager.
+type lsiWriter struct {
+	pageSize int
+	dat      bytes.Buffer
+	ids      []int64
+	entries  []lsiPageEntry
+}
+
+func (w *lsiWriter) store(id int64, data []byte) {
+	e := lsiPageEntry{length: uint32(len(data))}
+	for len(data) > 0 {
+		page := make([]byte, w.pageSize)
+		n := copy(page, data)
+		data = data[n:]
+		e.pages = append(e.pages, int64(w.dat.Len()/w.pageSize))
+		w.dat.Write(page)
+	}
+	w.ids = append(w.ids, id)
+	w.entries = append(w.entries, e)
+}
+
+func (w *lsiWriter) idx() []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&buf, le, uint32(w.pageSize))
+	binary.Write(&buf, le, int64(w.dat.Len()/w.pageSize))
+	binary.Write(&buf, le, uint32(0))
+	binary.Write(&buf, le, uint32(len(w.ids)))
+	for i, id := range w.ids {
+		binary.Write(&buf, le, id)
+		binary.Write(&buf, le, w.entries[i].length)
+		binary.Write(&buf, le, uint32(len(w.entries[i].pages)))
+		binary.Write(&buf, le, w.entries[i].pages)
+	}
+	return buf.Bytes()
+}
+
+func lsiHeader(rootID int64, dim uint32) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&buf, le, rootID)
+	binary.Write(&buf, le, uint32(1))   // variant
+	binary.Write(&buf, le, 0.7)         // fill factor
+	binary.Write(&buf, le, uint32(100)) // index capacity
+	binary.Write(&buf, le, uint32(100)) // leaf capacity
+	binary.Write(&buf, le, uint32(32))  // near minimum overlap factor
+	binary.Write(&buf, le, 0.4)         // split distribution factor
+	binary.Write(&buf, le, 0.3)         // reinsert factor
+	binary.Write(&buf, le, dim)
+	buf.WriteByte(1)                  // tight MBRs
+	binary.Write(&buf, le, uint32(0)) // nodes
+	binary.Write(&buf, le, uint64(0)) // data
+	binary.Write(&buf, le, uint32(0)) // height
+	return buf.Bytes()
+}
+
+func lsiNode(nodeType uint32, level uint32, boxes []BBox, ids []int64) []byte {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&buf, le, nodeType)
+	binary.Write(&buf, le, level)
+	binary.Write(&buf, le, uint32(len(boxes)))
+	bound := EmptyBBox
+	for i, bb := range boxes {
+		binary.Write(&buf, le, []float64{bb.MinX, bb.MinY, bb.MaxX, bb.MaxY})
+		binary.Write(&buf, le, ids[i])
+		binary.Write(&buf, le, uint32(3)) // data length
+		buf.WriteString("abc")
+		bound = combine(bound, bb)
+	}
+	binary.Write(&buf, le, []float64{bound.MinX, bound.MinY, bound.MaxX, bound.MaxY})
+	return buf.Bytes()
+}
+
+func TestLoadLibSpatialIndex(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	w := &lsiWriter{pageSize: 64}
+	w.store(0, lsiHeader(1, 2))
+
+	var boxes []BBox
+	var leafBounds []BBox
+	var leafIDs []int64
+	for leaf := 0; leaf < 4; leaf++ {
+		var bs []BBox
+		var ids []int64
+		bound := EmptyBBox
+		for i := 0; i < 5; i++ {
+			bb := randomBox(rnd, 0.9, 0.1)
+			bs = append(bs, bb)
+			ids = append(ids, int64(len(boxes)))
+			boxes = append(boxes, bb)
+			bound = combine(bound, bb)
+		}
+		id := int64(2 + leaf)
+		w.store(id, lsiNode(lsiLeafNode, 0, bs, ids))
+		leafBounds = append(leafBounds, bound)
+		leafIDs = append(leafIDs, id)
+	}
+	w.store(1, lsiNode(lsiIndexNode, 1, leafBounds, leafIDs))
+
+	rt, err := LoadLibSpatialIndex(bytes.NewReader(w.idx()), bytes.NewReader(w.dat.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, rt)
+	checkSearch(t, rt, boxes, rnd)
+}
+
+func TestLoadLibSpatialIndexErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		header  []byte
+		root    []byte
+		want    string
+		corrupt bool
+	}{
+		"3d":         {lsiHeader(1, 3), lsiNode(lsiLeafNode, 0, nil, nil), "dimensions", false},
+		"short":      {lsiHeader(1, 2)[:20], lsiNode(lsiLeafNode, 0, nil, nil), "truncated", true},
+		"missing":    {lsiHeader(9, 2), lsiNode(lsiLeafNode, 0, nil, nil), "not found", true},
+		"bad node":   {lsiHeader(1, 2), []byte{7, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "node type", true},
+		"cycle":      {lsiHeader(1, 2), lsiNode(lsiIndexNode, 1, []BBox{{0, 0, 1, 1}}, []int64{1}), "too deep", true},
+		"short node": {lsiHeader(1, 2), lsiNode(lsiLeafNode, 0, []BBox{{0, 0, 1, 1}}, []int64{1})[:30], "truncated", true},
+	} {
+		w := &lsiWriter{pageSize: 32}
+		w.store(0, tc.header)
+		w.store(1, tc.root)
+		_, err := LoadLibSpatialIndex(bytes.NewReader(w.idx()), bytes.NewReader(w.dat.Bytes()))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected error containing %q, got %v", name, tc.want, err)
+		}
+		if errors.Is(err, ErrCorruptTree) != tc.corrupt {
+			t.Errorf("%s: got errors.Is(err, ErrCorruptTree) = %t, want %t", name, !tc.corrupt, tc.corrupt)
+		}
+	}
+
+	w := &lsiWriter{pageSize: 32}
+	w.store(0, lsiHeader(1, 2))
+	idx := w.idx()
+	for name, idx := range map[string][]byte{
+		"empty index":     nil,
+		"truncated index": idx[:len(idx)-1],
+		"zero page size":  append([]byte{0, 0, 0, 0}, idx[4:]...),
+	} {
+		_, err := LoadLibSpatialIndex(bytes.NewReader(idx), bytes.NewReader(w.dat.Bytes()))
+		if !errors.Is(err, ErrCorruptTree) {
+			t.Errorf("%s: expected ErrCorruptTree, got %v", name, err)
+		}
+	}
+}
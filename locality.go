@@ -0,0 +1,88 @@
+package rtree
+
+// EnableLocalityHint turns on a cursor that remembers the leaf that the most
+// recent insertion was placed in. Subsequent insertions start from that leaf
+// and only walk up the tree as far as the first ancestor whose bounding box
+// contains the new item, rather than always descending from the root. This
+// speeds up spatially coherent workloads (such as scanline ingestion), at the
+// cost of items sometimes being placed in a leaf that a full descent from the
+// root wouldn't have chosen.
+//
+// Searches can't make use of the hint, since the bounding boxes of nodes may
+// overlap. An item overlapping the query could be anywhere in the tree, even
+// if the query is entirely inside the hinted leaf's bounding box.
+func (t *RTree) EnableLocalityHint() {
+	if t.hint == nil {
+		t.hint = &localityHint{}
+	}
+}
+
+// DisableLocalityHint turns off the cursor enabled by EnableLocalityHint.
+func (t *RTree) DisableLocalityHint() {
+	t.hint = nil
+}
+
+// localityHint remembers the path to the leaf that the previous insertion
+// was placed in.
+type localityHint struct {
+	path []nodeStep // empty if there is no usable leaf
+}
+
+// invalidateHint forgets the hinted leaf. It must be called whenever nodes
+// are renumbered.
+func (t *RTree) invalidateHint() {
+	if t.hint != nil {
+		t.hint.path = t.hint.path[:0]
+	}
+}
+
+// hintStart gives the path to the node that the search for a leaf to hold
+// the bounding box should start from. This is the lowest ancestor of the
+// hinted leaf whose bounding box contains bb, or the root if there is no such
+// ancestor (or no hint).
+func (t *RTree) hintStart(bb BBox) []nodeStep {
+	if t.hint == nil || !t.validPath(t.hint.path) {
+		return t.rootPath()
+	}
+	path := append(t.pathBuf[:0], t.hint.path...)
+	return t.ancestorContaining(path, bb)
+}
+
+// validPath checks if a path still leads from the root to a leaf. Paths
+// become invalid when the nodes on them are split.
+func (t *RTree) validPath(path []nodeStep) bool {
+	if len(path) == 0 || path[0].node != t.RootIndex {
+		return false
+	}
+	for i, step := range path {
+		if step.node < 0 || step.node >= len(t.Nodes) {
+			return false
+		}
+		node := &t.Nodes[step.node]
+		if i == len(path)-1 {
+			return node.IsLeaf
+		}
+		if node.IsLeaf || step.entry < 0 || step.entry >= len(node.Entries) ||
+			node.Entries[step.entry].Index != path[i+1].node {
+			return false
+		}
+	}
+	return true
+}
+
+// ancestorContaining truncates the path so that it ends at the lowest of its
+// last node and that node's ancestors whose bounding box contains bb. It's
+// truncated to just the root if there is no such node.
+func (t *RTree) ancestorContaining(path []nodeStep, bb BBox) []nodeStep {
+	for len(path) > 1 && !contains(t.parentEntry(path).BBox, bb) {
+		path = path[:len(path)-1]
+	}
+	return path
+}
+
+// recordHint remembers the path to the leaf that an insertion was placed in.
+func (t *RTree) recordHint(path []nodeStep) {
+	if t.hint != nil {
+		t.hint.path = append(t.hint.path[:0], path...)
+	}
+}
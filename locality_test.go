@@ -0,0 +1,58 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestLocalityHint(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	rt.EnableLocalityHint()
+
+	// Insert along a scanline, so that consecutive items are close.
+	var boxes []BBox
+	var hinted int
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 30; x++ {
+			bb := BBox{float64(x) / 30, float64(y) / 10, float64(x+1) / 30, float64(y+1) / 10}
+			if len(rt.Nodes) > 0 && len(rt.hintStart(bb)) > 1 {
+				hinted++
+			}
+			rt.Insert(bb, len(boxes), ins)
+			boxes = append(boxes, bb)
+		}
+	}
+	if hinted == 0 {
+		t.Error("expected some insertions to start below the root")
+	}
+	checkInvariants(t, rt)
+	checkSearch(t, rt, boxes, rnd)
+
+	// Deleting renumbers nodes, so the hint must be invalidated.
+	rt.DeleteFunc(BBox{0, 0, 0.5, 1}, func(int) bool { return true })
+	if len(rt.hint.path) != 0 {
+		t.Error("expected hint to be invalidated")
+	}
+	for i, bb := range boxes {
+		if bb.MinX <= 0.5 {
+			boxes[i] = BBox{-5, -5, -4, -4}
+		}
+	}
+	for i := 0; i < 50; i++ {
+		bb := randomBox(rnd, 0.9, 0.1)
+		rt.Insert(bb, len(boxes), ins)
+		boxes = append(boxes, bb)
+	}
+	checkInvariants(t, rt)
+	checkSearch(t, rt, boxes, rnd)
+
+	rt.DisableLocalityHint()
+	if got := rt.hintStart(BBox{0.9, 0.9, 0.9, 0.9}); len(got) != 1 || got[0].node != rt.RootIndex {
+		t.Errorf("expected disabled hint to start at the root, got %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package rtree
+
+// EnableLookup turns on tracking of the bounding box of each item by its data
+// index, so that BBoxOf doesn't need to scan the whole tree. Items already in
+// the tree are tracked straight away, and the tracking is maintained as items
+// are inserted, deleted and adjusted. It uses extra memory proportional to
+// the number of items.
+//
+// The tracking relies on data indices being unique. If multiple items share
+// a data index, then only the most recently inserted one is tracked.
+func (t *RTree) EnableLookup() {
+	if t.lookup != nil {
+		return
+	}
+	t.lookup = make(map[int]BBox)
+	for _, node := range t.Nodes {
+		if !node.IsLeaf {
+			continue
+		}
+		for _, e := range node.Entries {
+			t.lookup[e.Index] = e.BBox
+		}
+	}
+}
+
+// BBoxOf gives the bounding box of the item with the given data index. If
+// lookup tracking has been turned on by EnableLookup, then this takes
+// constant time, otherwise it scans the whole tree. The return value ok
+// indicates if an item with the data index was found.
+func (t *RTree) BBoxOf(dataIndex int) (bb BBox, ok bool) {
+	if t.isTombstoned(dataIndex) {
+		return BBox{}, false
+	}
+	if t.lookup != nil {
+		bb, ok = t.lookup[dataIndex]
+		return bb, ok
+	}
+	path, ok := t.findEntry(dataIndex)
+	if !ok {
+		return BBox{}, false
+	}
+	step := path[len(path)-1]
+	return t.Nodes[step.node].Entries[step.entry].BBox, true
+}
+
+// Has checks if the tree contains an item with the given data index. Like
+// BBoxOf, it takes constant time if lookup tracking has been turned on by
+// EnableLookup, and otherwise scans the whole tree.
+func (t *RTree) Has(dataIndex int) bool {
+	_, ok := t.BBoxOf(dataIndex)
+	return ok
+}
+
+// setLookup tracks the bounding box of an item, if lookup tracking is turned
+// on. Changes are also recorded in the version history.
+func (t *RTree) setLookup(dataIndex int, bb BBox) {
+	if t.lookup == nil {
+		return
+	}
+	if t.versions != nil {
+		if old, ok := t.lookup[dataIndex]; !ok || old != bb {
+			t.recordChange(dataIndex, old, ok)
+		}
+	}
+	t.lookup[dataIndex] = bb
+}
+
+// deleteLookup stops tracking an item that has been removed from the tree.
+// Tombstoned items were already recorded as removed in the version history
+// when they were marked as deleted.
+func (t *RTree) deleteLookup(dataIndex int) {
+	if t.lookup == nil {
+		return
+	}
+	if t.versions != nil && !t.isTombstoned(dataIndex) {
+		if old, ok := t.lookup[dataIndex]; ok {
+			t.recordChange(dataIndex, old, true)
+		}
+	}
+	delete(t.lookup, dataIndex)
+}
@@ -0,0 +1,65 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBBoxOf(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, enableFirst := range []bool{false, true} {
+		rnd := rand.New(rand.NewSource(0))
+		var rt RTree
+		if enableFirst {
+			rt.EnableLookup()
+		}
+		boxes := make(map[int]BBox)
+		for i := 0; i < 200; i++ {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Insert(boxes[i], i, ins)
+		}
+		check := func() {
+			t.Helper()
+			for i := -1; i <= 1000; i++ {
+				want, wantOK := boxes[i]
+				got, ok := rt.BBoxOf(i)
+				if ok != wantOK || got != want {
+					t.Fatalf("index %d: got %v %t want %v %t", i, got, ok, want, wantOK)
+				}
+				if has := rt.Has(i); has != wantOK {
+					t.Fatalf("index %d: Has gave %t", i, has)
+				}
+			}
+		}
+		check()
+		rt.EnableLookup()
+		check()
+
+		for i := 0; i < 200; i += 3 {
+			rt.DeleteFunc(boxes[i], func(idx int) bool { return idx == i })
+			delete(boxes, i)
+		}
+		for i := 1; i < 200; i += 3 {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Adjust(i, boxes[i], ins)
+		}
+		check()
+
+		var batch Batch
+		batch.Update(boxes[2], BBox{0, 0, 1, 1}, 2)
+		batch.Delete(boxes[5], 5)
+		batch.Insert(BBox{2, 2, 3, 3}, 1000)
+		rt.Apply(batch, ins)
+		boxes[2] = BBox{0, 0, 1, 1}
+		delete(boxes, 5)
+		boxes[1000] = BBox{2, 2, 3, 3}
+		check()
+
+		rt.Clear()
+		boxes = map[int]BBox{}
+		check()
+	}
+}
@@ -0,0 +1,106 @@
+package rtree
+
+import "unsafe"
+
+// Metrics holds instrumentation counters for an RTree. The counters are only
+// updated while Enabled is true, so that there is no overhead for users that
+// don't need them. Counters accumulate until they are explicitly reset.
+//
+// The counters aren't updated atomically, so while Enabled is true, searches
+// of the tree must not run concurrently with each other (or with reads of
+// the counters).
+type Metrics struct {
+	// Enabled turns on the collection of metrics.
+	Enabled bool
+
+	// Searches is the number of searches performed.
+	Searches int
+
+	// NodesVisited is the total number of nodes visited by searches.
+	NodesVisited int
+
+	// EntriesCompared is the total number of entries whose bounding boxes
+	// were compared against a search bounding box.
+	EntriesCompared int
+
+	// Splits is the number of node splits performed during insertion.
+	Splits int
+
+	// BytesAllocated is an estimate of the number of bytes allocated for
+	// nodes and entries during insertion.
+	BytesAllocated int
+}
+
+// Reset sets all counters back to zero, without changing Enabled.
+func (m *Metrics) Reset() {
+	*m = Metrics{Enabled: m.Enabled}
+}
+
+// NodesVisitedPerSearch gives the average number of nodes visited per search.
+func (m *Metrics) NodesVisitedPerSearch() float64 {
+	if m.Searches == 0 {
+		return 0
+	}
+	return float64(m.NodesVisited) / float64(m.Searches)
+}
+
+// MutationCounts holds counts of the structural changes made to an RTree
+// since it was created. Unlike Metrics, they're always collected (and can't
+// be reset). High counts relative to the number of items indicate churn that
+// may have degraded the tree's structure, suggesting that it should be
+// rebuilt (e.g. using Repack).
+type MutationCounts struct {
+	// Splits is the number of node splits.
+	Splits int
+
+	// Reinsertions is the number of entries (items or subtrees) that were
+	// removed from their nodes and inserted again, such as the orphans of
+	// condensed nodes and items moved out of their leaves.
+	Reinsertions int
+
+	// RootGrowths is the number of times that the root was split, adding a
+	// level to the tree.
+	RootGrowths int
+
+	// Condensations is the number of empty or underfull nodes that were
+	// removed while condensing the tree after deletions.
+	Condensations int
+}
+
+// MutationCounts gives the counts of structural changes made to the tree
+// since it was created. They aren't written by WriteTo.
+func (t *RTree) MutationCounts() MutationCounts {
+	return t.mutations
+}
+
+// countNodeGrowth records the bytes allocated if a Nodes slice has grown from
+// oldCap to newCap.
+func (m *Metrics) countNodeGrowth(oldCap, newCap int) {
+	if m.Enabled && newCap != oldCap {
+		m.BytesAllocated += newCap * int(unsafe.Sizeof(Node{}))
+	}
+}
+
+// countEntryGrowth records the bytes allocated if an Entries slice has grown
+// from oldCap to newCap.
+func (m *Metrics) countEntryGrowth(oldCap, newCap int) {
+	if m.Enabled && newCap != oldCap {
+		m.BytesAllocated += newCap * int(unsafe.Sizeof(Entry{}))
+	}
+}
+
+// MemoryUsage gives an estimate of the number of heap bytes used by the tree.
+// This includes the nodes and their entries, as well as any slack capacity in
+// the slices that hold them (including capacity retained by Clear and unused
+// capacity in the entries arena). It doesn't include the size of the RTree
+// struct itself, or the maps used by optional features such as lookup
+// tracking, tags and weights.
+func (t *RTree) MemoryUsage() int {
+	nodes := t.Nodes[:cap(t.Nodes)]
+	total := len(nodes) * int(unsafe.Sizeof(Node{}))
+	for _, n := range nodes {
+		total += cap(n.Entries) * int(unsafe.Sizeof(Entry{}))
+	}
+	total += (cap(t.arena) - len(t.arena)) * int(unsafe.Sizeof(Entry{}))
+	return total
+}
@@ -0,0 +1,116 @@
+package rtree
+
+// Move changes the bounding box of the item with the given data index. It's
+// tuned for moving objects, where each update is a small displacement:
+//
+//   - If the new bounding box is still inside the bounding box of the parent
+//     of the item's leaf, then the item's entry is rewritten in place and the
+//     bounding boxes of its ancestors are adjusted.
+//
+//   - Otherwise, the item is removed from its leaf and reinserted under the
+//     lowest ancestor whose bounding box contains the new bounding box,
+//     rather than from the root.
+//
+// Compared to Adjust, Move keeps items in their existing leaves more often,
+// trading some extra node overlap for cheaper updates. If lookup tracking has
+// been turned on by EnableLookup, then the item is found without scanning
+// the whole tree.
+//
+// The return value indicates if an item with the data index was found.
+func (t *RTree) Move(dataIndex int, newBB BBox, policy InsertionPolicy) bool {
+	if t.isTombstoned(dataIndex) {
+		return false
+	}
+	if !isFinite(newBB) && policy.nonFinite != NonFiniteAllow {
+		return t.Adjust(dataIndex, newBB, policy)
+	}
+	newBB = policy.snap(newBB)
+	path, ok := t.locateEntry(dataIndex)
+	if !ok {
+		return false
+	}
+	leaf, pos := path[len(path)-1].node, path[len(path)-1].entry
+	t.generation++
+	t.setLookup(dataIndex, newBB)
+
+	if len(path) == 1 || contains(t.nodeBound(path[:len(path)-1]), newBB) {
+		t.hookMove(t.Nodes[leaf].Entries[pos], newBB)
+		t.Nodes[leaf].Entries[pos].BBox = newBB
+		t.tightenAncestors(path)
+		return true
+	}
+
+	entries := t.Nodes[leaf].Entries
+	if len(entries) == 1 {
+		// Removing the entry would leave the leaf empty, which requires the
+		// tree to be condensed.
+		t.reinsert(entries[pos], newBB, policy)
+		return true
+	}
+	entry := entries[pos]
+	t.hookMove(entry, newBB)
+	entry.BBox = newBB
+	t.Nodes[leaf].Entries = append(entries[:pos], entries[pos+1:]...)
+	t.refreshAncestors(path)
+
+	t.mutations.Reinsertions++
+	start := t.ancestorContaining(path, newBB)
+	t.splitOverfull(t.placeEntryBelow(start, entry), policy)
+	return true
+}
+
+// locateEntry finds the path to the item with the given data index, like
+// findEntry. If lookup tracking is turned on, then only the parts of the tree
+// containing the item's bounding box are searched.
+func (t *RTree) locateEntry(dataIndex int) ([]nodeStep, bool) {
+	bb, tracked := t.lookup[dataIndex]
+	if !tracked || !isFinite(bb) || len(t.Nodes) == 0 {
+		return t.findEntry(dataIndex)
+	}
+	var path []nodeStep
+	var recurse func(n int) bool
+	recurse = func(n int) bool {
+		path = append(path, nodeStep{node: n, entry: -1})
+		node := &t.Nodes[n]
+		for i, e := range node.Entries {
+			path[len(path)-1].entry = i
+			switch {
+			case !contains(e.BBox, bb):
+			case !node.IsLeaf:
+				if recurse(e.Index) {
+					return true
+				}
+			case e.Index == dataIndex:
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if recurse(t.RootIndex) {
+		return path, true
+	}
+	return t.findEntry(dataIndex)
+}
+
+// nodeBound gives the bounding box of the last node on the path.
+func (t *RTree) nodeBound(path []nodeStep) BBox {
+	if len(path) == 1 {
+		return t.calculateBound(path[0].node)
+	}
+	return t.parentEntry(path).BBox
+}
+
+// refreshAncestors recalculates the aggregates and tags of the last node on
+// the path and its ancestors, along with the bounding boxes of the entries
+// leading to them.
+func (t *RTree) refreshAncestors(path []nodeStep) {
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i].node
+		t.summarise(n)
+		if i == 0 {
+			return
+		}
+		t.parentEntry(path[:i+1]).BBox = t.calculateBound(n)
+	}
+}
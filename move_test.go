@@ -0,0 +1,52 @@
+package rtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestMove(t *testing.T) {
+	for _, lookup := range []bool{false, true} {
+		t.Run(fmt.Sprintf("lookup_%t", lookup), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(0))
+			ins, err := NewInsertionPolicy(2, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rt RTree
+			if lookup {
+				rt.EnableLookup()
+			}
+			boxes := make([]BBox, 100)
+			for i := range boxes {
+				boxes[i] = randomBox(rnd, 0.9, 0.1)
+				rt.InsertWithWeight(boxes[i], i, float64(i), ins)
+			}
+			for tick := 0; tick < 20; tick++ {
+				for i := range boxes {
+					// Mostly small displacements, with occasional
+					// teleports.
+					d := 0.01
+					if rnd.Intn(10) == 0 {
+						d = 0.5
+					}
+					dx, dy := (rnd.Float64()-0.5)*d, (rnd.Float64()-0.5)*d
+					bb := BBox{boxes[i].MinX + dx, boxes[i].MinY + dy, boxes[i].MaxX + dx, boxes[i].MaxY + dy}
+					if !rt.Move(i, bb, ins) {
+						t.Fatalf("item %d not found", i)
+					}
+					boxes[i] = bb
+				}
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+			}
+			if got, want := rt.SumWithin(BBox{-10, -10, 10, 10}), float64(99*100/2); got != want {
+				t.Errorf("got sum %v, want %v", got, want)
+			}
+			if rt.Move(len(boxes), BBox{}, ins) {
+				t.Error("expected missing item not to be moved")
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package rtree
+
+import "math"
+
+// MovingRTree is an R-Tree for moving objects, in the style of a TPR-tree
+// (time-parameterised R-Tree). Each item has a bounding box at a particular
+// time along with a velocity, and its position at other times is
+// extrapolated from its velocity. The bounds of nodes are time-parameterised
+// too, so they keep covering their items as the items move. This allows
+// window queries for where items will be at some time, without needing to
+// update the items each time they move (only when their velocities change).
+//
+// Bounds are stored relative to a reference time. Queries are most
+// efficient for times between the reference time and the reference time
+// plus the horizon (the period that the tree is tuned for), since node
+// bounds grow as time moves away from the reference time.
+type MovingRTree struct {
+	nodes   []movingNode
+	root    int
+	free    []int // indices of nodes that can be reused
+	size    int
+	refTime float64
+	horizon float64
+}
+
+type movingNode struct {
+	isLeaf  bool
+	entries []movingEntry
+}
+
+type movingEntry struct {
+	bound movingBound
+	index int // data index for leaf entries, node index otherwise
+}
+
+// movingBound is a time-parameterised bounding box. The box is the bound at
+// the reference time, and the velocities give the rates that each of its
+// edges move at. For items, the minimum and maximum velocities are the same.
+type movingBound struct {
+	box                        BBox
+	vMinX, vMinY, vMaxX, vMaxY float64
+}
+
+// at gives the bounding box covered by the bound at dt after the reference
+// time. Before the reference time, the bound is extrapolated backwards, so
+// the roles of the minimum and maximum velocities are swapped to keep the
+// box covering everything under it.
+func (b movingBound) at(dt float64) BBox {
+	if dt >= 0 {
+		return BBox{
+			MinX: b.box.MinX + b.vMinX*dt,
+			MinY: b.box.MinY + b.vMinY*dt,
+			MaxX: b.box.MaxX + b.vMaxX*dt,
+			MaxY: b.box.MaxY + b.vMaxY*dt,
+		}
+	}
+	return BBox{
+		MinX: b.box.MinX + b.vMaxX*dt,
+		MinY: b.box.MinY + b.vMaxY*dt,
+		MaxX: b.box.MaxX + b.vMinX*dt,
+		MaxY: b.box.MaxY + b.vMinY*dt,
+	}
+}
+
+func combineMoving(a, b movingBound) movingBound {
+	return movingBound{
+		box:   combine(a.box, b.box),
+		vMinX: math.Min(a.vMinX, b.vMinX),
+		vMinY: math.Min(a.vMinY, b.vMinY),
+		vMaxX: math.Max(a.vMaxX, b.vMaxX),
+		vMaxY: math.Max(a.vMaxY, b.vMaxY),
+	}
+}
+
+// cost approximates the area swept by the bound over the horizon, using the
+// areas at the start and end of the horizon.
+func (t *MovingRTree) cost(b movingBound) float64 {
+	return area(b.at(0)) + area(b.at(t.horizon))
+}
+
+// growth gives how much the cost of a would increase to accommodate b.
+func (t *MovingRTree) growth(a, b movingBound) float64 {
+	return t.cost(combineMoving(a, b)) - t.cost(a)
+}
+
+// NewMovingRTree creates a new empty tree. Bounds are stored relative to the
+// reference time, and the horizon is how far past the reference time that
+// queries are expected to be made.
+func NewMovingRTree(refTime, horizon float64) *MovingRTree {
+	return &MovingRTree{refTime: refTime, horizon: horizon}
+}
+
+// Len gives the number of items in the tree.
+func (t *MovingRTree) Len() int {
+	return t.size
+}
+
+// Insert adds a new data item to the tree. The item has the bounding box at
+// time tm, and moves with velocity (vx, vy). It panics if the insertion
+// policy is the zero value.
+func (t *MovingRTree) Insert(bb BBox, tm, vx, vy float64, dataIndex int, policy InsertionPolicy) {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	dt := tm - t.refTime
+	entry := movingEntry{
+		bound: movingBound{
+			box: BBox{
+				MinX: bb.MinX - vx*dt,
+				MinY: bb.MinY - vy*dt,
+				MaxX: bb.MaxX - vx*dt,
+				MaxY: bb.MaxY - vy*dt,
+			},
+			vMinX: vx, vMinY: vy, vMaxX: vx, vMaxY: vy,
+		},
+		index: dataIndex,
+	}
+	if len(t.nodes) == 0 {
+		t.root = t.newNode(movingNode{isLeaf: true})
+	}
+	if sibling := t.insert(t.root, entry, policy); sibling != -1 {
+		old := t.root
+		t.root = t.newNode(movingNode{entries: []movingEntry{
+			{t.bound(old), old},
+			{t.bound(sibling), sibling},
+		}})
+	}
+	t.size++
+}
+
+// newNode adds a node to the tree (reusing the storage of a deleted node if
+// possible), giving its index.
+func (t *MovingRTree) newNode(node movingNode) int {
+	if n := len(t.free); n > 0 {
+		idx := t.free[n-1]
+		t.free = t.free[:n-1]
+		t.nodes[idx] = node
+		return idx
+	}
+	t.nodes = append(t.nodes, node)
+	return len(t.nodes) - 1
+}
+
+// insert adds the entry to the subtree rooted at n. If n had to be split,
+// then the index of the new sibling node is returned, otherwise -1.
+func (t *MovingRTree) insert(n int, entry movingEntry, policy InsertionPolicy) int {
+	if t.nodes[n].isLeaf {
+		t.nodes[n].entries = append(t.nodes[n].entries, entry)
+	} else {
+		best := 0
+		entries := t.nodes[n].entries
+		bestGrowth := t.growth(entries[0].bound, entry.bound)
+		for i, e := range entries[1:] {
+			g := t.growth(e.bound, entry.bound)
+			if g < bestGrowth || (g == bestGrowth && t.cost(e.bound) < t.cost(entries[best].bound)) {
+				best, bestGrowth = i+1, g
+			}
+		}
+		child := entries[best].index
+		sibling := t.insert(child, entry, policy)
+		t.nodes[n].entries[best].bound = t.bound(child)
+		if sibling != -1 {
+			t.nodes[n].entries = append(t.nodes[n].entries, movingEntry{t.bound(sibling), sibling})
+		}
+	}
+
+	nodePolicy := policy.forNode(t.nodes[n].isLeaf)
+	if len(t.nodes[n].entries) <= nodePolicy.maxChildren {
+		return -1
+	}
+	a, b := t.split(t.nodes[n].entries, nodePolicy)
+	t.nodes[n].entries = a
+	return t.newNode(movingNode{isLeaf: t.nodes[n].isLeaf, entries: b})
+}
+
+func (t *MovingRTree) bound(n int) movingBound {
+	entries := t.nodes[n].entries
+	b := entries[0].bound
+	for _, e := range entries[1:] {
+		b = combineMoving(b, e.bound)
+	}
+	return b
+}
+
+// split partitions entries into two groups using Guttman's quadratic
+// algorithm, with the cost over the horizon in place of area.
+func (t *MovingRTree) split(entries []movingEntry, policy InsertionPolicy) ([]movingEntry, []movingEntry) {
+	seedA, seedB := 0, 1
+	worst := math.Inf(-1)
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			waste := t.cost(combineMoving(entries[i].bound, entries[j].bound)) -
+				t.cost(entries[i].bound) - t.cost(entries[j].bound)
+			if waste > worst {
+				worst = waste
+				seedA, seedB = i, j
+			}
+		}
+	}
+
+	groupA := []movingEntry{entries[seedA]}
+	groupB := []movingEntry{entries[seedB]}
+	boundA, boundB := entries[seedA].bound, entries[seedB].bound
+	var remaining []movingEntry
+	for i, e := range entries {
+		if i != seedA && i != seedB {
+			remaining = append(remaining, e)
+		}
+	}
+	for i, e := range remaining {
+		left := len(remaining) - i
+		if len(groupA)+left <= policy.minChildren {
+			groupA = append(groupA, remaining[i:]...)
+			break
+		}
+		if len(groupB)+left <= policy.minChildren {
+			groupB = append(groupB, remaining[i:]...)
+			break
+		}
+		ga, gb := t.growth(boundA, e.bound), t.growth(boundB, e.bound)
+		if ga < gb || (ga == gb && len(groupA) <= len(groupB)) {
+			groupA = append(groupA, e)
+			boundA = combineMoving(boundA, e.bound)
+		} else {
+			groupB = append(groupB, e)
+			boundB = combineMoving(boundB, e.bound)
+		}
+	}
+	return groupA, groupB
+}
+
+// Delete removes the item with the given data index. The return value
+// indicates if the item was found. Since the item's current position isn't
+// known, the whole tree may need to be scanned.
+func (t *MovingRTree) Delete(dataIndex int) bool {
+	if len(t.nodes) == 0 || !t.delete(t.root, dataIndex) {
+		return false
+	}
+	t.size--
+
+	// Replace the root with its only child for as long as possible, or with
+	// an empty leaf if the tree is now empty.
+	for {
+		root := &t.nodes[t.root]
+		if len(root.entries) == 0 {
+			root.isLeaf = true
+			break
+		}
+		if root.isLeaf || len(root.entries) != 1 {
+			break
+		}
+		old := t.root
+		t.root = root.entries[0].index
+		t.freeNode(old)
+	}
+	return true
+}
+
+// delete removes the item from the subtree rooted at n, reporting if it was
+// found. Nodes left empty are removed.
+func (t *MovingRTree) delete(n, dataIndex int) bool {
+	entries := t.nodes[n].entries
+	for i, e := range entries {
+		if t.nodes[n].isLeaf {
+			if e.index != dataIndex {
+				continue
+			}
+		} else {
+			if !t.delete(e.index, dataIndex) {
+				continue
+			}
+			if len(t.nodes[e.index].entries) > 0 {
+				entries[i].bound = t.bound(e.index)
+				return true
+			}
+			t.freeNode(e.index)
+		}
+		t.nodes[n].entries = append(entries[:i], entries[i+1:]...)
+		return true
+	}
+	return false
+}
+
+func (t *MovingRTree) freeNode(n int) {
+	t.nodes[n] = movingNode{}
+	t.free = append(t.free, n)
+}
+
+// Update changes the bounding box (at time tm) and velocity of the item with
+// the given data index. The return value indicates if the item was found.
+func (t *MovingRTree) Update(bb BBox, tm, vx, vy float64, dataIndex int, policy InsertionPolicy) bool {
+	if !t.Delete(dataIndex) {
+		return false
+	}
+	t.Insert(bb, tm, vx, vy, dataIndex, policy)
+	return true
+}
+
+// Search looks for any items that overlap with the given bounding box at
+// time tm, extrapolating the positions of the items from their velocities.
+// The callback is called with the item index for each found item.
+func (t *MovingRTree) Search(bb BBox, tm float64, callback func(index int)) {
+	if t.size == 0 {
+		return
+	}
+	dt := tm - t.refTime
+	var recurse func(int)
+	recurse = func(n int) {
+		node := &t.nodes[n]
+		for _, e := range node.entries {
+			if !overlap(e.bound.at(dt), bb) {
+				continue
+			}
+			if node.isLeaf {
+				callback(e.index)
+			} else {
+				recurse(e.index)
+			}
+		}
+	}
+	recurse(t.root)
+}
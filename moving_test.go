@@ -0,0 +1,143 @@
+package rtree
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMovingRTree(t *testing.T) {
+	for _, maxCapacity := range []int{2, 5, 20} {
+		ins, err := NewInsertionPolicy(maxCapacity/2, maxCapacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rnd := rand.New(rand.NewSource(0))
+		const refTime = 100
+		type item struct {
+			bb     BBox
+			tm     float64
+			vx, vy float64
+			live   bool
+		}
+		items := make([]item, 300)
+		tr := NewMovingRTree(refTime, 10)
+		randomItem := func() item {
+			return item{
+				bb:   randomBox(rnd, 0.9, 0.1),
+				tm:   refTime + float64(rnd.Intn(10)),
+				vx:   rnd.Float64()*0.1 - 0.05,
+				vy:   rnd.Float64()*0.1 - 0.05,
+				live: true,
+			}
+		}
+		for i := range items {
+			items[i] = randomItem()
+			tr.Insert(items[i].bb, items[i].tm, items[i].vx, items[i].vy, i, ins)
+		}
+
+		// Change the velocities of some items, and remove others.
+		live := len(items)
+		for i := 0; i < 100; i++ {
+			j := rnd.Intn(len(items))
+			if rnd.Intn(2) == 0 {
+				if got := tr.Delete(j); got != items[j].live {
+					t.Fatalf("delete %d: got %t want %t", j, got, items[j].live)
+				}
+				if items[j].live {
+					live--
+				}
+				items[j].live = false
+				continue
+			}
+			it := randomItem()
+			if got := tr.Update(it.bb, it.tm, it.vx, it.vy, j, ins); got != items[j].live {
+				t.Fatalf("update %d: got %t want %t", j, got, items[j].live)
+			}
+			if items[j].live {
+				items[j] = it
+			}
+		}
+		if tr.Len() != live {
+			t.Fatalf("expected %d items, got %d", live, tr.Len())
+		}
+
+		for i := 0; i < 50; i++ {
+			bb := randomBox(rnd, 0.5, 0.5)
+			tm := refTime + float64(rnd.Intn(40)-10)
+			var got, want []int
+			tr.Search(bb, tm, func(idx int) { got = append(got, idx) })
+			for j, it := range items {
+				if !it.live {
+					continue
+				}
+				// Extrapolate via the reference time, in the same way as the
+				// tree, so that rounding is identical.
+				b := movingBound{
+					box: BBox{
+						MinX: it.bb.MinX - it.vx*(it.tm-refTime),
+						MinY: it.bb.MinY - it.vy*(it.tm-refTime),
+						MaxX: it.bb.MaxX - it.vx*(it.tm-refTime),
+						MaxY: it.bb.MaxY - it.vy*(it.tm-refTime),
+					},
+					vMinX: it.vx, vMinY: it.vy, vMaxX: it.vx, vMaxY: it.vy,
+				}
+				if overlap(b.at(tm-refTime), bb) {
+					want = append(want, j)
+				}
+			}
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("time %v: got %v want %v", tm, got, want)
+			}
+		}
+
+		for j := range items {
+			tr.Delete(j)
+		}
+		if tr.Len() != 0 {
+			t.Fatalf("expected empty tree, got %d items", tr.Len())
+		}
+		tr.Search(BBox{-10, -10, 10, 10}, refTime, func(idx int) {
+			t.Errorf("unexpected item %d in empty tree", idx)
+		})
+	}
+}
+
+func TestMovingRTreeLeafCapacity(t *testing.T) {
+	policy, err := mustPolicy(t, 2, 4).WithLeafCapacity(8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	rt := NewMovingRTree(0, 10)
+	for i := 0; i < 500; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), 0, rnd.Float64(), rnd.Float64(), i, policy)
+	}
+	var bigLeaf bool
+	for i, node := range rt.nodes {
+		maxChildren := 4
+		if node.isLeaf {
+			maxChildren = 16
+			bigLeaf = bigLeaf || len(node.entries) > 4
+		}
+		if n := len(node.entries); n > maxChildren {
+			t.Errorf("node %d (leaf=%v) has %d entries, want at most %d", i, node.isLeaf, n, maxChildren)
+		}
+	}
+	if !bigLeaf {
+		t.Error("expected leaves to use the leaf capacity")
+	}
+}
+
+func TestMovingRTreeZeroPolicy(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("expected panic with ErrInvalidPolicy, got %v", err)
+		}
+	}()
+	rt := NewMovingRTree(0, 10)
+	rt.Insert(BBox{0, 0, 1, 1}, 0, 0, 0, 0, InsertionPolicy{})
+}
@@ -0,0 +1,115 @@
+package rtree
+
+import "sort"
+
+// SearchMulti looks for any items in the tree that overlap with any of the
+// given query bounding boxes. The callback is called with the position of
+// the query box (within boxes) and the item index for each query box and
+// item that overlap.
+//
+// All queries are answered in a single traversal of the tree, so nodes that
+// are relevant to multiple query boxes are only visited once. This is much
+// faster than calling Search for each query box when the query boxes are
+// close together, e.g. adjacent map tiles.
+func (t *RTree) SearchMulti(boxes []BBox, callback func(queryIdx, dataIdx int)) {
+	if len(t.Nodes) == 0 || len(boxes) == 0 {
+		return
+	}
+	if t.Metrics.Enabled {
+		t.Metrics.Searches += len(boxes)
+	}
+
+	type query struct {
+		bb  BBox
+		idx int
+	}
+	var queries []query
+	for i, bb := range boxes {
+		for _, q := range t.Period.wrappedQueries(bb) {
+			queries = append(queries, query{q, i})
+		}
+	}
+	report := callback
+	if len(queries) > len(boxes) {
+		// In a periodic space, an item may overlap with more than one of
+		// the wrapped versions of a query, but should only be reported once
+		// for each query.
+		type key struct {
+			queryIdx, dataIdx int
+		}
+		seen := make(map[key]bool)
+		report = func(queryIdx, dataIdx int) {
+			k := key{queryIdx, dataIdx}
+			if !seen[k] {
+				seen[k] = true
+				callback(queryIdx, dataIdx)
+			}
+		}
+	}
+
+	// The queries that are still relevant at each level of the traversal
+	// are stored on a stack in buf, which is shared between all levels.
+	var buf []int
+	for i := range queries {
+		buf = append(buf, i)
+	}
+	gen := t.generation
+	var recurse func(n *Node, active []int)
+	recurse = func(n *Node, active []int) {
+		if t.Metrics.Enabled {
+			t.Metrics.NodesVisited++
+			t.Metrics.EntriesCompared += len(n.Entries)
+		}
+		for _, entry := range n.Entries {
+			start := len(buf)
+			for _, q := range active {
+				if overlap(entry.BBox, queries[q].bb) {
+					buf = append(buf, q)
+				}
+			}
+			if n.IsLeaf {
+				if t.isTombstoned(entry.Index) {
+					buf = buf[:start]
+					continue
+				}
+				for _, q := range buf[start:] {
+					report(queries[q].idx, entry.Index)
+					t.checkGeneration(gen)
+				}
+			} else if len(buf) > start {
+				recurse(&t.Nodes[entry.Index], buf[start:])
+			}
+			buf = buf[:start]
+		}
+	}
+	recurse(&t.Nodes[t.RootIndex], buf)
+}
+
+// BatchSearch looks for the items in the tree that overlap with each of the
+// given query bounding boxes. The item indices found for each query box are
+// given at the same position in the result.
+//
+// The queries are answered in the order of their positions along a Hilbert
+// curve rather than in the order given, so that consecutive queries tend to
+// visit the same nodes. This gives better cache locality than calling Search
+// for each query box, especially when the query boxes are unordered (e.g.
+// the points of a point-in-polygon pipeline).
+func (t *RTree) BatchSearch(boxes []BBox) [][]int {
+	order := make([]int, len(boxes))
+	keys := make([]uint64, len(boxes))
+	for i, bb := range boxes {
+		order[i] = i
+		keys[i] = HilbertOf(bb, EmptyBBox)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return keys[order[i]] < keys[order[j]]
+	})
+
+	results := make([][]int, len(boxes))
+	for _, q := range order {
+		t.search(boxes[q], 0, BoundaryClosed, func(e Entry) {
+			results[q] = append(results[q], e.Index)
+		})
+	}
+	return results
+}
@@ -0,0 +1,67 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSearchMulti(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, period := range []Period{{}, {X: 1}} {
+		rnd := rand.New(rand.NewSource(0))
+		rt := RTree{Period: period}
+		for i := 0; i < 300; i++ {
+			rt.Insert(randomBox(rnd, 0.9, 0.1), i, ins)
+		}
+		queries := make([]BBox, 50)
+		for i := range queries {
+			queries[i] = randomBox(rnd, 0.9, 0.2)
+		}
+
+		got := make([][]int, len(queries))
+		rt.SearchMulti(queries, func(q, idx int) {
+			got[q] = append(got[q], idx)
+		})
+		for q, bb := range queries {
+			var want []int
+			rt.Search(bb, func(idx int) { want = append(want, idx) })
+			sort.Ints(got[q])
+			sort.Ints(want)
+			if !reflect.DeepEqual(got[q], want) {
+				t.Fatalf("query %d: got %v want %v", q, got[q], want)
+			}
+		}
+	}
+}
+
+func TestBatchSearch(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	for i := 0; i < 300; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, ins)
+	}
+	queries := make([]BBox, 100)
+	for i := range queries {
+		queries[i] = randomBox(rnd, 0.9, 0.1)
+	}
+	got := rt.BatchSearch(queries)
+	if len(got) != len(queries) {
+		t.Fatalf("expected %d results, got %d", len(queries), len(got))
+	}
+	for q, bb := range queries {
+		var want []int
+		rt.Search(bb, func(idx int) { want = append(want, idx) })
+		if !reflect.DeepEqual(got[q], want) {
+			t.Fatalf("query %d: got %v want %v", q, got[q], want)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package rtree
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Nearest gives the data indices of (up to) the k items nearest to the point
+// (x, y), ordered from nearest to farthest. The distance to an item is the
+// distance from the point to the closest point in the item's bounding box.
+func (t *RTree) Nearest(x, y float64, k int) []int {
+	return t.NearestFunc(x, y, k, nil)
+}
+
+// NearestFunc is like Nearest, but only considers items for which the accept
+// function returns true. Rejected items don't count towards k, and the
+// search continues past them. A nil accept function accepts all items.
+func (t *RTree) NearestFunc(x, y float64, k int, accept func(index int) bool) []int {
+	return t.nearestPoint(x, y, k, math.Inf(1), accept)
+}
+
+// NearestWithin is like Nearest, but only considers items whose distance from
+// the point (x, y) is at most maxDist. Any parts of the tree farther away
+// than maxDist are never examined, so this is much cheaper than Nearest when
+// fewer than k items lie within the radius.
+func (t *RTree) NearestWithin(x, y float64, k int, maxDist float64) []int {
+	return t.nearestPoint(x, y, k, maxDist, nil)
+}
+
+func (t *RTree) nearestPoint(x, y float64, k int, maxDist float64, accept func(int) bool) []int {
+	if k <= 0 {
+		return nil
+	}
+	var result []int
+	t.nearest(maxDist, func(bb BBox) float64 {
+		return t.pointDistance(x, y, bb)
+	}, nil, func(e Entry, _ float64) bool {
+		if accept == nil || accept(e.Index) {
+			result = append(result, e.Index)
+		}
+		return len(result) < k
+	})
+	return result
+}
+
+// NearestToBox visits items in order of increasing distance from the query
+// bounding box. The distance to an item is the minimum distance between the
+// query bounding box and the item's bounding box (zero if they overlap). The
+// callback is called with the data index and distance of each item, until it
+// returns false or all items have been visited.
+func (t *RTree) NearestToBox(query BBox, callback func(index int, dist float64) bool) {
+	t.nearest(math.Inf(1), func(bb BBox) float64 {
+		return t.boxDistance(query, bb)
+	}, nil, func(e Entry, d float64) bool {
+		return callback(e.Index, d)
+	})
+}
+
+// Farthest gives the data index of the item farthest from the point (x, y).
+// Like Nearest, the distance to an item is the distance from the point to
+// the closest point in the item's bounding box. If the tree is empty, then
+// ok is false.
+func (t *RTree) Farthest(x, y float64) (index int, ok bool) {
+	if len(t.Nodes) == 0 {
+		return 0, false
+	}
+
+	// Candidates are prioritised by the negation of their distance, so that
+	// the min-heap gives the farthest candidate first. For nodes, the
+	// distance used is an upper bound on the distance to any of the items
+	// inside them.
+	var queue nearestQueue
+	pushNode := func(n int) {
+		node := &t.Nodes[n]
+		for _, e := range node.Entries {
+			if node.IsLeaf && t.isTombstoned(e.Index) {
+				continue
+			}
+			d := t.pointDistance(x, y, e.BBox)
+			if !node.IsLeaf {
+				d = t.maxPointDistance(x, y, e.BBox)
+			}
+			heap.Push(&queue, nearestCandidate{dist: -d, entry: e, isLeaf: node.IsLeaf})
+		}
+	}
+	pushNode(t.RootIndex)
+	for queue.Len() > 0 {
+		c := heap.Pop(&queue).(nearestCandidate)
+		if c.isLeaf {
+			return c.entry.Index, true
+		}
+		pushNode(c.entry.Index)
+	}
+	return 0, false
+}
+
+// NearestMetric gives the data indices of (up to) the k items nearest to some
+// target, ordered from nearest to farthest, using a custom distance metric.
+// This allows non-Euclidean metrics (such as great circle distance) to be
+// used.
+//
+// The bound function must give a lower bound of the distance from the target
+// to anything inside the bounding box, and is used to prune the search. The
+// exact function gives the actual distance to the item with the given data
+// index, and must never be less than the bound of the item's bounding box.
+// If exact is nil, then the bound of each item's bounding box is used as its
+// distance.
+func (t *RTree) NearestMetric(k int, bound func(bb BBox) float64, exact func(index int) float64) []int {
+	if k <= 0 {
+		return nil
+	}
+	var exactEntry func(Entry) float64
+	if exact != nil {
+		exactEntry = func(e Entry) float64 { return exact(e.Index) }
+	}
+	var result []int
+	t.nearest(math.Inf(1), bound, exactEntry, func(e Entry, _ float64) bool {
+		result = append(result, e.Index)
+		return len(result) < k
+	})
+	return result
+}
+
+// nearest visits leaf entries in order of increasing distance, as given by the
+// dist function. The dist function must give a lower bound of the distance
+// to anything inside the bounding box. If exact is non-nil, then it gives the
+// exact distance to a leaf entry (which must be at least the lower bound),
+// otherwise the lower bound is treated as exact. The visit function is called
+// for each leaf entry (along with its distance) until it returns false.
+// Entries and nodes farther away than maxDist are pruned.
+func (t *RTree) nearest(
+	maxDist float64,
+	dist func(BBox) float64,
+	exact func(Entry) float64,
+	visit func(e Entry, d float64) bool,
+) {
+	if len(t.Nodes) == 0 {
+		return
+	}
+	gen := t.generation
+	var queue nearestQueue
+	pushNode := func(n int) {
+		node := &t.Nodes[n]
+		for _, e := range node.Entries {
+			if node.IsLeaf && t.isTombstoned(e.Index) {
+				continue
+			}
+			if d := dist(e.BBox); d <= maxDist {
+				heap.Push(&queue, nearestCandidate{
+					dist:   d,
+					entry:  e,
+					isLeaf: node.IsLeaf,
+					exact:  node.IsLeaf && exact == nil,
+				})
+			}
+		}
+	}
+	if t.linearScan() {
+		t.scanLeaves(pushNode)
+	} else {
+		pushNode(t.RootIndex)
+	}
+	for queue.Len() > 0 {
+		c := heap.Pop(&queue).(nearestCandidate)
+		switch {
+		case !c.isLeaf:
+			pushNode(c.entry.Index)
+		case !c.exact:
+			// Requeue the entry using its exact distance, since there may
+			// be other entries closer than it.
+			if d := exact(c.entry); d <= maxDist {
+				c.dist, c.exact = d, true
+				heap.Push(&queue, c)
+			}
+		default:
+			if !visit(c.entry, c.dist) {
+				return
+			}
+			t.checkGeneration(gen)
+		}
+	}
+}
+
+type nearestCandidate struct {
+	dist   float64
+	entry  Entry
+	isLeaf bool
+	exact  bool // only relevant for leaf entries
+}
+
+// nearestQueue is a min-heap of candidates ordered by distance.
+type nearestQueue []nearestCandidate
+
+func (q nearestQueue) Len() int            { return len(q) }
+func (q nearestQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q nearestQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nearestQueue) Push(x interface{}) { *q = append(*q, x.(nearestCandidate)) }
+func (q *nearestQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// pointDistance gives the distance from the point (x, y) to the closest point
+// in the bounding box, taking into account the period of the tree's space.
+func (t *RTree) pointDistance(x, y float64, bb BBox) float64 {
+	dx := axisDistance(x, bb.MinX, bb.MaxX, t.Period.X)
+	dy := axisDistance(y, bb.MinY, bb.MaxY, t.Period.Y)
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// maxPointDistance gives the distance from the point (x, y) to the farthest
+// point in the bounding box. If the tree's space is periodic, then the result
+// is an upper bound rather than the exact distance.
+func (t *RTree) maxPointDistance(x, y float64, bb BBox) float64 {
+	dx := math.Max(math.Abs(x-bb.MinX), math.Abs(x-bb.MaxX))
+	dy := math.Max(math.Abs(y-bb.MinY), math.Abs(y-bb.MaxY))
+	if t.Period.X > 0 {
+		dx = math.Min(dx, t.Period.X/2)
+	}
+	if t.Period.Y > 0 {
+		dy = math.Min(dy, t.Period.Y/2)
+	}
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// axisDistance gives the distance from v to the closest value in the range
+// [min, max]. If the period is non-zero, then the distance is the shortest
+// distance around the wrapped space.
+func axisDistance(v, min, max, period float64) float64 {
+	d := rangeDistance(v, min, max)
+	if period > 0 {
+		d = math.Min(d, rangeDistance(v-period, min, max))
+		d = math.Min(d, rangeDistance(v+period, min, max))
+	}
+	return d
+}
+
+// boxDistance gives the minimum distance between any point in bounding box a
+// and any point in bounding box b, taking into account the period of the
+// tree's space.
+func (t *RTree) boxDistance(a, b BBox) float64 {
+	dx := axisGap(a.MinX, a.MaxX, b.MinX, b.MaxX, t.Period.X)
+	dy := axisGap(a.MinY, a.MaxY, b.MinY, b.MaxY, t.Period.Y)
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// axisGap gives the size of the gap between the ranges [minA, maxA] and
+// [minB, maxB] (zero if they overlap). If the period is non-zero, then the
+// gap is the shortest gap around the wrapped space.
+func axisGap(minA, maxA, minB, maxB, period float64) float64 {
+	d := rangeGap(minA, maxA, minB, maxB)
+	if period > 0 {
+		d = math.Min(d, rangeGap(minA-period, maxA-period, minB, maxB))
+		d = math.Min(d, rangeGap(minA+period, maxA+period, minB, maxB))
+	}
+	return d
+}
+
+func rangeGap(minA, maxA, minB, maxB float64) float64 {
+	switch {
+	case maxA < minB:
+		return minB - maxA
+	case maxB < minA:
+		return minA - maxB
+	default:
+		return 0
+	}
+}
+
+func rangeDistance(v, min, max float64) float64 {
+	switch {
+	case v < min:
+		return min - v
+	case v > max:
+		return v - max
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,282 @@
+package rtree
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNearest(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, period := range []Period{{}, {X: 1}, {X: 1, Y: 1}} {
+		rnd := rand.New(rand.NewSource(0))
+		boxes := make([]BBox, 200)
+		rt := RTree{Period: period}
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Insert(boxes[i], i, ins)
+		}
+		for i := 0; i < 20; i++ {
+			x, y := rnd.Float64(), rnd.Float64()
+			dist := func(bb BBox) float64 {
+				return rt.pointDistance(x, y, bb)
+			}
+			got := rt.Nearest(x, y, 5)
+			want := bruteForceNearest(boxes, 5, dist)
+			checkNearest(t, boxes, dist, got, want)
+		}
+	}
+}
+
+func TestNearestFunc(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	var boxes, odd []BBox
+	for i := 0; i < 200; i++ {
+		bb := randomBox(rnd, 0.9, 0.1)
+		boxes = append(boxes, bb)
+		if i%2 == 1 {
+			odd = append(odd, bb)
+		} else {
+			odd = append(odd, BBox{math.Inf(1), math.Inf(1), math.Inf(1), math.Inf(1)})
+		}
+		rt.Insert(bb, i, ins)
+	}
+	for i := 0; i < 20; i++ {
+		x, y := rnd.Float64(), rnd.Float64()
+		dist := func(bb BBox) float64 {
+			return rt.pointDistance(x, y, bb)
+		}
+		got := rt.NearestFunc(x, y, 5, func(idx int) bool { return idx%2 == 1 })
+		for _, idx := range got {
+			if idx%2 != 1 {
+				t.Fatalf("rejected index %d returned", idx)
+			}
+		}
+		want := bruteForceNearest(odd, 5, dist)
+		checkNearest(t, boxes, dist, got, want)
+	}
+	if got := rt.NearestFunc(0, 0, 5, func(int) bool { return false }); len(got) != 0 {
+		t.Errorf("expected no results, got %v", got)
+	}
+}
+
+func TestNearestWithin(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	boxes := make([]BBox, 200)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.01)
+		rt.Insert(boxes[i], i, ins)
+	}
+	for i := 0; i < 20; i++ {
+		x, y := rnd.Float64(), rnd.Float64()
+		const maxDist = 0.1
+		dist := func(bb BBox) float64 {
+			return rt.pointDistance(x, y, bb)
+		}
+		var within []int
+		for _, idx := range bruteForceNearest(boxes, 10, dist) {
+			if dist(boxes[idx]) <= maxDist {
+				within = append(within, idx)
+			}
+		}
+		got := rt.NearestWithin(x, y, 10, maxDist)
+		checkNearest(t, boxes, dist, got, within)
+	}
+}
+
+func TestNearestMetric(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	boxes := make([]BBox, 200)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Insert(boxes[i], i, ins)
+	}
+	for i := 0; i < 20; i++ {
+		// Anisotropic Manhattan distance from (x, y), where the exact
+		// distance to each item is measured to the centre of its box.
+		x, y := rnd.Float64(), rnd.Float64()
+		bound := func(bb BBox) float64 {
+			return 3*rangeDistance(x, bb.MinX, bb.MaxX) + rangeDistance(y, bb.MinY, bb.MaxY)
+		}
+		exactBox := func(bb BBox) float64 {
+			return 3*math.Abs(x-(bb.MinX+bb.MaxX)/2) + math.Abs(y-(bb.MinY+bb.MaxY)/2)
+		}
+		got := rt.NearestMetric(5, bound, func(idx int) float64 {
+			return exactBox(boxes[idx])
+		})
+		checkNearest(t, boxes, exactBox, got, bruteForceNearest(boxes, 5, exactBox))
+
+		got = rt.NearestMetric(5, bound, nil)
+		checkNearest(t, boxes, bound, got, bruteForceNearest(boxes, 5, bound))
+	}
+}
+
+func TestNearestToBox(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, period := range []Period{{}, {X: 1}} {
+		rnd := rand.New(rand.NewSource(0))
+		boxes := make([]BBox, 200)
+		rt := RTree{Period: period}
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Insert(boxes[i], i, ins)
+		}
+		for i := 0; i < 20; i++ {
+			query := randomBox(rnd, 0.9, 0.3)
+			dist := func(bb BBox) float64 {
+				return rt.boxDistance(query, bb)
+			}
+			var got []int
+			rt.NearestToBox(query, func(idx int, d float64) bool {
+				if d != dist(boxes[idx]) {
+					t.Fatalf("reported distance %v doesn't match %v", d, dist(boxes[idx]))
+				}
+				got = append(got, idx)
+				return len(got) < 10
+			})
+			want := bruteForceNearest(boxes, 10, dist)
+			checkNearest(t, boxes, dist, got, want)
+		}
+	}
+}
+
+func TestFarthest(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, period := range []Period{{}, {X: 1}, {X: 1, Y: 1}} {
+		rt := RTree{Period: period}
+		if _, ok := rt.Farthest(0, 0); ok {
+			t.Fatal("expected no result for empty tree")
+		}
+		rnd := rand.New(rand.NewSource(0))
+		boxes := make([]BBox, 200)
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Insert(boxes[i], i, ins)
+		}
+		for i := 0; i < 20; i++ {
+			x, y := rnd.Float64(), rnd.Float64()
+			got, ok := rt.Farthest(x, y)
+			if !ok {
+				t.Fatal("expected result")
+			}
+			var want float64
+			for _, bb := range boxes {
+				want = math.Max(want, rt.pointDistance(x, y, bb))
+			}
+			if d := rt.pointDistance(x, y, boxes[got]); d != want {
+				t.Fatalf("got distance %v want %v", d, want)
+			}
+		}
+	}
+}
+
+func TestBoxDistance(t *testing.T) {
+	var rt RTree
+	for _, tc := range []struct {
+		a, b BBox
+		want float64
+	}{
+		{BBox{0, 0, 1, 1}, BBox{0.5, 0.5, 2, 2}, 0},
+		{BBox{0, 0, 1, 1}, BBox{4, 0, 5, 1}, 3},
+		{BBox{0, 0, 1, 1}, BBox{4, 5, 5, 6}, 5},
+		{BBox{4, 5, 5, 6}, BBox{0, 0, 1, 1}, 5},
+	} {
+		if got := rt.boxDistance(tc.a, tc.b); got != tc.want {
+			t.Errorf("%v %v: got %v want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+// bruteForceNearest gives the k boxes with the smallest distance.
+func bruteForceNearest(boxes []BBox, k int, dist func(BBox) float64) []int {
+	idxs := make([]int, len(boxes))
+	for i := range idxs {
+		idxs[i] = i
+	}
+	sort.SliceStable(idxs, func(i, j int) bool {
+		return dist(boxes[idxs[i]]) < dist(boxes[idxs[j]])
+	})
+	if len(idxs) > k {
+		idxs = idxs[:k]
+	}
+	return idxs
+}
+
+// checkNearest checks that nearest neighbour results match. Items with equal
+// distances may be legitimately reported in any order, so the distances are
+// compared rather than the indices.
+func checkNearest(t *testing.T, boxes []BBox, dist func(BBox) float64, got, want []int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range got {
+		if dist(boxes[got[i]]) != dist(boxes[want[i]]) {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+}
+
+func TestPeriodicSearch(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := RTree{Period: Period{X: 360}}
+	rt.Insert(BBox{350, 0, 370, 10}, 0, ins) // crosses the boundary
+	rt.Insert(BBox{5, 0, 15, 10}, 1, ins)
+	rt.Insert(BBox{180, 0, 190, 10}, 2, ins)
+
+	for _, tc := range []struct {
+		query BBox
+		want  []int
+	}{
+		{BBox{0, 0, 1, 1}, []int{0}},
+		{BBox{355, 0, 365, 1}, []int{0, 1}},
+		{BBox{-20, 0, 360, 1}, []int{0, 1, 2}},
+		{BBox{100, 0, 110, 1}, nil},
+	} {
+		var got []int
+		rt.Search(tc.query, func(idx int) { got = append(got, idx) })
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("query %v: got %v want %v", tc.query, got, tc.want)
+		}
+	}
+
+	if got := rt.Nearest(359, 5, 1); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("nearest: got %v", got)
+	}
+	if got := rt.Nearest(375, 5, 2); !reflect.DeepEqual(got, []int{1, 0}) {
+		t.Errorf("nearest: got %v", got)
+	}
+	if d := rt.pointDistance(1, 5, BBox{350, 0, 355, 10}); math.Abs(d-6) > 1e-9 {
+		t.Errorf("unexpected wrapped distance: %v", d)
+	}
+}
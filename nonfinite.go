@@ -0,0 +1,95 @@
+package rtree
+
+import (
+	"fmt"
+	"math"
+)
+
+// NonFiniteHandling controls how bounding boxes containing NaN or infinite
+// coordinates are treated when inserted. A single NaN can otherwise poison
+// the bounding boxes of all ancestor nodes, breaking searches.
+type NonFiniteHandling int
+
+const (
+	// NonFiniteAllow inserts bounding boxes as they are. This is the
+	// default.
+	NonFiniteAllow NonFiniteHandling = iota
+
+	// NonFiniteReject causes insertion of the bounding box to fail.
+	NonFiniteReject
+
+	// NonFiniteClamp replaces infinite coordinates with the largest finite
+	// value of the same sign. NaN minimums are replaced with the smallest
+	// finite value, and NaN maximums with the largest finite value.
+	NonFiniteClamp
+
+	// NonFiniteQuarantine keeps the item out of the tree. Quarantined items
+	// are never returned by searches, but can be retrieved using
+	// Quarantined.
+	NonFiniteQuarantine
+)
+
+// Quarantined gives the items that were kept out of the tree because they
+// had non-finite bounding boxes and were inserted using
+// NonFiniteQuarantine.
+func (t *RTree) Quarantined() []Entry {
+	return append([]Entry(nil), t.quarantine...)
+}
+
+// admit applies the grid snapping and non-finite handling of the policy to
+// an entry that is about to be inserted. It reports if the entry should be
+// placed in the tree.
+func (t *RTree) admit(entry *Entry, policy InsertionPolicy) (bool, error) {
+	entry.BBox = policy.snap(entry.BBox)
+	if isFinite(entry.BBox) {
+		return true, nil
+	}
+	switch policy.nonFinite {
+	case NonFiniteReject:
+		return false, nonFiniteError(entry.BBox)
+	case NonFiniteClamp:
+		entry.BBox = BBox{
+			MinX: clampFloat(entry.BBox.MinX, -math.MaxFloat64),
+			MinY: clampFloat(entry.BBox.MinY, -math.MaxFloat64),
+			MaxX: clampFloat(entry.BBox.MaxX, math.MaxFloat64),
+			MaxY: clampFloat(entry.BBox.MaxY, math.MaxFloat64),
+		}
+		return true, nil
+	case NonFiniteQuarantine:
+		t.quarantine = append(t.quarantine, *entry)
+		t.generation++
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+// clampFloat replaces infinities with the largest finite value of the same
+// sign, and NaN with the given replacement.
+func clampFloat(f, nanReplacement float64) float64 {
+	switch {
+	case math.IsNaN(f):
+		return nanReplacement
+	case math.IsInf(f, 1):
+		return math.MaxFloat64
+	case math.IsInf(f, -1):
+		return -math.MaxFloat64
+	default:
+		return f
+	}
+}
+
+// nonFiniteError gives the error for a bounding box rejected by
+// NonFiniteReject.
+func nonFiniteError(bb BBox) error {
+	return fmt.Errorf("%w: has non-finite coordinates: %v", ErrInvalidBBox, bb)
+}
+
+func isFinite(bb BBox) bool {
+	for _, f := range [...]float64{bb.MinX, bb.MinY, bb.MaxX, bb.MaxY} {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return false
+		}
+	}
+	return true
+}
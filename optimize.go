@@ -0,0 +1,50 @@
+package rtree
+
+// Optimize renumbers the tree's nodes into depth-first order, so that the
+// root is first and each node's subtree is stored contiguously after it.
+// Splits append new nodes to the end of the Nodes slice, so the nodes of a
+// long-lived tree become scattered through memory. Renumbering them improves
+// the memory locality of searches. The items and shape of the tree are
+// unchanged.
+//
+// Since node indices change, Optimize counts as a modification of the tree.
+func (t *RTree) Optimize() {
+	if len(t.Nodes) == 0 {
+		return
+	}
+	order := make([]int, 0, len(t.Nodes))
+	var recurse func(int)
+	recurse = func(n int) {
+		order = append(order, n)
+		if t.Nodes[n].IsLeaf {
+			return
+		}
+		for _, e := range t.Nodes[n].Entries {
+			recurse(e.Index)
+		}
+	}
+	recurse(t.RootIndex)
+
+	newIndex := make([]int, len(t.Nodes))
+	for i, n := range order {
+		newIndex[n] = i
+	}
+	nodes := make([]Node, len(t.Nodes), cap(t.Nodes))
+	for i, n := range order {
+		node := t.Nodes[n]
+		if !node.IsLeaf {
+			for j := range node.Entries {
+				node.Entries[j].Index = newIndex[node.Entries[j].Index]
+			}
+		}
+		nodes[i] = node
+	}
+
+	// Keep any spare nodes left over from a previous Clear, so that their
+	// storage can still be reused.
+	copy(nodes[len(nodes):cap(nodes)], t.Nodes[len(t.Nodes):cap(t.Nodes)])
+	t.Nodes = nodes
+	t.RootIndex = 0
+	t.invalidateHint()
+	t.generation++
+}
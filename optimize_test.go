@@ -0,0 +1,62 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	var empty RTree
+	empty.Optimize()
+
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	boxes := make([]BBox, 300)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.InsertWithWeight(boxes[i], i, float64(i), ins)
+	}
+	nodes := len(rt.Nodes)
+	gen := rt.Generation()
+
+	rt.Optimize()
+	if rt.Generation() == gen {
+		t.Error("expected generation to change")
+	}
+	if len(rt.Nodes) != nodes || rt.RootIndex != 0 {
+		t.Fatalf("got %d nodes with root %d", len(rt.Nodes), rt.RootIndex)
+	}
+	checkInvariants(t, rt)
+	checkSearch(t, rt, boxes, rnd)
+
+	// Each node's first child immediately follows it, and each subsequent
+	// child follows the previous child's subtree.
+	var next int
+	var recurse func(int)
+	recurse = func(n int) {
+		if n != next {
+			t.Fatalf("node %d found at position %d", n, next)
+		}
+		next++
+		if rt.Nodes[n].IsLeaf {
+			return
+		}
+		for _, e := range rt.Nodes[n].Entries {
+			recurse(e.Index)
+		}
+	}
+	recurse(rt.RootIndex)
+
+	// The tree can continue to be modified.
+	for i := 0; i < 50; i++ {
+		bb := randomBox(rnd, 0.9, 0.1)
+		rt.Insert(bb, len(boxes), ins)
+		boxes = append(boxes, bb)
+	}
+	checkInvariants(t, rt)
+	checkSearch(t, rt, boxes, rnd)
+}
@@ -0,0 +1,172 @@
+package rtree
+
+import (
+	"fmt"
+	"math"
+)
+
+// PackedRTree is a read-only R-Tree with a structure-of-arrays layout. The
+// bounding box coordinates of the entries are stored in separate contiguous
+// slices (one per coordinate), with the entries of each node stored next to
+// each other. This makes the overlap tests performed during a search a tight
+// loop over contiguous floats, which is considerably more cache friendly
+// than the layout used by RTree.
+type PackedRTree struct {
+	minX, minY, maxX, maxY []float64
+
+	// The data index for leaf entries, and the node number for non-leaf
+	// entries. Exactly one of index32 and index64 is used (the other is nil),
+	// depending on the index width of the tree.
+	index32 []uint32
+	index64 []int64
+
+	// The entries of node n are at positions start[n] to start[n+1]
+	// (exclusive).
+	start  []int
+	isLeaf []bool
+}
+
+// IndexWidth selects the size of the integers that a PackedRTree uses to
+// store data indices and references to nodes.
+type IndexWidth int
+
+const (
+	// IndexWidthAuto uses unsigned 32-bit integers if all indices fit, and
+	// signed 64-bit integers otherwise.
+	IndexWidthAuto IndexWidth = iota
+
+	// IndexWidth32 always uses unsigned 32-bit integers, so data indices must
+	// be between 0 and math.MaxUint32.
+	IndexWidth32
+
+	// IndexWidth64 always uses signed 64-bit integers.
+	IndexWidth64
+)
+
+// Pack creates a PackedRTree containing the same items as the tree (other
+// than those marked as deleted by MarkDeleted). Later modifications to the
+// tree are not reflected in the packed tree.
+//
+// If all data indices fit in a uint32, then they are stored using 32 bits
+// rather than 64 bits, reducing the memory used by the packed tree.
+func (t *RTree) Pack() *PackedRTree {
+	p := t.pack()
+	p.compactIndices()
+	return p
+}
+
+// PackWithIndexWidth is like Pack, but stores indices using the given width.
+// An error wrapping ErrInvalidPolicy is returned if the width isn't one of
+// the IndexWidth constants, or if the width is IndexWidth32 and a data index
+// (or the number of entries) doesn't fit in a uint32.
+func (t *RTree) PackWithIndexWidth(width IndexWidth) (*PackedRTree, error) {
+	switch width {
+	case IndexWidthAuto, IndexWidth32, IndexWidth64:
+	default:
+		return nil, fmt.Errorf("%w: invalid index width %d", ErrInvalidPolicy, width)
+	}
+	p := t.pack()
+	switch width {
+	case IndexWidthAuto:
+		p.compactIndices()
+	case IndexWidth32:
+		if !p.compactIndices() {
+			return nil, fmt.Errorf("%w: index doesn't fit in 32 bits", ErrInvalidPolicy)
+		}
+	}
+	return p, nil
+}
+
+// pack creates a PackedRTree containing the same items as the tree, with
+// 64-bit indices.
+func (t *RTree) pack() *PackedRTree {
+	t = t.withoutTombstones()
+	p := new(PackedRTree)
+	if len(t.Nodes) == 0 {
+		return p
+	}
+
+	// Nodes are numbered in breadth first order, so that the entries of
+	// each node are laid out level by level.
+	queue := []int{t.RootIndex}
+	for len(queue) > 0 {
+		node := &t.Nodes[queue[0]]
+		queue = queue[1:]
+		p.start = append(p.start, len(p.index64))
+		p.isLeaf = append(p.isLeaf, node.IsLeaf)
+		for _, e := range node.Entries {
+			p.minX = append(p.minX, e.BBox.MinX)
+			p.minY = append(p.minY, e.BBox.MinY)
+			p.maxX = append(p.maxX, e.BBox.MaxX)
+			p.maxY = append(p.maxY, e.BBox.MaxY)
+			if node.IsLeaf {
+				p.index64 = append(p.index64, int64(e.Index))
+			} else {
+				p.index64 = append(p.index64, int64(len(p.isLeaf)+len(queue)))
+				queue = append(queue, e.Index)
+			}
+		}
+	}
+	p.start = append(p.start, len(p.index64))
+	return p
+}
+
+// compactIndices switches to 32-bit index storage if all indices fit,
+// reporting if it did so.
+func (p *PackedRTree) compactIndices() bool {
+	for _, idx := range p.index64 {
+		if idx < 0 || idx > math.MaxUint32 {
+			return false
+		}
+	}
+	p.index32 = make([]uint32, len(p.index64))
+	for i, idx := range p.index64 {
+		p.index32[i] = uint32(idx)
+	}
+	p.index64 = nil
+	return true
+}
+
+// MemoryUsage gives an estimate of the number of heap bytes used by the
+// packed tree.
+func (p *PackedRTree) MemoryUsage() int {
+	return 8*(cap(p.minX)+cap(p.minY)+cap(p.maxX)+cap(p.maxY)+cap(p.index64)+cap(p.start)) +
+		4*cap(p.index32) + cap(p.isLeaf)
+}
+
+// Search looks for any items in the tree that overlap with the the given
+// bounding box. The callback is called with the item index for each found
+// item.
+func (p *PackedRTree) Search(bb BBox, callback func(index int)) {
+	if len(p.isLeaf) == 0 {
+		return
+	}
+	stack := make([]int, 1, 32)
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		lo, hi := p.start[n], p.start[n+1]
+		minX, minY := p.minX[lo:hi], p.minY[lo:hi]
+		maxX, maxY := p.maxX[lo:hi], p.maxY[lo:hi]
+		leaf := p.isLeaf[n]
+		for i := range minX {
+			if minX[i] > bb.MaxX || maxX[i] < bb.MinX || minY[i] > bb.MaxY || maxY[i] < bb.MinY {
+				continue
+			}
+			idx := p.indexAt(lo + i)
+			if leaf {
+				callback(idx)
+			} else {
+				stack = append(stack, idx)
+			}
+		}
+	}
+}
+
+// indexAt gives the index stored for entry i.
+func (p *PackedRTree) indexAt(i int) int {
+	if p.index32 != nil {
+		return int(p.index32[i])
+	}
+	return int(p.index64[i])
+}
@@ -0,0 +1,149 @@
+package rtree
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPackedRTree(t *testing.T) {
+	for _, population := range []int{0, 1, 10, 500} {
+		rnd := rand.New(rand.NewSource(0))
+		ins, err := NewInsertionPolicy(2, 6)
+		if err != nil {
+			t.Fatal(err)
+		}
+		boxes := make([]BBox, population)
+		var rt RTree
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Insert(boxes[i], i, ins)
+		}
+		packed := rt.Pack()
+		for i := 0; i < 20; i++ {
+			searchBB := randomBox(rnd, 0.5, 0.5)
+			var want, got []int
+			rt.Search(searchBB, func(idx int) { want = append(want, idx) })
+			packed.Search(searchBB, func(idx int) { got = append(got, idx) })
+			sort.Ints(want)
+			sort.Ints(got)
+			if !reflect.DeepEqual(want, got) {
+				t.Fatalf("pop %d: got %v want %v", population, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(4, 16)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var rt RTree
+	for i := 0; i < 100000; i++ {
+		rt.Insert(randomBox(rnd, 0.99, 0.01), i, ins)
+	}
+	queries := make([]BBox, 1000)
+	for i := range queries {
+		queries[i] = randomBox(rnd, 0.9, 0.1)
+	}
+	b.Run("rtree", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rt.Search(queries[i%len(queries)], func(int) {})
+		}
+	})
+	packed := rt.Pack()
+	b.Run("packed", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			packed.Search(queries[i%len(queries)], func(int) {})
+		}
+	})
+}
+
+func TestPackedRTreeIndexWidth(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var small, large RTree
+	for i := 0; i < 100; i++ {
+		bb := BBox{float64(i), 0, float64(i) + 1, 1}
+		small.Insert(bb, i, ins)
+		large.Insert(bb, i<<40, ins)
+	}
+	ps, pl := small.Pack(), large.Pack()
+	if ps.index32 == nil || pl.index32 != nil {
+		t.Fatalf("unexpected index widths")
+	}
+	if ps.MemoryUsage() >= pl.MemoryUsage() {
+		t.Errorf("expected 32-bit indices to use less memory: %d vs %d",
+			ps.MemoryUsage(), pl.MemoryUsage())
+	}
+	var got []int
+	pl.Search(BBox{10.5, 0, 10.5, 1}, func(idx int) { got = append(got, idx) })
+	sort.Ints(got)
+	if !reflect.DeepEqual(got, []int{10 << 40}) {
+		t.Errorf("unexpected search result: %v", got)
+	}
+}
+
+func TestPackWithIndexWidth(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var small, unsigned, negative, large RTree
+	for i := 0; i < 100; i++ {
+		bb := BBox{float64(i), 0, float64(i) + 1, 1}
+		small.Insert(bb, i, ins)
+		unsigned.Insert(bb, math.MaxInt32+i, ins)
+		negative.Insert(bb, -i, ins)
+		large.Insert(bb, i<<40, ins)
+	}
+	for _, tc := range []struct {
+		tr     *RTree
+		width  IndexWidth
+		want32 bool
+		ok     bool
+	}{
+		{&small, IndexWidthAuto, true, true},
+		{&small, IndexWidth32, true, true},
+		{&small, IndexWidth64, false, true},
+		{&unsigned, IndexWidthAuto, true, true},
+		{&unsigned, IndexWidth32, true, true},
+		{&negative, IndexWidthAuto, false, true},
+		{&negative, IndexWidth32, false, false},
+		{&negative, IndexWidth64, false, true},
+		{&large, IndexWidthAuto, false, true},
+		{&large, IndexWidth32, false, false},
+		{&large, IndexWidth64, false, true},
+		{&small, IndexWidth(99), false, false},
+	} {
+		p, err := tc.tr.PackWithIndexWidth(tc.width)
+		if (err == nil) != tc.ok {
+			t.Errorf("width %d: unexpected error: %v", tc.width, err)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("width %d: unexpected error: %v", tc.width, err)
+		}
+		if err != nil {
+			continue
+		}
+		if got := p.index32 != nil; got != tc.want32 {
+			t.Errorf("width %d: got 32-bit indices %t, want %t", tc.width, got, tc.want32)
+		}
+		var want, got []int
+		tc.tr.Search(BBox{10.5, 0, 20.5, 1}, func(idx int) { want = append(want, idx) })
+		p.Search(BBox{10.5, 0, 20.5, 1}, func(idx int) { got = append(got, idx) })
+		sort.Ints(want)
+		sort.Ints(got)
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("width %d: got %v want %v", tc.width, got, want)
+		}
+	}
+}
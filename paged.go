@@ -0,0 +1,511 @@
+package rtree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Pager provides storage for the fixed-size pages that make up a PagedRTree.
+// Pages are identified by consecutive integers starting at 0.
+type Pager interface {
+	// ReadPage reads the page with the given id. It is only called for pages
+	// that have previously been written.
+	ReadPage(id int) ([]byte, error)
+
+	// WritePage writes the page with the given id. The data is always the
+	// same size for a particular tree. The pager must not retain data after
+	// WritePage returns.
+	WritePage(id int, data []byte) error
+}
+
+// MemPager is a Pager that stores pages in memory. Its zero value is an empty
+// pager that is ready to use.
+type MemPager struct {
+	pages map[int][]byte
+}
+
+// ReadPage implements the Pager interface.
+func (p *MemPager) ReadPage(id int) ([]byte, error) {
+	page, ok := p.pages[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: page %d does not exist", ErrNotFound, id)
+	}
+	return page, nil
+}
+
+// WritePage implements the Pager interface.
+func (p *MemPager) WritePage(id int, data []byte) error {
+	if p.pages == nil {
+		p.pages = make(map[int][]byte)
+	}
+	p.pages[id] = append([]byte(nil), data...)
+	return nil
+}
+
+// FilePager is a Pager that stores pages at fixed offsets in a file (or any
+// other random access storage).
+type FilePager struct {
+	rw interface {
+		io.ReaderAt
+		io.WriterAt
+	}
+	pageSize int
+}
+
+// NewFilePager creates a FilePager storing pages of the given size. The page
+// size must match the page size of the tree that uses the pager (see
+// PageSize).
+func NewFilePager(rw interface {
+	io.ReaderAt
+	io.WriterAt
+}, pageSize int) *FilePager {
+	return &FilePager{rw, pageSize}
+}
+
+// ReadPage implements the Pager interface.
+func (p *FilePager) ReadPage(id int) ([]byte, error) {
+	page := make([]byte, p.pageSize)
+	if _, err := p.rw.ReadAt(page, int64(id)*int64(p.pageSize)); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// WritePage implements the Pager interface.
+func (p *FilePager) WritePage(id int, data []byte) error {
+	if len(data) != p.pageSize {
+		return fmt.Errorf("page size mismatch: expected %d but got %d", p.pageSize, len(data))
+	}
+	_, err := p.rw.WriteAt(data, int64(id)*int64(p.pageSize))
+	return err
+}
+
+const (
+	pagedMagic      = "RTPG"
+	metaPageID      = 0
+	metaPageSize    = 40
+	nodeHeaderSize  = 8
+	entryRecordSize = 48
+)
+
+// PageSize gives the size of the pages used by a PagedRTree with the given
+// insertion policy.
+func PageSize(policy InsertionPolicy) int {
+	size := nodeHeaderSize + policy.maxChildren*entryRecordSize
+	if size < metaPageSize {
+		size = metaPageSize
+	}
+	return size
+}
+
+// PagedRTree is an R-Tree whose nodes are each stored in a fixed-size page,
+// fetched and stored through a Pager. Only the nodes needed by an operation
+// are read, so the tree may be larger than the available memory.
+//
+// Page 0 holds metadata about the tree, and each other page holds a single
+// node. Pages belonging to nodes removed by deletion are not reused.
+type PagedRTree struct {
+	pager     Pager
+	wal       *walPager  // nil unless a write-ahead log is used
+	cache     *nodeCache // nil unless caching is enabled
+	policy    InsertionPolicy
+	pageSize  int
+	root      int // 0 if the tree is empty
+	pageCount int
+	size      int
+}
+
+// NewPagedRTree creates a new empty tree, stored using the pager. An error is
+// returned if the policy is the zero value.
+func NewPagedRTree(pager Pager, policy InsertionPolicy) (*PagedRTree, error) {
+	if err := policy.check(); err != nil {
+		return nil, err
+	}
+	t := &PagedRTree{
+		pager:     pager,
+		policy:    policy,
+		pageSize:  PageSize(policy),
+		pageCount: 1,
+	}
+	if err := t.writeMeta(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// OpenPagedRTree opens an existing tree previously created by NewPagedRTree.
+func OpenPagedRTree(pager Pager) (*PagedRTree, error) {
+	page, err := pager.ReadPage(metaPageID)
+	if err != nil {
+		return nil, err
+	}
+	if len(page) < metaPageSize || string(page[:4]) != pagedMagic {
+		return nil, fmt.Errorf("%w: not a paged rtree", ErrCorruptTree)
+	}
+	le := binary.LittleEndian
+	policy, err := NewInsertionPolicy(int(le.Uint32(page[4:])), int(le.Uint32(page[8:])))
+	if err != nil {
+		return nil, err
+	}
+	return &PagedRTree{
+		pager:     pager,
+		policy:    policy,
+		pageSize:  PageSize(policy),
+		root:      int(le.Uint64(page[16:])),
+		pageCount: int(le.Uint64(page[24:])),
+		size:      int(le.Uint64(page[32:])),
+	}, nil
+}
+
+// Len gives the number of items in the tree.
+func (t *PagedRTree) Len() int {
+	return t.size
+}
+
+// mutate runs a modification of the tree. If a write-ahead log is in use,
+// then the modification is committed to the log as a single unit, and is
+// rolled back if it fails.
+func (t *PagedRTree) mutate(fn func() error) error {
+	if t.wal == nil {
+		return fn()
+	}
+	root, pageCount, size := t.root, t.pageCount, t.size
+	err := fn()
+	if err == nil {
+		err = t.wal.commit()
+	}
+	if err != nil {
+		t.wal.rollback()
+		t.root, t.pageCount, t.size = root, pageCount, size
+		if t.cache != nil {
+			t.cache.clear()
+		}
+	}
+	return err
+}
+
+func (t *PagedRTree) writeMeta() error {
+	page := make([]byte, t.pageSize)
+	le := binary.LittleEndian
+	copy(page, pagedMagic)
+	le.PutUint32(page[4:], uint32(t.policy.minChildren))
+	le.PutUint32(page[8:], uint32(t.policy.maxChildren))
+	le.PutUint64(page[16:], uint64(t.root))
+	le.PutUint64(page[24:], uint64(t.pageCount))
+	le.PutUint64(page[32:], uint64(t.size))
+	return t.pager.WritePage(metaPageID, page)
+}
+
+func (t *PagedRTree) readNode(id int) (Node, error) {
+	if t.cache != nil {
+		if node, ok := t.cache.get(id); ok {
+			return node, nil
+		}
+	}
+	page, err := t.pager.ReadPage(id)
+	if err != nil {
+		return Node{}, err
+	}
+	node, err := decodeNode(page)
+	if err == nil && t.cache != nil {
+		t.cache.put(id, node)
+	}
+	return node, err
+}
+
+func (t *PagedRTree) writeNode(id int, node Node) error {
+	page := make([]byte, t.pageSize)
+	encodeNode(page, node)
+	if err := t.pager.WritePage(id, page); err != nil {
+		return err
+	}
+	if t.cache != nil {
+		t.cache.put(id, node)
+	}
+	return nil
+}
+
+func (t *PagedRTree) allocPage() int {
+	t.pageCount++
+	return t.pageCount - 1
+}
+
+func encodeNode(page []byte, node Node) {
+	le := binary.LittleEndian
+	if node.IsLeaf {
+		page[0] = 1
+	}
+	le.PutUint32(page[4:], uint32(len(node.Entries)))
+	for i, e := range node.Entries {
+		encodeEntryRecord(page[nodeHeaderSize+i*entryRecordSize:], e)
+	}
+}
+
+// encodeEntryRecord encodes an entry into a fixed size record.
+func encodeEntryRecord(rec []byte, e Entry) {
+	le := binary.LittleEndian
+	le.PutUint64(rec[0:], math.Float64bits(e.BBox.MinX))
+	le.PutUint64(rec[8:], math.Float64bits(e.BBox.MinY))
+	le.PutUint64(rec[16:], math.Float64bits(e.BBox.MaxX))
+	le.PutUint64(rec[24:], math.Float64bits(e.BBox.MaxY))
+	le.PutUint64(rec[32:], uint64(e.Index))
+	le.PutUint64(rec[40:], e.Payload)
+}
+
+// decodeEntryRecord decodes a record encoded by encodeEntryRecord.
+func decodeEntryRecord(rec []byte) Entry {
+	le := binary.LittleEndian
+	return Entry{
+		BBox: BBox{
+			MinX: math.Float64frombits(le.Uint64(rec[0:])),
+			MinY: math.Float64frombits(le.Uint64(rec[8:])),
+			MaxX: math.Float64frombits(le.Uint64(rec[16:])),
+			MaxY: math.Float64frombits(le.Uint64(rec[24:])),
+		},
+		Index:   int(le.Uint64(rec[32:])),
+		Payload: le.Uint64(rec[40:]),
+	}
+}
+
+func decodeNode(page []byte) (Node, error) {
+	le := binary.LittleEndian
+	if len(page) < nodeHeaderSize {
+		return Node{}, fmt.Errorf("%w: node page too short", ErrCorruptTree)
+	}
+	n := int(le.Uint32(page[4:]))
+	if len(page) < nodeHeaderSize+n*entryRecordSize {
+		return Node{}, fmt.Errorf("%w: node page too short", ErrCorruptTree)
+	}
+	node := Node{IsLeaf: page[0] == 1, Entries: make([]Entry, n)}
+	for i := range node.Entries {
+		node.Entries[i] = decodeEntryRecord(page[nodeHeaderSize+i*entryRecordSize:])
+	}
+	return node, nil
+}
+
+// nodeBound calculates the smallest bounding box that fits a node.
+func nodeBound(node Node) BBox {
+	bb := EmptyBBox
+	for _, entry := range node.Entries {
+		bb = combine(bb, entry.BBox)
+	}
+	return bb
+}
+
+// Search looks for any items in the tree that overlap with the the given
+// bounding box. The callback is called with the item index for each found
+// item.
+func (t *PagedRTree) Search(bb BBox, callback func(index int)) error {
+	if t.root == 0 {
+		return nil
+	}
+	var recurse func(int) error
+	recurse = func(id int) error {
+		node, err := t.readNode(id)
+		if err != nil {
+			return err
+		}
+		for _, entry := range node.Entries {
+			if !overlap(entry.BBox, bb) {
+				continue
+			}
+			if node.IsLeaf {
+				callback(entry.Index)
+			} else if err := recurse(entry.Index); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return recurse(t.root)
+}
+
+// pathStep records a node visited during a descent from the root, along with
+// the entry that was followed.
+type pathStep struct {
+	id    int
+	node  Node
+	entry int
+}
+
+// Insert adds a new data item to the tree.
+func (t *PagedRTree) Insert(bb BBox, dataIndex int) error {
+	return t.mutate(func() error {
+		return t.insert(bb, dataIndex)
+	})
+}
+
+func (t *PagedRTree) insert(bb BBox, dataIndex int) error {
+	newEntry := Entry{BBox: bb, Index: dataIndex}
+	if t.root == 0 {
+		t.root = t.allocPage()
+		t.size++
+		leaf := Node{IsLeaf: true, Entries: []Entry{newEntry}}
+		if err := t.writeNode(t.root, leaf); err != nil {
+			return err
+		}
+		return t.writeMeta()
+	}
+
+	// Descend to the leaf, recording the path taken.
+	var path []pathStep
+	id := t.root
+	for {
+		node, err := t.readNode(id)
+		if err != nil {
+			return err
+		}
+		if node.IsLeaf {
+			node.Entries = append(node.Entries, newEntry)
+			path = append(path, pathStep{id, node, -1})
+			break
+		}
+		best := 0
+		bestDelta := enlargement(node.Entries[0].BBox, bb)
+		for i, e := range node.Entries[1:] {
+			delta := enlargement(e.BBox, bb)
+			if delta < bestDelta || (delta == bestDelta && area(e.BBox) < area(node.Entries[best].BBox)) {
+				best = i + 1
+				bestDelta = delta
+			}
+		}
+		path = append(path, pathStep{id, node, best})
+		id = node.Entries[best].Index
+	}
+
+	// Ascend back to the root, splitting overfull nodes and adjusting
+	// bounding boxes.
+	for i := len(path) - 1; i >= 0; i-- {
+		step := path[i]
+		var split *Node
+		var splitID int
+		if len(step.node.Entries) > t.policy.maxChildren {
+			var a, b []Entry
+			if len(step.node.Entries) <= maxExhaustiveSplit {
+				a, b = exhaustiveSplit(step.node.Entries, t.policy)
+			} else {
+				a, b = quadraticSplit(step.node.Entries, t.policy)
+			}
+			step.node.Entries = a
+			split = &Node{IsLeaf: step.node.IsLeaf, Entries: b}
+			splitID = t.allocPage()
+			if err := t.writeNode(splitID, *split); err != nil {
+				return err
+			}
+		}
+		if err := t.writeNode(step.id, step.node); err != nil {
+			return err
+		}
+
+		if i == 0 {
+			if split != nil {
+				t.root = t.allocPage()
+				root := Node{Entries: []Entry{
+					{BBox: nodeBound(step.node), Index: step.id},
+					{BBox: nodeBound(*split), Index: splitID},
+				}}
+				if err := t.writeNode(t.root, root); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		parent := &path[i-1]
+		parent.node.Entries[parent.entry].BBox = nodeBound(step.node)
+		if split != nil {
+			parent.node.Entries = append(parent.node.Entries, Entry{
+				BBox: nodeBound(*split), Index: splitID,
+			})
+		}
+	}
+
+	t.size++
+	return t.writeMeta()
+}
+
+// Delete removes a single item matching the bounding box and data index from
+// the tree. It returns false if no item matched.
+func (t *PagedRTree) Delete(bb BBox, dataIndex int) (bool, error) {
+	var found bool
+	err := t.mutate(func() error {
+		var err error
+		found, err = t.delete(bb, dataIndex)
+		return err
+	})
+	return found, err
+}
+
+func (t *PagedRTree) delete(bb BBox, dataIndex int) (bool, error) {
+	if t.root == 0 {
+		return false, nil
+	}
+
+	// Find the path to the leaf containing the item.
+	var path []pathStep
+	var find func(int) (bool, error)
+	find = func(id int) (bool, error) {
+		node, err := t.readNode(id)
+		if err != nil {
+			return false, err
+		}
+		for i, e := range node.Entries {
+			if node.IsLeaf {
+				if e.Index == dataIndex && e.BBox == bb {
+					path = append(path, pathStep{id, node, i})
+					return true, nil
+				}
+				continue
+			}
+			if !contains(e.BBox, bb) {
+				continue
+			}
+			found, err := find(e.Index)
+			if err != nil || found {
+				path = append(path, pathStep{id, node, i})
+				return found, err
+			}
+		}
+		return false, nil
+	}
+	found, err := find(t.root)
+	if err != nil || !found {
+		return false, err
+	}
+
+	// The path was built leaf first. Remove the item, then condense the
+	// path by removing empty nodes and tightening bounding boxes.
+	removeChild := true
+	for i, step := range path {
+		if removeChild {
+			step.node.Entries = append(step.node.Entries[:step.entry], step.node.Entries[step.entry+1:]...)
+		} else {
+			child := path[i-1]
+			step.node.Entries[step.entry].BBox = nodeBound(child.node)
+		}
+		removeChild = len(step.node.Entries) == 0
+		if removeChild && i != len(path)-1 {
+			continue
+		}
+		if err := t.writeNode(step.id, step.node); err != nil {
+			return false, err
+		}
+		path[i] = step
+	}
+
+	root := path[len(path)-1].node
+	switch {
+	case len(root.Entries) == 0:
+		t.root = 0
+	case !root.IsLeaf && len(root.Entries) == 1:
+		for !root.IsLeaf && len(root.Entries) == 1 {
+			t.root = root.Entries[0].Index
+			if root, err = t.readNode(t.root); err != nil {
+				return false, err
+			}
+		}
+	}
+	t.size--
+	return true, t.writeMeta()
+}
@@ -0,0 +1,136 @@
+package rtree
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPagedRTree(t *testing.T) {
+	f, err := ioutil.TempFile("", "rtree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	for _, maxCapacity := range []int{2, 4, 20} {
+		ins, err := NewInsertionPolicy(maxCapacity/2, maxCapacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pagers := map[string]Pager{
+			"mem":  new(MemPager),
+			"file": NewFilePager(f, PageSize(ins)),
+		}
+		for name, pager := range pagers {
+			rnd := rand.New(rand.NewSource(0))
+			tr, err := NewPagedRTree(pager, ins)
+			if err != nil {
+				t.Fatal(err)
+			}
+			boxes := make([]BBox, 200)
+			for i := range boxes {
+				boxes[i] = randomBox(rnd, 0.9, 0.1)
+				if err := tr.Insert(boxes[i], i); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkPagedSearch(t, tr, boxes, rnd)
+
+			for i := 0; i < len(boxes); i += 3 {
+				ok, err := tr.Delete(boxes[i], i)
+				if err != nil || !ok {
+					t.Fatalf("%s: could not delete %d: %v", name, i, err)
+				}
+				boxes[i] = BBox{-2, -2, -1, -1}
+			}
+			if ok, err := tr.Delete(BBox{}, len(boxes)); ok || err != nil {
+				t.Fatalf("%s: unexpected delete result: %v %v", name, ok, err)
+			}
+
+			reopened, err := OpenPagedRTree(pager)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reopened.Len() != tr.Len() {
+				t.Errorf("%s: reopened tree has %d items, want %d", name, reopened.Len(), tr.Len())
+			}
+			checkPagedSearch(t, reopened, boxes, rnd)
+		}
+	}
+}
+
+func checkPagedSearch(t *testing.T, tr *PagedRTree, boxes []BBox, rnd *rand.Rand) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		searchBB := randomBox(rnd, 0.5, 0.5)
+		var got, want []int
+		if err := tr.Search(searchBB, func(idx int) {
+			got = append(got, idx)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		for j, bb := range boxes {
+			if overlap(bb, searchBB) {
+				want = append(want, j)
+			}
+		}
+		sort.Ints(got)
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("search failed, got: %v want: %v", got, want)
+		}
+	}
+}
+
+func TestPagedRTreeCache(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	tr, err := NewPagedRTree(new(MemPager), ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetCacheCapacity(8)
+	boxes := make([]BBox, 200)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		if err := tr.Insert(boxes[i], i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < len(boxes); i += 5 {
+		if ok, err := tr.Delete(boxes[i], i); err != nil || !ok {
+			t.Fatalf("could not delete %d: %v", i, err)
+		}
+		boxes[i] = BBox{-2, -2, -1, -1}
+	}
+	checkPagedSearch(t, tr, boxes, rnd)
+
+	stats := tr.CacheStats()
+	if stats.Hits == 0 || stats.Misses == 0 {
+		t.Errorf("unexpected cache stats: %+v", stats)
+	}
+	if tr.cache.lru.Len() > 8 {
+		t.Errorf("cache exceeded capacity: %d", tr.cache.lru.Len())
+	}
+}
+
+func TestPagedRTreeZeroPolicy(t *testing.T) {
+	pager := new(MemPager)
+	if _, err := NewPagedRTree(pager, InsertionPolicy{}); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("got %v, want ErrInvalidPolicy", err)
+	}
+	if _, err := NewPagedRTreeWithWAL(pager, new(memLog), InsertionPolicy{}); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("got %v, want ErrInvalidPolicy with a write-ahead log", err)
+	}
+	if _, err := pager.ReadPage(metaPageID); err == nil {
+		t.Fatal("expected nothing to have been written")
+	}
+}
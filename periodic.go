@@ -0,0 +1,42 @@
+package rtree
+
+// Period describes a space that wraps around (i.e. has periodic boundary
+// conditions) in X and/or Y. For example, longitudes in the range 0 to 360
+// wrap around with a period of 360 in X. A zero period means that the space
+// doesn't wrap in that direction.
+//
+// All bounding boxes (both stored and used in queries) should have their
+// minimum coordinates within the same canonical range of one period, e.g.
+// [0, 360). A bounding box that crosses the boundary of the range should
+// extend past the upper end of the range, e.g. MinX=350, MaxX=370.
+type Period struct {
+	X, Y float64
+}
+
+// shifts gives the offsets that a query box must be translated by to find all
+// overlapping boxes in the wrapped space.
+func (p Period) shifts(period float64) []float64 {
+	if period > 0 {
+		return []float64{0, -period, period}
+	}
+	return []float64{0}
+}
+
+// wrappedQueries gives the query boxes equivalent to bb in the wrapped space.
+func (p Period) wrappedQueries(bb BBox) []BBox {
+	if p.X <= 0 && p.Y <= 0 {
+		return []BBox{bb}
+	}
+	var queries []BBox
+	for _, dx := range p.shifts(p.X) {
+		for _, dy := range p.shifts(p.Y) {
+			queries = append(queries, BBox{
+				MinX: bb.MinX + dx,
+				MinY: bb.MinY + dy,
+				MaxX: bb.MaxX + dx,
+				MaxY: bb.MaxY + dy,
+			})
+		}
+	}
+	return queries
+}
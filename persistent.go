@@ -0,0 +1,196 @@
+package rtree
+
+// PersistentRTree is an immutable R-Tree. Rather than modifying the tree in
+// place, Insert and Delete return a new tree that shares all unmodified nodes
+// with the original tree. This makes it cheap to keep old versions of a
+// tree, and allows trees to be shared between goroutines without locking.
+//
+// The zero value is an empty tree.
+type PersistentRTree struct {
+	root *persistentNode
+	size int
+}
+
+type persistentNode struct {
+	isLeaf  bool
+	entries []persistentEntry
+}
+
+type persistentEntry struct {
+	bbox  BBox
+	child *persistentNode // nil for leaf entries
+	index int
+}
+
+// Len gives the number of items in the tree.
+func (t PersistentRTree) Len() int {
+	return t.size
+}
+
+// Search looks for any items in the tree that overlap with the the given
+// bounding box. The callback is called with the item index for each found
+// item.
+func (t PersistentRTree) Search(bb BBox, callback func(index int)) {
+	if t.root == nil {
+		return
+	}
+	var recurse func(*persistentNode)
+	recurse = func(n *persistentNode) {
+		for _, entry := range n.entries {
+			if !overlap(entry.bbox, bb) {
+				continue
+			}
+			if n.isLeaf {
+				callback(entry.index)
+			} else {
+				recurse(entry.child)
+			}
+		}
+	}
+	recurse(t.root)
+}
+
+// Insert returns a new tree containing the items of the original tree, plus
+// the new data item. The original tree is unchanged.
+func (t PersistentRTree) Insert(bb BBox, dataIndex int, policy InsertionPolicy) PersistentRTree {
+	entry := persistentEntry{bbox: bb, index: dataIndex}
+	if t.root == nil {
+		root := &persistentNode{isLeaf: true, entries: []persistentEntry{entry}}
+		return PersistentRTree{root: root, size: 1}
+	}
+
+	n1, n2 := persistentInsert(t.root, entry, policy)
+	root := n1
+	if n2 != nil {
+		root = &persistentNode{entries: []persistentEntry{
+			{bbox: n1.bound(), child: n1},
+			{bbox: n2.bound(), child: n2},
+		}}
+	}
+	return PersistentRTree{root: root, size: t.size + 1}
+}
+
+// persistentInsert inserts the entry into a copy of the subtree rooted at n.
+// If the copy had to be split, then the second node is non-nil.
+func persistentInsert(n *persistentNode, entry persistentEntry, policy InsertionPolicy) (*persistentNode, *persistentNode) {
+	entries := make([]persistentEntry, len(n.entries), len(n.entries)+1)
+	copy(entries, n.entries)
+
+	if n.isLeaf {
+		entries = append(entries, entry)
+	} else {
+		best := 0
+		bestDelta := enlargement(entries[0].bbox, entry.bbox)
+		for i, e := range entries[1:] {
+			delta := enlargement(e.bbox, entry.bbox)
+			if delta < bestDelta || (delta == bestDelta && area(e.bbox) < area(entries[best].bbox)) {
+				best = i + 1
+				bestDelta = delta
+			}
+		}
+		c1, c2 := persistentInsert(entries[best].child, entry, policy)
+		entries[best] = persistentEntry{bbox: c1.bound(), child: c1}
+		if c2 != nil {
+			entries = append(entries, persistentEntry{bbox: c2.bound(), child: c2})
+		}
+	}
+
+	if len(entries) <= policy.maxChildren {
+		return &persistentNode{isLeaf: n.isLeaf, entries: entries}, nil
+	}
+	a, b := splitPersistentEntries(entries, policy)
+	return &persistentNode{isLeaf: n.isLeaf, entries: a},
+		&persistentNode{isLeaf: n.isLeaf, entries: b}
+}
+
+// splitPersistentEntries partitions entries into two groups, using the same
+// algorithms as for an RTree.
+func splitPersistentEntries(entries []persistentEntry, policy InsertionPolicy) ([]persistentEntry, []persistentEntry) {
+	proxies := make([]Entry, len(entries))
+	for i, e := range entries {
+		proxies[i] = Entry{BBox: e.bbox, Index: i}
+	}
+	var proxiesA, proxiesB []Entry
+	if len(proxies) <= maxExhaustiveSplit {
+		proxiesA, proxiesB = exhaustiveSplit(proxies, policy)
+	} else {
+		proxiesA, proxiesB = quadraticSplit(proxies, policy)
+	}
+	a := make([]persistentEntry, len(proxiesA))
+	for i, p := range proxiesA {
+		a[i] = entries[p.Index]
+	}
+	b := make([]persistentEntry, len(proxiesB))
+	for i, p := range proxiesB {
+		b[i] = entries[p.Index]
+	}
+	return a, b
+}
+
+// Delete returns a new tree with a single data item matching the bounding box
+// and data index removed. The original tree is unchanged. If no item
+// matches, then the original tree is returned and the boolean result is
+// false.
+func (t PersistentRTree) Delete(bb BBox, dataIndex int) (PersistentRTree, bool) {
+	if t.root == nil {
+		return t, false
+	}
+	root, ok := persistentDelete(t.root, bb, dataIndex)
+	if !ok {
+		return t, false
+	}
+	for root != nil && !root.isLeaf && len(root.entries) == 1 {
+		root = root.entries[0].child
+	}
+	return PersistentRTree{root: root, size: t.size - 1}, true
+}
+
+// persistentDelete removes the item from a copy of the subtree rooted at n.
+// The returned node is nil if the copy would be empty.
+func persistentDelete(n *persistentNode, bb BBox, dataIndex int) (*persistentNode, bool) {
+	for i, e := range n.entries {
+		if n.isLeaf {
+			if e.index != dataIndex || e.bbox != bb {
+				continue
+			}
+			return n.without(i), true
+		}
+
+		if !contains(e.bbox, bb) {
+			continue
+		}
+		child, ok := persistentDelete(e.child, bb, dataIndex)
+		if !ok {
+			continue
+		}
+		if child == nil {
+			return n.without(i), true
+		}
+		entries := make([]persistentEntry, len(n.entries))
+		copy(entries, n.entries)
+		entries[i] = persistentEntry{bbox: child.bound(), child: child}
+		return &persistentNode{isLeaf: n.isLeaf, entries: entries}, true
+	}
+	return n, false
+}
+
+// without gives a copy of the node with entry i removed. If the copy would be
+// empty, then nil is returned.
+func (n *persistentNode) without(i int) *persistentNode {
+	if len(n.entries) == 1 {
+		return nil
+	}
+	entries := make([]persistentEntry, 0, len(n.entries)-1)
+	entries = append(entries, n.entries[:i]...)
+	entries = append(entries, n.entries[i+1:]...)
+	return &persistentNode{isLeaf: n.isLeaf, entries: entries}
+}
+
+// bound calculates the smallest bounding box that fits the node.
+func (n *persistentNode) bound() BBox {
+	bb := EmptyBBox
+	for _, e := range n.entries {
+		bb = combine(bb, e.bbox)
+	}
+	return bb
+}
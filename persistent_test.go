@@ -0,0 +1,71 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestPersistentRTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const population = 100
+	boxes := make([]BBox, population)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+	}
+
+	// Each version i contains the first i boxes.
+	versions := []PersistentRTree{{}}
+	for i, bb := range boxes {
+		versions = append(versions, versions[i].Insert(bb, i, ins))
+	}
+	for i, v := range versions {
+		if v.Len() != i {
+			t.Fatalf("version %d has length %d", i, v.Len())
+		}
+		checkPersistentSearch(t, v, boxes[:i], rnd)
+	}
+
+	// Delete every other item, checking that the original is unaffected.
+	tr := versions[population]
+	for i := 0; i < population; i += 2 {
+		var ok bool
+		tr, ok = tr.Delete(boxes[i], i)
+		if !ok {
+			t.Fatalf("could not delete item %d", i)
+		}
+	}
+	if _, ok := tr.Delete(boxes[0], 0); ok {
+		t.Fatalf("deleted item twice")
+	}
+	remaining := make([]BBox, population)
+	for i := range remaining {
+		if i%2 == 0 {
+			remaining[i] = BBox{-2, -2, -1, -1}
+		} else {
+			remaining[i] = boxes[i]
+		}
+	}
+	checkPersistentSearch(t, tr, remaining, rnd)
+	checkPersistentSearch(t, versions[population], boxes, rnd)
+}
+
+func checkPersistentSearch(t *testing.T, tr PersistentRTree, boxes []BBox, rnd *rand.Rand) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		searchBB := randomBox(rnd, 0.5, 0.5)
+		got := make(map[int]bool)
+		tr.Search(searchBB, func(idx int) {
+			got[idx] = true
+		})
+		for j, bb := range boxes {
+			if overlap(bb, searchBB) != got[j] {
+				t.Fatalf("search mismatch for item %d", j)
+			}
+		}
+	}
+}
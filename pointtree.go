@@ -0,0 +1,209 @@
+package rtree
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// PointTree is an R-Tree specialised for point data. Its leaves store the
+// (x, y) coordinates of each point rather than a full bounding box, which
+// uses much less memory than storing degenerate bounding boxes in an RTree,
+// and makes distance calculations for leaf entries cheaper.
+//
+// Overfull nodes are split by sorting their entries along the axis with the
+// largest spread and dividing them in half.
+//
+// The zero value is an empty tree.
+type PointTree struct {
+	nodes []pointNode
+	root  int
+	size  int
+}
+
+// pointNode is a node in a PointTree. Leaf nodes only use points, and other
+// nodes only use children.
+type pointNode struct {
+	isLeaf   bool
+	points   []pointEntry
+	children []pointChild
+}
+
+type pointEntry struct {
+	x, y  float64
+	index int
+}
+
+type pointChild struct {
+	bbox  BBox
+	index int
+}
+
+// Len gives the number of points in the tree.
+func (t *PointTree) Len() int {
+	return t.size
+}
+
+// Insert adds a new point to the tree.
+func (t *PointTree) Insert(x, y float64, dataIndex int, policy InsertionPolicy) {
+	t.size++
+	if len(t.nodes) == 0 {
+		t.nodes = append(t.nodes, pointNode{isLeaf: true})
+		t.root = 0
+	}
+	if sibling := t.insert(t.root, pointEntry{x, y, dataIndex}, policy); sibling != -1 {
+		old := t.root
+		t.nodes = append(t.nodes, pointNode{children: []pointChild{
+			{t.bound(old), old},
+			{t.bound(sibling), sibling},
+		}})
+		t.root = len(t.nodes) - 1
+	}
+}
+
+// insert adds the point to the subtree rooted at n. If n had to be split,
+// then the index of the new sibling node is returned, otherwise -1.
+func (t *PointTree) insert(n int, p pointEntry, policy InsertionPolicy) int {
+	node := &t.nodes[n]
+	if node.isLeaf {
+		node.points = append(node.points, p)
+		if len(node.points) <= policy.forNode(true).maxChildren {
+			return -1
+		}
+		splitPoints(node.points)
+		half := len(node.points) / 2
+		moved := append([]pointEntry(nil), node.points[half:]...)
+		node.points = node.points[:half]
+		t.nodes = append(t.nodes, pointNode{isLeaf: true, points: moved})
+		return len(t.nodes) - 1
+	}
+
+	pbb := BBox{p.x, p.y, p.x, p.y}
+	best := 0
+	bestDelta := enlargement(node.children[0].bbox, pbb)
+	for i, c := range node.children[1:] {
+		delta := enlargement(c.bbox, pbb)
+		if delta < bestDelta || (delta == bestDelta && area(c.bbox) < area(node.children[best].bbox)) {
+			best, bestDelta = i+1, delta
+		}
+	}
+	child := node.children[best].index
+	sibling := t.insert(child, p, policy)
+	node = &t.nodes[n] // the insertion may have moved the nodes
+	node.children[best].bbox = combine(node.children[best].bbox, pbb)
+	if sibling == -1 {
+		return -1
+	}
+	node.children[best].bbox = t.bound(child)
+	node.children = append(node.children, pointChild{t.bound(sibling), sibling})
+	if len(node.children) <= policy.maxChildren {
+		return -1
+	}
+	splitChildren(node.children)
+	half := len(node.children) / 2
+	moved := append([]pointChild(nil), node.children[half:]...)
+	node.children = node.children[:half]
+	t.nodes = append(t.nodes, pointNode{children: moved})
+	return len(t.nodes) - 1
+}
+
+// splitPoints sorts points along the axis with the largest spread, so that
+// they can be split in half.
+func splitPoints(points []pointEntry) {
+	bb := EmptyBBox
+	for _, p := range points {
+		bb = combine(bb, BBox{p.x, p.y, p.x, p.y})
+	}
+	if bb.MaxX-bb.MinX >= bb.MaxY-bb.MinY {
+		sort.Slice(points, func(i, j int) bool { return points[i].x < points[j].x })
+	} else {
+		sort.Slice(points, func(i, j int) bool { return points[i].y < points[j].y })
+	}
+}
+
+// splitChildren sorts children by the centres of their bounding boxes along
+// the axis with the largest spread, so that they can be split in half.
+func splitChildren(children []pointChild) {
+	bb := EmptyBBox
+	for _, c := range children {
+		bb = combine(bb, c.bbox)
+	}
+	if bb.MaxX-bb.MinX >= bb.MaxY-bb.MinY {
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].bbox.MinX+children[i].bbox.MaxX < children[j].bbox.MinX+children[j].bbox.MaxX
+		})
+	} else {
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].bbox.MinY+children[i].bbox.MaxY < children[j].bbox.MinY+children[j].bbox.MaxY
+		})
+	}
+}
+
+// bound gives the smallest bounding box containing everything in node n.
+func (t *PointTree) bound(n int) BBox {
+	bb := EmptyBBox
+	node := &t.nodes[n]
+	for _, p := range node.points {
+		bb = combine(bb, BBox{p.x, p.y, p.x, p.y})
+	}
+	for _, c := range node.children {
+		bb = combine(bb, c.bbox)
+	}
+	return bb
+}
+
+// Search looks for any points in the tree that are inside the given bounding
+// box (including on its boundary). The callback is called with the data
+// index of each found point.
+func (t *PointTree) Search(bb BBox, callback func(index int)) {
+	if len(t.nodes) == 0 {
+		return
+	}
+	var recurse func(int)
+	recurse = func(n int) {
+		node := &t.nodes[n]
+		for _, p := range node.points {
+			if p.x >= bb.MinX && p.x <= bb.MaxX && p.y >= bb.MinY && p.y <= bb.MaxY {
+				callback(p.index)
+			}
+		}
+		for _, c := range node.children {
+			if overlap(c.bbox, bb) {
+				recurse(c.index)
+			}
+		}
+	}
+	recurse(t.root)
+}
+
+// Nearest gives the data indices of (up to) the k points nearest to (x, y),
+// ordered from nearest to farthest.
+func (t *PointTree) Nearest(x, y float64, k int) []int {
+	if k <= 0 || len(t.nodes) == 0 {
+		return nil
+	}
+	var result []int
+	var queue nearestQueue
+	pushNode := func(n int) {
+		node := &t.nodes[n]
+		for _, p := range node.points {
+			d := math.Hypot(p.x-x, p.y-y)
+			heap.Push(&queue, nearestCandidate{dist: d, entry: Entry{Index: p.index}, isLeaf: true})
+		}
+		for _, c := range node.children {
+			dx := rangeDistance(x, c.bbox.MinX, c.bbox.MaxX)
+			dy := rangeDistance(y, c.bbox.MinY, c.bbox.MaxY)
+			heap.Push(&queue, nearestCandidate{dist: math.Hypot(dx, dy), entry: Entry{Index: c.index}})
+		}
+	}
+	pushNode(t.root)
+	for queue.Len() > 0 && len(result) < k {
+		c := heap.Pop(&queue).(nearestCandidate)
+		if c.isLeaf {
+			result = append(result, c.entry.Index)
+		} else {
+			pushNode(c.entry.Index)
+		}
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package rtree
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestPointTree(t *testing.T) {
+	for _, population := range []int{0, 1, 5, 50, 500} {
+		t.Run(fmt.Sprintf("pop_%d", population), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(0))
+			ins, err := NewInsertionPolicy(2, 6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var pt PointTree
+			xs := make([]float64, population)
+			ys := make([]float64, population)
+			for i := range xs {
+				// Snap some points to a grid so there are duplicates.
+				xs[i], ys[i] = rnd.Float64(), rnd.Float64()
+				if i%4 == 0 {
+					xs[i], ys[i] = math.Round(xs[i]*4)/4, math.Round(ys[i]*4)/4
+				}
+				pt.Insert(xs[i], ys[i], i, ins)
+			}
+			if pt.Len() != population {
+				t.Fatalf("got len %d, want %d", pt.Len(), population)
+			}
+
+			for i := 0; i < 20; i++ {
+				query := randomBox(rnd, 0.8, 0.4)
+				var want []int
+				for j := range xs {
+					if overlap(query, BBox{xs[j], ys[j], xs[j], ys[j]}) {
+						want = append(want, j)
+					}
+				}
+				var got []int
+				pt.Search(query, func(idx int) { got = append(got, idx) })
+				sort.Ints(got)
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("search %v: got %v, want %v", query, got, want)
+				}
+
+				x, y := rnd.Float64(), rnd.Float64()
+				dist := func(idx int) float64 { return math.Hypot(xs[idx]-x, ys[idx]-y) }
+				nearest := pt.Nearest(x, y, 10)
+				wantLen := 10
+				if population < wantLen {
+					wantLen = population
+				}
+				if len(nearest) != wantLen {
+					t.Fatalf("got %d nearest, want %d", len(nearest), wantLen)
+				}
+				all := make([]int, population)
+				for j := range all {
+					all[j] = j
+				}
+				sort.Slice(all, func(a, b int) bool { return dist(all[a]) < dist(all[b]) })
+				for j, idx := range nearest {
+					if dist(idx) != dist(all[j]) {
+						t.Fatalf("nearest %d: got distance %v, want %v", j, dist(idx), dist(all[j]))
+					}
+				}
+			}
+		})
+	}
+}
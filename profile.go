@@ -0,0 +1,138 @@
+package rtree
+
+import (
+	"math/bits"
+	"slices"
+	"sync"
+)
+
+// SearchProfile holds statistics about the searches made on an RTree,
+// broken down by level. It's the data needed to tune node capacities: many
+// visits at a level relative to the number of items reported suggests that
+// the nodes at that level overlap too much (or are too small), and a low
+// proportion of tested leaf entries being reported suggests that leaves are
+// too large.
+//
+// Levels are numbered by their depth from the root, so level 0 is the root.
+// Leaves aren't necessarily all at the same depth (e.g. in trees built by
+// BulkLoad). Searches of trees small enough to be scanned directly (see
+// LinearScanThreshold) only visit the leaves.
+type SearchProfile struct {
+	// Searches is the number of searches profiled.
+	Searches int
+
+	// LevelVisits gives the total number of nodes visited at each depth.
+	LevelVisits []int
+
+	// VisitHistogram counts searches by the number of nodes that they
+	// visited, in buckets of powers of 2. Bucket 0 counts searches that
+	// visited no nodes, and bucket i (for i > 0) counts searches that
+	// visited at least 2^(i-1) and fewer than 2^i nodes.
+	VisitHistogram []int
+
+	// LeafEntriesTested is the number of leaf entries that had their
+	// bounding boxes tested against a search bounding box.
+	LeafEntriesTested int
+
+	// LeafEntriesReported is the number of leaf entries that were found by
+	// searches.
+	LeafEntriesReported int
+}
+
+// LeafHitRate gives the proportion of tested leaf entries that were found
+// by searches.
+func (p *SearchProfile) LeafHitRate() float64 {
+	if p.LeafEntriesTested == 0 {
+		return 0
+	}
+	return float64(p.LeafEntriesReported) / float64(p.LeafEntriesTested)
+}
+
+// EnableProfiling turns on the collection of a SearchProfile for searches
+// made using Search (and its variants that take a single bounding box). It
+// has a small overhead for each search, so it's off by default. Searches may
+// still run concurrently while profiling is on, but EnableProfiling,
+// DisableProfiling and ResetProfile must not be called concurrently with
+// searches.
+func (t *RTree) EnableProfiling() {
+	if t.profile == nil {
+		t.profile = &profiler{}
+	}
+}
+
+// DisableProfiling turns off the collection enabled by EnableProfiling, and
+// discards the collected profile.
+func (t *RTree) DisableProfiling() {
+	t.profile = nil
+}
+
+// Profile gives the statistics collected since profiling was enabled by
+// EnableProfiling (or since the last call to ResetProfile). It gives the
+// zero value if profiling isn't enabled.
+func (t *RTree) Profile() SearchProfile {
+	if t.profile == nil {
+		return SearchProfile{}
+	}
+	t.profile.mu.Lock()
+	defer t.profile.mu.Unlock()
+	p := t.profile.profile
+	p.LevelVisits = slices.Clone(p.LevelVisits)
+	p.VisitHistogram = slices.Clone(p.VisitHistogram)
+	return p
+}
+
+// ResetProfile discards the statistics collected so far, without turning
+// off profiling.
+func (t *RTree) ResetProfile() {
+	if t.profile != nil {
+		t.profile.mu.Lock()
+		t.profile.profile = SearchProfile{}
+		t.profile.mu.Unlock()
+	}
+}
+
+// profiler collects a SearchProfile. The profile is guarded by a mutex,
+// since searches (which add to it) may run concurrently.
+type profiler struct {
+	mu      sync.Mutex
+	profile SearchProfile
+}
+
+func (p *profiler) add(s *searchStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.profile.add(s)
+}
+
+// searchStats accumulates the statistics for a single search.
+type searchStats struct {
+	levelVisits   []int
+	tested, found int
+}
+
+// visit records a visit to a node at the given depth.
+func (s *searchStats) visit(depth int) {
+	for len(s.levelVisits) <= depth {
+		s.levelVisits = append(s.levelVisits, 0)
+	}
+	s.levelVisits[depth]++
+}
+
+func (p *SearchProfile) add(s *searchStats) {
+	p.Searches++
+	if n := len(s.levelVisits); n > len(p.LevelVisits) {
+		p.LevelVisits = append(p.LevelVisits, make([]int, n-len(p.LevelVisits))...)
+	}
+	var visits int
+	for level, v := range s.levelVisits {
+		p.LevelVisits[level] += v
+		visits += v
+	}
+	bucket := bits.Len(uint(visits))
+	if bucket >= len(p.VisitHistogram) {
+		p.VisitHistogram = append(p.VisitHistogram, make([]int, bucket+1-len(p.VisitHistogram))...)
+	}
+	p.VisitHistogram[bucket]++
+	p.LeafEntriesTested += s.tested
+	p.LeafEntriesReported += s.found
+}
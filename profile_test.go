@@ -0,0 +1,112 @@
+package rtree
+
+import (
+	"math/bits"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestSearchProfile(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 4)
+	var rt RTree
+	for i := 0; i < 300; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+	}
+	rt.Search(everywhere, func(int) {})
+	if p := rt.Profile(); p.Searches != 0 {
+		t.Fatalf("expected no profile while disabled, got %+v", p)
+	}
+
+	rt.EnableProfiling()
+	rt.Metrics.Enabled = true
+	var found int
+	for i := 0; i < 20; i++ {
+		rt.Search(randomBox(rnd, 0.5, 0.5), func(int) { found++ })
+	}
+	rt.MarkDeleted(0)
+	rt.Search(everywhere, func(int) { found++ })
+
+	p := rt.Profile()
+	if p.Searches != 21 {
+		t.Errorf("expected 21 searches, got %d", p.Searches)
+	}
+	if len(p.LevelVisits) != rt.height()+1 {
+		t.Fatalf("expected %d levels, got %d", rt.height()+1, len(p.LevelVisits))
+	}
+	if root := p.LevelVisits[0]; root != p.Searches {
+		t.Errorf("expected root to be visited once per search, got %d", root)
+	}
+	var visits int
+	for _, v := range p.LevelVisits {
+		visits += v
+	}
+	if visits != rt.Metrics.NodesVisited {
+		t.Errorf("got %d visits, metrics has %d", visits, rt.Metrics.NodesVisited)
+	}
+	var searches int
+	for _, n := range p.VisitHistogram {
+		searches += n
+	}
+	if searches != p.Searches {
+		t.Errorf("histogram has %d searches, want %d", searches, p.Searches)
+	}
+	if p.VisitHistogram[len(p.VisitHistogram)-1] == 0 || len(p.VisitHistogram) != bits.Len(uint(len(rt.Nodes)))+1 {
+		t.Errorf("expected full search in last bucket, got histogram %v", p.VisitHistogram)
+	}
+	if p.LeafEntriesReported != found {
+		t.Errorf("got %d reported entries, want %d", p.LeafEntriesReported, found)
+	}
+	if p.LeafEntriesTested <= p.LeafEntriesReported {
+		t.Errorf("expected more tested than reported entries, got %+v", p)
+	}
+	if r := p.LeafHitRate(); r <= 0 || r >= 1 {
+		t.Errorf("unexpected leaf hit rate %v", r)
+	}
+
+	rt.ResetProfile()
+	if p := rt.Profile(); p.Searches != 0 || len(p.LevelVisits) != 0 {
+		t.Errorf("expected empty profile after reset, got %+v", p)
+	}
+	rt.DisableProfiling()
+	rt.Search(everywhere, func(int) {})
+	if p := rt.Profile(); p.Searches != 0 {
+		t.Errorf("expected no profile after disabling, got %+v", p)
+	}
+}
+
+func TestSearchProfileUnevenDepths(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	items := make([]InsertItem, 1000)
+	for i := range items {
+		items[i] = InsertItem{BBox: randomBox(rnd, 0.9, 0.1), DataIndex: i}
+	}
+	rt := BulkLoad(items)
+	rt.EnableProfiling()
+
+	// Searches may run concurrently while profiling.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				rt.Search(everywhere, func(int) {})
+			}
+		}()
+	}
+	wg.Wait()
+
+	p := rt.Profile()
+	if p.Searches != 40 || p.LevelVisits[0] != 40 {
+		t.Fatalf("unexpected profile: %+v", p)
+	}
+	var visits int
+	for _, v := range p.LevelVisits {
+		visits += v
+	}
+	if visits != 40*len(rt.Nodes) {
+		t.Errorf("got %d visits, want %d", visits, 40*len(rt.Nodes))
+	}
+}
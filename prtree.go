@@ -0,0 +1,95 @@
+package rtree
+
+import "sort"
+
+// prFanOut is the number of entries in each node built by BulkLoadPR (except
+// for nodes holding the last few entries of a group).
+const prFanOut = 16
+
+// BulkLoadPR bulk loads items into a new R-Tree using the Priority R-Tree
+// construction algorithm of Arge, de Berg, Haverkort and Yi.
+//
+// Unlike BulkLoad and BulkLoadExternal, which order items by their centres,
+// the PR-Tree treats each bounding box as a point in 4 dimensions and
+// groups extreme items into dedicated "priority" nodes. This gives a worst
+// case optimal bound on the number of nodes visited by a window query, so
+// the tree doesn't degenerate for adversarial data (e.g. long thin boxes, or
+// boxes of wildly differing sizes). For well behaved data, the other bulk
+// loaders produce trees that are just as good and are faster to build.
+func BulkLoadPR(inserts []InsertItem) RTree {
+	var tr RTree
+	if len(inserts) == 0 {
+		return tr
+	}
+	entries := make([]Entry, len(inserts))
+	for i, item := range inserts {
+		entries[i] = Entry{BBox: item.BBox, Index: item.DataIndex, Payload: item.Payload}
+	}
+
+	isLeaf := true
+	for {
+		var groups [][]Entry
+		prGroups(entries, 0, prFanOut, &groups)
+		var level []Entry
+		for _, group := range groups {
+			tr.Nodes = append(tr.Nodes, Node{IsLeaf: isLeaf, Entries: group})
+			n := len(tr.Nodes) - 1
+			level = append(level, Entry{BBox: tr.calculateBound(n), Index: n})
+		}
+		if len(level) == 1 {
+			tr.RootIndex = level[0].Index
+			tr.calculateAggregates(tr.RootIndex)
+			return tr
+		}
+		entries = level
+		isLeaf = false
+	}
+}
+
+// prGroups partitions entries into groups of at most fanOut entries using
+// the leaves of a pseudo-PR-tree. At each step, the fanOut entries with the
+// most extreme MinX, MinY, MaxX and MaxY are split off into priority groups,
+// and the remaining entries are divided in half along a dimension that
+// cycles with depth. The entries slice is reordered in place.
+func prGroups(entries []Entry, depth, fanOut int, groups *[][]Entry) {
+	if len(entries) == 0 {
+		return
+	}
+	if len(entries) <= fanOut {
+		*groups = append(*groups, append([]Entry(nil), entries...))
+		return
+	}
+	for dim := 0; dim < 4 && len(entries) > 0; dim++ {
+		sortByPRKey(entries, dim)
+		take := fanOut
+		if take > len(entries) {
+			take = len(entries)
+		}
+		*groups = append(*groups, append([]Entry(nil), entries[:take]...))
+		entries = entries[take:]
+	}
+	sortByPRKey(entries, depth%4)
+	mid := len(entries) / 2
+	prGroups(entries[:mid], depth+1, fanOut, groups)
+	prGroups(entries[mid:], depth+1, fanOut, groups)
+}
+
+// sortByPRKey sorts entries so that the most extreme entries in the given
+// dimension (0: MinX, 1: MinY, 2: MaxX, 3: MaxY) come first.
+func sortByPRKey(entries []Entry, dim int) {
+	key := func(bb BBox) float64 {
+		switch dim {
+		case 0:
+			return bb.MinX
+		case 1:
+			return bb.MinY
+		case 2:
+			return -bb.MaxX
+		default:
+			return -bb.MaxY
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return key(entries[i].BBox) < key(entries[j].BBox)
+	})
+}
@@ -0,0 +1,46 @@
+package rtree
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestBulkLoadPR(t *testing.T) {
+	for _, population := range []int{0, 1, 15, 16, 17, 64, 65, 300, 2000} {
+		t.Run(fmt.Sprintf("pop_%d", population), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(0))
+			boxes := make([]BBox, population)
+			items := make([]InsertItem, population)
+			for i := range boxes {
+				boxes[i] = randomBox(rnd, 0.9, 0.1)
+				items[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+			}
+			rt := BulkLoadPR(items)
+			checkInvariants(t, rt)
+			checkSearch(t, rt, boxes, rnd)
+			for i, item := range items {
+				if item.DataIndex != i {
+					t.Fatalf("input items were modified")
+				}
+			}
+		})
+	}
+}
+
+func TestBulkLoadPRAdversarial(t *testing.T) {
+	// Long thin boxes of varying lengths, which cause centre based bulk
+	// loads to produce nodes with large overlap.
+	rnd := rand.New(rand.NewSource(0))
+	var boxes []BBox
+	var items []InsertItem
+	for i := 0; i < 1000; i++ {
+		y := rnd.Float64()
+		bb := BBox{MinX: 0.5 - rnd.Float64()/2, MinY: y, MaxX: 0.5 + rnd.Float64()/2, MaxY: y}
+		boxes = append(boxes, bb)
+		items = append(items, InsertItem{BBox: bb, DataIndex: i})
+	}
+	rt := BulkLoadPR(items)
+	checkInvariants(t, rt)
+	checkSearch(t, rt, boxes, rnd)
+}
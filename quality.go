@@ -0,0 +1,129 @@
+package rtree
+
+import (
+	"math"
+	"sort"
+)
+
+// Quality describes how well the structure of a tree suits searching. Trees
+// built by inserting items one at a time tend to degrade as they are
+// modified, and a Quality report can be used to decide when it's worth
+// rebuilding the tree (e.g. using BulkLoad).
+type Quality struct {
+	// Overlap is the total area of the pairwise intersections between the
+	// bounding boxes of entries that share a node. Overlap causes searches
+	// to visit multiple paths through the tree.
+	Overlap float64
+
+	// Levels describes each level of the tree, starting at the root.
+	Levels []LevelQuality
+
+	// Score is a normalised measure of the overall quality of the tree,
+	// between 0 (worst) and 1 (best). It's the proportion of the total
+	// area covered by nodes that is neither overlapping nor dead space.
+	// If the overlap or dead space is infinite (which can happen with items
+	// that have infinite bounding boxes), the score is 0.
+	Score float64
+}
+
+// LevelQuality describes a single level of a tree.
+type LevelQuality struct {
+	// Nodes is the number of nodes at the level.
+	Nodes int
+
+	// Coverage is the total area of the bounding boxes of the nodes at
+	// the level.
+	Coverage float64
+
+	// DeadSpace is the total area inside the bounding boxes of the nodes
+	// at the level that isn't covered by any of their entries. Dead space
+	// causes searches to visit nodes that don't contain any results.
+	DeadSpace float64
+}
+
+// Quality computes a report describing the quality of the tree's structure.
+func (t *RTree) Quality() Quality {
+	q := Quality{Score: 1}
+	if len(t.Nodes) == 0 {
+		return q
+	}
+
+	var recurse func(n int, bb BBox, level int)
+	recurse = func(n int, bb BBox, level int) {
+		if level == len(q.Levels) {
+			q.Levels = append(q.Levels, LevelQuality{})
+		}
+		lq := &q.Levels[level]
+		node := &t.Nodes[n]
+		lq.Nodes++
+		lq.Coverage += area(bb)
+		if len(node.Entries) > 0 {
+			lq.DeadSpace += areaSub(area(bb), unionArea(node.Entries))
+		}
+		for i, a := range node.Entries {
+			for _, b := range node.Entries[i+1:] {
+				if inter, ok := a.BBox.Intersect(b.BBox); ok {
+					q.Overlap += area(inter)
+				}
+			}
+		}
+		if !node.IsLeaf {
+			for _, e := range node.Entries {
+				recurse(e.Index, e.BBox, level+1)
+			}
+		}
+	}
+	recurse(t.RootIndex, t.calculateBound(t.RootIndex), 0)
+
+	var coverage, waste float64
+	for _, lq := range q.Levels {
+		coverage += lq.Coverage
+		waste += lq.DeadSpace
+	}
+	waste += q.Overlap
+	switch {
+	case math.IsInf(waste, 1):
+		// Infinite waste (e.g. from overlapping items with UniverseBBox)
+		// is as bad as it gets, even if the coverage is also infinite.
+		q.Score = 0
+	case coverage > 0:
+		q.Score = 1 - waste/coverage
+		if q.Score < 0 {
+			q.Score = 0
+		}
+	}
+	return q
+}
+
+// unionArea gives the area of the union of the bounding boxes of the
+// entries. The plane is divided into a grid using the edges of the boxes,
+// and the area of each grid cell covered by any box is summed.
+func unionArea(entries []Entry) float64 {
+	var xs, ys []float64
+	for _, e := range entries {
+		xs = append(xs, e.BBox.MinX, e.BBox.MaxX)
+		ys = append(ys, e.BBox.MinY, e.BBox.MaxY)
+	}
+	sort.Float64s(xs)
+	sort.Float64s(ys)
+
+	var total float64
+	for i := 1; i < len(xs); i++ {
+		if xs[i] == xs[i-1] {
+			continue
+		}
+		for j := 1; j < len(ys); j++ {
+			if ys[j] == ys[j-1] {
+				continue
+			}
+			cell := BBox{xs[i-1], ys[j-1], xs[i], ys[j]}
+			for _, e := range entries {
+				if contains(e.BBox, cell) {
+					total += area(cell)
+					break
+				}
+			}
+		}
+	}
+	return total
+}
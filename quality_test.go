@@ -0,0 +1,77 @@
+package rtree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestUnionArea(t *testing.T) {
+	for _, tc := range []struct {
+		boxes []BBox
+		want  float64
+	}{
+		{nil, 0},
+		{[]BBox{{0, 0, 2, 3}}, 6},
+		{[]BBox{{0, 0, 2, 2}, {1, 1, 3, 3}}, 7},
+		{[]BBox{{0, 0, 2, 2}, {0, 0, 2, 2}}, 4},
+		{[]BBox{{0, 0, 1, 1}, {2, 2, 3, 3}}, 2},
+		{[]BBox{{0, 0, 4, 4}, {1, 1, 2, 2}}, 16},
+	} {
+		var entries []Entry
+		for _, bb := range tc.boxes {
+			entries = append(entries, Entry{BBox: bb})
+		}
+		if got := unionArea(entries); got != tc.want {
+			t.Errorf("%v: got %v want %v", tc.boxes, got, tc.want)
+		}
+	}
+}
+
+func TestQuality(t *testing.T) {
+	var empty RTree
+	if q := empty.Quality(); q.Score != 1 || len(q.Levels) != 0 {
+		t.Errorf("unexpected quality for empty tree: %+v", q)
+	}
+
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	rt.Insert(BBox{0, 0, 2, 2}, 0, ins)
+	rt.Insert(BBox{1, 1, 3, 3}, 1, ins)
+	q := rt.Quality()
+	if q.Overlap != 1 || len(q.Levels) != 1 {
+		t.Fatalf("unexpected quality: %+v", q)
+	}
+	if lq := q.Levels[0]; lq.Nodes != 1 || lq.Coverage != 9 || lq.DeadSpace != 2 {
+		t.Fatalf("unexpected level quality: %+v", lq)
+	}
+	if want := 1 - 3.0/9; math.Abs(q.Score-want) > 1e-12 {
+		t.Fatalf("got score %v want %v", q.Score, want)
+	}
+
+	// A bulk loaded tree should be at least as good as one built by
+	// inserting random items one at a time.
+	rnd := rand.New(rand.NewSource(0))
+	var items []InsertItem
+	var inserted RTree
+	for i := 0; i < 1000; i++ {
+		bb := randomBox(rnd, 0.9, 0.1)
+		items = append(items, InsertItem{BBox: bb, DataIndex: i})
+		inserted.Insert(bb, i, ins)
+	}
+	bulk := BulkLoad(items)
+	qi, qb := inserted.Quality(), bulk.Quality()
+	if qi.Score <= 0 || qi.Score >= 1 || qb.Score < qi.Score {
+		t.Errorf("unexpected scores: inserted=%v bulk=%v", qi.Score, qb.Score)
+	}
+	var nodes int
+	for _, lq := range qi.Levels {
+		nodes += lq.Nodes
+	}
+	if nodes != len(inserted.Nodes) {
+		t.Errorf("levels account for %d nodes, tree has %d", nodes, len(inserted.Nodes))
+	}
+}
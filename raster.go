@@ -0,0 +1,47 @@
+package rtree
+
+// Rasterize renders the number of items covering each pixel of a width by
+// height raster over the extent, e.g. for quick coverage previews of large
+// datasets. The raster is given in row-major order, with row 0 and column 0
+// at the minimum corner of the extent, and must have at least width*height
+// elements. Each item adds 1 to every pixel that its bounding box overlaps
+// (points on the boundary between two pixels are in the higher one). Counts
+// are added to the raster's existing values, so several trees can be
+// rendered into the same raster.
+//
+// Only the parts of the tree overlapping with the extent are traversed, so
+// empty regions and items outside of the extent are skipped cheaply. Items
+// marked as deleted by MarkDeleted aren't rendered.
+func (t *RTree) Rasterize(extent BBox, width, height int, raster []int) {
+	if width <= 0 || height <= 0 || extent.MinX > extent.MaxX || extent.MinY > extent.MaxY {
+		return
+	}
+	if len(raster) < width*height {
+		panic("rtree: raster is smaller than width*height")
+	}
+	if len(t.Nodes) == 0 {
+		return
+	}
+	var recurse func(n int)
+	recurse = func(n int) {
+		node := &t.Nodes[n]
+		for _, e := range node.Entries {
+			switch {
+			case !overlap(e.BBox, extent):
+			case !node.IsLeaf:
+				recurse(e.Index)
+			case !t.isTombstoned(e.Index):
+				c0 := gridPosition(max(e.BBox.MinX, extent.MinX), extent.MinX, extent.MaxX, width)
+				c1 := gridPosition(min(e.BBox.MaxX, extent.MaxX), extent.MinX, extent.MaxX, width)
+				r0 := gridPosition(max(e.BBox.MinY, extent.MinY), extent.MinY, extent.MaxY, height)
+				r1 := gridPosition(min(e.BBox.MaxY, extent.MaxY), extent.MinY, extent.MaxY, height)
+				for row := r0; row <= r1; row++ {
+					for col := c0; col <= c1; col++ {
+						raster[row*width+col]++
+					}
+				}
+			}
+		}
+	}
+	recurse(t.RootIndex)
+}
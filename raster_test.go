@@ -0,0 +1,71 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRasterize(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 5)
+	var rt RTree
+	var boxes []BBox
+	for i := 0; i < 300; i++ {
+		// Unlike randomBox, the boxes aren't rounded, so they don't fall
+		// exactly on the boundaries between pixels.
+		x, y := rnd.Float64()*1.2, rnd.Float64()*1.2
+		bb := BBox{x, y, x + rnd.Float64()*0.2, y + rnd.Float64()*0.2}
+		boxes = append(boxes, bb)
+		rt.Insert(bb, i, policy)
+	}
+	rt.MarkDeleted(7)
+
+	extent := BBox{0.1, 0.2, 0.9, 0.7}
+	const width, height = 16, 10
+	raster := make([]int, width*height)
+	raster[0] = 100
+	rt.Rasterize(extent, width, height, raster)
+
+	for row := 0; row < height; row++ {
+		for col := 0; col < width; col++ {
+			pixel := BBox{
+				MinX: extent.MinX + (extent.MaxX-extent.MinX)*float64(col)/width,
+				MinY: extent.MinY + (extent.MaxY-extent.MinY)*float64(row)/height,
+				MaxX: extent.MinX + (extent.MaxX-extent.MinX)*float64(col+1)/width,
+				MaxY: extent.MinY + (extent.MaxY-extent.MinY)*float64(row+1)/height,
+			}
+			var want int
+			if row == 0 && col == 0 {
+				want = 100
+			}
+			for i, bb := range boxes {
+				if i != 7 && overlap(bb, pixel) {
+					want++
+				}
+			}
+			if got := raster[row*width+col]; got != want {
+				t.Errorf("pixel (%d, %d): got %d want %d", col, row, got, want)
+			}
+		}
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		var rt RTree
+		raster := make([]int, 4)
+		rt.Rasterize(extent, 2, 2, raster)
+		for i, v := range raster {
+			if v != 0 {
+				t.Errorf("pixel %d: got %d want 0", i, v)
+			}
+		}
+	})
+
+	t.Run("small_raster", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+		rt.Rasterize(extent, 4, 4, make([]int, 15))
+	})
+}
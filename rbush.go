@@ -0,0 +1,145 @@
+package rtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// rbushNode is a node in the JSON representation of a tree used by the rbush
+// JavaScript library. The bounding box is nil if it's non-finite (JSON can't
+// represent infinities, so JavaScript writes them as null).
+type rbushNode struct {
+	Children []interface{} `json:"children"`
+	Height   int           `json:"height"`
+	Leaf     bool          `json:"leaf"`
+	MinX     *float64      `json:"minX"`
+	MinY     *float64      `json:"minY"`
+	MaxX     *float64      `json:"maxX"`
+	MaxY     *float64      `json:"maxY"`
+}
+
+// rbushItem is an item in the JSON representation of an rbush tree.
+type rbushItem struct {
+	MinX  float64 `json:"minX"`
+	MinY  float64 `json:"minY"`
+	MaxX  float64 `json:"maxX"`
+	MaxY  float64 `json:"maxY"`
+	Index int     `json:"index"`
+}
+
+// SaveRBush writes the tree as JSON in the format used by the toJSON and
+// fromJSON methods of the rbush JavaScript library. The structure of the tree
+// is kept as is. Each item is written as an object with minX, minY, maxX and
+// maxY properties (as rbush expects by default), and an index property
+// holding its data index. Items marked as deleted by MarkDeleted are left
+// out.
+func (t *RTree) SaveRBush(w io.Writer) error {
+	t = t.withoutTombstones()
+	if len(t.Nodes) == 0 {
+		return json.NewEncoder(w).Encode(rbushNode{Children: []interface{}{}, Height: 1, Leaf: true})
+	}
+	return json.NewEncoder(w).Encode(t.rbushNode(t.RootIndex))
+}
+
+func (t *RTree) rbushNode(n int) rbushNode {
+	node := &t.Nodes[n]
+	out := rbushNode{Children: []interface{}{}, Height: 1, Leaf: node.IsLeaf}
+	if len(node.Entries) > 0 {
+		bb := t.calculateBound(n)
+		out.MinX, out.MinY, out.MaxX, out.MaxY = finitePtr(bb.MinX), finitePtr(bb.MinY), finitePtr(bb.MaxX), finitePtr(bb.MaxY)
+	}
+	for _, e := range node.Entries {
+		if node.IsLeaf {
+			out.Children = append(out.Children, rbushItem{e.BBox.MinX, e.BBox.MinY, e.BBox.MaxX, e.BBox.MaxY, e.Index})
+			continue
+		}
+		child := t.rbushNode(e.Index)
+		out.Height = child.Height + 1
+		out.Children = append(out.Children, child)
+	}
+	return out
+}
+
+func finitePtr(f float64) *float64 {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil
+	}
+	return &f
+}
+
+// rbushInput is used to decode both nodes and items of an rbush tree.
+type rbushInput struct {
+	Children []rbushInput `json:"children"`
+	Leaf     bool         `json:"leaf"`
+	MinX     *float64     `json:"minX"`
+	MinY     *float64     `json:"minY"`
+	MaxX     *float64     `json:"maxX"`
+	MaxY     *float64     `json:"maxY"`
+	Index    *int         `json:"index"`
+}
+
+// LoadRBush reads a tree from JSON in the format used by the toJSON and
+// fromJSON methods of the rbush JavaScript library. The structure of the
+// tree is kept as is. Items must have minX, minY, maxX and maxY properties.
+// If items have an integer index property, then it's used as their data
+// index. Otherwise, the data index is the position of the item in a depth
+// first traversal of the tree. Errors caused by malformed input wrap
+// ErrCorruptTree.
+func LoadRBush(r io.Reader) (RTree, error) {
+	var root rbushInput
+	if err := json.NewDecoder(r).Decode(&root); err != nil {
+		return RTree{}, wrapJSONError(err, "decoding rbush JSON")
+	}
+	var t RTree
+	if len(root.Children) == 0 {
+		return t, nil
+	}
+	var items int
+	depth := -1
+	var build func(in rbushInput, level int) (int, error)
+	build = func(in rbushInput, level int) (int, error) {
+		if len(in.Children) == 0 {
+			return 0, fmt.Errorf("%w: rbush JSON has a node without children", ErrCorruptTree)
+		}
+		if in.Leaf {
+			if depth == -1 {
+				depth = level
+			} else if depth != level {
+				return 0, fmt.Errorf("%w: rbush JSON has leaves at different depths", ErrCorruptTree)
+			}
+		}
+		t.Nodes = append(t.Nodes, Node{IsLeaf: in.Leaf})
+		n := len(t.Nodes) - 1
+		var entries []Entry
+		for _, child := range in.Children {
+			if !in.Leaf {
+				c, err := build(child, level+1)
+				if err != nil {
+					return 0, err
+				}
+				entries = append(entries, Entry{BBox: t.calculateBound(c), Index: c})
+				continue
+			}
+			if child.MinX == nil || child.MinY == nil || child.MaxX == nil || child.MaxY == nil {
+				return 0, fmt.Errorf("%w: rbush JSON item is missing minX, minY, maxX or maxY", ErrCorruptTree)
+			}
+			e := Entry{BBox: BBox{*child.MinX, *child.MinY, *child.MaxX, *child.MaxY}, Index: items}
+			if child.Index != nil {
+				e.Index = *child.Index
+			}
+			items++
+			entries = append(entries, e)
+		}
+		t.Nodes[n].Entries = entries
+		return n, nil
+	}
+	rootIndex, err := build(root, 0)
+	if err != nil {
+		return RTree{}, err
+	}
+	t.RootIndex = rootIndex
+	t.calculateAggregates(rootIndex)
+	return t, nil
+}
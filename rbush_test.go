@@ -0,0 +1,80 @@
+package rtree
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRBushRoundTrip(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, population := range []int{0, 1, 100} {
+		rnd := rand.New(rand.NewSource(0))
+		var rt RTree
+		boxes := make([]BBox, population)
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Insert(boxes[i], i, ins)
+		}
+		var buf bytes.Buffer
+		if err := rt.SaveRBush(&buf); err != nil {
+			t.Fatal(err)
+		}
+		got, err := LoadRBush(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkInvariants(t, got)
+		checkSearch(t, got, boxes, rnd)
+		if len(got.Nodes) != len(rt.Nodes) {
+			t.Errorf("expected %d nodes, got %d", len(rt.Nodes), len(got.Nodes))
+		}
+	}
+}
+
+func TestLoadRBush(t *testing.T) {
+	// Output of rbush's toJSON for a small tree, with the items lacking
+	// index properties. The empty tree has null bounds since rbush uses
+	// infinities.
+	const input = `{"children":[
+		{"children":[{"minX":0,"minY":0,"maxX":1,"maxY":1},{"minX":2,"minY":2,"maxX":3,"maxY":3}],"height":1,"leaf":true,"minX":0,"minY":0,"maxX":3,"maxY":3},
+		{"children":[{"minX":5,"minY":5,"maxX":6,"maxY":6},{"minX":7,"minY":7,"maxX":8,"maxY":8}],"height":1,"leaf":true,"minX":5,"minY":5,"maxX":8,"maxY":8}
+	],"height":2,"leaf":false,"minX":0,"minY":0,"maxX":8,"maxY":8}`
+	rt, err := LoadRBush(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, rt)
+	var got []int
+	rt.Search(BBox{2, 2, 7, 7}, func(idx int) { got = append(got, idx) })
+	if !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("got %v", got)
+	}
+
+	empty, err := LoadRBush(strings.NewReader(
+		`{"children":[],"height":1,"leaf":true,"minX":null,"minY":null,"maxX":null,"maxY":null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.Nodes) != 0 {
+		t.Errorf("expected empty tree")
+	}
+
+	for _, bad := range []string{
+		`[`,
+		`{"children":[{"minX":0,"minY":0,"maxX":1}],"leaf":true}`,
+		`{"children":[{"children":[],"leaf":true}],"leaf":false}`,
+		`{"children":[{"children":[{"minX":0,"minY":0,"maxX":1,"maxY":1}],"leaf":true},
+			{"children":[{"children":[{"minX":0,"minY":0,"maxX":1,"maxY":1}],"leaf":true}],"leaf":false}],"leaf":false}`,
+	} {
+		if _, err := LoadRBush(strings.NewReader(bad)); !errors.Is(err, ErrCorruptTree) {
+			t.Errorf("%s: expected ErrCorruptTree, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package rtree
+
+// Repack gives a new tree containing the same items as this tree, rebuilt
+// with nodes packed according to the insertion policy (see
+// BulkLoadWithPolicy). This allows the node capacity of an existing tree to
+// be changed. All fields of the items (such as payloads, tags and weights)
+// are retained. Items marked as deleted by MarkDeleted are left out.
+//
+// The new tree has the same Period, Tracer and Hooks as this tree, and has lookup
+// tracking turned on if this tree does. This tree is left unchanged. It
+// panics if the policy is the zero value.
+func (t *RTree) Repack(policy InsertionPolicy) RTree {
+	var entries []Entry
+	for _, node := range t.Nodes {
+		if !node.IsLeaf {
+			continue
+		}
+		for _, e := range node.Entries {
+			if !t.isTombstoned(e.Index) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	out := RTree{Period: t.Period, Tracer: t.Tracer, Hooks: t.Hooks}
+	for _, e := range entries {
+		out.setTags(e.Index, t.tags[e.Index])
+		out.setWeight(e.Index, t.weights[e.Index])
+	}
+	out.packEntries(entries, policy)
+	out.quarantine = append([]Entry(nil), t.quarantine...)
+	if t.lookup != nil {
+		out.EnableLookup()
+	}
+	return out
+}
+
+// repack rebuilds the tree in place from its items, packing nodes according
+// to the insertion policy. All of the items' fields are retained.
+func (t *RTree) repack(policy InsertionPolicy) {
+	var entries []Entry
+	for _, node := range t.Nodes {
+		if node.IsLeaf {
+			entries = append(entries, node.Entries...)
+		}
+	}
+	t.packEntries(entries, policy)
+	t.invalidateHint()
+	t.generation++
+}
+
+// packEntries replaces the nodes of the tree with nodes bulk loaded from the
+// leaf entries.
+func (t *RTree) packEntries(entries []Entry, policy InsertionPolicy) {
+	items := make([]InsertItem, len(entries))
+	for i, e := range entries {
+		items[i] = InsertItem{BBox: e.BBox, DataIndex: i}
+	}
+
+	// The data indices of the packed tree refer to the collected entries,
+	// which replace them once packing is complete.
+	packed := BulkLoadWithPolicy(items, policy)
+	for _, node := range packed.Nodes {
+		if !node.IsLeaf {
+			continue
+		}
+		for j, e := range node.Entries {
+			node.Entries[j] = entries[e.Index]
+		}
+	}
+	t.RootIndex, t.Nodes = packed.RootIndex, packed.Nodes
+	if len(t.Nodes) > 0 {
+		t.refreshSubtree(t.RootIndex)
+	}
+}
@@ -0,0 +1,70 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRepack(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	small, err := NewInsertionPolicy(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := NewInsertionPolicy(8, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rt RTree
+	rt.EnableLookup()
+	boxes := make([]BBox, 500)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.InsertWithTags(boxes[i], i, 1<<uint(i%3), small)
+	}
+	rt.MarkDeleted(3)
+	nodes := len(rt.Nodes)
+
+	packed := rt.Repack(large)
+	if len(rt.Nodes) != nodes || !rt.Has(0) {
+		t.Error("expected original tree to be unchanged")
+	}
+	checkInvariants(t, packed)
+	for i, n := range packed.Nodes {
+		if len(n.Entries) > 16 || (i != packed.RootIndex && len(n.Entries) < 8) {
+			t.Fatalf("node %d has %d entries", i, len(n.Entries))
+		}
+	}
+	if len(packed.Nodes) >= nodes {
+		t.Errorf("expected fewer nodes, got %d (was %d)", len(packed.Nodes), nodes)
+	}
+
+	boxes[3] = BBox{-5, -5, -4, -4}
+	checkSearch(t, packed, boxes, rnd)
+	if packed.Has(3) {
+		t.Error("expected tombstoned item to be left out")
+	}
+	if packed.lookup == nil {
+		t.Error("expected lookup tracking to be kept")
+	}
+	var tagged int
+	packed.SearchTagged(BBox{0, 0, 1, 1}, 1, func(idx int) {
+		if idx%3 != 0 {
+			t.Errorf("item %d has wrong tags", idx)
+		}
+		tagged++
+	})
+	if tagged == 0 {
+		t.Error("expected tagged items")
+	}
+
+	// The repacked tree can be modified using the new policy.
+	for i := 0; i < 50; i++ {
+		bb := randomBox(rnd, 0.9, 0.1)
+		packed.Insert(bb, len(boxes), large)
+		boxes = append(boxes, bb)
+	}
+	checkInvariants(t, packed)
+	checkSearch(t, packed, boxes, rnd)
+}
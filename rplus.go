@@ -0,0 +1,223 @@
+package rtree
+
+import "sort"
+
+// RPlusTree is a variant of the R-Tree (an R+-Tree) in which the regions
+// covered by sibling nodes never overlap. Instead, items that span the
+// boundary between two nodes are stored in both of them. This makes
+// insertion more expensive and increases the size of the tree, but means
+// that point queries only need to follow a single path from the root (other
+// than for points lying exactly on a node boundary).
+//
+// The zero value is an empty tree.
+type RPlusTree struct {
+	nodes []rplusNode
+	root  int
+	size  int
+}
+
+// rplusNode is a node in an RPlusTree. For non-leaf nodes, the bounding box
+// of each entry is the region covered by the child node rather than the
+// bound of the child's entries. The regions of a node's children tile the
+// node's own region.
+type rplusNode struct {
+	isLeaf  bool
+	entries []Entry
+}
+
+// everywhere is the region covered by the root of an RPlusTree.
+var everywhere = UniverseBBox
+
+// Len gives the number of items in the tree. Items stored in multiple nodes
+// are only counted once.
+func (t *RPlusTree) Len() int {
+	return t.size
+}
+
+// Insert adds a new data item to the tree. Only the maximum number of
+// children of the insertion policy is used. Nodes that can't be split
+// without duplicating all of their entries (e.g. many identical bounding
+// boxes) are allowed to grow beyond the maximum.
+func (t *RPlusTree) Insert(bb BBox, dataIndex int, policy InsertionPolicy) {
+	t.size++
+	if len(t.nodes) == 0 {
+		t.nodes = append(t.nodes, rplusNode{isLeaf: true})
+		t.root = 0
+	}
+	entry := Entry{BBox: bb, Index: dataIndex}
+	sibling, regionA, regionB, split := t.insert(t.root, everywhere, entry, policy)
+	if split {
+		t.nodes = append(t.nodes, rplusNode{entries: []Entry{
+			{BBox: regionA, Index: t.root},
+			{BBox: regionB, Index: sibling},
+		}})
+		t.root = len(t.nodes) - 1
+	}
+}
+
+// insert adds the entry to each leaf under node n whose region overlaps the
+// entry. If n had to be split, then the new sibling node is returned along
+// with the regions of n and the sibling.
+func (t *RPlusTree) insert(n int, region BBox, entry Entry, policy InsertionPolicy) (int, BBox, BBox, bool) {
+	if t.nodes[n].isLeaf {
+		t.nodes[n].entries = append(t.nodes[n].entries, entry)
+	} else {
+		count := len(t.nodes[n].entries)
+		for i := 0; i < count; i++ {
+			child := t.nodes[n].entries[i]
+			if !overlap(child.BBox, entry.BBox) {
+				continue
+			}
+			sibling, regionA, regionB, split := t.insert(child.Index, child.BBox, entry, policy)
+			if split {
+				t.nodes[n].entries[i].BBox = regionA
+				t.nodes[n].entries = append(t.nodes[n].entries, Entry{BBox: regionB, Index: sibling})
+			}
+		}
+	}
+
+	if len(t.nodes[n].entries) <= policy.maxChildren {
+		return 0, BBox{}, BBox{}, false
+	}
+	return t.splitRPlusNode(n, region)
+}
+
+// splitRPlusNode splits node n (covering the given region) in two using an
+// axis aligned cut. For leaves, entries crossing the cut are copied into
+// both halves. For non-leaves, the cut is chosen so that no child region
+// crosses it, which is always possible because each node's region is
+// partitioned by a sequence of such cuts.
+func (t *RPlusTree) splitRPlusNode(n int, region BBox) (int, BBox, BBox, bool) {
+	node := t.nodes[n]
+	horizontal, cut, ok := chooseRPlusCut(node, region)
+	if !ok {
+		return 0, BBox{}, BBox{}, false
+	}
+
+	regionA, regionB := region, region
+	if horizontal {
+		regionA.MaxX, regionB.MinX = cut, cut
+	} else {
+		regionA.MaxY, regionB.MinY = cut, cut
+	}
+	var entriesA, entriesB []Entry
+	for _, e := range node.entries {
+		if node.isLeaf {
+			if overlap(e.BBox, regionA) {
+				entriesA = append(entriesA, e)
+			}
+			if overlap(e.BBox, regionB) {
+				entriesB = append(entriesB, e)
+			}
+		} else if (horizontal && e.BBox.MaxX <= cut) || (!horizontal && e.BBox.MaxY <= cut) {
+			entriesA = append(entriesA, e)
+		} else {
+			entriesB = append(entriesB, e)
+		}
+	}
+
+	t.nodes[n].entries = entriesA
+	t.nodes = append(t.nodes, rplusNode{isLeaf: node.isLeaf, entries: entriesB})
+	return len(t.nodes) - 1, regionA, regionB, true
+}
+
+// chooseRPlusCut finds the axis aligned cut through the region of a node
+// that divides its entries most evenly. For leaves, the candidate cuts lie
+// midway between consecutive entry edges, and a cut is only acceptable if
+// both halves end up with fewer entries than the original node. For
+// non-leaves, the candidate cuts are the edges of the child regions that
+// aren't crossed by any child.
+func chooseRPlusCut(node rplusNode, region BBox) (horizontal bool, cut float64, ok bool) {
+	bestSize, bestTotal := len(node.entries), 0
+	for _, horiz := range []bool{true, false} {
+		lo, hi := region.MinY, region.MaxY
+		span := func(bb BBox) (float64, float64) { return bb.MinY, bb.MaxY }
+		if horiz {
+			lo, hi = region.MinX, region.MaxX
+			span = func(bb BBox) (float64, float64) { return bb.MinX, bb.MaxX }
+		}
+
+		var edges []float64
+		for _, e := range node.entries {
+			min, max := span(e.BBox)
+			for _, v := range []float64{min, max} {
+				if v > lo && v < hi {
+					edges = append(edges, v)
+				}
+			}
+		}
+		sort.Float64s(edges)
+
+		var candidates []float64
+		if node.isLeaf {
+			for i := 1; i < len(edges); i++ {
+				if edges[i] != edges[i-1] {
+					candidates = append(candidates, edges[i-1]+(edges[i]-edges[i-1])/2)
+				}
+			}
+		} else {
+			candidates = edges
+		}
+
+	candidate:
+		for _, c := range candidates {
+			var countA, countB int
+			for _, e := range node.entries {
+				min, max := span(e.BBox)
+				switch {
+				case node.isLeaf:
+					if min <= c {
+						countA++
+					}
+					if max >= c {
+						countB++
+					}
+				case min < c && max > c:
+					continue candidate
+				case max <= c:
+					countA++
+				default:
+					countB++
+				}
+			}
+			size := countA
+			if countB > size {
+				size = countB
+			}
+			if countA == 0 || countB == 0 || size >= len(node.entries) {
+				continue
+			}
+			if size < bestSize || (size == bestSize && countA+countB < bestTotal) {
+				bestSize, bestTotal = size, countA+countB
+				horizontal, cut, ok = horiz, c, true
+			}
+		}
+	}
+	return horizontal, cut, ok
+}
+
+// Search looks for any items in the tree that overlap with the given
+// bounding box. The callback is called with the item index for each found
+// item. Each item is reported once, even if it is stored in multiple nodes.
+func (t *RPlusTree) Search(bb BBox, callback func(index int)) {
+	if len(t.nodes) == 0 {
+		return
+	}
+	seen := make(map[Entry]bool)
+	var recurse func(int)
+	recurse = func(n int) {
+		node := &t.nodes[n]
+		for _, e := range node.entries {
+			if !overlap(e.BBox, bb) {
+				continue
+			}
+			if !node.isLeaf {
+				recurse(e.Index)
+			} else if !seen[e] {
+				seen[e] = true
+				callback(e.Index)
+			}
+		}
+	}
+	recurse(t.root)
+}
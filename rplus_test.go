@@ -0,0 +1,89 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestRPlusTree(t *testing.T) {
+	for _, maxCapacity := range []int{2, 3, 5, 20} {
+		ins, err := NewInsertionPolicy(maxCapacity/2, maxCapacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rnd := rand.New(rand.NewSource(0))
+		var tr RPlusTree
+		var boxes []BBox
+		for i := 0; i < 300; i++ {
+			bb := randomBox(rnd, 0.9, 0.1)
+			if i%10 == 9 {
+				// Include some identical boxes, which can't be separated.
+				bb = boxes[rnd.Intn(len(boxes))]
+			}
+			boxes = append(boxes, bb)
+			tr.Insert(bb, i, ins)
+			checkRPlusInvariants(t, &tr)
+		}
+		if tr.Len() != len(boxes) {
+			t.Fatalf("expected len %d, got %d", len(boxes), tr.Len())
+		}
+		for i := 0; i < 50; i++ {
+			query := randomBox(rnd, 0.5, 0.5)
+			if i%2 == 0 {
+				query.MaxX, query.MaxY = query.MinX, query.MinY
+			}
+			var got, want []int
+			tr.Search(query, func(idx int) { got = append(got, idx) })
+			for j, bb := range boxes {
+				if overlap(bb, query) {
+					want = append(want, j)
+				}
+			}
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("max=%d: got %v want %v", maxCapacity, got, want)
+			}
+		}
+	}
+}
+
+// checkRPlusInvariants checks that the regions of each node's children tile
+// the node's region without overlap, that all leaves are at the same depth,
+// and that each leaf entry overlaps its leaf's region.
+func checkRPlusInvariants(t *testing.T, tr *RPlusTree) {
+	t.Helper()
+	leafDepth := -1
+	var recurse func(n int, region BBox, depth int)
+	recurse = func(n int, region BBox, depth int) {
+		node := tr.nodes[n]
+		if node.isLeaf {
+			if leafDepth == -1 {
+				leafDepth = depth
+			}
+			if depth != leafDepth {
+				t.Fatalf("leaves at different depths")
+			}
+			for _, e := range node.entries {
+				if !overlap(e.BBox, region) {
+					t.Fatalf("entry %v outside of leaf region %v", e.BBox, region)
+				}
+			}
+			return
+		}
+		for i, a := range node.entries {
+			if !contains(region, a.BBox) {
+				t.Fatalf("child region %v not inside parent region %v", a.BBox, region)
+			}
+			for _, b := range node.entries[i+1:] {
+				if a.BBox.MinX < b.BBox.MaxX && b.BBox.MinX < a.BBox.MaxX &&
+					a.BBox.MinY < b.BBox.MaxY && b.BBox.MinY < a.BBox.MaxY {
+					t.Fatalf("child regions overlap: %v %v", a.BBox, b.BBox)
+				}
+			}
+			recurse(a.Index, a.BBox, depth+1)
+		}
+	}
+	recurse(tr.root, everywhere, 0)
+}
@@ -5,40 +5,247 @@ package rtree
 type Node struct {
 	IsLeaf  bool
 	Entries []Entry
-	Parent  int
+
+	// Aggregate summarises the weights (and number) of all terminal items
+	// under the node.
+	Aggregate Aggregate
+
+	// Tags is the union of the tags of all terminal items under the node,
+	// which allows SearchTagged to skip nodes.
+	Tags uint64
 }
 
 // Entry is an entry under a node, leading either to terminal items, or more nodes.
 type Entry struct {
 	BBox  BBox
 	Index int
+
+	// Payload is an arbitrary value stored alongside a terminal item. It
+	// allows small amounts of data to be retrieved by a search without a
+	// separate lookup using the item index. It's always zero for entries
+	// leading to more nodes.
+	Payload uint64
 }
 
 // RTree is an in-memory R-Tree data structure. Its zero value is an empty R-Tree.
+//
+// An RTree must not be searched while it's being modified. ConcurrentRTree
+// should be used instead if searches need to run concurrently with
+// modifications. Searches may run concurrently with each other, unless
+// Metrics.Enabled is set.
 type RTree struct {
 	RootIndex int
 	Nodes     []Node
+
+	// Metrics holds optional instrumentation counters. They are only
+	// collected when Metrics.Enabled is set, in which case searches update
+	// them and so must not run concurrently with each other.
+	Metrics Metrics
+
+	// Tracer optionally receives events describing insertion decisions.
+	Tracer Tracer
+
+	// Hooks optionally receives callbacks as the tree is modified.
+	Hooks Hooks
+
+	// Period optionally makes the space indexed by the tree wrap around in
+	// X and/or Y. Search and Nearest take the wrapping into account.
+	Period Period
+
+	// LinearScanThreshold is the number of items below which Search and
+	// the nearest neighbour queries scan the items directly, rather than
+	// traversing the tree. If it's zero, then DefaultLinearScanThreshold is
+	// used. If it's negative, then the tree is always traversed.
+	LinearScanThreshold int
+
+	generation uint64
+
+	// mutations counts structural changes, for MutationCounts.
+	mutations MutationCounts
+
+	// quarantine holds items with non-finite bounding boxes that are kept
+	// out of the tree by NonFiniteQuarantine.
+	quarantine []Entry
+
+	// lookup optionally maps data indices to the bounding boxes of their
+	// items. It's nil unless enabled by EnableLookup.
+	lookup map[int]BBox
+
+	// tombstones holds the data indices of items marked as deleted by
+	// MarkDeleted. They're skipped by searches until removed by Vacuum.
+	tombstones map[int]bool
+
+	// tags and weights hold the tags and weights of items by their data
+	// indices, as given to InsertWithTags and InsertWithWeight. They're
+	// kept out of Entry so that trees not using them don't pay for them,
+	// and are nil until first used.
+	tags    map[int]uint64
+	weights map[int]float64
+
+	// hint is the locality cursor used by insertions. It's nil unless
+	// enabled by EnableLocalityHint.
+	hint *localityHint
+
+	// arena is the chunk that entries slices for new nodes are carved
+	// from. Its length is the portion of the chunk already in use.
+	arena []Entry
+
+	// versions is the history of the items, used to search earlier
+	// generations. It's nil unless enabled by EnableVersioning.
+	versions *versionHistory
+
+	// profile collects statistics about searches. It's nil unless enabled
+	// by EnableProfiling.
+	profile *profiler
+
+	// journal holds the insertions and deletions that can be undone. It's
+	// nil unless enabled by EnableJournal.
+	journal *journal
+
+	// pathBuf is storage for the path followed by an insertion, which is
+	// reused so that insertions don't need to allocate it.
+	pathBuf []nodeStep
+}
+
+// Generation gives a counter that is incremented each time the tree is
+// modified. It can be used to detect that a tree has changed.
+func (t *RTree) Generation() uint64 {
+	return t.generation
+}
+
+// checkGeneration panics if the tree has been modified since the generation
+// was recorded.
+func (t *RTree) checkGeneration(gen uint64) {
+	if t.generation != gen {
+		panic("rtree: tree modified during traversal")
+	}
 }
 
 // Search looks for any items in the tree that overlap with the the given
 // bounding box. The callback is called with the item index for each found
-// item.
+// item. Items that only touch the bounding box are found too (see
+// SearchWithBoundary for excluding them).
+//
+// The callback must not modify the tree. Search panics if it detects that the
+// tree was modified by the callback, since node indices may have changed.
 func (t *RTree) Search(bb BBox, callback func(index int)) {
+	t.search(bb, 0, BoundaryClosed, func(e Entry) { callback(e.Index) })
+}
+
+// SearchWithPayload is like Search, but also gives the payload of each found
+// item to the callback.
+func (t *RTree) SearchWithPayload(bb BBox, callback func(index int, payload uint64)) {
+	t.search(bb, 0, BoundaryClosed, func(e Entry) { callback(e.Index, e.Payload) })
+}
+
+// search finds the leaf entries overlapping with the bounding box (according
+// to the boundary semantics). If the tag mask is non-zero, then only entries
+// with tags in the mask are found.
+func (t *RTree) search(bb BBox, tagMask uint64, boundary Boundary, callback func(Entry)) {
 	if len(t.Nodes) == 0 {
 		return
 	}
-	var recurse func(*Node)
-	recurse = func(n *Node) {
+	if t.Metrics.Enabled {
+		t.Metrics.Searches++
+	}
+	var stats *searchStats
+	if t.profile != nil {
+		stats = &searchStats{}
+		defer t.profile.add(stats)
+		inner := callback
+		callback = func(e Entry) {
+			stats.found++
+			inner(e)
+		}
+	}
+	queries := t.Period.wrappedQueries(bb)
+	if len(queries) > 1 {
+		// An item may overlap with more than one of the wrapped queries,
+		// but should only be reported once.
+		type key struct {
+			index int
+			bb    BBox
+		}
+		seen := make(map[key]bool)
+		for _, q := range queries {
+			t.searchOnce(q, tagMask, boundary, stats, func(e Entry) {
+				k := key{e.Index, e.BBox}
+				if !seen[k] {
+					seen[k] = true
+					callback(e)
+				}
+			})
+		}
+		return
+	}
+	t.searchOnce(bb, tagMask, boundary, stats, callback)
+}
+
+// searchOnce finds the leaf entries overlapping with the bounding box,
+// without taking the period into account. If stats is non-nil, then the
+// nodes visited and leaf entries tested are added to it.
+func (t *RTree) searchOnce(bb BBox, tagMask uint64, boundary Boundary, stats *searchStats, callback func(Entry)) {
+	gen := t.generation
+	var recurse func(n *Node, depth int)
+	recurse = func(n *Node, depth int) {
+		if t.Metrics.Enabled {
+			t.Metrics.NodesVisited++
+			t.Metrics.EntriesCompared += len(n.Entries)
+		}
+		if stats != nil {
+			stats.visit(depth)
+			if n.IsLeaf {
+				stats.tested += len(n.Entries)
+			}
+		}
 		for _, entry := range n.Entries {
-			if !overlap(entry.BBox, bb) {
+			if !boundary.overlaps(entry.BBox, bb) || (tagMask != 0 && t.entryTags(entry, n.IsLeaf)&tagMask == 0) {
 				continue
 			}
 			if n.IsLeaf {
-				callback(entry.Index)
+				if t.isTombstoned(entry.Index) {
+					continue
+				}
+				callback(entry)
+				t.checkGeneration(gen)
 			} else {
-				recurse(&t.Nodes[entry.Index])
+				recurse(&t.Nodes[entry.Index], depth+1)
 			}
 		}
 	}
-	recurse(&t.Nodes[t.RootIndex])
+	if t.linearScan() {
+		var height int
+		if stats != nil {
+			height = t.height()
+		}
+		t.scanLeaves(func(n int) { recurse(&t.Nodes[n], height) })
+		return
+	}
+	recurse(&t.Nodes[t.RootIndex], 0)
+}
+
+// Clear removes all items from the tree. The memory allocated for nodes and
+// their entries is retained, and is reused by subsequent insertions. This
+// avoids reallocation for workloads that repeatedly rebuild the tree.
+//
+// The removals aren't journaled, so Clear can't be undone, and the journal's
+// existing undo and redo steps are discarded. The version history is
+// discarded too, so SearchAsOf can't search generations before the Clear.
+func (t *RTree) Clear() {
+	t.hookAllItems(nil)
+	for i := range t.Nodes {
+		t.Nodes[i].Entries = t.Nodes[i].Entries[:0]
+	}
+	t.Nodes = t.Nodes[:0]
+	t.RootIndex = 0
+	t.quarantine = t.quarantine[:0]
+	if t.lookup != nil {
+		t.lookup = make(map[int]BBox)
+	}
+	t.tombstones = nil
+	t.tags = nil
+	t.weights = nil
+	t.invalidateHint()
+	t.generation++
+	t.resetHistory()
 }
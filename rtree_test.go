@@ -1,11 +1,13 @@
 package rtree
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"reflect"
 	"sort"
 	"testing"
+	"unsafe"
 )
 
 func TestRandom(t *testing.T) {
@@ -99,32 +101,43 @@ func checkInvariants(t *testing.T, rt RTree) {
 	t.Logf("RTree description:")
 	t.Logf("node_count=%v, root=%d", len(rt.Nodes), rt.RootIndex)
 	for i, n := range rt.Nodes {
-		t.Logf("%d: leaf=%t numEntries=%d parent=%d", i, n.IsLeaf, len(n.Entries), n.Parent)
+		t.Logf("%d: leaf=%t numEntries=%d", i, n.IsLeaf, len(n.Entries))
 		for j, e := range n.Entries {
 			t.Logf("\t%d: index=%d bbox=%v", j, e.Index, e.BBox)
 		}
 	}
 
-	// Each node has the correct parent set.
-	for i, node := range rt.Nodes {
-		if i == rt.RootIndex {
-			if node.Parent != -1 {
-				t.Fatalf("expected root to have parent -1, but has %d", node.Parent)
-			}
+	if len(rt.Nodes) == 0 {
+		return
+	}
+
+	// Each node other than the root is referred to by exactly one entry.
+	refs := make([]int, len(rt.Nodes))
+	for _, node := range rt.Nodes {
+		if node.IsLeaf {
 			continue
 		}
-		if node.Parent == -1 {
-			t.Fatalf("expected parent for non-root not to be -1, but was -1")
+		for _, entry := range node.Entries {
+			refs[entry.Index]++
+		}
+	}
+	for i, count := range refs {
+		want := 1
+		if i == rt.RootIndex {
+			want = 0
+		}
+		if count != want {
+			t.Fatalf("expected node %d to be referred to %d times, but was %d", i, want, count)
 		}
+	}
 
-		var matchingChildren int
-		for _, entry := range rt.Nodes[node.Parent].Entries {
-			if entry.Index == i {
-				matchingChildren++
-			}
+	// Each node's aggregate should summarise the weights under it.
+	for i := range rt.Nodes {
+		if len(rt.Nodes[i].Entries) == 0 {
+			continue
 		}
-		if matchingChildren != 1 {
-			t.Fatalf("expected parent to have 1 matching child, but has %d", matchingChildren)
+		if got, want := rt.Nodes[i].Aggregate, rt.calculateAggregate(i); got != want {
+			t.Fatalf("node %d has aggregate %v, want %v", i, got, want)
 		}
 	}
 
@@ -145,6 +158,21 @@ func checkInvariants(t *testing.T, rt RTree) {
 		}
 	}
 
+	// Each node's tags should be the union of the tags of its entries.
+	for i, node := range rt.Nodes {
+		var tags uint64
+		for _, e := range node.Entries {
+			if node.IsLeaf {
+				tags |= rt.tags[e.Index]
+			} else {
+				tags |= rt.Nodes[e.Index].Tags
+			}
+		}
+		if tags != node.Tags {
+			t.Fatalf("expected node %d to have union of its entries' tags", i)
+		}
+	}
+
 	// Each leaf should be reached exactly once from the root. This implies
 	// that the tree has no loops, and there are no orphan leafs. Also checks
 	// that each non-leaf is visited at least once (i.e. no orphan non-leaves).
@@ -174,3 +202,624 @@ func checkInvariants(t *testing.T, rt RTree) {
 		}
 	}
 }
+
+func TestLargeMaxChildren(t *testing.T) {
+	for _, maxCapacity := range []int{16, 17, 64, 65, 100} {
+		t.Run(fmt.Sprintf("max_%d", maxCapacity), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(0))
+			boxes := make([]BBox, 1000)
+			for i := range boxes {
+				boxes[i] = randomBox(rnd, 0.9, 0.1)
+			}
+			ins, err := NewInsertionPolicy(maxCapacity/2, maxCapacity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rt RTree
+			for i, bb := range boxes {
+				rt.Insert(bb, i, ins)
+			}
+			for _, n := range rt.Nodes {
+				if len(n.Entries) > maxCapacity {
+					t.Fatalf("node has %d entries", len(n.Entries))
+				}
+			}
+			checkInvariants(t, rt)
+			checkSearch(t, rt, boxes, rnd)
+		})
+	}
+}
+
+func TestNewInsertionPolicyValidation(t *testing.T) {
+	for _, tc := range []struct {
+		min, max int
+		ok       bool
+	}{
+		{1, 2, true},
+		{0, 2, false},
+		{1, 1, false},
+		{2, 3, false},
+		{50, 100, true},
+	} {
+		_, err := NewInsertionPolicy(tc.min, tc.max)
+		if (err == nil) != tc.ok {
+			t.Errorf("min=%d max=%d: unexpected err: %v", tc.min, tc.max, err)
+		}
+	}
+}
+
+func TestLeafCapacity(t *testing.T) {
+	if _, err := mustPolicy(t, 2, 4).WithLeafCapacity(3, 4); err == nil {
+		t.Error("expected error for invalid leaf capacity")
+	}
+
+	for _, tc := range []struct {
+		leafMin, leafMax int
+		min, max         int
+	}{
+		{2, 4, 4, 10},
+		{8, 16, 1, 3},
+		{1, 2, 2, 4},
+	} {
+		name := fmt.Sprintf("leaf_%d_%d_node_%d_%d", tc.leafMin, tc.leafMax, tc.min, tc.max)
+		t.Run(name, func(t *testing.T) {
+			policy, err := mustPolicy(t, tc.min, tc.max).WithLeafCapacity(tc.leafMin, tc.leafMax)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkCapacity := func(t *testing.T, rt RTree) {
+				t.Helper()
+				for i, n := range rt.Nodes {
+					lo, hi := tc.min, tc.max
+					if n.IsLeaf {
+						lo, hi = tc.leafMin, tc.leafMax
+					}
+					if len(n.Entries) > hi {
+						t.Fatalf("node %d (leaf=%t) has %d entries", i, n.IsLeaf, len(n.Entries))
+					}
+					if i != rt.RootIndex && len(n.Entries) < lo {
+						t.Fatalf("node %d (leaf=%t) has %d entries", i, n.IsLeaf, len(n.Entries))
+					}
+				}
+			}
+
+			rnd := rand.New(rand.NewSource(0))
+			boxes := make([]BBox, 500)
+			inserts := make([]InsertItem, len(boxes))
+			var rt RTree
+			for i := range boxes {
+				boxes[i] = randomBox(rnd, 0.9, 0.1)
+				inserts[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+				rt.Insert(boxes[i], i, policy)
+			}
+			checkInvariants(t, rt)
+			checkSearch(t, rt, boxes, rnd)
+			checkCapacity(t, rt)
+
+			for _, load := range []func([]InsertItem, InsertionPolicy) RTree{
+				BulkLoadWithPolicy,
+				BulkLoadKD,
+			} {
+				bulk := load(inserts, policy)
+				checkInvariants(t, bulk)
+				checkSearch(t, bulk, boxes, rnd)
+				for i, n := range bulk.Nodes {
+					hi := tc.max
+					if n.IsLeaf {
+						hi = tc.leafMax
+					}
+					if len(n.Entries) > hi {
+						t.Fatalf("node %d (leaf=%t) has %d entries", i, n.IsLeaf, len(n.Entries))
+					}
+				}
+			}
+		})
+	}
+}
+
+func mustPolicy(t *testing.T, min, max int) InsertionPolicy {
+	t.Helper()
+	policy, err := NewInsertionPolicy(min, max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestMetrics(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rt RTree
+	rt.Insert(randomBox(rnd, 0.9, 0.1), 0, ins)
+	rt.Search(BBox{0, 0, 1, 1}, func(int) {})
+	if rt.Metrics != (Metrics{}) {
+		t.Fatalf("expected no metrics while disabled, got %+v", rt.Metrics)
+	}
+
+	rt.Metrics.Enabled = true
+	for i := 1; i < 100; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, ins)
+	}
+	for i := 0; i < 10; i++ {
+		rt.Search(randomBox(rnd, 0.5, 0.5), func(int) {})
+	}
+	m := rt.Metrics
+	if m.Searches != 10 {
+		t.Errorf("expected 10 searches, got %d", m.Searches)
+	}
+	if m.NodesVisited < m.Searches || m.EntriesCompared < m.NodesVisited {
+		t.Errorf("unexpected search counters: %+v", m)
+	}
+	if m.Splits == 0 || m.BytesAllocated == 0 {
+		t.Errorf("unexpected insert counters: %+v", m)
+	}
+
+	rt.Metrics.Reset()
+	if rt.Metrics != (Metrics{Enabled: true}) {
+		t.Errorf("unexpected metrics after reset: %+v", rt.Metrics)
+	}
+}
+
+func TestMutationCounts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins := mustPolicy(t, 2, 4)
+	tracer := new(recordingTracer)
+	rt := RTree{Tracer: tracer}
+	boxes := make([]BBox, 200)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Insert(boxes[i], i, ins)
+	}
+	c := rt.MutationCounts()
+	if c.Splits != tracer.splitNode || c.RootGrowths != tracer.grewRoot {
+		t.Errorf("counts %+v don't match tracer %+v", c, *tracer)
+	}
+	if c.Reinsertions != 0 || c.Condensations != 0 {
+		t.Errorf("expected no reinsertions or condensations from inserts, got %+v", c)
+	}
+
+	del, err := NewDeletionPolicy(2, ReinsertAtOriginalLevel, ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 150; i++ {
+		rt.DeleteFuncWithPolicy(boxes[i], func(idx int) bool { return idx == i }, del)
+	}
+	c = rt.MutationCounts()
+	if c.Condensations == 0 || c.Reinsertions == 0 {
+		t.Errorf("expected deletions to condense the tree, got %+v", c)
+	}
+	for i := 150; i < 200; i++ {
+		rt.Move(i, randomBox(rnd, 0.9, 0.1), ins)
+	}
+	if got := rt.MutationCounts().Reinsertions; got <= c.Reinsertions {
+		t.Errorf("expected moves to reinsert items, got %d reinsertions", got)
+	}
+
+	rt.Clear()
+	if rt.MutationCounts() == (MutationCounts{}) {
+		t.Error("expected counts to be kept after clear")
+	}
+}
+
+type recordingTracer struct {
+	choseLeaf, splitNode, grewRoot int
+}
+
+func (r *recordingTracer) ChoseLeaf(BBox, int, int)             { r.choseLeaf++ }
+func (r *recordingTracer) SplitNode(int, int, []Entry, []Entry) { r.splitNode++ }
+func (r *recordingTracer) GrewRoot(int, int, int)               { r.grewRoot++ }
+
+func TestTracer(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := new(recordingTracer)
+	rt := RTree{Tracer: tracer}
+	for i := 0; i < 100; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, ins)
+	}
+	if tracer.choseLeaf != 100 {
+		t.Errorf("expected 100 ChoseLeaf events, got %d", tracer.choseLeaf)
+	}
+	if tracer.splitNode != len(rt.Nodes)-1-tracer.grewRoot {
+		t.Errorf("split count %d inconsistent with node count %d and root growth %d",
+			tracer.splitNode, len(rt.Nodes), tracer.grewRoot)
+	}
+	if tracer.grewRoot == 0 {
+		t.Errorf("expected root to have grown")
+	}
+}
+
+func TestClear(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	for round := 0; round < 3; round++ {
+		boxes := make([]BBox, 50)
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.Insert(boxes[i], i, ins)
+		}
+		checkInvariants(t, rt)
+		checkSearch(t, rt, boxes, rnd)
+
+		nodeCap := cap(rt.Nodes)
+		rt.Clear()
+		if len(rt.Nodes) != 0 || cap(rt.Nodes) != nodeCap {
+			t.Fatalf("expected empty tree with retained capacity, got len=%d cap=%d",
+				len(rt.Nodes), cap(rt.Nodes))
+		}
+		rt.Search(BBox{0, 0, 1, 1}, func(i int) {
+			t.Errorf("unexpected item %d after clear", i)
+		})
+	}
+}
+
+func TestGeneration(t *testing.T) {
+	ins, err := NewInsertionPolicy(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	for i := 0; i < 10; i++ {
+		before := rt.Generation()
+		rt.Insert(BBox{0, 0, 1, 1}, i, ins)
+		if rt.Generation() == before {
+			t.Fatalf("expected generation to change on insert")
+		}
+	}
+
+	before := rt.Generation()
+	rt.Search(BBox{0, 0, 1, 1}, func(int) {})
+	if rt.DeleteFunc(BBox{2, 2, 3, 3}, func(int) bool { return true }) != 0 {
+		t.Fatalf("unexpected deletion")
+	}
+	if rt.Generation() != before {
+		t.Fatalf("expected generation to be unchanged without modification")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic when modifying tree during search")
+		}
+	}()
+	rt.Search(BBox{0, 0, 1, 1}, func(idx int) {
+		rt.Insert(BBox{0, 0, 1, 1}, 100+idx, ins)
+	})
+}
+
+func TestMemoryUsage(t *testing.T) {
+	var rt RTree
+	if got := rt.MemoryUsage(); got != 0 {
+		t.Errorf("expected zero memory usage for empty tree, got %d", got)
+	}
+
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	for i := 0; i < 100; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, ins)
+	}
+	used := rt.MemoryUsage()
+	if min := 100 * int(unsafe.Sizeof(Entry{})); used < min {
+		t.Errorf("memory usage %d less than minimum %d", used, min)
+	}
+	rt.Clear()
+	if got := rt.MemoryUsage(); got != used {
+		t.Errorf("expected memory usage to be retained after clear, got %d want %d", got, used)
+	}
+}
+
+func TestEntriesArena(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	boxes := make([]BBox, 500)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Insert(boxes[i], i, ins)
+	}
+	for i, n := range rt.Nodes {
+		if cap(n.Entries) != 6 {
+			t.Fatalf("node %d has entries capacity %d", i, cap(n.Entries))
+		}
+	}
+	checkSearch(t, rt, boxes, rnd)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), 0, ins)
+	})
+	if allocs > 4 {
+		t.Errorf("too many allocations per insert: %v", allocs)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	boxes := make([]BBox, 100)
+	var rt RTree
+	var items []InsertItem
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.InsertWithPayload(boxes[i], i, uint64(i)*7, ins)
+		items = append(items, InsertItem{BBox: boxes[i], DataIndex: i, Payload: uint64(i) * 7})
+	}
+	for i := 0; i < 100; i += 2 {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Adjust(i, boxes[i], ins)
+	}
+	bulk := BulkLoad(items)
+
+	for _, tr := range []*RTree{&rt, &bulk} {
+		var count int
+		tr.SearchWithPayload(BBox{0, 0, 1, 1}, func(idx int, payload uint64) {
+			count++
+			if payload != uint64(idx)*7 {
+				t.Errorf("item %d has payload %d", idx, payload)
+			}
+		})
+		if count != len(boxes) {
+			t.Errorf("found %d items, want %d", count, len(boxes))
+		}
+	}
+}
+
+func TestBulkLoadDeterministic(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	var items []InsertItem
+	for i := 0; i < 200; i++ {
+		bb := randomBox(rnd, 0.9, 0.1)
+		if i%4 == 0 {
+			// Boxes with identical centres exercise the tie-break.
+			bb = BBox{0.5 - float64(i%3)*0.1, 0.5, 0.5 + float64(i%3)*0.1, 0.5}
+		}
+		items = append(items, InsertItem{BBox: bb, DataIndex: i, Payload: uint64(i % 7)})
+	}
+	want := BulkLoad(items)
+	for i := 0; i < 5; i++ {
+		rnd.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		got := BulkLoad(items)
+		if !reflect.DeepEqual(got.Nodes, want.Nodes) || got.RootIndex != want.RootIndex {
+			t.Fatal("bulk loaded trees differ")
+		}
+	}
+}
+
+func TestBulkLoadZeroPolicy(t *testing.T) {
+	items := []InsertItem{
+		{BBox: BBox{0, 0, 1, 1}, DataIndex: 0},
+		{BBox: BBox{2, 2, 3, 3}, DataIndex: 1},
+		{BBox: BBox{4, 4, 5, 5}, DataIndex: 2},
+	}
+	var zero InsertionPolicy
+	for name, load := range map[string]func(){
+		"BulkLoadWithPolicy": func() { BulkLoadWithPolicy(items, zero) },
+		"BulkLoadTGS":        func() { BulkLoadTGS(items, zero) },
+		"BulkLoadKD":         func() { BulkLoadKD(items, zero) },
+		"BulkLoadSorted":     func() { BulkLoadSorted(items, zero) },
+		"BulkLoadMorton":     func() { BulkLoadMorton(items, zero) },
+		"BulkLoadSTR":        func() { BulkLoadSTR(items, zero, STROptions{}) },
+		"Repack": func() {
+			rt := BulkLoad(items)
+			rt.Repack(zero)
+		},
+		"TransformAndRepack": func() {
+			rt := BulkLoad(items)
+			rt.TransformAndRepack(0, -1, 1, 0, 0, 0, zero)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if err, _ := recover().(error); !errors.Is(err, ErrInvalidPolicy) {
+					t.Errorf("expected panic with ErrInvalidPolicy, got %v", err)
+				}
+			}()
+			load()
+		})
+	}
+}
+
+func TestBulkLoadWithPolicy(t *testing.T) {
+	for _, population := range []int{0, 1, 2, 4, 5, 16, 17, 63, 64, 65, 200} {
+		for _, maxChildren := range []int{2, 4, 9} {
+			name := fmt.Sprintf("max_%d_pop_%d", maxChildren, population)
+			t.Run(name, func(t *testing.T) {
+				rnd := rand.New(rand.NewSource(0))
+				boxes := make([]BBox, population)
+				inserts := make([]InsertItem, population)
+				for i := range boxes {
+					boxes[i] = randomBox(rnd, 0.9, 0.1)
+					inserts[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+				}
+				policy, err := NewInsertionPolicy(maxChildren/2, maxChildren)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rt := BulkLoadWithPolicy(inserts, policy)
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+
+				// All leaves should be at the same depth, and nodes
+				// other than the root should respect the policy.
+				leafDepth := -1
+				var recurse func(n, depth int)
+				recurse = func(n, depth int) {
+					node := &rt.Nodes[n]
+					if len(node.Entries) > maxChildren {
+						t.Fatalf("node %d has %d entries", n, len(node.Entries))
+					}
+					if n != rt.RootIndex && len(node.Entries) < maxChildren/2 {
+						t.Fatalf("node %d has %d entries", n, len(node.Entries))
+					}
+					if node.IsLeaf {
+						if leafDepth != -1 && leafDepth != depth {
+							t.Fatalf("leaves at depths %d and %d", leafDepth, depth)
+						}
+						leafDepth = depth
+						return
+					}
+					for _, e := range node.Entries {
+						recurse(e.Index, depth+1)
+					}
+				}
+				if population > 0 {
+					recurse(rt.RootIndex, 0)
+				}
+
+				// The tree can continue to be modified using the policy.
+				for i := 0; i < 20; i++ {
+					bb := randomBox(rnd, 0.9, 0.1)
+					rt.Insert(bb, len(boxes), policy)
+					boxes = append(boxes, bb)
+				}
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+			})
+		}
+	}
+}
+
+func TestBulkLoadSorted(t *testing.T) {
+	for _, population := range []int{0, 1, 2, 5, 16, 17, 100, 300} {
+		for _, maxChildren := range []int{2, 3, 8} {
+			name := fmt.Sprintf("max_%d_pop_%d", maxChildren, population)
+			t.Run(name, func(t *testing.T) {
+				rnd := rand.New(rand.NewSource(0))
+				boxes := make([]BBox, population)
+				inserts := make([]InsertItem, population)
+				for i := range boxes {
+					boxes[i] = randomBox(rnd, 0.9, 0.1)
+					inserts[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+				}
+				sort.Slice(inserts, func(i, j int) bool {
+					return HilbertOf(inserts[i].BBox, EmptyBBox) < HilbertOf(inserts[j].BBox, EmptyBBox)
+				})
+				rt := BulkLoadSorted(inserts, mustPolicy(t, 1, maxChildren))
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+				for i, n := range rt.Nodes {
+					if len(n.Entries) > maxChildren {
+						t.Fatalf("node %d has %d entries", i, len(n.Entries))
+					}
+					if i != rt.RootIndex && 2*len(n.Entries) < maxChildren {
+						t.Fatalf("node %d has only %d entries", i, len(n.Entries))
+					}
+				}
+
+				// The items should be in the leaves in their original order.
+				var got []int
+				for idx := range rt.All() {
+					got = append(got, idx)
+				}
+				for i, item := range inserts {
+					if got[i] != item.DataIndex {
+						t.Fatalf("item %d is %d, want %d", i, got[i], item.DataIndex)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBulkLoadMorton(t *testing.T) {
+	for _, population := range []int{0, 1, 2, 5, 16, 17, 100, 300} {
+		for _, maxChildren := range []int{2, 3, 8} {
+			name := fmt.Sprintf("max_%d_pop_%d", maxChildren, population)
+			t.Run(name, func(t *testing.T) {
+				rnd := rand.New(rand.NewSource(0))
+				boxes := make([]BBox, population)
+				inserts := make([]InsertItem, population)
+				for i := range boxes {
+					boxes[i] = randomBox(rnd, 0.9, 0.1)
+					inserts[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+				}
+				policy := mustPolicy(t, 1, maxChildren)
+				rt := BulkLoadMorton(inserts, policy)
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+				for i, item := range inserts {
+					if item.DataIndex != i {
+						t.Fatalf("input items were modified")
+					}
+				}
+
+				// The items should be in the leaves in Z-order.
+				var prev uint64
+				extent := BBox{0, 0, 1, 1}
+				if population > 0 {
+					extent = rt.calculateBound(rt.RootIndex)
+				}
+				for idx := range rt.All() {
+					key := MortonOf(boxes[idx], extent)
+					if key < prev {
+						t.Fatalf("item %d is out of order", idx)
+					}
+					prev = key
+				}
+
+				// The tree shouldn't depend on the order of the items.
+				rnd.Shuffle(len(inserts), func(i, j int) {
+					inserts[i], inserts[j] = inserts[j], inserts[i]
+				})
+				again := BulkLoadMorton(inserts, policy)
+				if !reflect.DeepEqual(rt.Nodes, again.Nodes) || rt.RootIndex != again.RootIndex {
+					t.Error("expected tree to be deterministic")
+				}
+			})
+		}
+	}
+}
+
+func TestBulkLoadKD(t *testing.T) {
+	for _, population := range []int{0, 1, 2, 5, 16, 17, 100, 300} {
+		for _, maxChildren := range []int{2, 3, 8} {
+			name := fmt.Sprintf("max_%d_pop_%d", maxChildren, population)
+			t.Run(name, func(t *testing.T) {
+				rnd := rand.New(rand.NewSource(0))
+				boxes := make([]BBox, population)
+				inserts := make([]InsertItem, population)
+				for i := range boxes {
+					boxes[i] = randomBox(rnd, 0.9, 0.1)
+					inserts[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+				}
+				policy, err := NewInsertionPolicy(1, maxChildren)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rt := BulkLoadKD(inserts, policy)
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+				for i, n := range rt.Nodes {
+					if len(n.Entries) > maxChildren {
+						t.Fatalf("node %d has %d entries", i, len(n.Entries))
+					}
+				}
+				if maxChildren == 2 {
+					want := BulkLoad(inserts)
+					if !reflect.DeepEqual(rt.Nodes, want.Nodes) || rt.RootIndex != want.RootIndex {
+						t.Error("expected fan-out of 2 to match BulkLoad")
+					}
+				}
+			})
+		}
+	}
+}
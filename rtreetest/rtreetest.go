@@ -0,0 +1,226 @@
+// Package rtreetest provides utilities for testing code built on top of the
+// rtree package: generators for random bounding boxes and trees, a checker
+// for the structural invariants of a tree, and a fuzz target.
+package rtreetest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/peterstace/rtree"
+)
+
+// RandomBox generates a random bounding box. Its minimum corner has
+// coordinates in [0, maxStart), and its width and height are in [0,
+// maxWidth). Coordinates are truncated to 2 decimal places, so that
+// generated boxes often share edges.
+func RandomBox(rnd *rand.Rand, maxStart, maxWidth float64) rtree.BBox {
+	bb := rtree.BBox{
+		MinX: rnd.Float64() * maxStart,
+		MinY: rnd.Float64() * maxStart,
+	}
+	bb.MaxX = bb.MinX + rnd.Float64()*maxWidth
+	bb.MaxY = bb.MinY + rnd.Float64()*maxWidth
+
+	bb.MinX = float64(int(bb.MinX*100)) / 100
+	bb.MinY = float64(int(bb.MinY*100)) / 100
+	bb.MaxX = float64(int(bb.MaxX*100)) / 100
+	bb.MaxY = float64(int(bb.MaxY*100)) / 100
+	return bb
+}
+
+// RandomTree generates a tree holding the given number of random items. The
+// shape of the tree varies: it's built by one of the bulk loaders, or by
+// inserting the items one at a time using the policy (with some items
+// inserted twice and then deleted to exercise condensation). The item with
+// data index i has the bounding box boxes[i].
+func RandomTree(rnd *rand.Rand, population int, policy rtree.InsertionPolicy) (tr rtree.RTree, boxes []rtree.BBox) {
+	boxes = make([]rtree.BBox, population)
+	items := make([]rtree.InsertItem, population)
+	for i := range boxes {
+		boxes[i] = RandomBox(rnd, 0.9, 0.1)
+		items[i] = rtree.InsertItem{BBox: boxes[i], DataIndex: i}
+	}
+	switch rnd.Intn(4) {
+	case 0:
+		tr = rtree.BulkLoad(items)
+	case 1:
+		tr = rtree.BulkLoadWithPolicy(items, policy)
+	case 2:
+		tr = rtree.BulkLoadKD(items, policy)
+	default:
+		extra := population
+		for i, bb := range boxes {
+			tr.Insert(bb, i, policy)
+			if rnd.Intn(4) == 0 {
+				tr.Insert(RandomBox(rnd, 0.9, 0.1), extra, policy)
+				extra++
+			}
+		}
+		tr.DeleteFunc(rtree.BBox{MinX: -1, MinY: -1, MaxX: 2, MaxY: 2}, func(idx int) bool {
+			return idx >= population
+		})
+	}
+	return tr, boxes
+}
+
+// CheckInvariants checks the structural invariants of a tree, giving an
+// error describing the first violation found. As well as the structure
+// checked by RTree.ValidateDeep, it checks that each node's aggregate
+// summarises the weights of the items under it. The error wraps
+// rtree.ErrCorruptTree.
+func CheckInvariants(tr *rtree.RTree) error {
+	if err := tr.ValidateDeep(); err != nil {
+		return err
+	}
+	if len(tr.Nodes) == 0 {
+		return nil
+	}
+	if _, err := checkAggregates(tr, tr.RootIndex); err != nil {
+		return fmt.Errorf("%w: %v", rtree.ErrCorruptTree, err)
+	}
+	return nil
+}
+
+// checkAggregates checks the aggregates of node n and the nodes under it,
+// giving the aggregate of the weights of the items under n. The structure of
+// the tree must already have been validated.
+func checkAggregates(tr *rtree.RTree, n int) (rtree.Aggregate, error) {
+	node := &tr.Nodes[n]
+	var agg rtree.Aggregate
+	for i, e := range node.Entries {
+		w := tr.WeightOf(e.Index)
+		a := rtree.Aggregate{Sum: w, Min: w, Max: w, Count: 1}
+		if !node.IsLeaf {
+			var err error
+			if a, err = checkAggregates(tr, e.Index); err != nil {
+				return agg, err
+			}
+		}
+		if i == 0 {
+			agg = a
+		} else {
+			agg = rtree.Aggregate{
+				Sum:   agg.Sum + a.Sum,
+				Min:   math.Min(agg.Min, a.Min),
+				Max:   math.Max(agg.Max, a.Max),
+				Count: agg.Count + a.Count,
+			}
+		}
+	}
+
+	// The sum may have been accumulated in a different order, so isn't
+	// necessarily exactly equal.
+	got := node.Aggregate
+	if got.Min != agg.Min || got.Max != agg.Max || got.Count != agg.Count || !approxEqual(got.Sum, agg.Sum) {
+		return agg, fmt.Errorf("node %d has aggregate %v, expected %v", n, got, agg)
+	}
+	return agg, nil
+}
+
+func approxEqual(a, b float64) bool {
+	return a == b || math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// CheckSearch checks that searching the tree with the query finds exactly
+// the items whose bounding boxes (given by boxes, indexed by data index)
+// overlap with it. Items with empty bounding boxes are expected to be absent
+// from the tree.
+func CheckSearch(tr *rtree.RTree, boxes []rtree.BBox, query rtree.BBox) error {
+	var got []int
+	tr.Search(query, func(idx int) { got = append(got, idx) })
+	var want []int
+	for i, bb := range boxes {
+		if !bb.IsEmpty() && !query.IsEmpty() &&
+			bb.MinX <= query.MaxX && bb.MaxX >= query.MinX &&
+			bb.MinY <= query.MaxY && bb.MaxY >= query.MinY {
+			want = append(want, i)
+		}
+	}
+	sort.Ints(got)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Errorf("search %v found %v, expected %v", query, got, want)
+	}
+	return nil
+}
+
+// Fuzz is a fuzz target compatible with go-fuzz (and easily wrapped by a
+// native Go fuzz test). The data is interpreted as an insertion policy
+// followed by a sequence of operations on a tree, and the tree's invariants
+// are checked after each operation. It panics if an invariant is violated.
+//
+// The return value is 1 if the data was a valid sequence of operations, and 0
+// otherwise.
+func Fuzz(data []byte) int {
+	if err := runOps(data); err != nil {
+		if errors.Is(err, errInvalidInput) {
+			return 0
+		}
+		panic(err)
+	}
+	return 1
+}
+
+var errInvalidInput = errors.New("invalid input")
+
+// opSize is the number of bytes used to encode each operation: an opcode
+// followed by the four coordinates of a bounding box.
+const opSize = 5
+
+func runOps(data []byte) error {
+	if len(data) < 1 || (len(data)-1)%opSize != 0 {
+		return errInvalidInput
+	}
+	maxChildren := 2 + int(data[0]%15)
+	minChildren := 1 + int(data[0]/15)%(maxChildren/2)
+	policy, err := rtree.NewInsertionPolicy(minChildren, maxChildren)
+	if err != nil {
+		return err
+	}
+
+	var tr rtree.RTree
+	var boxes []rtree.BBox
+	for ops := data[1:]; len(ops) > 0; ops = ops[opSize:] {
+		bb := rtree.BBox{
+			MinX: float64(ops[1]) / 16,
+			MinY: float64(ops[2]) / 16,
+		}
+		bb.MaxX = bb.MinX + float64(ops[3])/64
+		bb.MaxY = bb.MinY + float64(ops[4])/64
+
+		switch ops[0] % 4 {
+		case 0, 1:
+			tr.Insert(bb, len(boxes), policy)
+			boxes = append(boxes, bb)
+		case 2:
+			// Delete every item overlapping with the box whose data
+			// index has the same parity as the opcode.
+			parity := int(ops[0]/4) % 2
+			tr.DeleteFunc(bb, func(idx int) bool {
+				if idx%2 != parity {
+					return false
+				}
+				boxes[idx] = rtree.EmptyBBox
+				return true
+			})
+		case 3:
+			if len(boxes) == 0 {
+				continue
+			}
+			idx := int(ops[0]/4) % len(boxes)
+			if tr.Adjust(idx, bb, policy) {
+				boxes[idx] = bb
+			}
+		}
+		if err := CheckInvariants(&tr); err != nil {
+			return err
+		}
+		if err := CheckSearch(&tr, boxes, bb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package rtreetest
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/peterstace/rtree"
+)
+
+func TestRandomTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy, err := rtree.NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		tr, boxes := RandomTree(rnd, rnd.Intn(200), policy)
+		if err := CheckInvariants(&tr); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 10; j++ {
+			if err := CheckSearch(&tr, boxes, RandomBox(rnd, 0.5, 0.5)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestCheckInvariantsDetectsCorruption(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy, err := rtree.NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, corrupt := range map[string]func(*rtree.RTree){
+		"loose_bbox": func(tr *rtree.RTree) {
+			tr.Nodes[tr.RootIndex].Entries[0].BBox.MaxX++
+		},
+		"shared_child": func(tr *rtree.RTree) {
+			root := &tr.Nodes[tr.RootIndex]
+			root.Entries[1].Index = root.Entries[0].Index
+		},
+		"wrong_tags": func(tr *rtree.RTree) {
+			tr.Nodes[tr.RootIndex].Tags = 1
+		},
+		"wrong_aggregate": func(tr *rtree.RTree) {
+			tr.Nodes[tr.RootIndex].Aggregate.Max = 1
+		},
+		"orphan": func(tr *rtree.RTree) {
+			tr.Nodes = append(tr.Nodes, rtree.Node{IsLeaf: true})
+		},
+	} {
+		var tr rtree.RTree
+		for i := 0; i < 50; i++ {
+			tr.Insert(RandomBox(rnd, 0.9, 0.1), i, policy)
+		}
+		if err := CheckInvariants(&tr); err != nil {
+			t.Fatalf("%s: unexpected error before corruption: %v", name, err)
+		}
+		corrupt(&tr)
+		if err := CheckInvariants(&tr); !errors.Is(err, rtree.ErrCorruptTree) {
+			t.Errorf("%s: expected ErrCorruptTree, got %v", name, err)
+		}
+	}
+}
+
+func TestFuzz(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	for i := 0; i < 200; i++ {
+		data := make([]byte, 1+opSize*rnd.Intn(100))
+		rnd.Read(data)
+		if Fuzz(data) != 1 {
+			t.Fatalf("expected valid input: %x", data)
+		}
+	}
+	if Fuzz(nil) != 0 || Fuzz([]byte{1, 2}) != 0 {
+		t.Error("expected invalid input")
+	}
+}
@@ -0,0 +1,119 @@
+package rtree
+
+import "math/rand"
+
+// Sample gives the data indices of n distinct items chosen uniformly at
+// random, in random order. Each item is found by descending from the root,
+// using the number of items under each subtree (see Aggregate) to pick the
+// child to descend into, so drawing an item takes time proportional to the
+// height of the tree rather than the number of items. All items are given
+// (in random order) if the tree holds n or fewer. Items marked as deleted by
+// MarkDeleted are never chosen.
+//
+// To sample items with probability proportional to their area (or some
+// other measure), see SampleByWeight.
+func (t *RTree) Sample(n int, rnd *rand.Rand) []int {
+	if n <= 0 || len(t.Nodes) == 0 {
+		return nil
+	}
+	total := t.Nodes[t.RootIndex].Aggregate.Count
+	if 2*n >= total {
+		// Most of the items are needed, so it's cheaper to shuffle all of
+		// them than to keep drawing until enough distinct items are found.
+		var all []int
+		for idx := range t.All() {
+			all = append(all, idx)
+		}
+		rnd.Shuffle(len(all), func(i, j int) {
+			all[i], all[j] = all[j], all[i]
+		})
+		return all[:min(n, len(all))]
+	}
+
+	drawn := make(map[int]bool, n)
+	sample := make([]int, 0, n)
+	for len(sample) < n && len(drawn) < total {
+		rank := rnd.Intn(total)
+		if drawn[rank] {
+			continue
+		}
+		drawn[rank] = true
+		if e := t.entryAtRank(rank); !t.isTombstoned(e.Index) {
+			sample = append(sample, e.Index)
+		}
+	}
+	return sample
+}
+
+// SampleByWeight gives the data indices of n items chosen at random, with
+// each item's probability of being chosen proportional to its weight (see
+// InsertWithWeight). Like Sample, each item is found by descending from the
+// root, in this case using the sum of the weights under each subtree. Items
+// are chosen independently, so the same item may be given more than once.
+//
+// Using each item's area as its weight gives an area-weighted sample, where
+// larger items are more likely to be chosen. Weights must not be negative.
+// It gives nil if the items don't have a positive total weight. Items marked
+// as deleted by MarkDeleted are skipped when chosen, so fewer than n indices
+// may be given if there are any.
+func (t *RTree) SampleByWeight(n int, rnd *rand.Rand) []int {
+	if n <= 0 || len(t.Nodes) == 0 || !(t.Nodes[t.RootIndex].Aggregate.Sum > 0) {
+		return nil
+	}
+	sample := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		e := t.entryAtWeight(rnd.Float64() * t.Nodes[t.RootIndex].Aggregate.Sum)
+		if !t.isTombstoned(e.Index) {
+			sample = append(sample, e.Index)
+		}
+	}
+	return sample
+}
+
+// entryAtRank gives the leaf entry at the given rank, where leaf entries are
+// ranked in depth-first order. The rank must be less than the number of
+// items in the tree.
+func (t *RTree) entryAtRank(rank int) Entry {
+	n := t.RootIndex
+	for !t.Nodes[n].IsLeaf {
+		for _, e := range t.Nodes[n].Entries {
+			count := t.Nodes[e.Index].Aggregate.Count
+			if rank < count {
+				n = e.Index
+				break
+			}
+			rank -= count
+		}
+	}
+	return t.Nodes[n].Entries[rank]
+}
+
+// entryAtWeight gives the leaf entry whose weight spans the given position
+// within the cumulative weights of the leaf entries (in depth-first order).
+// Positions beyond the total weight (due to rounding) give the last entry
+// with a positive weight.
+func (t *RTree) entryAtWeight(pos float64) Entry {
+	n := t.RootIndex
+	for {
+		node := &t.Nodes[n]
+		chosen := -1
+		for i, e := range node.Entries {
+			w := t.weights[e.Index]
+			if !node.IsLeaf {
+				w = t.Nodes[e.Index].Aggregate.Sum
+			}
+			if w <= 0 {
+				continue
+			}
+			chosen = i
+			if pos < w {
+				break
+			}
+			pos -= w
+		}
+		if node.IsLeaf {
+			return node.Entries[chosen]
+		}
+		n = node.Entries[chosen].Index
+	}
+}
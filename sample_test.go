@@ -0,0 +1,119 @@
+package rtree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 5)
+
+	t.Run("empty", func(t *testing.T) {
+		var rt RTree
+		if got := rt.Sample(3, rnd); got != nil {
+			t.Errorf("expected nil, got %v", got)
+		}
+		if got := rt.SampleByWeight(3, rnd); got != nil {
+			t.Errorf("expected nil, got %v", got)
+		}
+	})
+
+	t.Run("distinct", func(t *testing.T) {
+		var items []InsertItem
+		for i := 0; i < 200; i++ {
+			items = append(items, InsertItem{BBox: randomBox(rnd, 0.9, 0.1), DataIndex: i})
+		}
+		rt := BulkLoadWithPolicy(items, policy)
+		for _, n := range []int{1, 10, 99, 150, 200, 300} {
+			got := rt.Sample(n, rnd)
+			if want := min(n, 200); len(got) != want {
+				t.Fatalf("n=%d: got %d items, want %d", n, len(got), want)
+			}
+			seen := make(map[int]bool)
+			for _, idx := range got {
+				if idx < 0 || idx >= 200 || seen[idx] {
+					t.Fatalf("n=%d: invalid or repeated item %d", n, idx)
+				}
+				seen[idx] = true
+			}
+		}
+	})
+
+	t.Run("uniform", func(t *testing.T) {
+		var rt RTree
+		for i := 0; i < 50; i++ {
+			rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+		}
+		counts := make([]int, 50)
+		const draws = 20000
+		for i := 0; i < draws/5; i++ {
+			for _, idx := range rt.Sample(5, rnd) {
+				counts[idx]++
+			}
+		}
+		for idx, c := range counts {
+			if want := draws / 50; math.Abs(float64(c-want)) > 0.25*float64(want) {
+				t.Errorf("item %d sampled %d times, want about %d", idx, c, want)
+			}
+		}
+	})
+
+	t.Run("skips_tombstones", func(t *testing.T) {
+		var rt RTree
+		for i := 0; i < 100; i++ {
+			rt.InsertWithWeight(randomBox(rnd, 0.9, 0.1), i, 1, policy)
+		}
+		for i := 0; i < 100; i += 2 {
+			rt.MarkDeleted(i)
+		}
+		for _, n := range []int{5, 100} {
+			got := rt.Sample(n, rnd)
+			if want := min(n, 50); len(got) != want {
+				t.Errorf("n=%d: got %d items, want %d", n, len(got), want)
+			}
+			for _, idx := range append(got, rt.SampleByWeight(n, rnd)...) {
+				if idx%2 == 0 {
+					t.Errorf("sampled deleted item %d", idx)
+				}
+			}
+		}
+	})
+}
+
+func TestSampleByWeight(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 5)
+	var rt RTree
+	var total float64
+	for i := 0; i < 20; i++ {
+		// Weight each item by its area, so that larger items are sampled more
+		// often.
+		bb := BBox{0, 0, float64(i + 1), 1}
+		area := (bb.MaxX - bb.MinX) * (bb.MaxY - bb.MinY)
+		rt.InsertWithWeight(bb, i, area, policy)
+		total += area
+	}
+	rt.InsertWithWeight(BBox{0, 0, 1, 1}, 20, 0, policy)
+	checkInvariants(t, rt)
+
+	const draws = 50000
+	got := rt.SampleByWeight(draws, rnd)
+	if len(got) != draws {
+		t.Fatalf("got %d items, want %d", len(got), draws)
+	}
+	counts := make([]int, 21)
+	for _, idx := range got {
+		counts[idx]++
+	}
+	if counts[20] != 0 {
+		t.Errorf("item with zero weight sampled %d times", counts[20])
+	}
+	for i := 0; i < 20; i++ {
+		want := draws * float64(i+1) / total
+		if math.Abs(float64(counts[i])-want) > 0.2*want+20 {
+			t.Errorf("item %d sampled %d times, want about %.0f", i, counts[i], want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package rtree
+
+// DefaultLinearScanThreshold is the number of items below which searches
+// scan the items directly rather than traversing the tree, unless
+// RTree.LinearScanThreshold says otherwise.
+const DefaultLinearScanThreshold = 32
+
+// linearScan checks if the tree is small enough that searches should scan
+// its leaves directly. For trees with only a few dozen items, this avoids
+// the overhead of descending through the internal nodes (and testing their
+// bounding boxes).
+//
+// Since the tree doesn't keep a count of its items, the leaves are counted
+// until the threshold is reached. This is cheap for large trees too, since
+// the count stops early.
+func (t *RTree) linearScan() bool {
+	threshold := t.LinearScanThreshold
+	if threshold == 0 {
+		threshold = DefaultLinearScanThreshold
+	}
+	if threshold < 0 || t.Nodes[t.RootIndex].IsLeaf {
+		// A tree consisting of a single leaf is searched by scanning it
+		// anyway.
+		return false
+	}
+	var items int
+	for i := range t.Nodes {
+		if t.Nodes[i].IsLeaf {
+			items += len(t.Nodes[i].Entries)
+			if items >= threshold {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// scanLeaves calls fn with the index of each leaf node in the tree.
+func (t *RTree) scanLeaves(fn func(n int)) {
+	for i := range t.Nodes {
+		if t.Nodes[i].IsLeaf {
+			fn(i)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestLinearScan(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 4)
+	for _, population := range []int{0, 3, 20, 31, 32, 100} {
+		var rt RTree
+		for i := 0; i < population; i++ {
+			rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+		}
+		if population > 0 {
+			rt.MarkDeleted(0)
+		}
+		traversed := rt
+		traversed.LinearScanThreshold = -1
+
+		var leaves int
+		for _, n := range rt.Nodes {
+			if n.IsLeaf {
+				leaves++
+			}
+		}
+		wantScan := population > 0 && population < DefaultLinearScanThreshold && !rt.Nodes[rt.RootIndex].IsLeaf
+		if len(rt.Nodes) > 0 && rt.linearScan() != wantScan {
+			t.Fatalf("population %d: expected linear scan %t", population, wantScan)
+		}
+
+		for i := 0; i < 10; i++ {
+			query := randomBox(rnd, 0.5, 0.5)
+			rt.Metrics = Metrics{Enabled: true}
+			var got, want []int
+			rt.Search(query, func(idx int) { got = append(got, idx) })
+			traversed.Search(query, func(idx int) { want = append(want, idx) })
+			sort.Ints(got)
+			sort.Ints(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("population %d: got %v want %v", population, got, want)
+			}
+			if wantScan && rt.Metrics.NodesVisited != leaves {
+				t.Errorf("expected scan to visit %d leaves, visited %d nodes", leaves, rt.Metrics.NodesVisited)
+			}
+
+			x, y := rnd.Float64(), rnd.Float64()
+			if got, want := rt.Nearest(x, y, 5), traversed.Nearest(x, y, 5); !reflect.DeepEqual(got, want) {
+				t.Fatalf("population %d: nearest got %v want %v", population, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package rtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The serialised format of an RTree, as written by WriteTo and read by
+// ReadRTree, is:
+//
+//	magic        [4]byte  "RTRE"
+//	byte order   uint16   0xFEFF, written in the byte order of the rest of the data
+//	version      uint16   currently 1
+//	entry fields uint16   bitmask of the optional entry fields that are present
+//	entry size   uint16   size in bytes of each entry
+//	root index   uint64
+//	node count   uint64
+//	nodes        node count nodes
+//
+// Each node is:
+//
+//	is leaf      uint8    0 or 1
+//	entry count  uint32
+//	entries      entry count entries
+//
+// Each entry is:
+//
+//	bbox         4 * float64 (MinX, MinY, MaxX, MaxY)
+//	index        int64
+//	payload      uint64   (if entry fields has bit 0 set)
+//	tags         uint64   (if entry fields has bit 1 set)
+//	weight       float64  (if entry fields has bit 2 set)
+//
+// WriteTo always writes little endian data with all optional entry fields.
+// ReadRTree accepts either byte order and any combination of the optional
+// entry fields (missing fields are zero), so that data written by older
+// versions of this package remains readable. Node parents and aggregates
+// aren't stored, and are recalculated when the tree is read.
+const (
+	serialMagic       = "RTRE"
+	serialByteOrder   = 0xFEFF
+	serialVersion     = 1
+	serialPayload     = 1 << 0
+	serialTags        = 1 << 1
+	serialWeight      = 1 << 2
+	serialFieldsKnown = serialPayload | serialTags | serialWeight
+)
+
+// serialEntrySize gives the size of an entry with the given optional fields.
+func serialEntrySize(fields uint16) int {
+	size := 40
+	for _, f := range []uint16{serialPayload, serialTags, serialWeight} {
+		if fields&f != 0 {
+			size += 8
+		}
+	}
+	return size
+}
+
+// WriteTo writes the tree to w in a versioned binary format that can be read
+// using ReadRTree. The Metrics, Tracer, Hooks and Period of the tree aren't
+// written, and nor are items marked as deleted by MarkDeleted.
+func (t *RTree) WriteTo(w io.Writer) (int64, error) {
+	t = t.withoutTombstones()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	le := binary.LittleEndian
+	const fields = serialFieldsKnown
+
+	header := make([]byte, 28)
+	copy(header, serialMagic)
+	le.PutUint16(header[4:], serialByteOrder)
+	le.PutUint16(header[6:], serialVersion)
+	le.PutUint16(header[8:], fields)
+	le.PutUint16(header[10:], uint16(serialEntrySize(fields)))
+	le.PutUint64(header[12:], uint64(t.RootIndex))
+	le.PutUint64(header[20:], uint64(len(t.Nodes)))
+	cw.write(header)
+
+	rec := make([]byte, serialEntrySize(fields))
+	for _, node := range t.Nodes {
+		var nodeHeader [5]byte
+		if node.IsLeaf {
+			nodeHeader[0] = 1
+		}
+		le.PutUint32(nodeHeader[1:], uint32(len(node.Entries)))
+		cw.write(nodeHeader[:])
+		for _, e := range node.Entries {
+			le.PutUint64(rec[0:], math.Float64bits(e.BBox.MinX))
+			le.PutUint64(rec[8:], math.Float64bits(e.BBox.MinY))
+			le.PutUint64(rec[16:], math.Float64bits(e.BBox.MaxX))
+			le.PutUint64(rec[24:], math.Float64bits(e.BBox.MaxY))
+			le.PutUint64(rec[32:], uint64(e.Index))
+			le.PutUint64(rec[40:], e.Payload)
+			le.PutUint64(rec[48:], t.entryTags(e, node.IsLeaf))
+			var weight float64
+			if node.IsLeaf {
+				weight = t.weights[e.Index]
+			}
+			le.PutUint64(rec[56:], math.Float64bits(weight))
+			cw.write(rec)
+		}
+	}
+	if cw.err == nil {
+		cw.err = cw.w.(*bufio.Writer).Flush()
+	}
+	return cw.n, cw.err
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) write(p []byte) {
+	if c.err != nil {
+		return
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+}
+
+// ReadRTree reads a tree that was written by WriteTo. An error is returned if
+// the data is malformed, or was written in a format version that isn't
+// supported.
+func ReadRTree(r io.Reader) (RTree, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, 28)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return RTree{}, wrapReadError(err, "reading rtree header")
+	}
+	if string(header[:4]) != serialMagic {
+		return RTree{}, fmt.Errorf("%w: not a serialised rtree: bad magic", ErrCorruptTree)
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint16(header[4:]) == serialByteOrder:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint16(header[4:]) == serialByteOrder:
+		order = binary.BigEndian
+	default:
+		return RTree{}, fmt.Errorf("%w: serialised rtree has invalid byte order marker", ErrCorruptTree)
+	}
+	if v := order.Uint16(header[6:]); v == 0 || v > serialVersion {
+		return RTree{}, fmt.Errorf("unsupported rtree format version %d (supported versions are 1 to %d)", v, serialVersion)
+	}
+	fields := order.Uint16(header[8:])
+	if fields&^serialFieldsKnown != 0 {
+		return RTree{}, fmt.Errorf("unsupported rtree entry fields %#x", fields)
+	}
+	entrySize := int(order.Uint16(header[10:]))
+	if entrySize != serialEntrySize(fields) {
+		return RTree{}, fmt.Errorf("%w: rtree entry size %d doesn't match entry fields %#x", ErrCorruptTree, entrySize, fields)
+	}
+	root := order.Uint64(header[12:])
+	nodeCount := order.Uint64(header[20:])
+	if nodeCount == 0 && root != 0 || nodeCount > 0 && root >= nodeCount {
+		return RTree{}, fmt.Errorf("%w: rtree root index %d out of range", ErrCorruptTree, root)
+	}
+
+	t := RTree{RootIndex: int(root)}
+	rec := make([]byte, entrySize)
+	for i := uint64(0); i < nodeCount; i++ {
+		var nodeHeader [5]byte
+		if _, err := io.ReadFull(br, nodeHeader[:]); err != nil {
+			return RTree{}, wrapReadError(err, "reading rtree node %d", i)
+		}
+		node := Node{IsLeaf: nodeHeader[0] == 1}
+		count := order.Uint32(nodeHeader[1:])
+		for j := uint32(0); j < count; j++ {
+			if _, err := io.ReadFull(br, rec); err != nil {
+				return RTree{}, wrapReadError(err, "reading rtree node %d", i)
+			}
+			e := Entry{
+				BBox: BBox{
+					MinX: math.Float64frombits(order.Uint64(rec[0:])),
+					MinY: math.Float64frombits(order.Uint64(rec[8:])),
+					MaxX: math.Float64frombits(order.Uint64(rec[16:])),
+					MaxY: math.Float64frombits(order.Uint64(rec[24:])),
+				},
+				Index: int(int64(order.Uint64(rec[32:]))),
+			}
+			off := 40
+			if fields&serialPayload != 0 {
+				e.Payload = order.Uint64(rec[off:])
+				off += 8
+			}
+			// The tags of entries leading to nodes are recalculated
+			// once the whole tree has been read.
+			if fields&serialTags != 0 {
+				if node.IsLeaf {
+					t.setTags(e.Index, order.Uint64(rec[off:]))
+				}
+				off += 8
+			}
+			if fields&serialWeight != 0 && node.IsLeaf {
+				t.setWeight(e.Index, math.Float64frombits(order.Uint64(rec[off:])))
+			}
+			node.Entries = append(node.Entries, e)
+		}
+		t.Nodes = append(t.Nodes, node)
+	}
+
+	// Check that the nodes form a tree, i.e. that each node other than the
+	// root is the child of exactly one node.
+	hasParent := make([]bool, len(t.Nodes))
+	for i, node := range t.Nodes {
+		if node.IsLeaf {
+			continue
+		}
+		for _, e := range node.Entries {
+			if e.Index < 0 || e.Index >= len(t.Nodes) || e.Index == t.RootIndex || hasParent[e.Index] {
+				return RTree{}, fmt.Errorf("%w: rtree node %d has invalid child %d", ErrCorruptTree, i, e.Index)
+			}
+			hasParent[e.Index] = true
+		}
+	}
+	if len(t.Nodes) > 0 {
+		t.calculateAggregates(t.RootIndex)
+	}
+	return t, nil
+}
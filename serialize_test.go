@@ -0,0 +1,133 @@
+package rtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSerialiseRoundTrip(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, population := range []int{0, 1, 100} {
+		rnd := rand.New(rand.NewSource(0))
+		var rt RTree
+		boxes := make([]BBox, population)
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.setTags(i, uint64(rnd.Intn(8)))
+			rt.setWeight(i, float64(rnd.Intn(100)))
+			rt.insertEntry(Entry{
+				BBox:    boxes[i],
+				Index:   i,
+				Payload: rnd.Uint64(),
+			}, ins)
+		}
+
+		var buf bytes.Buffer
+		n, err := rt.WriteTo(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(buf.Len()) {
+			t.Errorf("WriteTo reported %d bytes, but wrote %d", n, buf.Len())
+		}
+		got, err := ReadRTree(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkInvariants(t, got)
+		checkSearch(t, got, boxes, rnd)
+		if got.RootIndex != rt.RootIndex || len(got.Nodes) != len(rt.Nodes) {
+			t.Fatalf("structure differs")
+		}
+		for i := range rt.Nodes {
+			if !reflect.DeepEqual(got.Nodes[i], rt.Nodes[i]) {
+				t.Fatalf("node %d differs: got %v want %v", i, got.Nodes[i], rt.Nodes[i])
+			}
+		}
+		if !reflect.DeepEqual(got.tags, rt.tags) || !reflect.DeepEqual(got.weights, rt.weights) {
+			t.Fatalf("tags or weights differ")
+		}
+	}
+}
+
+func TestReadRTreeOlderLayout(t *testing.T) {
+	// A big endian tree with a single leaf and no optional entry fields.
+	var buf bytes.Buffer
+	be := binary.BigEndian
+	buf.WriteString("RTRE")
+	binary.Write(&buf, be, []uint16{0xFEFF, 1, 0, 40})
+	binary.Write(&buf, be, []uint64{0, 1})
+	buf.WriteByte(1)
+	binary.Write(&buf, be, uint32(2))
+	binary.Write(&buf, be, []float64{0, 0, 1, 1})
+	binary.Write(&buf, be, int64(7))
+	binary.Write(&buf, be, []float64{2, 2, 3, 3})
+	binary.Write(&buf, be, int64(8))
+
+	rt, err := ReadRTree(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, rt)
+	var got []int
+	rt.Search(BBox{0, 0, 3, 3}, func(idx int) { got = append(got, idx) })
+	if !reflect.DeepEqual(got, []int{7, 8}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestReadRTreeErrors(t *testing.T) {
+	var rt RTree
+	ins, _ := NewInsertionPolicy(1, 2)
+	for i := 0; i < 10; i++ {
+		rt.Insert(BBox{float64(i), 0, float64(i), 0}, i, ins)
+	}
+	var buf bytes.Buffer
+	if _, err := rt.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	good := buf.Bytes()
+
+	modified := func(fn func([]byte)) []byte {
+		b := append([]byte(nil), good...)
+		fn(b)
+		return b
+	}
+	le := binary.LittleEndian
+	for _, tc := range []struct {
+		data      []byte
+		want      string
+		corrupt   bool
+		truncated bool
+	}{
+		{good[:10], "header", true, true},
+		{good[:len(good)-1], "reading rtree node", true, true},
+		{modified(func(b []byte) { b[0] = 'X' }), "magic", true, false},
+		{modified(func(b []byte) { le.PutUint16(b[4:], 0x1234) }), "byte order", true, false},
+		{modified(func(b []byte) { le.PutUint16(b[6:], 2) }), "unsupported rtree format version 2", false, false},
+		{modified(func(b []byte) { le.PutUint16(b[8:], 1<<8) }), "entry fields", false, false},
+		{modified(func(b []byte) { le.PutUint16(b[10:], 99) }), "entry size", true, false},
+		{modified(func(b []byte) { le.PutUint64(b[12:], math.MaxUint32) }), "root index", true, false},
+	} {
+		_, err := ReadRTree(bytes.NewReader(tc.data))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("expected error containing %q, got %v", tc.want, err)
+		}
+		if errors.Is(err, ErrCorruptTree) != tc.corrupt {
+			t.Errorf("%q: got errors.Is(err, ErrCorruptTree) = %t, want %t", tc.want, !tc.corrupt, tc.corrupt)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) != tc.truncated {
+			t.Errorf("%q: got errors.Is(err, io.ErrUnexpectedEOF) = %t, want %t", tc.want, !tc.truncated, tc.truncated)
+		}
+	}
+}
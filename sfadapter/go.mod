@@ -0,0 +1,10 @@
+module github.com/peterstace/rtree/sfadapter
+
+go 1.23
+
+require (
+	github.com/peterstace/rtree v0.0.0
+	github.com/peterstace/simplefeatures v0.45.0
+)
+
+replace github.com/peterstace/rtree => ../
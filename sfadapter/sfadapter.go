@@ -0,0 +1,58 @@
+// Package sfadapter converts between the bounding boxes used by
+// github.com/peterstace/rtree and the envelopes used by
+// github.com/peterstace/simplefeatures.
+//
+// It's a separate module so that the rtree package doesn't depend on
+// simplefeatures. Running go test ./... from the repository root doesn't
+// include it, so its tests must be run from within the sfadapter directory.
+package sfadapter
+
+import (
+	"fmt"
+
+	"github.com/peterstace/rtree"
+	"github.com/peterstace/simplefeatures/geom"
+)
+
+// FromEnvelope converts a simplefeatures envelope to a bounding box. An empty
+// envelope gives rtree.EmptyBBox.
+func FromEnvelope(env geom.Envelope) rtree.BBox {
+	min, max, ok := env.MinMaxXYs()
+	if !ok {
+		return rtree.EmptyBBox
+	}
+	return rtree.BBox{MinX: min.X, MinY: min.Y, MaxX: max.X, MaxY: max.Y}
+}
+
+// ToEnvelope converts a bounding box to a simplefeatures envelope.
+// rtree.EmptyBBox gives an empty envelope. Envelopes can't have non-finite
+// coordinates, so an error wrapping rtree.ErrInvalidBBox is returned for
+// bounding boxes that do.
+func ToEnvelope(bb rtree.BBox) (geom.Envelope, error) {
+	if bb.IsEmpty() {
+		return geom.Envelope{}, nil
+	}
+	env, err := geom.NewEnvelope([]geom.XY{
+		{X: bb.MinX, Y: bb.MinY},
+		{X: bb.MaxX, Y: bb.MaxY},
+	})
+	if err != nil {
+		return geom.Envelope{}, fmt.Errorf("%w: %v", rtree.ErrInvalidBBox, err)
+	}
+	return env, nil
+}
+
+// BulkLoad bulk loads the bounds of the geometries into a new R-Tree. The
+// data index of each item is the position of its geometry in the slice.
+// Empty geometries are skipped.
+func BulkLoad(geoms []geom.Geometry) rtree.RTree {
+	items := make([]rtree.InsertItem, 0, len(geoms))
+	for i, g := range geoms {
+		bb := FromEnvelope(g.Envelope())
+		if bb.IsEmpty() {
+			continue
+		}
+		items = append(items, rtree.InsertItem{BBox: bb, DataIndex: i})
+	}
+	return rtree.BulkLoad(items)
+}
@@ -0,0 +1,54 @@
+package sfadapter
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/peterstace/rtree"
+	"github.com/peterstace/simplefeatures/geom"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	for _, bb := range []rtree.BBox{
+		{MinX: 1, MinY: 2, MaxX: 3, MaxY: 4},
+		{MinX: 1, MinY: 2, MaxX: 1, MaxY: 2},
+		rtree.EmptyBBox,
+	} {
+		env, err := ToEnvelope(bb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := FromEnvelope(env); got != bb && !(got.IsEmpty() && bb.IsEmpty()) {
+			t.Errorf("got %v want %v", got, bb)
+		}
+	}
+	if _, err := ToEnvelope(rtree.UniverseBBox); !errors.Is(err, rtree.ErrInvalidBBox) {
+		t.Errorf("expected ErrInvalidBBox, got %v", err)
+	}
+}
+
+func TestBulkLoad(t *testing.T) {
+	var geoms []geom.Geometry
+	for _, wkt := range []string{
+		"POINT(1 2)",
+		"POINT EMPTY",
+		"LINESTRING(0 0,4 5)",
+	} {
+		g, err := geom.UnmarshalWKT(wkt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		geoms = append(geoms, g)
+	}
+	tr := BulkLoad(geoms)
+	var got []int
+	tr.Search(rtree.BBox{MinX: 1, MinY: 2, MaxX: 1, MaxY: 2}, func(idx int) {
+		got = append(got, idx)
+	})
+	sort.Ints(got)
+	if want := []int{0, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
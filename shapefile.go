@@ -0,0 +1,107 @@
+package rtree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Shapefile shape types that have a single point rather than a bounding box
+// in their record contents.
+const (
+	shapeNull   = 0
+	shapePoint  = 1
+	shapePointZ = 11
+	shapePointM = 21
+)
+
+// BulkLoadShapefile bulk loads the shapes from the main file (.shp) of an
+// ESRI shapefile into a new R-Tree. The data index of each item is the
+// shape's record number (which starts at 1). Only the bounding box in the
+// header of each record is read, so the geometries themselves don't need to
+// be parsed. Null shapes are skipped. Errors caused by malformed (including
+// truncated) input wrap ErrCorruptTree.
+func BulkLoadShapefile(r io.Reader) (RTree, error) {
+	var header [100]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return RTree{}, wrapReadError(err, "reading shapefile header")
+	}
+	if code := binary.BigEndian.Uint32(header[0:]); code != 9994 {
+		return RTree{}, fmt.Errorf("%w: not a shapefile: file code %d", ErrCorruptTree, code)
+	}
+
+	var items []InsertItem
+	for {
+		var recHeader [8]byte
+		_, err := io.ReadFull(r, recHeader[:])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return RTree{}, wrapReadError(err, "reading shapefile record header")
+		}
+		recNum := int(int32(binary.BigEndian.Uint32(recHeader[0:])))
+		length := int64(binary.BigEndian.Uint32(recHeader[4:])) * 2 // 16-bit words
+
+		bb, ok, err := readShapeBBox(io.LimitReader(r, length), length)
+		if err != nil {
+			return RTree{}, wrapReadError(err, "reading shapefile record %d", recNum)
+		}
+		if ok {
+			items = append(items, InsertItem{BBox: bb, DataIndex: recNum})
+		}
+	}
+	return BulkLoad(items), nil
+}
+
+// readShapeBBox reads the bounding box of a shape from its record contents,
+// consuming the entire record. The return value ok is false for null
+// shapes.
+func readShapeBBox(r io.Reader, length int64) (bb BBox, ok bool, err error) {
+	defer func() {
+		if err == nil {
+			_, err = io.Copy(io.Discard, r)
+		}
+	}()
+
+	if length < 4 {
+		return BBox{}, false, fmt.Errorf("%w: record too short", ErrCorruptTree)
+	}
+	var shapeType int32
+	if err := binary.Read(r, binary.LittleEndian, &shapeType); err != nil {
+		return BBox{}, false, err
+	}
+	readFloats := func(n int) ([]float64, error) {
+		fs := make([]float64, n)
+		if int64(4+8*n) > length {
+			return nil, fmt.Errorf("%w: record too short", ErrCorruptTree)
+		}
+		if err := binary.Read(r, binary.LittleEndian, fs); err != nil {
+			return nil, err
+		}
+		return fs, nil
+	}
+
+	switch shapeType {
+	case shapeNull:
+		return BBox{}, false, nil
+	case shapePoint, shapePointZ, shapePointM:
+		xy, err := readFloats(2)
+		if err != nil {
+			return BBox{}, false, err
+		}
+		return BBox{xy[0], xy[1], xy[0], xy[1]}, true, nil
+	default:
+		box, err := readFloats(4)
+		if err != nil {
+			return BBox{}, false, err
+		}
+		for _, f := range box {
+			if math.IsNaN(f) {
+				return BBox{}, false, fmt.Errorf("%w: bounding box has NaN coordinates", ErrCorruptTree)
+			}
+		}
+		return BBox{box[0], box[1], box[2], box[3]}, true, nil
+	}
+}
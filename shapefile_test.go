@@ -0,0 +1,87 @@
+package rtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// buildShapefile builds the main file of a shapefile. Each record is given
+// by its shape type and the floats following the shape type.
+func buildShapefile(records []shapeRecord) []byte {
+	var body bytes.Buffer
+	for i, rec := range records {
+		var content bytes.Buffer
+		binary.Write(&content, binary.LittleEndian, int32(rec.shapeType))
+		binary.Write(&content, binary.LittleEndian, rec.floats)
+		binary.Write(&body, binary.BigEndian, int32(i+1))
+		binary.Write(&body, binary.BigEndian, int32(content.Len()/2))
+		body.Write(content.Bytes())
+	}
+	header := make([]byte, 100)
+	binary.BigEndian.PutUint32(header[0:], 9994)
+	binary.BigEndian.PutUint32(header[24:], uint32((100+body.Len())/2))
+	binary.LittleEndian.PutUint32(header[28:], 1000)
+	return append(header, body.Bytes()...)
+}
+
+type shapeRecord struct {
+	shapeType int
+	floats    []float64
+}
+
+func TestBulkLoadShapefile(t *testing.T) {
+	shp := buildShapefile([]shapeRecord{
+		{shapePoint, []float64{1, 2}},
+		{0, nil},
+		// Polygon: bbox, numParts, numPoints would follow (as floats here
+		// just to pad the record).
+		{5, []float64{0, 0, 10, 5, 123, 456}},
+		{shapePointZ, []float64{7, 8, 9}},
+		{13, []float64{-3, -4, -1, -2}},
+	})
+	rt, err := BulkLoadShapefile(bytes.NewReader(shp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, rt)
+
+	for _, tc := range []struct {
+		query BBox
+		want  []int
+	}{
+		{BBox{-100, -100, 100, 100}, []int{1, 3, 4, 5}},
+		{BBox{1, 2, 1, 2}, []int{1, 3}},
+		{BBox{-2, -2, -2, -2}, []int{5}},
+		{BBox{7, 8, 7, 8}, []int{4}},
+	} {
+		var got []int
+		rt.Search(tc.query, func(idx int) { got = append(got, idx) })
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %v want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestBulkLoadShapefileErrors(t *testing.T) {
+	good := buildShapefile([]shapeRecord{{5, []float64{0, 0, 1, 1}}})
+	for name, data := range map[string][]byte{
+		"short header":    good[:50],
+		"bad file code":   append([]byte{0, 0, 0, 1}, good[4:]...),
+		"truncated":       good[:len(good)-8],
+		"short bbox":      buildShapefile([]shapeRecord{{5, []float64{0, 0}}}),
+		"partial record":  good[:104],
+		"short point rec": buildShapefile([]shapeRecord{{shapePoint, []float64{1}}}),
+	} {
+		if _, err := BulkLoadShapefile(bytes.NewReader(data)); !errors.Is(err, ErrCorruptTree) {
+			t.Errorf("%s: expected ErrCorruptTree, got %v", name, err)
+		} else if !strings.Contains(err.Error(), "shapefile") {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+}
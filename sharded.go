@@ -0,0 +1,194 @@
+package rtree
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// ShardedRTree partitions items across several independent R-Trees (shards),
+// so that all cores can be used for both modifications and queries. Each
+// item is assigned to a shard based on the position of its bounding box
+// centre along a Hilbert curve covering an extent, so each shard covers a
+// spatially compact region. Modifications to different shards can proceed
+// concurrently, and queries are run against the shards concurrently before
+// the results are merged.
+//
+// All methods are safe for concurrent use.
+type ShardedRTree struct {
+	extent BBox
+	policy InsertionPolicy
+	shards []treeShard
+}
+
+type treeShard struct {
+	mu   sync.RWMutex
+	tree RTree
+}
+
+// NewShardedRTree creates a new empty tree with the given number of shards,
+// each using the insertion policy. The extent should cover the region that
+// items are expected to be in. Items outside the extent are still accepted,
+// but are assigned to the shards covering the nearest part of the extent,
+// which may unbalance the shards.
+//
+// An error wrapping ErrInvalidPolicy is returned if the number of shards is
+// less than 1 or the policy is the zero value, and an error wrapping
+// ErrInvalidBBox is returned if the extent is empty or not finite.
+func NewShardedRTree(shards int, extent BBox, policy InsertionPolicy) (*ShardedRTree, error) {
+	if shards < 1 {
+		return nil, fmt.Errorf("%w: must have at least 1 shard", ErrInvalidPolicy)
+	}
+	if err := policy.check(); err != nil {
+		return nil, err
+	}
+	if extent.IsEmpty() || !isFinite(extent) {
+		return nil, fmt.Errorf("%w: extent must be non-empty and finite", ErrInvalidBBox)
+	}
+	return &ShardedRTree{
+		extent: extent,
+		policy: policy,
+		shards: make([]treeShard, shards),
+	}, nil
+}
+
+// shardFor gives the shard that an item with the bounding box belongs to.
+func (t *ShardedRTree) shardFor(bb BBox) *treeShard {
+	cx, cy := bb.Center()
+	x := gridCoord(cx, t.extent.MinX, t.extent.MaxX)
+	y := gridCoord(cy, t.extent.MinY, t.extent.MaxY)
+	hi, _ := bits.Mul64(hilbert(x, y), uint64(len(t.shards)))
+	return &t.shards[hi]
+}
+
+// gridCoord maps v from the range [min, max] onto a 2^32 cell grid, clamping
+// it to the range first. Every value maps to cell 0 if the range is empty or
+// has zero width.
+func gridCoord(v, min, max float64) uint32 {
+	if !(max > min) {
+		return 0
+	}
+	f := (v - min) / (max - min) * (1 << 32)
+	switch {
+	case !(f > 0): // also catches NaN
+		return 0
+	case f >= math.MaxUint32:
+		return math.MaxUint32
+	default:
+		return uint32(f)
+	}
+}
+
+// Len gives the number of items in the tree.
+func (t *ShardedRTree) Len() int {
+	var n int
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.mu.RLock()
+		for _, node := range s.tree.Nodes {
+			if node.IsLeaf {
+				n += len(node.Entries)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+// Insert adds a new data item to the tree. It panics if the insertion policy
+// rejects the bounding box.
+func (t *ShardedRTree) Insert(bb BBox, dataIndex int) {
+	s := t.shardFor(bb)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree.Insert(bb, dataIndex, t.policy)
+}
+
+// Delete removes a single data item matching the bounding box and data index
+// from the tree. It returns false if no item matched.
+func (t *ShardedRTree) Delete(bb BBox, dataIndex int) bool {
+	s := t.shardFor(bb)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var done bool
+	s.tree.deleteEntries(bb, func(e Entry) bool {
+		if done || e.Index != dataIndex || e.BBox != bb {
+			return false
+		}
+		done = true
+		return true
+	}, DeletionPolicy{})
+	return done
+}
+
+// Search looks for any items in the tree that overlap with the given
+// bounding box. The shards are searched concurrently, and then the callback
+// is called with the data index of each found item (from the calling
+// goroutine).
+func (t *ShardedRTree) Search(bb BBox, callback func(index int)) {
+	results := make([][]int, len(t.shards))
+	t.eachShard(func(i int, tr *RTree) {
+		tr.Search(bb, func(idx int) {
+			results[i] = append(results[i], idx)
+		})
+	})
+	for _, r := range results {
+		for _, idx := range r {
+			callback(idx)
+		}
+	}
+}
+
+// Nearest gives the data indices of (up to) the k items nearest to the point
+// (x, y), ordered from nearest to farthest. The shards are searched
+// concurrently, and their results merged.
+func (t *ShardedRTree) Nearest(x, y float64, k int) []int {
+	if k <= 0 {
+		return nil
+	}
+	type candidate struct {
+		index int
+		dist  float64
+	}
+	results := make([][]candidate, len(t.shards))
+	t.eachShard(func(i int, tr *RTree) {
+		tr.NearestToBox(BBox{x, y, x, y}, func(idx int, d float64) bool {
+			results[i] = append(results[i], candidate{idx, d})
+			return len(results[i]) < k
+		})
+	})
+	var merged []candidate
+	for _, r := range results {
+		merged = append(merged, r...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].dist < merged[j].dist
+	})
+	if len(merged) > k {
+		merged = merged[:k]
+	}
+	indices := make([]int, len(merged))
+	for i, c := range merged {
+		indices[i] = c.index
+	}
+	return indices
+}
+
+// eachShard calls fn concurrently for each shard's tree, holding the shard's
+// read lock.
+func (t *ShardedRTree) eachShard(fn func(i int, tr *RTree)) {
+	var wg sync.WaitGroup
+	for i := range t.shards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s := &t.shards[i]
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			fn(i, &s.tree)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,124 @@
+package rtree
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestShardedRTree(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewShardedRTree(0, BBox{0, 0, 1, 1}, ins); err == nil {
+		t.Error("expected error for no shards")
+	}
+	if _, err := NewShardedRTree(4, EmptyBBox, ins); err == nil {
+		t.Error("expected error for empty extent")
+	}
+
+	st, err := NewShardedRTree(4, BBox{0, 0, 1, 1}, ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	boxes := make([]BBox, 1000)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+	}
+	boxes[0] = BBox{5, 5, 6, 6} // outside of the extent
+
+	// Insert concurrently from several goroutines.
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(boxes); i += 4 {
+				st.Insert(boxes[i], i)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if st.Len() != len(boxes) {
+		t.Fatalf("got len %d, want %d", st.Len(), len(boxes))
+	}
+	for i := range st.shards {
+		if n := len(st.shards[i].tree.Nodes); n == 0 {
+			t.Errorf("shard %d is empty", i)
+		}
+	}
+
+	for i := 0; i < len(boxes); i += 3 {
+		if !st.Delete(boxes[i], i) {
+			t.Fatalf("item %d not deleted", i)
+		}
+		boxes[i] = BBox{-5, -5, -4, -4}
+	}
+	if st.Delete(BBox{-5, -5, -4, -4}, 0) {
+		t.Error("expected missing item not to be deleted")
+	}
+
+	var all RTree
+	for i, bb := range boxes {
+		if bb.MinX >= 0 {
+			all.Insert(bb, i, ins)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		query := randomBox(rnd, 0.8, 0.4)
+		var got, want []int
+		st.Search(query, func(idx int) { got = append(got, idx) })
+		all.Search(query, func(idx int) { want = append(want, idx) })
+		sort.Ints(got)
+		sort.Ints(want)
+		if len(got) != len(want) {
+			t.Fatalf("search %v: got %v, want %v", query, got, want)
+		}
+		for j := range got {
+			if got[j] != want[j] {
+				t.Fatalf("search %v: got %v, want %v", query, got, want)
+			}
+		}
+
+		x, y := rnd.Float64(), rnd.Float64()
+		gotNN := st.Nearest(x, y, 7)
+		wantNN := all.Nearest(x, y, 7)
+		if len(gotNN) != len(wantNN) {
+			t.Fatalf("nearest: got %v, want %v", gotNN, wantNN)
+		}
+		for j := range gotNN {
+			gd := all.pointDistance(x, y, boxes[gotNN[j]])
+			wd := all.pointDistance(x, y, boxes[wantNN[j]])
+			if gd != wd {
+				t.Fatalf("nearest %d: got distance %v, want %v", j, gd, wd)
+			}
+		}
+	}
+}
+
+func TestNewShardedRTreeErrors(t *testing.T) {
+	policy := mustPolicy(t, 2, 8)
+	extent := BBox{0, 0, 1, 1}
+	for _, tc := range []struct {
+		name   string
+		shards int
+		extent BBox
+		policy InsertionPolicy
+		want   error
+	}{
+		{"no_shards", 0, extent, policy, ErrInvalidPolicy},
+		{"zero_policy", 4, extent, InsertionPolicy{}, ErrInvalidPolicy},
+		{"empty_extent", 4, EmptyBBox, policy, ErrInvalidBBox},
+		{"infinite_extent", 4, UniverseBBox, policy, ErrInvalidBBox},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewShardedRTree(tc.shards, tc.extent, tc.policy); !errors.Is(err, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package rtree
+
+import (
+	"math"
+	"sort"
+)
+
+// STROptions controls the shape of the tiles that BulkLoadSTR groups entries
+// into.
+type STROptions struct {
+	// Slices is the number of vertical slices that the items are divided
+	// into when building the leaves (it's capped at the number of leaves).
+	// If zero, the number of slices is chosen using Aspect. Levels above the
+	// leaves always choose the number of slices using Aspect.
+	Slices int
+
+	// Aspect is the desired ratio of the width to the height of each tile,
+	// in the units of the data. For example, an Aspect of 10 gives tiles 10
+	// times wider than they are tall. If zero, each level is divided into
+	// the same number of slices as there are tiles within each slice (the
+	// standard STR tiling), regardless of the shape of the data.
+	//
+	// Setting Aspect to 1 gives square tiles. For elongated datasets (e.g. a
+	// road network along a coastline), this gives tighter nodes than the
+	// standard tiling, which stretches tiles along the dataset's long axis.
+	Aspect float64
+}
+
+// BulkLoadSTR bulk loads items into a new R-Tree using the Sort-Tile-Recursive
+// algorithm of Leutenegger, Lopez and Edgington. The tree is built bottom up.
+// At each level, the entries are sorted by the centres of their bounding
+// boxes along the X axis and divided into vertical slices, then each slice
+// is sorted along the Y axis and divided into tiles that become the nodes of
+// the level. The shape of the tiles can be tuned using opts.
+//
+// Nodes hold up to the policy's maximum number of children, with the entries
+// of each slice spread evenly between its nodes. Like BulkLoad, the resulting
+// tree is deterministic. It panics if the policy is the zero value.
+func BulkLoadSTR(inserts []InsertItem, policy InsertionPolicy, opts STROptions) RTree {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	var tr RTree
+	if len(inserts) == 0 {
+		return tr
+	}
+	level := make([]Entry, len(inserts))
+	for i, item := range inserts {
+		level[i] = Entry{BBox: item.BBox, Index: item.DataIndex, Payload: item.Payload}
+	}
+
+	isLeaf := true
+	slices := opts.Slices
+	for {
+		fanOut := policy.forNode(isLeaf).maxChildren
+		if len(level) <= fanOut {
+			strSort(level, true)
+			tr.Nodes = append(tr.Nodes, Node{IsLeaf: isLeaf, Entries: level})
+			tr.RootIndex = len(tr.Nodes) - 1
+			break
+		}
+
+		tiles := (len(level) + fanOut - 1) / fanOut
+		if slices <= 0 {
+			slices = strSlices(level, tiles, opts.Aspect)
+		}
+		slices = min(slices, tiles)
+
+		var next []Entry
+		strSort(level, true)
+		for _, slice := range splitEvenly(level, slices) {
+			strSort(slice, false)
+			for _, tile := range splitEvenly(slice, (len(slice)+fanOut-1)/fanOut) {
+				tr.Nodes = append(tr.Nodes, Node{IsLeaf: isLeaf, Entries: append([]Entry(nil), tile...)})
+				n := len(tr.Nodes) - 1
+				next = append(next, Entry{BBox: tr.calculateBound(n), Index: n})
+			}
+		}
+		level = next
+		isLeaf = false
+		slices = 0
+	}
+	tr.calculateAggregates(tr.RootIndex)
+	return tr
+}
+
+// strSlices gives the number of slices to divide the entries into, such that
+// dividing each slice into tiles gives tiles with the desired aspect ratio.
+func strSlices(entries []Entry, tiles int, aspect float64) int {
+	if aspect <= 0 {
+		return int(math.Ceil(math.Sqrt(float64(tiles))))
+	}
+	bound := entries[0].BBox
+	for _, e := range entries[1:] {
+		bound = combine(bound, e.BBox)
+	}
+	w, h := bound.MaxX-bound.MinX, bound.MaxY-bound.MinY
+	switch {
+	case w <= 0:
+		return 1
+	case h <= 0:
+		return tiles
+	}
+	// With s slices, each tile is w/s wide and h*s/tiles tall.
+	s := int(math.Round(math.Sqrt(w * float64(tiles) / (h * aspect))))
+	return min(max(s, 1), tiles)
+}
+
+// strSort sorts entries by the centres of their bounding boxes along one
+// axis, with ties broken in the same way as bulkLess.
+func strSort(entries []Entry, horizontal bool) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		return bulkLess(
+			InsertItem{BBox: a.BBox, DataIndex: a.Index, Payload: a.Payload},
+			InsertItem{BBox: b.BBox, DataIndex: b.Index, Payload: b.Payload},
+			horizontal,
+		)
+	})
+}
@@ -0,0 +1,104 @@
+package rtree
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBulkLoadSTR(t *testing.T) {
+	for _, population := range []int{0, 1, 2, 5, 16, 17, 100, 300} {
+		for _, maxChildren := range []int{2, 3, 8} {
+			for _, opts := range []STROptions{{}, {Slices: 3}, {Aspect: 1}, {Aspect: 20}} {
+				name := fmt.Sprintf("max_%d_pop_%d_slices_%d_aspect_%v", maxChildren, population, opts.Slices, opts.Aspect)
+				t.Run(name, func(t *testing.T) {
+					rnd := rand.New(rand.NewSource(0))
+					boxes := make([]BBox, population)
+					inserts := make([]InsertItem, population)
+					for i := range boxes {
+						boxes[i] = randomBox(rnd, 0.9, 0.1)
+						inserts[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+					}
+					policy := mustPolicy(t, 1, maxChildren)
+					rt := BulkLoadSTR(inserts, policy, opts)
+					checkInvariants(t, rt)
+					checkSearch(t, rt, boxes, rnd)
+					for i, n := range rt.Nodes {
+						if len(n.Entries) > maxChildren {
+							t.Fatalf("node %d has %d entries", i, len(n.Entries))
+						}
+					}
+					for i, item := range inserts {
+						if item.DataIndex != i {
+							t.Fatalf("input items were modified")
+						}
+					}
+
+					// The tree shouldn't depend on the order of the items.
+					rnd.Shuffle(len(inserts), func(i, j int) {
+						inserts[i], inserts[j] = inserts[j], inserts[i]
+					})
+					again := BulkLoadSTR(inserts, policy, opts)
+					if !reflect.DeepEqual(rt.Nodes, again.Nodes) || rt.RootIndex != again.RootIndex {
+						t.Error("expected tree to be deterministic")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestBulkLoadSTRSlices(t *testing.T) {
+	// With a single slice, the leaves divide the items purely by their Y
+	// coordinates.
+	rnd := rand.New(rand.NewSource(0))
+	var items []InsertItem
+	for i := 0; i < 200; i++ {
+		x, y := rnd.Float64(), rnd.Float64()
+		items = append(items, InsertItem{BBox: BBox{x, y, x, y}, DataIndex: i})
+	}
+	rt := BulkLoadSTR(items, mustPolicy(t, 2, 8), STROptions{Slices: 1})
+	checkInvariants(t, rt)
+	var leaves []BBox
+	for i, n := range rt.Nodes {
+		if n.IsLeaf {
+			leaves = append(leaves, rt.calculateBound(i))
+		}
+	}
+	for i := range leaves {
+		for j := i + 1; j < len(leaves); j++ {
+			if leaves[i].MaxY >= leaves[j].MinY && leaves[j].MaxY >= leaves[i].MinY {
+				t.Fatalf("leaves %v and %v overlap in Y", leaves[i], leaves[j])
+			}
+		}
+	}
+}
+
+func TestBulkLoadSTRAspect(t *testing.T) {
+	// Points along a long thin strip, which the standard tiling divides
+	// into tiles stretched along the strip.
+	rnd := rand.New(rand.NewSource(0))
+	var items []InsertItem
+	for i := 0; i < 2000; i++ {
+		x, y := rnd.Float64()*100, rnd.Float64()
+		items = append(items, InsertItem{BBox: BBox{x, y, x, y}, DataIndex: i})
+	}
+	leafMargin := func(rt RTree) float64 {
+		var total float64
+		for i, n := range rt.Nodes {
+			if n.IsLeaf {
+				bb := rt.calculateBound(i)
+				total += bb.MaxX - bb.MinX + bb.MaxY - bb.MinY
+			}
+		}
+		return total
+	}
+	policy := mustPolicy(t, 4, 16)
+	standard := BulkLoadSTR(items, policy, STROptions{})
+	square := BulkLoadSTR(items, policy, STROptions{Aspect: 1})
+	checkInvariants(t, square)
+	if got, other := leafMargin(square), leafMargin(standard); got >= other {
+		t.Errorf("expected square tiles to have a smaller total margin: %v vs %v", got, other)
+	}
+}
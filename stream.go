@@ -0,0 +1,352 @@
+package rtree
+
+import (
+	"bufio"
+	"container/heap"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	// streamRunSize is the maximum number of items that are held in memory
+	// at once while sorting the items of a streaming bulk load. Larger
+	// inputs are sorted in runs that are spilled to temporary files.
+	streamRunSize = 1 << 20
+
+	// streamFanOut is the maximum number of entries in each node built by a
+	// streaming bulk load, unless a policy is given.
+	streamFanOut = 16
+)
+
+// BulkLoadFrom bulk loads items from a stream into a new R-Tree. The next
+// function is called repeatedly to get each item, and should return false
+// once there are no more items.
+//
+// Unlike BulkLoad, the items don't need to be held in memory all at once.
+// Items are sorted along a space filling curve in bounded size runs that are
+// spilled to temporary files, and are then merged and packed into the tree
+// bottom up. An error is only returned if there is a problem with the
+// temporary files.
+func BulkLoadFrom(next func() (InsertItem, bool)) (RTree, error) {
+	return bulkLoadFrom(next, ExternalSortOptions{})
+}
+
+// ExternalSortOptions configures the external memory sort used when bulk
+// loading items that don't fit in memory.
+type ExternalSortOptions struct {
+	// RunSize is the maximum number of items held in memory at once while
+	// sorting. Items are sorted in runs of this size, which are spilled to
+	// temporary files. Defaults to 1,048,576 if zero.
+	RunSize int
+
+	// TempDir is the directory in which temporary files are created.
+	// Defaults to the system temporary directory if empty.
+	TempDir string
+
+	// Policy controls the number of entries in each node. Like
+	// BulkLoadSorted, the entries at each level are spread evenly between
+	// its nodes, so nodes hold up to the policy's maximum number of
+	// children and (other than the root) at least half of the maximum.
+	// Defaults to nodes of up to 16 children if zero. A policy that wasn't
+	// created by NewInsertionPolicy (such as one only given non-finite
+	// handling by WithNonFiniteHandling) gives an error wrapping
+	// ErrInvalidPolicy.
+	Policy InsertionPolicy
+}
+
+// BulkLoadExternal bulk loads items into a new R-Tree, in the same way as
+// BulkLoadFrom. Unlike BulkLoad, it doesn't make an in-memory copy of the
+// items to sort them. Instead, the items are sorted in runs that are spilled
+// to temporary files and then merged, with the tree built bottom up from the
+// merged stream.
+func BulkLoadExternal(inserts []InsertItem, opts ExternalSortOptions) (RTree, error) {
+	var i int
+	return bulkLoadFrom(func() (InsertItem, bool) {
+		if i == len(inserts) {
+			return InsertItem{}, false
+		}
+		i++
+		return inserts[i-1], true
+	}, opts)
+}
+
+func bulkLoadFrom(next func() (InsertItem, bool), opts ExternalSortOptions) (RTree, error) {
+	if opts.RunSize <= 0 {
+		opts.RunSize = streamRunSize
+	}
+	if opts.Policy == (InsertionPolicy{}) {
+		opts.Policy, _ = NewInsertionPolicy(streamFanOut/2, streamFanOut)
+	}
+	if err := opts.Policy.check(); err != nil {
+		return RTree{}, err
+	}
+	runs, err := sortedRuns(next, opts)
+	if err != nil {
+		return RTree{}, err
+	}
+	defer runs.close()
+
+	var tr RTree
+	if err := tr.packSorted(runs.merge(), runs.count, opts.Policy); err != nil {
+		return RTree{}, err
+	}
+	if len(tr.Nodes) > 0 {
+		tr.calculateAggregates(tr.RootIndex)
+	}
+	return tr, nil
+}
+
+// packSorted builds the tree bottom up from a stream of count sorted items.
+// The items are spread evenly between the leaves, which hold up to the
+// policy's maximum number of children.
+func (t *RTree) packSorted(next func() (InsertItem, bool, error), count int, policy InsertionPolicy) error {
+	leafMax := policy.forNode(true).maxChildren
+	leaves := (count + leafMax - 1) / leafMax
+	var level []Entry
+	for i := 0; i < leaves; i++ {
+		node := Node{IsLeaf: true}
+		for j := count * i / leaves; j < count*(i+1)/leaves; j++ {
+			item, ok, err := next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return io.ErrUnexpectedEOF
+			}
+			node.Entries = append(node.Entries, Entry{
+				BBox:    item.BBox,
+				Index:   item.DataIndex,
+				Payload: item.Payload,
+			})
+		}
+		t.Nodes = append(t.Nodes, node)
+		n := len(t.Nodes) - 1
+		level = append(level, Entry{BBox: t.calculateBound(n), Index: n})
+	}
+	t.packLevels(level, policy)
+	return nil
+}
+
+// packLevels builds the non-leaf levels of the tree above the given entries,
+// which refer to nodes that have already been built. The entries at each
+// level are spread evenly between its nodes. If there are no entries, then
+// the tree is left empty.
+func (t *RTree) packLevels(level []Entry, policy InsertionPolicy) {
+	if len(level) == 0 {
+		return
+	}
+	fanOut := policy.forNode(false).maxChildren
+	for len(level) > 1 {
+		var parents []Entry
+		for _, group := range splitEvenly(level, (len(level)+fanOut-1)/fanOut) {
+			t.Nodes = append(t.Nodes, Node{Entries: append([]Entry(nil), group...)})
+			n := len(t.Nodes) - 1
+			parents = append(parents, Entry{BBox: t.calculateBound(n), Index: n})
+		}
+		level = parents
+	}
+	t.RootIndex = level[0].Index
+}
+
+// sortableFloatBits maps a float64 to a uint64 such that the ordering of the
+// uint64s matches the ordering of the float64s.
+func sortableFloatBits(f float64) uint64 {
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		return ^bits
+	}
+	return bits | 1<<63
+}
+
+// interleave spreads the bits of x and y, so that the bits of x are in the
+// odd positions and the bits of y are in the even positions.
+func interleave(x, y uint32) uint64 {
+	return spread(x)<<1 | spread(y)
+}
+
+func spread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+type keyedItem struct {
+	key  uint64
+	item InsertItem
+}
+
+// runSet is a set of sorted runs of items. The last run is kept in memory,
+// and the others are spilled to temporary files.
+type runSet struct {
+	tempDir string
+	files   []*os.File
+	memory  []keyedItem
+	count   int // total number of items in all runs
+}
+
+// sortedRuns reads all items from the stream, sorting them in runs of at
+// most opts.RunSize items.
+func sortedRuns(next func() (InsertItem, bool), opts ExternalSortOptions) (*runSet, error) {
+	rs := &runSet{tempDir: opts.TempDir}
+	var buf []keyedItem
+	for {
+		item, ok := next()
+		if !ok {
+			break
+		}
+		rs.count++
+		buf = append(buf, keyedItem{MortonOf(item.BBox, EmptyBBox), item})
+		if len(buf) == opts.RunSize {
+			sortKeyedItems(buf)
+			if err := rs.spill(buf); err != nil {
+				rs.close()
+				return nil, err
+			}
+			buf = buf[:0]
+		}
+	}
+	sortKeyedItems(buf)
+	rs.memory = buf
+	return rs, nil
+}
+
+func sortKeyedItems(items []keyedItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].key < items[j].key
+	})
+}
+
+func (rs *runSet) spill(items []keyedItem) error {
+	f, err := os.CreateTemp(rs.tempDir, "rtree-run-")
+	if err != nil {
+		return err
+	}
+	rs.files = append(rs.files, f)
+	w := bufio.NewWriter(f)
+	var rec [entryRecordSize]byte
+	for _, ki := range items {
+		encodeEntryRecord(rec[:], Entry{
+			BBox:    ki.item.BBox,
+			Index:   ki.item.DataIndex,
+			Payload: ki.item.Payload,
+		})
+		if _, err := w.Write(rec[:]); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+func (rs *runSet) close() {
+	for _, f := range rs.files {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	rs.files = nil
+}
+
+// merge gives a stream of all items from all runs, in sorted order.
+func (rs *runSet) merge() func() (InsertItem, bool, error) {
+	var h runHeap
+	var firstErr error
+	for _, f := range rs.files {
+		r := bufio.NewReader(f)
+		var rec [entryRecordSize]byte
+		h.sources = append(h.sources, func() (keyedItem, bool, error) {
+			if _, err := io.ReadFull(r, rec[:]); err != nil {
+				if err == io.EOF {
+					return keyedItem{}, false, nil
+				}
+				return keyedItem{}, false, err
+			}
+			e := decodeEntryRecord(rec[:])
+			item := InsertItem{BBox: e.BBox, DataIndex: e.Index, Payload: e.Payload}
+			return keyedItem{MortonOf(item.BBox, EmptyBBox), item}, true, nil
+		})
+	}
+	memory := rs.memory
+	h.sources = append(h.sources, func() (keyedItem, bool, error) {
+		if len(memory) == 0 {
+			return keyedItem{}, false, nil
+		}
+		ki := memory[0]
+		memory = memory[1:]
+		return ki, true, nil
+	})
+	for i, src := range h.sources {
+		ki, ok, err := src()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		if ok {
+			h.heads = append(h.heads, runHead{ki, i})
+		}
+	}
+	heap.Init(&h)
+
+	return func() (InsertItem, bool, error) {
+		if firstErr != nil {
+			return InsertItem{}, false, firstErr
+		}
+		if h.Len() == 0 {
+			return InsertItem{}, false, nil
+		}
+		top := h.heads[0]
+		if err := h.advance(); err != nil {
+			return InsertItem{}, false, err
+		}
+		return top.item.item, true, nil
+	}
+}
+
+// runHeap is a min-heap of the heads of each run, used for a k-way merge.
+type runHeap struct {
+	sources []func() (keyedItem, bool, error)
+	heads   []runHead
+}
+
+type runHead struct {
+	item   keyedItem
+	source int
+}
+
+func (h *runHeap) Len() int { return len(h.heads) }
+func (h *runHeap) Less(i, j int) bool {
+	if h.heads[i].item.key != h.heads[j].item.key {
+		return h.heads[i].item.key < h.heads[j].item.key
+	}
+	return h.heads[i].source < h.heads[j].source
+}
+func (h *runHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *runHeap) Push(x interface{}) { h.heads = append(h.heads, x.(runHead)) }
+func (h *runHeap) Pop() interface{} {
+	last := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
+
+// advance replaces the head at the top of the heap with the next item from
+// the same source.
+func (h *runHeap) advance() error {
+	ki, ok, err := h.sources[h.heads[0].source]()
+	if err != nil {
+		return err
+	}
+	if ok {
+		h.heads[0].item = ki
+		heap.Fix(h, 0)
+	} else {
+		heap.Pop(h)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package rtree
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"testing"
+)
+
+func TestBulkLoadExternal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rtree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, population := range []int{0, 1, 15, 16, 17, 300, 1000} {
+		for _, runSize := range []int{7, 100, 0} {
+			for _, maxChildren := range []int{0, 5} {
+				name := fmt.Sprintf("pop_%d_run_%d_max_%d", population, runSize, maxChildren)
+				t.Run(name, func(t *testing.T) {
+					rnd := rand.New(rand.NewSource(0))
+					boxes := make([]BBox, population)
+					for i := range boxes {
+						boxes[i] = randomBox(rnd, 0.9, 0.1)
+					}
+					items := make([]InsertItem, len(boxes))
+					for i, bb := range boxes {
+						items[i] = InsertItem{BBox: bb, DataIndex: i}
+					}
+					opts := ExternalSortOptions{RunSize: runSize, TempDir: dir}
+					fanOut := streamFanOut
+					if maxChildren != 0 {
+						opts.Policy = mustPolicy(t, 2, maxChildren)
+						fanOut = maxChildren
+					}
+					rt, err := BulkLoadExternal(items, opts)
+					if err != nil {
+						t.Fatal(err)
+					}
+					checkInvariants(t, rt)
+					checkSearch(t, rt, boxes, rnd)
+					for i, n := range rt.Nodes {
+						if len(n.Entries) > fanOut || (i != rt.RootIndex && len(n.Entries) < fanOut/2) {
+							t.Fatalf("node %d has %d entries, want between %d and %d", i, len(n.Entries), fanOut/2, fanOut)
+						}
+					}
+
+					if files, err := ioutil.ReadDir(dir); err != nil || len(files) != 0 {
+						t.Errorf("expected temporary files to be removed: %v %v", files, err)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestSortableFloatBits(t *testing.T) {
+	fs := []float64{math.Inf(-1), -1e300, -2, -1, -0.5, 0, 0.5, 1, 2, 1e300, math.Inf(1)}
+	keys := make([]uint64, len(fs))
+	for i, f := range fs {
+		keys[i] = sortableFloatBits(f)
+	}
+	if !sort.SliceIsSorted(keys, func(i, j int) bool { return keys[i] < keys[j] }) {
+		t.Errorf("keys not sorted: %v", keys)
+	}
+}
+
+func TestBulkLoadFrom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	boxes := make([]BBox, 100)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+	}
+	var i int
+	rt, err := BulkLoadFrom(func() (InsertItem, bool) {
+		if i == len(boxes) {
+			return InsertItem{}, false
+		}
+		i++
+		return InsertItem{BBox: boxes[i-1], DataIndex: i - 1}, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, rt)
+	checkSearch(t, rt, boxes, rnd)
+}
+
+func TestBulkLoadExternalInvalidPolicy(t *testing.T) {
+	items := []InsertItem{{BBox: BBox{0, 0, 1, 1}, DataIndex: 0}}
+	opts := ExternalSortOptions{Policy: InsertionPolicy{}.WithNonFiniteHandling(NonFiniteReject)}
+	if _, err := BulkLoadExternal(items, opts); !errors.Is(err, ErrInvalidPolicy) {
+		t.Errorf("expected ErrInvalidPolicy, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package rtree
+
+// InsertWithTags adds a new data item to the RTree, tagged with a bitmask of
+// categories that it belongs to. The item can be found using SearchTagged. It
+// panics if the insertion policy rejects the bounding box.
+//
+// Storing several categories of items (e.g. the layers of a map) in a single
+// tree using tags is usually more efficient than using a separate tree for
+// each category.
+//
+// Tags are stored by data index (rather than in each Entry), so that trees
+// that don't use them don't pay for them. They're dropped when the item is
+// deleted. Like EnableLookup, this relies on data indices being unique.
+func (t *RTree) InsertWithTags(bb BBox, dataIndex int, tags uint64, policy InsertionPolicy) {
+	old := t.tags[dataIndex]
+	t.setTags(dataIndex, tags)
+	if err := t.insertEntry(Entry{BBox: bb, Index: dataIndex}, policy); err != nil {
+		t.setTags(dataIndex, old)
+		panic(err)
+	}
+}
+
+// TagsOf gives the tags of the item with the given data index, as given to
+// InsertWithTags. It's zero if the item was inserted without tags.
+func (t *RTree) TagsOf(dataIndex int) uint64 {
+	return t.tags[dataIndex]
+}
+
+// SearchTagged is like Search, but only finds items that have at least one
+// of the tags in the tag mask. Nodes that don't contain any items with those
+// tags are skipped entirely.
+func (t *RTree) SearchTagged(bb BBox, tagMask uint64, callback func(index int)) {
+	if tagMask == 0 {
+		return
+	}
+	t.search(bb, tagMask, BoundaryClosed, func(e Entry) { callback(e.Index) })
+}
+
+// setTags records the tags of the item with the given data index. The map
+// holding them is only allocated once an item has non-zero tags.
+func (t *RTree) setTags(dataIndex int, tags uint64) {
+	if tags == 0 {
+		delete(t.tags, dataIndex)
+		return
+	}
+	if t.tags == nil {
+		t.tags = make(map[int]uint64)
+	}
+	t.tags[dataIndex] = tags
+}
+
+// entryTags gives the tags of the item for a leaf entry, or the union of
+// the tags under the node for a non-leaf entry.
+func (t *RTree) entryTags(e Entry, isLeaf bool) uint64 {
+	if isLeaf {
+		return t.tags[e.Index]
+	}
+	return t.Nodes[e.Index].Tags
+}
+
+// calculateTags gives the union of the tags of the entries in node n.
+func (t *RTree) calculateTags(n int) uint64 {
+	node := &t.Nodes[n]
+	if node.IsLeaf && len(t.tags) == 0 {
+		return 0
+	}
+	var tags uint64
+	for _, e := range node.Entries {
+		tags |= t.entryTags(e, node.IsLeaf)
+	}
+	return tags
+}
@@ -0,0 +1,106 @@
+package rtree
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSearchTagged(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	var rt RTree
+	boxes := make([]BBox, 300)
+	tags := make([]uint64, len(boxes))
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		tags[i] = 1 << uint(rnd.Intn(4))
+		rt.InsertWithTags(boxes[i], i, tags[i], ins)
+	}
+	checkInvariants(t, rt)
+
+	// Deleting and adjusting items must keep the tags of parent entries up
+	// to date.
+	for i := 0; i < len(boxes); i += 7 {
+		rt.DeleteFunc(boxes[i], func(idx int) bool { return idx == i })
+		boxes[i] = BBox{-2, -2, -1, -1}
+	}
+	for i := 1; i < len(boxes); i += 7 {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Adjust(i, boxes[i], ins)
+	}
+	checkInvariants(t, rt)
+	for i := 0; i < len(boxes); i++ {
+		want := tags[i]
+		if i%7 == 0 {
+			want = 0
+		}
+		if got := rt.TagsOf(i); got != want {
+			t.Fatalf("item %d: got tags %#x, want %#x", i, got, want)
+		}
+	}
+
+	for _, mask := range []uint64{0, 1, 2, 5, 15} {
+		for i := 0; i < 10; i++ {
+			query := randomBox(rnd, 0.5, 0.5)
+			var got, want []int
+			rt.SearchTagged(query, mask, func(idx int) { got = append(got, idx) })
+			for j, bb := range boxes {
+				if overlap(bb, query) && tags[j]&mask != 0 {
+					want = append(want, j)
+				}
+			}
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("mask=%d: got %v want %v", mask, got, want)
+			}
+		}
+	}
+}
+
+func TestTagsFollowItems(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	rt.EnableJournal()
+	for i := 0; i < 40; i++ {
+		rt.InsertWithTags(BBox{float64(i), 0, float64(i) + 1, 1}, i, 1<<uint(i%3), ins)
+	}
+	check := func(when string, idx int, tags uint64) {
+		t.Helper()
+		checkInvariants(t, rt)
+		if got := rt.TagsOf(idx); got != tags {
+			t.Errorf("%s: item %d has tags %#x, want %#x", when, idx, got, tags)
+		}
+	}
+	check("inserted", 4, 1<<1)
+
+	// Moving an item far enough to be reinserted keeps its tags.
+	rt.Move(4, BBox{100, 100, 101, 101}, ins)
+	check("moved", 4, 1<<1)
+
+	// Deleting an item drops them, and undoing the deletion restores them.
+	rt.DeleteByIndex(4)
+	check("deleted", 4, 0)
+	rt.Undo(1, ins)
+	check("undone", 4, 1<<1)
+
+	repacked := rt.Repack(ins)
+	checkInvariants(t, repacked)
+	for i := 0; i < 40; i++ {
+		if rt.TagsOf(i) != repacked.TagsOf(i) {
+			t.Errorf("item %d has different tags after repacking", i)
+		}
+	}
+
+	rt.Clear()
+	if rt.tags != nil {
+		t.Errorf("expected tags to be discarded by Clear")
+	}
+}
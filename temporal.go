@@ -0,0 +1,210 @@
+package rtree
+
+import "math"
+
+// TemporalRTree is an R-Tree for spatio-temporal data. Each item has a
+// bounding box as well as a time interval, and searches find items that
+// overlap with both a bounding box and a time interval. Time is treated as a
+// third dimension when deciding where to insert items and how to split
+// nodes, so items that are close in space but far apart in time are kept
+// apart.
+//
+// The zero value is an empty tree.
+type TemporalRTree struct {
+	nodes []temporalNode
+	root  int
+}
+
+type temporalNode struct {
+	isLeaf  bool
+	entries []temporalEntry
+}
+
+type temporalEntry struct {
+	box   spaceTime
+	index int // data index for leaf entries, node index otherwise
+}
+
+// spaceTime is a bounding box extended with a time interval.
+type spaceTime struct {
+	BBox
+	MinT, MaxT float64
+}
+
+func combineSpaceTime(a, b spaceTime) spaceTime {
+	return spaceTime{
+		BBox: combine(a.BBox, b.BBox),
+		MinT: math.Min(a.MinT, b.MinT),
+		MaxT: math.Max(a.MaxT, b.MaxT),
+	}
+}
+
+// measure gives the volume and margin (sum of extents) of the box. The
+// margin is used to break ties, since the volume is zero for all boxes when
+// any of the dimensions is degenerate (e.g. instantaneous events).
+func (s spaceTime) measure() (volume, margin float64) {
+	dx, dy, dt := s.MaxX-s.MinX, s.MaxY-s.MinY, s.MaxT-s.MinT
+	return dx * dy * dt, dx + dy + dt
+}
+
+// growth gives how much the volume and margin of a would increase to
+// accommodate b.
+func (s spaceTime) growth(b spaceTime) (float64, float64) {
+	v1, m1 := combineSpaceTime(s, b).measure()
+	v0, m0 := s.measure()
+	return v1 - v0, m1 - m0
+}
+
+// lessMeasure compares volume/margin pairs, using the volume first and the
+// margin as a tie breaker.
+func lessMeasure(v1, m1, v2, m2 float64) bool {
+	return v1 < v2 || (v1 == v2 && m1 < m2)
+}
+
+// Len gives the number of items in the tree.
+func (t *TemporalRTree) Len() int {
+	var n int
+	for _, node := range t.nodes {
+		if node.isLeaf {
+			n += len(node.entries)
+		}
+	}
+	return n
+}
+
+// Insert adds a new data item to the tree, with the given bounding box and
+// time interval [minT, maxT]. It panics if the insertion policy is the zero
+// value.
+func (t *TemporalRTree) Insert(bb BBox, minT, maxT float64, dataIndex int, policy InsertionPolicy) {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	entry := temporalEntry{spaceTime{bb, minT, maxT}, dataIndex}
+	if len(t.nodes) == 0 {
+		t.nodes = append(t.nodes, temporalNode{isLeaf: true})
+		t.root = 0
+	}
+	if sibling := t.insert(t.root, entry, policy); sibling != -1 {
+		old := t.root
+		t.nodes = append(t.nodes, temporalNode{entries: []temporalEntry{
+			{t.bound(old), old},
+			{t.bound(sibling), sibling},
+		}})
+		t.root = len(t.nodes) - 1
+	}
+}
+
+// insert adds the entry to the subtree rooted at n. If n had to be split,
+// then the index of the new sibling node is returned, otherwise -1.
+func (t *TemporalRTree) insert(n int, entry temporalEntry, policy InsertionPolicy) int {
+	if t.nodes[n].isLeaf {
+		t.nodes[n].entries = append(t.nodes[n].entries, entry)
+	} else {
+		best := 0
+		entries := t.nodes[n].entries
+		bestV, bestM := entries[0].box.growth(entry.box)
+		for i, e := range entries[1:] {
+			v, m := e.box.growth(entry.box)
+			if lessMeasure(v, m, bestV, bestM) {
+				best, bestV, bestM = i+1, v, m
+			}
+		}
+		child := entries[best].index
+		sibling := t.insert(child, entry, policy)
+		t.nodes[n].entries[best].box = t.bound(child)
+		if sibling != -1 {
+			t.nodes[n].entries = append(t.nodes[n].entries, temporalEntry{t.bound(sibling), sibling})
+		}
+	}
+
+	if len(t.nodes[n].entries) <= policy.maxChildren {
+		return -1
+	}
+	a, b := splitTemporal(t.nodes[n].entries, policy)
+	t.nodes[n].entries = a
+	t.nodes = append(t.nodes, temporalNode{isLeaf: t.nodes[n].isLeaf, entries: b})
+	return len(t.nodes) - 1
+}
+
+func (t *TemporalRTree) bound(n int) spaceTime {
+	entries := t.nodes[n].entries
+	box := entries[0].box
+	for _, e := range entries[1:] {
+		box = combineSpaceTime(box, e.box)
+	}
+	return box
+}
+
+// splitTemporal partitions entries into two groups using Guttman's quadratic
+// algorithm, generalised to three dimensions.
+func splitTemporal(entries []temporalEntry, policy InsertionPolicy) ([]temporalEntry, []temporalEntry) {
+	seedA, seedB := 0, 1
+	worstV, worstM := math.Inf(-1), math.Inf(-1)
+	for i := range entries {
+		for j := i + 1; j < len(entries); j++ {
+			v, m := combineSpaceTime(entries[i].box, entries[j].box).measure()
+			vi, mi := entries[i].box.measure()
+			vj, mj := entries[j].box.measure()
+			v, m = v-vi-vj, m-mi-mj
+			if lessMeasure(worstV, worstM, v, m) {
+				worstV, worstM = v, m
+				seedA, seedB = i, j
+			}
+		}
+	}
+
+	groupA := []temporalEntry{entries[seedA]}
+	groupB := []temporalEntry{entries[seedB]}
+	boxA, boxB := entries[seedA].box, entries[seedB].box
+	var remaining []temporalEntry
+	for i, e := range entries {
+		if i != seedA && i != seedB {
+			remaining = append(remaining, e)
+		}
+	}
+	for i, e := range remaining {
+		left := len(remaining) - i
+		if len(groupA)+left <= policy.minChildren {
+			groupA = append(groupA, remaining[i:]...)
+			break
+		}
+		if len(groupB)+left <= policy.minChildren {
+			groupB = append(groupB, remaining[i:]...)
+			break
+		}
+		va, ma := boxA.growth(e.box)
+		vb, mb := boxB.growth(e.box)
+		if lessMeasure(va, ma, vb, mb) || (va == vb && ma == mb && len(groupA) <= len(groupB)) {
+			groupA = append(groupA, e)
+			boxA = combineSpaceTime(boxA, e.box)
+		} else {
+			groupB = append(groupB, e)
+			boxB = combineSpaceTime(boxB, e.box)
+		}
+	}
+	return groupA, groupB
+}
+
+// Search looks for any items in the tree that overlap with the given
+// bounding box and whose time intervals overlap with the interval [minT,
+// maxT]. The callback is called with the item index for each found item.
+func (t *TemporalRTree) Search(bb BBox, minT, maxT float64, callback func(index int)) {
+	if len(t.nodes) == 0 {
+		return
+	}
+	var recurse func(int)
+	recurse = func(n int) {
+		node := &t.nodes[n]
+		for _, e := range node.entries {
+			if !overlap(e.box.BBox, bb) || e.box.MinT > maxT || e.box.MaxT < minT {
+				continue
+			}
+			if node.isLeaf {
+				callback(e.index)
+			} else {
+				recurse(e.index)
+			}
+		}
+	}
+	recurse(t.root)
+}
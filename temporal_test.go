@@ -0,0 +1,59 @@
+package rtree
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestTemporalRTree(t *testing.T) {
+	for _, maxCapacity := range []int{2, 5, 20} {
+		ins, err := NewInsertionPolicy(maxCapacity/2, maxCapacity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rnd := rand.New(rand.NewSource(0))
+		type item struct {
+			bb         BBox
+			minT, maxT float64
+		}
+		items := make([]item, 300)
+		var tr TemporalRTree
+		for i := range items {
+			minT := float64(rnd.Intn(100))
+			items[i] = item{randomBox(rnd, 0.9, 0.1), minT, minT + float64(rnd.Intn(3))}
+			tr.Insert(items[i].bb, items[i].minT, items[i].maxT, i, ins)
+		}
+		if tr.Len() != len(items) {
+			t.Fatalf("expected %d items, got %d", len(items), tr.Len())
+		}
+		for i := 0; i < 20; i++ {
+			bb := randomBox(rnd, 0.5, 0.5)
+			t0 := float64(rnd.Intn(100))
+			t1 := t0 + float64(rnd.Intn(10))
+			var got, want []int
+			tr.Search(bb, t0, t1, func(idx int) { got = append(got, idx) })
+			for j, it := range items {
+				if overlap(it.bb, bb) && it.minT <= t1 && it.maxT >= t0 {
+					want = append(want, j)
+				}
+			}
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v want %v", got, want)
+			}
+		}
+	}
+}
+
+func TestTemporalRTreeZeroPolicy(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("expected panic with ErrInvalidPolicy, got %v", err)
+		}
+	}()
+	var rt TemporalRTree
+	rt.Insert(BBox{0, 0, 1, 1}, 0, 1, 0, InsertionPolicy{})
+}
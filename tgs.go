@@ -0,0 +1,90 @@
+package rtree
+
+import "sort"
+
+// BulkLoadTGS bulk loads items into a new R-Tree using the Top-down Greedy
+// Split algorithm of García, López and Leutenegger. Like BulkLoadWithPolicy,
+// the tree is built top down with nodes holding up to the policy's maximum
+// number of children (and other than the root, filled to at least half of
+// the maximum). Rather than always dividing the items along the longest
+// axis, each node's items are divided by a sequence of binary splits, each
+// chosen to minimise the total area of the two sides out of every split
+// position and every ordering of the items by the minimum, maximum or centre
+// of their bounding boxes along each axis.
+//
+// Building the tree is slower than the other bulk loaders, but the smaller
+// nodes often make window queries faster (particularly for items that
+// aren't evenly sized or spread). Like BulkLoad, the resulting tree is
+// deterministic. It panics if the policy is the zero value.
+func BulkLoadTGS(inserts []InsertItem, policy InsertionPolicy) RTree {
+	return bulkLoadPacked(inserts, policy, tgsPartition)
+}
+
+// tgsKeys give the orderings of items considered by each split in
+// tgsPartition.
+var tgsKeys = [...]func(BBox) float64{
+	func(bb BBox) float64 { return bb.MinX },
+	func(bb BBox) float64 { return bb.MaxX },
+	func(bb BBox) float64 { return midpoint(bb.MinX, bb.MaxX) },
+	func(bb BBox) float64 { return bb.MinY },
+	func(bb BBox) float64 { return bb.MaxY },
+	func(bb BBox) float64 { return midpoint(bb.MinY, bb.MaxY) },
+}
+
+// tgsPartition splits the items into the given number of groups of (almost)
+// equal size, by repeatedly making the binary split that minimises the total
+// area of the bounding boxes of the two sides (with ties broken by their
+// total perimeter).
+func tgsPartition(items []InsertItem, groups int) [][]InsertItem {
+	if groups == 1 {
+		return [][]InsertItem{items}
+	}
+	bestKey, bestLeft := -1, 0
+	var bestCost [2]float64
+	suffix := make([]BBox, len(items))
+	for k, key := range tgsKeys {
+		tgsSort(items, key)
+		suffix[len(items)-1] = items[len(items)-1].BBox
+		for i := len(items) - 2; i >= 0; i-- {
+			suffix[i] = combine(items[i].BBox, suffix[i+1])
+		}
+		prefix := items[0].BBox
+		next := 0
+		for left := 1; left < groups; left++ {
+			split := len(items) * left / groups
+			for ; next < split; next++ {
+				prefix = combine(prefix, items[next].BBox)
+			}
+			cost := [2]float64{
+				area(prefix) + area(suffix[split]),
+				tgsPerimeter(prefix) + tgsPerimeter(suffix[split]),
+			}
+			if bestKey == -1 || cost[0] < bestCost[0] || (cost[0] == bestCost[0] && cost[1] < bestCost[1]) {
+				bestKey, bestLeft, bestCost = k, left, cost
+			}
+		}
+	}
+
+	tgsSort(items, tgsKeys[bestKey])
+	split := len(items) * bestLeft / groups
+	return append(
+		tgsPartition(items[:split], bestLeft),
+		tgsPartition(items[split:], groups-bestLeft)...,
+	)
+}
+
+// tgsSort sorts the items by a key, with ties broken in the same way as
+// bulkLess so that the order doesn't depend on the input order.
+func tgsSort(items []InsertItem, key func(BBox) float64) {
+	sort.Slice(items, func(i, j int) bool {
+		ki, kj := key(items[i].BBox), key(items[j].BBox)
+		if ki != kj {
+			return ki < kj
+		}
+		return bulkLess(items[i], items[j], true)
+	})
+}
+
+func tgsPerimeter(bb BBox) float64 {
+	return 2 * ((bb.MaxX - bb.MinX) + (bb.MaxY - bb.MinY))
+}
@@ -0,0 +1,78 @@
+package rtree
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestBulkLoadTGS(t *testing.T) {
+	for _, population := range []int{0, 1, 2, 5, 16, 17, 100, 300} {
+		for _, maxChildren := range []int{2, 3, 8} {
+			name := fmt.Sprintf("max_%d_pop_%d", maxChildren, population)
+			t.Run(name, func(t *testing.T) {
+				rnd := rand.New(rand.NewSource(0))
+				boxes := make([]BBox, population)
+				inserts := make([]InsertItem, population)
+				for i := range boxes {
+					boxes[i] = randomBox(rnd, 0.9, 0.1)
+					inserts[i] = InsertItem{BBox: boxes[i], DataIndex: i}
+				}
+				policy := mustPolicy(t, 1, maxChildren)
+				rt := BulkLoadTGS(inserts, policy)
+				checkInvariants(t, rt)
+				checkSearch(t, rt, boxes, rnd)
+				for i, n := range rt.Nodes {
+					if len(n.Entries) > maxChildren {
+						t.Fatalf("node %d has %d entries", i, len(n.Entries))
+					}
+					if i != rt.RootIndex && 2*len(n.Entries) < maxChildren {
+						t.Fatalf("node %d has only %d entries", i, len(n.Entries))
+					}
+				}
+				for i, item := range inserts {
+					if item.DataIndex != i {
+						t.Fatalf("input items were modified")
+					}
+				}
+
+				// The tree shouldn't depend on the order of the items.
+				rnd.Shuffle(len(inserts), func(i, j int) {
+					inserts[i], inserts[j] = inserts[j], inserts[i]
+				})
+				again := BulkLoadTGS(inserts, policy)
+				if !reflect.DeepEqual(rt.Nodes, again.Nodes) || rt.RootIndex != again.RootIndex {
+					t.Error("expected tree to be deterministic")
+				}
+			})
+		}
+	}
+}
+
+func TestBulkLoadTGSCoverage(t *testing.T) {
+	// Long thin boxes of varying lengths, for which dividing along the
+	// longest axis by centre gives poor nodes.
+	rnd := rand.New(rand.NewSource(0))
+	var boxes []BBox
+	var items []InsertItem
+	for i := 0; i < 1000; i++ {
+		y := rnd.Float64()
+		bb := BBox{MinX: 0.5 - rnd.Float64()/2, MinY: y, MaxX: 0.5 + rnd.Float64()/2, MaxY: y + 0.001}
+		boxes = append(boxes, bb)
+		items = append(items, InsertItem{BBox: bb, DataIndex: i})
+	}
+	policy := mustPolicy(t, 4, 8)
+	tgs := BulkLoadTGS(items, policy)
+	checkInvariants(t, tgs)
+	checkSearch(t, tgs, boxes, rnd)
+
+	leafCoverage := func(rt RTree) float64 {
+		q := rt.Quality()
+		return q.Levels[len(q.Levels)-1].Coverage
+	}
+	packed := BulkLoadWithPolicy(items, policy)
+	if got, other := leafCoverage(tgs), leafCoverage(packed); got >= other {
+		t.Errorf("expected TGS leaves to cover less area than BulkLoadWithPolicy: %v vs %v", got, other)
+	}
+}
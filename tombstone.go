@@ -0,0 +1,83 @@
+package rtree
+
+import (
+	"maps"
+	"slices"
+)
+
+// MarkDeleted lazily deletes the item with the given data index, by recording
+// a tombstone for it rather than removing it from the tree. This takes
+// constant time, regardless of the size of the tree. Tombstoned items are
+// skipped by Search (and its variants, including SearchMulti and
+// BatchSearch), Difference, SumWithin, MinWithin, MaxWithin, the nearest
+// neighbour queries, BBoxOf and Has, and can't be changed by Move or Adjust.
+// They are physically removed by Vacuum.
+//
+// Other operations (such as Walk) still see tombstoned items until Vacuum is
+// called, but they're left out when the tree is packed, frozen, repacked or
+// written out. The aggregate queries can no longer use the precomputed
+// aggregates of nodes, so are slower. The data index of a tombstoned item
+// must not be reused by an insertion until then.
+func (t *RTree) MarkDeleted(dataIndex int) {
+	if t.tombstones == nil {
+		t.tombstones = make(map[int]bool)
+	}
+	t.generation++
+	if t.versions != nil && !t.tombstones[dataIndex] {
+		if bb, ok := t.lookup[dataIndex]; ok {
+			t.recordChange(dataIndex, bb, true)
+		}
+	}
+	t.tombstones[dataIndex] = true
+}
+
+// Tombstones gives the number of data indices that have been marked as
+// deleted by MarkDeleted, but not yet removed by Vacuum.
+func (t *RTree) Tombstones() int {
+	return len(t.tombstones)
+}
+
+// Vacuum removes all items marked as deleted by MarkDeleted, and tightens the
+// bounding boxes of their ancestors. It gives the number of items removed.
+// Vacuum is much cheaper than deleting each item individually, since the
+// tree is condensed in a single pass.
+func (t *RTree) Vacuum() int {
+	if len(t.tombstones) == 0 {
+		return 0
+	}
+	defer t.suspendJournal()()
+	deleted := t.deleteEntries(everywhere, func(e Entry) bool {
+		return t.tombstones[e.Index]
+	}, DeletionPolicy{})
+	t.tombstones = nil
+	return deleted
+}
+
+// isTombstoned checks if the item with the given data index has been marked
+// as deleted by MarkDeleted.
+func (t *RTree) isTombstoned(dataIndex int) bool {
+	return len(t.tombstones) > 0 && t.tombstones[dataIndex]
+}
+
+// withoutTombstones gives the tree with the items marked as deleted by
+// MarkDeleted removed, for operations that copy the tree's items elsewhere.
+// If there aren't any, then the tree itself is given. Otherwise, a vacuumed
+// copy is made, leaving the tree unchanged.
+func (t *RTree) withoutTombstones() *RTree {
+	if len(t.tombstones) == 0 {
+		return t
+	}
+	c := &RTree{
+		RootIndex:  t.RootIndex,
+		Nodes:      make([]Node, len(t.Nodes)),
+		tombstones: t.tombstones,
+		tags:       maps.Clone(t.tags),
+		weights:    maps.Clone(t.weights),
+	}
+	for i, node := range t.Nodes {
+		node.Entries = slices.Clone(node.Entries)
+		c.Nodes[i] = node
+	}
+	c.Vacuum()
+	return c
+}
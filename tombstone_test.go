@@ -0,0 +1,222 @@
+package rtree
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestMarkDeletedAndVacuum(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	boxes := make([]BBox, 100)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Insert(boxes[i], i, ins)
+	}
+	nodes := len(rt.Nodes)
+
+	for i := range boxes {
+		if i%3 == 0 {
+			continue
+		}
+		gen := rt.Generation()
+		rt.MarkDeleted(i)
+		if rt.Generation() == gen {
+			t.Fatal("expected generation to change")
+		}
+		if rt.Has(i) {
+			t.Fatalf("expected item %d to be hidden", i)
+		}
+		boxes[i] = BBox{-5, -5, -4, -4}
+	}
+	if got, want := rt.Tombstones(), 66; got != want {
+		t.Fatalf("got %d tombstones, want %d", got, want)
+	}
+	if len(rt.Nodes) != nodes {
+		t.Fatal("expected nodes to be untouched before vacuum")
+	}
+	checkSearch(t, rt, boxes, rnd)
+	for _, idx := range rt.Nearest(0.5, 0.5, 100) {
+		if idx%3 != 0 {
+			t.Fatalf("nearest found tombstoned item %d", idx)
+		}
+	}
+	if idx, ok := rt.Farthest(0.5, 0.5); !ok || idx%3 != 0 {
+		t.Fatalf("farthest found %d, %t", idx, ok)
+	}
+
+	if got, want := rt.Vacuum(), 66; got != want {
+		t.Fatalf("vacuum removed %d items, want %d", got, want)
+	}
+	if rt.Tombstones() != 0 {
+		t.Fatal("expected no tombstones after vacuum")
+	}
+	if len(rt.Nodes) >= nodes {
+		t.Fatal("expected vacuum to remove nodes")
+	}
+	checkInvariants(t, rt)
+	checkSearch(t, rt, boxes, rnd)
+	if rt.Vacuum() != 0 {
+		t.Fatal("expected nothing to vacuum")
+	}
+
+	// Indices can be reused once vacuumed.
+	rt.Insert(BBox{0.5, 0.5, 0.5, 0.5}, 1, ins)
+	if !rt.Has(1) {
+		t.Fatal("expected reinserted item to be found")
+	}
+}
+
+func TestTombstonesHiddenFromQueries(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, b RTree
+	boxesA := make([]BBox, 200)
+	boxesB := make([]BBox, 50)
+	for i := range boxesA {
+		boxesA[i] = randomBox(rnd, 0.9, 0.1)
+		a.InsertWithWeight(boxesA[i], i, float64(i), ins)
+	}
+	for i := range boxesB {
+		boxesB[i] = randomBox(rnd, 0.9, 0.1)
+		b.Insert(boxesB[i], i, ins)
+	}
+	dead := func(i int) bool { return i%2 == 1 }
+	for i := range boxesA {
+		if dead(i) {
+			a.MarkDeleted(i)
+		}
+	}
+	for i := range boxesB {
+		if dead(i) {
+			b.MarkDeleted(i)
+		}
+	}
+
+	queries := []BBox{everywhere, {0, 0, 0.5, 0.5}, {0.25, 0.25, 0.75, 0.75}}
+	a.SearchMulti(queries, func(_, idx int) {
+		if dead(idx) {
+			t.Fatalf("SearchMulti found tombstoned item %d", idx)
+		}
+	})
+	for _, found := range a.BatchSearch(queries) {
+		for _, idx := range found {
+			if dead(idx) {
+				t.Fatalf("BatchSearch found tombstoned item %d", idx)
+			}
+		}
+	}
+
+	for _, q := range queries {
+		var want Aggregate
+		for i, bb := range boxesA {
+			if dead(i) || !overlap(bb, q) {
+				continue
+			}
+			if want.Count == 0 {
+				want = weightAggregate(float64(i))
+			} else {
+				want = want.combine(weightAggregate(float64(i)))
+			}
+		}
+		if got := a.SumWithin(q); got != want.Sum {
+			t.Errorf("SumWithin(%v) = %v, want %v", q, got, want.Sum)
+		}
+		if got, _ := a.MinWithin(q); got != want.Min {
+			t.Errorf("MinWithin(%v) = %v, want %v", q, got, want.Min)
+		}
+		if got, _ := a.MaxWithin(q); got != want.Max {
+			t.Errorf("MaxWithin(%v) = %v, want %v", q, got, want.Max)
+		}
+	}
+
+	var got []int
+	Difference(&a, &b, func(idx int) { got = append(got, idx) })
+	sort.Ints(got)
+	var want []int
+outer:
+	for i, ba := range boxesA {
+		if dead(i) {
+			continue
+		}
+		for j, bb := range boxesB {
+			if !dead(j) && overlap(ba, bb) {
+				continue outer
+			}
+		}
+		want = append(want, i)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Difference: got %v, want %v", got, want)
+	}
+
+	if a.Adjust(1, BBox{0, 0, 0.1, 0.1}, ins) {
+		t.Error("expected Adjust to refuse a tombstoned item")
+	}
+	path, _ := a.findEntry(1)
+	if bb := a.Nodes[path[len(path)-1].node].Entries[path[len(path)-1].entry].BBox; bb != boxesA[1] {
+		t.Errorf("tombstoned item moved to %v", bb)
+	}
+	checkInvariants(t, a)
+}
+
+func TestTombstonesLeftOutOfCopies(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 4)
+	var rt RTree
+	for i := 0; i < 20; i++ {
+		rt.InsertWithTags(randomBox(rnd, 0.9, 0.1), i, 1, policy)
+	}
+	rt.MarkDeleted(3)
+	count := func(search func(BBox, func(int))) []int {
+		var got []int
+		search(everywhere, func(idx int) { got = append(got, idx) })
+		sort.Ints(got)
+		return got
+	}
+	want := count(rt.Search)
+	if len(want) != 19 {
+		t.Fatalf("expected 19 live items, got %d", len(want))
+	}
+
+	var buf bytes.Buffer
+	if _, err := rt.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadRTree(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkInvariants(t, read)
+	if err := rt.SaveRBush(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rbush, err := LoadRBush(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, search := range map[string]func(BBox, func(int)){
+		"pack":  rt.Pack().Search,
+		"read":  read.Search,
+		"rbush": rbush.Search,
+	} {
+		if got := count(search); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v want %v", name, got, want)
+		}
+	}
+
+	// The tree itself is left unchanged.
+	if rt.Tombstones() != 1 || rt.TagsOf(3) != 1 || rt.Nodes[rt.RootIndex].Aggregate.Count != 20 {
+		t.Errorf("tree modified by copying")
+	}
+}
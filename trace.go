@@ -0,0 +1,21 @@
+package rtree
+
+// Tracer receives events describing the decisions made while inserting into
+// an RTree. It's intended for diagnosing why a tree has a particular shape.
+type Tracer interface {
+	// ChoseLeaf is called once the leaf node that an item will be inserted
+	// into has been chosen.
+	ChoseLeaf(bb BBox, dataIndex int, leaf int)
+
+	// SplitNode is called after an overfull node has been split. The node
+	// keeps the entries in a, and the newly created newNode gets the
+	// entries in b.
+	SplitNode(node, newNode int, a, b []Entry)
+
+	// GrewRoot is called when the root node is split, and a new root is
+	// created with the two halves of the old root as its children. When
+	// Apply divides an overfull root between more than two nodes, the new
+	// root also gets the remaining nodes as children (each of which is
+	// first reported by SplitNode).
+	GrewRoot(oldRoot, sibling, newRoot int)
+}
@@ -0,0 +1,128 @@
+package rtree
+
+// Translate shifts every item in the tree by (dx, dy). The structure of the
+// tree is unchanged, so this is much cheaper than rebuilding it.
+//
+// The shift isn't recorded item by item, so the undo and redo steps of the
+// journal (see EnableJournal) and the history searched by SearchAsOf (see
+// EnableVersioning) are discarded.
+func (t *RTree) Translate(dx, dy float64) {
+	shift := func(bb BBox) BBox {
+		return BBox{bb.MinX + dx, bb.MinY + dy, bb.MaxX + dx, bb.MaxY + dy}
+	}
+	t.hookAllItems(shift)
+	for i := range t.Nodes {
+		entries := t.Nodes[i].Entries
+		for j := range entries {
+			entries[j].BBox = shift(entries[j].BBox)
+		}
+	}
+	for i := range t.quarantine {
+		t.quarantine[i].BBox = shift(t.quarantine[i].BBox)
+	}
+	for idx, bb := range t.lookup {
+		t.lookup[idx] = shift(bb)
+	}
+	t.generation++
+	t.resetHistory()
+}
+
+// Transform applies the affine transformation
+//
+//	x' = a*x + b*y + tx
+//	y' = c*x + d*y + ty
+//
+// to every item in the tree. If the transformation only scales and
+// translates (b and c are both zero), then each bounding box is rewritten in
+// place and the structure of the tree is unchanged. Otherwise, each item's
+// bounding box is replaced by the bounding box of its transformed corners,
+// and the bounding boxes of the nodes are recalculated. The nodes keep their
+// existing items, so node overlap may increase substantially (e.g. after a
+// rotation). TransformAndRepack can be used to rebuild the tree instead.
+//
+// Like Translate, Transform discards the journal's undo and redo steps and
+// the version history.
+func (t *RTree) Transform(a, b, c, d, tx, ty float64) {
+	var fn func(BBox) BBox
+	if b == 0 && c == 0 {
+		fn = func(bb BBox) BBox {
+			out := BBox{a*bb.MinX + tx, d*bb.MinY + ty, a*bb.MaxX + tx, d*bb.MaxY + ty}
+			if a < 0 {
+				out.MinX, out.MaxX = out.MaxX, out.MinX
+			}
+			if d < 0 {
+				out.MinY, out.MaxY = out.MaxY, out.MinY
+			}
+			return out
+		}
+		t.hookAllItems(fn)
+		for i := range t.Nodes {
+			entries := t.Nodes[i].Entries
+			for j := range entries {
+				entries[j].BBox = fn(entries[j].BBox)
+			}
+		}
+	} else {
+		fn = func(bb BBox) BBox {
+			out := EmptyBBox
+			for _, x := range [2]float64{bb.MinX, bb.MaxX} {
+				for _, y := range [2]float64{bb.MinY, bb.MaxY} {
+					px, py := a*x+b*y+tx, c*x+d*y+ty
+					out = out.Union(BBox{px, py, px, py})
+				}
+			}
+			return out
+		}
+		t.hookAllItems(fn)
+		for i := range t.Nodes {
+			if !t.Nodes[i].IsLeaf {
+				continue
+			}
+			entries := t.Nodes[i].Entries
+			for j := range entries {
+				entries[j].BBox = fn(entries[j].BBox)
+			}
+		}
+		if len(t.Nodes) > 0 {
+			t.refreshSubtree(t.RootIndex)
+		}
+	}
+	for i := range t.quarantine {
+		t.quarantine[i].BBox = fn(t.quarantine[i].BBox)
+	}
+	for idx, bb := range t.lookup {
+		t.lookup[idx] = fn(bb)
+	}
+	t.generation++
+	t.resetHistory()
+}
+
+// TransformAndRepack is like Transform, but rebuilds the tree afterwards
+// (packing nodes according to the insertion policy) if the transformation
+// does more than scale and translate. This gives a tree with much less node
+// overlap after rotations and shears. It panics (without transforming the
+// tree) if the policy is the zero value.
+func (t *RTree) TransformAndRepack(a, b, c, d, tx, ty float64, policy InsertionPolicy) {
+	if err := policy.check(); err != nil {
+		panic(err)
+	}
+	t.Transform(a, b, c, d, tx, ty)
+	if b != 0 || c != 0 {
+		t.repack(policy)
+	}
+}
+
+// refreshSubtree recalculates the bounding boxes of the entries leading to
+// nodes under node n, along with the aggregates and tags of n and the nodes
+// under it.
+func (t *RTree) refreshSubtree(n int) {
+	node := &t.Nodes[n]
+	if !node.IsLeaf {
+		for i := range node.Entries {
+			child := node.Entries[i].Index
+			t.refreshSubtree(child)
+			node.Entries[i].BBox = t.calculateBound(child)
+		}
+	}
+	t.summarise(n)
+}
@@ -0,0 +1,114 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	rt.EnableLookup()
+	boxes := make([]BBox, 100)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		rt.Insert(boxes[i], i, ins)
+	}
+	nodes := len(rt.Nodes)
+	gen := rt.Generation()
+
+	rt.Translate(0.5, -0.25)
+	if rt.Generation() == gen {
+		t.Error("expected generation to change")
+	}
+	if len(rt.Nodes) != nodes {
+		t.Error("expected structure to be unchanged")
+	}
+	for i, bb := range boxes {
+		boxes[i] = BBox{bb.MinX + 0.5, bb.MinY - 0.25, bb.MaxX + 0.5, bb.MaxY - 0.25}
+		if got, _ := rt.BBoxOf(i); got != boxes[i] {
+			t.Fatalf("BBoxOf(%d) = %v, want %v", i, got, boxes[i])
+		}
+	}
+	checkInvariants(t, rt)
+	for i := 0; i < 10; i++ {
+		query := randomBox(rnd, 1.5, 0.5)
+		query.MinY -= 0.25
+		query.MaxY -= 0.25
+		var want []int
+		for j, bb := range boxes {
+			if overlap(bb, query) {
+				want = append(want, j)
+			}
+		}
+		var got []int
+		rt.Search(query, func(idx int) { got = append(got, idx) })
+		if len(got) != len(want) {
+			t.Fatalf("query %v: got %d items, want %d", query, len(got), len(want))
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		a, b, c, d         float64
+		tx, ty             float64
+		repack             bool
+		preservesStructure bool
+	}{
+		{"scale", 2, 0, 0, 3, 1, -1, false, true},
+		{"mirror", -1, 0, 0, -2, 0, 0, false, true},
+		{"rotate", 0.6, -0.8, 0.8, 0.6, 0.5, 0, false, true},
+		{"rotate_repack", 0.6, -0.8, 0.8, 0.6, 0.5, 0, true, false},
+		{"shear_repack", 1, 0.5, 0, 1, 0, 0, true, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(0))
+			ins, err := NewInsertionPolicy(2, 4)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rt RTree
+			rt.EnableLookup()
+			boxes := make([]BBox, 100)
+			for i := range boxes {
+				boxes[i] = randomBox(rnd, 0.9, 0.1)
+				rt.InsertWithTags(boxes[i], i, 1<<uint(i%5), ins)
+			}
+			nodes := len(rt.Nodes)
+
+			if tc.repack {
+				rt.TransformAndRepack(tc.a, tc.b, tc.c, tc.d, tc.tx, tc.ty, ins)
+			} else {
+				rt.Transform(tc.a, tc.b, tc.c, tc.d, tc.tx, tc.ty)
+			}
+			if tc.preservesStructure && len(rt.Nodes) != nodes {
+				t.Error("expected structure to be unchanged")
+			}
+			checkInvariants(t, rt)
+
+			// Each item should be found by a query at its transformed
+			// centre, and keep its tags.
+			for i, bb := range boxes {
+				x, y := (bb.MinX+bb.MaxX)/2, (bb.MinY+bb.MaxY)/2
+				px, py := tc.a*x+tc.b*y+tc.tx, tc.c*x+tc.d*y+tc.ty
+				var found bool
+				rt.SearchTagged(BBox{px, py, px, py}, 1<<uint(i%5), func(idx int) {
+					found = found || idx == i
+				})
+				if !found {
+					t.Fatalf("item %d not found at (%v, %v)", i, px, py)
+				}
+				got, _ := rt.BBoxOf(i)
+				if !contains(got, BBox{px, py, px, py}) {
+					t.Fatalf("BBoxOf(%d) = %v doesn't contain (%v, %v)", i, got, px, py)
+				}
+			}
+		})
+	}
+}
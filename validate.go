@@ -0,0 +1,164 @@
+package rtree
+
+import (
+	"fmt"
+	"math"
+)
+
+// ValidateDeep checks the structure of the tree, giving an error wrapping
+// ErrCorruptTree that describes the first problem found. Unlike operations
+// that assume a well formed tree, it doesn't panic or loop forever when the
+// tree is corrupt, so it's suitable for checking trees that have been
+// decoded from untrusted storage. It detects:
+//
+//   - Root or child node indices that are out of range.
+//   - Nodes that are reachable more than once (including via cycles), or
+//     that aren't reachable at all.
+//   - Empty nodes other than the root.
+//   - Entries leading to nodes whose bounding boxes aren't the smallest
+//     covering the nodes' entries, and nodes whose tags aren't the union of
+//     the tags of their entries.
+//
+// Leaves at different depths aren't a problem, since some bulk loaders
+// (such as BulkLoad and BulkLoadKD) produce them. Unreachable nodes, empty
+// nodes and problems in the last category can be fixed by Repair.
+func (t *RTree) ValidateDeep() error {
+	if len(t.Nodes) == 0 {
+		return nil
+	}
+	visited, err := t.checkStructure()
+	if err != nil {
+		return err
+	}
+	for n, v := range visited {
+		if !v {
+			return fmt.Errorf("%w: node %d is unreachable", ErrCorruptTree, n)
+		}
+	}
+	for n, node := range t.Nodes {
+		if tags := t.calculateTags(n); node.Tags != tags {
+			return fmt.Errorf("%w: node %d has tags %#x, expected %#x", ErrCorruptTree, n, node.Tags, tags)
+		}
+		if node.IsLeaf {
+			continue
+		}
+		for _, e := range node.Entries {
+			child := &t.Nodes[e.Index]
+			if len(child.Entries) == 0 {
+				return fmt.Errorf("%w: non-root node %d is empty", ErrCorruptTree, e.Index)
+			}
+			if bound := t.calculateBound(e.Index); !sameBBox(e.BBox, bound) {
+				return fmt.Errorf("%w: entry for node %d has bbox %v, expected %v", ErrCorruptTree, e.Index, e.BBox, bound)
+			}
+		}
+	}
+	return nil
+}
+
+// Repair fixes problems in the structure of the tree that can be re-derived
+// from the data in its leaves. The bounding boxes, tags and aggregates of
+// nodes are recalculated from the entries in the leaves, and empty or
+// unreachable nodes are removed.
+//
+// Problems that can't be fixed without losing items (out of range indices,
+// and nodes reachable more than once) cause an error wrapping ErrCorruptTree
+// to be returned, in which case the tree is left unchanged. Otherwise, the
+// journal's undo and redo steps (see EnableJournal) and the version history
+// (see EnableVersioning) are discarded, since they may refer to the tree as
+// it was before the repair.
+func (t *RTree) Repair() error {
+	if len(t.Nodes) == 0 {
+		return nil
+	}
+	visited, err := t.checkStructure()
+	if err != nil {
+		return err
+	}
+
+	dead := make([]bool, len(t.Nodes))
+	for n, v := range visited {
+		dead[n] = !v
+	}
+	var fix func(n int)
+	fix = func(n int) {
+		node := &t.Nodes[n]
+		if !node.IsLeaf {
+			kept := node.Entries[:0]
+			for _, e := range node.Entries {
+				fix(e.Index)
+				if len(t.Nodes[e.Index].Entries) == 0 {
+					dead[e.Index] = true
+					continue
+				}
+				e.BBox = t.calculateBound(e.Index)
+				kept = append(kept, e)
+			}
+			node.Entries = kept
+		}
+		t.summarise(n)
+	}
+	fix(t.RootIndex)
+
+	// If every leaf was empty, then the tree has no items and is reduced
+	// to an empty root leaf.
+	if root := &t.Nodes[t.RootIndex]; len(root.Entries) == 0 {
+		for n := range dead {
+			dead[n] = n != t.RootIndex
+		}
+		root.IsLeaf = true
+	}
+	t.compactNodes(dead)
+	t.invalidateHint()
+	if t.lookup != nil {
+		t.lookup = nil
+		t.EnableLookup()
+	}
+	t.generation++
+	t.resetHistory()
+	return nil
+}
+
+// checkStructure checks that the node indices reachable from the root are in
+// range, and that no node is reachable more than once. These are the
+// properties that other traversals rely on to terminate. The nodes reachable
+// from the root are reported.
+func (t *RTree) checkStructure() ([]bool, error) {
+	if t.RootIndex < 0 || t.RootIndex >= len(t.Nodes) {
+		return nil, fmt.Errorf("%w: root index %d out of range", ErrCorruptTree, t.RootIndex)
+	}
+	visited := make([]bool, len(t.Nodes))
+	var check func(n int) error
+	check = func(n int) error {
+		if visited[n] {
+			return fmt.Errorf("%w: node %d is reachable more than once", ErrCorruptTree, n)
+		}
+		visited[n] = true
+		node := &t.Nodes[n]
+		if node.IsLeaf {
+			return nil
+		}
+		for _, e := range node.Entries {
+			if e.Index < 0 || e.Index >= len(t.Nodes) {
+				return fmt.Errorf("%w: node %d has child %d out of range", ErrCorruptTree, n, e.Index)
+			}
+			if err := check(e.Index); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := check(t.RootIndex); err != nil {
+		return nil, err
+	}
+	return visited, nil
+}
+
+// sameBBox checks if two bounding boxes are identical, treating NaN
+// coordinates as equal to each other.
+func sameBBox(a, b BBox) bool {
+	same := func(x, y float64) bool {
+		return x == y || math.IsNaN(x) && math.IsNaN(y)
+	}
+	return same(a.MinX, b.MinX) && same(a.MinY, b.MinY) &&
+		same(a.MaxX, b.MaxX) && same(a.MaxY, b.MaxY)
+}
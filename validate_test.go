@@ -0,0 +1,163 @@
+package rtree
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestValidateDeepAndRepair(t *testing.T) {
+	build := func() (RTree, []BBox) {
+		rnd := rand.New(rand.NewSource(0))
+		policy, err := NewInsertionPolicy(2, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rt RTree
+		boxes := make([]BBox, 100)
+		for i := range boxes {
+			boxes[i] = randomBox(rnd, 0.9, 0.1)
+			rt.InsertWithTags(boxes[i], i, uint64(1)<<(i%8), policy)
+		}
+		return rt, boxes
+	}
+	firstChild := func(rt *RTree) int {
+		return rt.Nodes[rt.RootIndex].Entries[0].Index
+	}
+	firstLeaf := func(rt *RTree) int {
+		n := rt.RootIndex
+		for !rt.Nodes[n].IsLeaf {
+			n = rt.Nodes[n].Entries[0].Index
+		}
+		return n
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		rt, _ := build()
+		if err := rt.ValidateDeep(); err != nil {
+			t.Fatal(err)
+		}
+		var empty RTree
+		if err := empty.ValidateDeep(); err != nil {
+			t.Fatal(err)
+		}
+
+		// BulkLoad and BulkLoadKD leave leaves at different depths.
+		_, boxes := build()
+		items := make([]InsertItem, len(boxes))
+		for i, bb := range boxes {
+			items[i] = InsertItem{BBox: bb, DataIndex: i}
+		}
+		policy := mustPolicy(t, 2, 4)
+		for _, bulk := range []RTree{BulkLoad(items), BulkLoadKD(items, policy)} {
+			if err := bulk.ValidateDeep(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+
+	for name, corrupt := range map[string]func(*RTree){
+		"loose_bbox": func(rt *RTree) {
+			rt.Nodes[rt.RootIndex].Entries[0].BBox.MaxX += 10
+		},
+		"wrong_tags": func(rt *RTree) {
+			rt.Nodes[rt.RootIndex].Tags = 0
+		},
+		"orphan": func(rt *RTree) {
+			rt.Nodes = append(rt.Nodes, Node{IsLeaf: true})
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rt, boxes := build()
+			corrupt(&rt)
+			if err := rt.ValidateDeep(); !errors.Is(err, ErrCorruptTree) {
+				t.Fatalf("expected ErrCorruptTree, got %v", err)
+			}
+			if err := rt.Repair(); err != nil {
+				t.Fatal(err)
+			}
+			if err := rt.ValidateDeep(); err != nil {
+				t.Fatal(err)
+			}
+			checkInvariants(t, rt)
+			checkSearch(t, rt, boxes, rand.New(rand.NewSource(0)))
+		})
+	}
+
+	t.Run("empty_leaf", func(t *testing.T) {
+		rt, boxes := build()
+		leaf := firstLeaf(&rt)
+		for _, e := range rt.Nodes[leaf].Entries {
+			boxes[e.Index] = BBox{-5, -5, -4, -4}
+		}
+		rt.Nodes[leaf].Entries = nil
+		if err := rt.ValidateDeep(); !errors.Is(err, ErrCorruptTree) {
+			t.Fatalf("expected ErrCorruptTree, got %v", err)
+		}
+		if err := rt.Repair(); err != nil {
+			t.Fatal(err)
+		}
+		if err := rt.ValidateDeep(); err != nil {
+			t.Fatal(err)
+		}
+		checkInvariants(t, rt)
+		var got int
+		rt.Search(BBox{0, 0, 1, 1}, func(int) { got++ })
+		var want int
+		for _, bb := range boxes {
+			if bb.MinX >= 0 {
+				want++
+			}
+		}
+		if got != want {
+			t.Errorf("found %d items, want %d", got, want)
+		}
+	})
+
+	t.Run("all_leaves_empty", func(t *testing.T) {
+		rt, _ := build()
+		for i := range rt.Nodes {
+			if rt.Nodes[i].IsLeaf {
+				rt.Nodes[i].Entries = nil
+			}
+		}
+		if err := rt.Repair(); err != nil {
+			t.Fatal(err)
+		}
+		if len(rt.Nodes) != 1 || !rt.Nodes[0].IsLeaf || len(rt.Nodes[0].Entries) != 0 {
+			t.Errorf("expected single empty leaf, got %d nodes", len(rt.Nodes))
+		}
+	})
+
+	for name, corrupt := range map[string]func(*RTree){
+		"root_out_of_range": func(rt *RTree) {
+			rt.RootIndex = len(rt.Nodes)
+		},
+		"child_out_of_range": func(rt *RTree) {
+			rt.Nodes[rt.RootIndex].Entries[0].Index = -1
+		},
+		"cycle": func(rt *RTree) {
+			rt.Nodes[firstChild(rt)].Entries[0].Index = rt.RootIndex
+		},
+		"shared_child": func(rt *RTree) {
+			root := &rt.Nodes[rt.RootIndex]
+			root.Entries[1].Index = root.Entries[0].Index
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rt, _ := build()
+			corrupt(&rt)
+			before := append([]Node(nil), rt.Nodes...)
+			if err := rt.ValidateDeep(); !errors.Is(err, ErrCorruptTree) {
+				t.Fatalf("expected ErrCorruptTree from ValidateDeep, got %v", err)
+			}
+			if err := rt.Repair(); !errors.Is(err, ErrCorruptTree) {
+				t.Fatalf("expected ErrCorruptTree from Repair, got %v", err)
+			}
+			if !reflect.DeepEqual(before, rt.Nodes) {
+				t.Error("tree modified by failed repair")
+			}
+		})
+	}
+}
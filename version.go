@@ -0,0 +1,136 @@
+package rtree
+
+import (
+	"fmt"
+	"sort"
+)
+
+// EnableVersioning turns on recording of the history of the tree's items, so
+// that SearchAsOf can find the items as they were at earlier generations (as
+// given by Generation). This is useful for debugging, and for consistent
+// reads while the tree is modified over a long period. It also turns on
+// lookup tracking (see EnableLookup), since the history is recorded as the
+// tracked bounding boxes change.
+//
+// The history starts at the current generation. It uses extra memory
+// proportional to the number of modifications, which can be released using
+// TrimVersions. Modifications that change every item at once (Translate,
+// Transform, Clear and a successful Repair) discard the history, which then
+// restarts at the generation they produce.
+func (t *RTree) EnableVersioning() {
+	t.EnableLookup()
+	if t.versions == nil {
+		t.versions = &versionHistory{since: t.generation}
+	}
+}
+
+// DisableVersioning turns off the recording enabled by EnableVersioning, and
+// discards the recorded history.
+func (t *RTree) DisableVersioning() {
+	t.versions = nil
+}
+
+// versionHistory records the changes made to items, in the order that they
+// were made.
+type versionHistory struct {
+	// since is the oldest generation that the history can reconstruct.
+	since   uint64
+	changes []versionChange
+}
+
+// versionChange records that an item's bounding box changed (or that it was
+// added or removed) while the tree was moving to the generation gen. The old
+// state of the item is kept, so that the change can be undone.
+type versionChange struct {
+	gen     uint64
+	index   int
+	old     BBox
+	present bool // whether the item was in the tree before the change
+}
+
+// recordChange notes that the item is about to change from the given state.
+// It must be called after the generation has been incremented for the
+// modification.
+func (t *RTree) recordChange(dataIndex int, old BBox, present bool) {
+	t.versions.changes = append(t.versions.changes, versionChange{t.generation, dataIndex, old, present})
+}
+
+// resetVersions discards the recorded history, so that it starts from the
+// current generation.
+func (t *RTree) resetVersions() {
+	if t.versions != nil {
+		t.versions.since = t.generation
+		t.versions.changes = nil
+	}
+}
+
+// OldestVersion gives the earliest generation that SearchAsOf can search. The
+// return value ok is false if versioning isn't enabled.
+func (t *RTree) OldestVersion() (gen uint64, ok bool) {
+	if t.versions == nil {
+		return 0, false
+	}
+	return t.versions.since, true
+}
+
+// TrimVersions discards the history needed to search generations earlier
+// than gen, releasing its memory.
+func (t *RTree) TrimVersions(gen uint64) {
+	if t.versions == nil || gen <= t.versions.since {
+		return
+	}
+	gen = min(gen, t.generation)
+	changes := t.versions.changes
+	i := sort.Search(len(changes), func(i int) bool { return changes[i].gen > gen })
+	t.versions.changes = append(changes[:0], changes[i:]...)
+	t.versions.since = gen
+}
+
+// SearchAsOf is like Search, but finds the items that overlapped with the
+// bounding box when the tree was at an earlier generation. Like lookup
+// tracking, it relies on data indices being unique.
+//
+// An error wrapping ErrNotFound is returned if versioning isn't enabled, or
+// if the generation is outside of the recorded history.
+func (t *RTree) SearchAsOf(bb BBox, gen uint64, callback func(index int)) error {
+	if t.versions == nil {
+		return fmt.Errorf("%w: versioning is not enabled", ErrNotFound)
+	}
+	if gen < t.versions.since || gen > t.generation {
+		return fmt.Errorf("%w: generation %d is outside of the recorded history (%d to %d)",
+			ErrNotFound, gen, t.versions.since, t.generation)
+	}
+
+	// Find the state of each item that has changed since the generation.
+	// Changes are undone from the most recent, so that the earliest change
+	// to each item gives its old state.
+	changed := make(map[int]versionChange)
+	changes := t.versions.changes
+	for i := len(changes) - 1; i >= 0 && changes[i].gen > gen; i-- {
+		changed[changes[i].index] = changes[i]
+	}
+
+	t.Search(bb, func(idx int) {
+		if _, ok := changed[idx]; !ok {
+			callback(idx)
+		}
+	})
+	var old []int
+	queries := t.Period.wrappedQueries(bb)
+	for idx, c := range changed {
+		if !c.present {
+			continue
+		}
+		for _, q := range queries {
+			if overlap(c.old, q) {
+				old = append(old, idx)
+				break
+			}
+		}
+	}
+	sort.Ints(old)
+	for _, idx := range old {
+		callback(idx)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package rtree
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSearchAsOf(t *testing.T) {
+	rnd := rand.New(rand.NewSource(0))
+	policy := mustPolicy(t, 2, 4)
+	var rt RTree
+	for i := 0; i < 50; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, policy)
+	}
+	rt.EnableVersioning()
+
+	// Record the items present at each generation, applying a mix of
+	// modifications to the tree.
+	current := make(map[int]BBox)
+	for idx, bb := range rt.lookup {
+		current[idx] = bb
+	}
+	snapshots := make(map[uint64]map[int]BBox)
+	snapshot := func() {
+		items := make(map[int]BBox, len(current))
+		for idx, bb := range current {
+			items[idx] = bb
+		}
+		snapshots[rt.Generation()] = items
+	}
+	snapshot()
+	next := 50
+	for i := 0; i < 300; i++ {
+		idx := rnd.Intn(next)
+		_, live := current[idx]
+		switch rnd.Intn(6) {
+		case 0:
+			bb := randomBox(rnd, 0.9, 0.1)
+			rt.Insert(bb, next, policy)
+			current[next] = bb
+			next++
+		case 1:
+			if live {
+				rt.DeleteByIndex(idx)
+				delete(current, idx)
+			}
+		case 2:
+			if live {
+				bb := randomBox(rnd, 0.9, 0.1)
+				rt.Move(idx, bb, policy)
+				current[idx] = bb
+			}
+		case 3:
+			if live {
+				bb := randomBox(rnd, 0.9, 0.1)
+				rt.Adjust(idx, bb, policy)
+				current[idx] = bb
+			}
+		case 4:
+			if live {
+				rt.MarkDeleted(idx)
+				delete(current, idx)
+			}
+		case 5:
+			rt.Vacuum()
+		}
+		snapshot()
+	}
+	checkInvariants(t, rt)
+
+	for gen, items := range snapshots {
+		query := randomBox(rnd, 0.5, 0.5)
+		var got, want []int
+		if err := rt.SearchAsOf(query, gen, func(idx int) { got = append(got, idx) }); err != nil {
+			t.Fatal(err)
+		}
+		for idx, bb := range items {
+			if overlap(bb, query) {
+				want = append(want, idx)
+			}
+		}
+		sort.Ints(got)
+		sort.Ints(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("generation %d: got %v want %v", gen, got, want)
+		}
+	}
+
+	oldest, _ := rt.OldestVersion()
+	mid := oldest + (rt.Generation()-oldest)/2
+	rt.TrimVersions(mid)
+	if got, ok := rt.OldestVersion(); !ok || got != mid {
+		t.Errorf("expected oldest version %d, got %d", mid, got)
+	}
+	if err := rt.SearchAsOf(everywhere, mid-1, func(int) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for trimmed generation, got %v", err)
+	}
+	var got []int
+	if err := rt.SearchAsOf(everywhere, mid, func(idx int) { got = append(got, idx) }); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(snapshots[mid]) {
+		t.Errorf("after trim: got %d items, want %d", len(got), len(snapshots[mid]))
+	}
+
+	rt.Clear()
+	if got, _ := rt.OldestVersion(); got != rt.Generation() {
+		t.Errorf("expected clear to reset history, oldest version is %d", got)
+	}
+	if err := rt.SearchAsOf(everywhere, rt.Generation()+1, func(int) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for future generation, got %v", err)
+	}
+	rt.DisableVersioning()
+	if err := rt.SearchAsOf(everywhere, rt.Generation(), func(int) {}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound when versioning is disabled, got %v", err)
+	}
+}
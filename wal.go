@@ -0,0 +1,254 @@
+package rtree
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// LogStore is the storage for a write-ahead log. It is satisfied by
+// *os.File.
+type LogStore interface {
+	io.ReadWriteSeeker
+
+	// Truncate changes the size of the log.
+	Truncate(size int64) error
+
+	// Sync commits the contents of the log to stable storage.
+	Sync() error
+}
+
+// NewPagedRTreeWithWAL creates a new empty tree, stored using the pager, with
+// modifications made durable using a write-ahead log. The log should be
+// empty.
+//
+// Each Insert and Delete is committed to the log before it returns. Modified
+// pages are held in memory, and are only written to the pager by Checkpoint.
+// The pager must have made the pages durable by the time WritePage returns.
+func NewPagedRTreeWithWAL(pager Pager, log LogStore, policy InsertionPolicy) (*PagedRTree, error) {
+	w := &walPager{base: pager, log: log, dirty: make(map[int][]byte)}
+	t, err := NewPagedRTree(w, policy)
+	if err != nil {
+		return nil, err
+	}
+	t.wal = w
+	if err := w.commit(); err != nil {
+		return nil, err
+	}
+	return t, t.Checkpoint()
+}
+
+// OpenPagedRTreeWithWAL opens an existing tree previously created by
+// NewPagedRTreeWithWAL. Any modifications committed to the log but not yet
+// checkpointed (e.g. due to a crash) are recovered. Partially written log
+// records are discarded, so the tree is restored to the state after the last
+// successfully committed modification.
+func OpenPagedRTreeWithWAL(pager Pager, log LogStore) (*PagedRTree, error) {
+	w := &walPager{base: pager, log: log, dirty: make(map[int][]byte)}
+	if err := w.recover(); err != nil {
+		return nil, err
+	}
+	t, err := OpenPagedRTree(w)
+	if err != nil {
+		return nil, err
+	}
+	t.wal = w
+	return t, t.Checkpoint()
+}
+
+// Checkpoint writes all modifications held in memory to the pager, and then
+// empties the write-ahead log. It has no effect if the tree doesn't use a
+// write-ahead log.
+func (t *PagedRTree) Checkpoint() error {
+	if t.wal == nil {
+		return nil
+	}
+	return t.wal.checkpoint()
+}
+
+const (
+	walPageRecord   = 1
+	walCommitRecord = 2
+)
+
+// walPager is a Pager that stages page writes, committing them to a
+// write-ahead log before making them visible.
+type walPager struct {
+	base    Pager
+	log     LogStore
+	pending map[int][]byte // written by the current modification
+	dirty   map[int][]byte // committed to the log, but not to base
+}
+
+func (w *walPager) ReadPage(id int) ([]byte, error) {
+	if page, ok := w.pending[id]; ok {
+		return page, nil
+	}
+	if page, ok := w.dirty[id]; ok {
+		return page, nil
+	}
+	return w.base.ReadPage(id)
+}
+
+func (w *walPager) WritePage(id int, data []byte) error {
+	if w.pending == nil {
+		w.pending = make(map[int][]byte)
+	}
+	w.pending[id] = append([]byte(nil), data...)
+	return nil
+}
+
+// commit appends the pending pages to the log followed by a commit record,
+// and syncs the log. If that fails, the log is truncated back to where it
+// was, so that the partially written records aren't followed by later
+// commits (which recovery would otherwise never reach).
+func (w *walPager) commit() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	start, err := w.log.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if err := w.appendPending(); err != nil {
+		if terr := w.log.Truncate(start); terr != nil {
+			return errors.Join(err, terr)
+		}
+		if _, serr := w.log.Seek(start, io.SeekStart); serr != nil {
+			return errors.Join(err, serr)
+		}
+		return err
+	}
+	for id, page := range w.pending {
+		w.dirty[id] = page
+	}
+	w.pending = nil
+	return nil
+}
+
+// appendPending writes the pending pages and a commit record to the log, and
+// syncs it.
+func (w *walPager) appendPending() error {
+	bw := bufio.NewWriter(w.log)
+	for id, page := range w.pending {
+		if err := writeWALRecord(bw, walPageRecord, id, page); err != nil {
+			return err
+		}
+	}
+	if err := writeWALRecord(bw, walCommitRecord, 0, nil); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return w.log.Sync()
+}
+
+func (w *walPager) rollback() {
+	w.pending = nil
+}
+
+func (w *walPager) checkpoint() error {
+	for id, page := range w.dirty {
+		if err := w.base.WritePage(id, page); err != nil {
+			return err
+		}
+	}
+	w.dirty = make(map[int][]byte)
+	if err := w.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := w.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return w.log.Sync()
+}
+
+// recover reads the log, staging the pages of all committed modifications
+// as dirty.
+func (w *walPager) recover() error {
+	if _, err := w.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	br := bufio.NewReader(w.log)
+	pending := make(map[int][]byte)
+	for {
+		typ, id, page, err := readWALRecord(br)
+		if err == errTornWALRecord {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case walPageRecord:
+			pending[id] = page
+		case walCommitRecord:
+			for id, page := range pending {
+				w.dirty[id] = page
+			}
+			pending = make(map[int][]byte)
+		default:
+			return errors.New("unknown write-ahead log record type")
+		}
+	}
+	_, err := w.log.Seek(0, io.SeekEnd)
+	return err
+}
+
+// Each record in the log consists of a type byte, a page id, the length of
+// the page data, the page data itself and finally a CRC32 checksum of
+// everything preceding it in the record.
+const walRecordHeaderSize = 1 + 8 + 4
+
+// maxWALPageSize guards against allocating huge buffers when reading a
+// corrupt record length.
+const maxWALPageSize = 1 << 26
+
+var errTornWALRecord = errors.New("torn write-ahead log record")
+
+func writeWALRecord(w io.Writer, typ byte, id int, page []byte) error {
+	buf := make([]byte, walRecordHeaderSize+len(page)+4)
+	buf[0] = typ
+	binary.LittleEndian.PutUint64(buf[1:], uint64(id))
+	binary.LittleEndian.PutUint32(buf[9:], uint32(len(page)))
+	copy(buf[walRecordHeaderSize:], page)
+	sum := crc32.ChecksumIEEE(buf[:walRecordHeaderSize+len(page)])
+	binary.LittleEndian.PutUint32(buf[walRecordHeaderSize+len(page):], sum)
+	_, err := w.Write(buf)
+	return err
+}
+
+// readWALRecord reads the next record from the log. If the log ends or the
+// next record is incomplete or corrupt (i.e. it was being written during a
+// crash), then errTornWALRecord is returned.
+func readWALRecord(r io.Reader) (byte, int, []byte, error) {
+	header := make([]byte, walRecordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, tornIfEOF(err)
+	}
+	n := binary.LittleEndian.Uint32(header[9:])
+	if n > maxWALPageSize {
+		return 0, 0, nil, errTornWALRecord
+	}
+	rest := make([]byte, int(n)+4)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, nil, tornIfEOF(err)
+	}
+	sum := crc32.NewIEEE()
+	sum.Write(header)
+	sum.Write(rest[:n])
+	if sum.Sum32() != binary.LittleEndian.Uint32(rest[n:]) {
+		return 0, 0, nil, errTornWALRecord
+	}
+	return header[0], int(binary.LittleEndian.Uint64(header[1:])), rest[:n], nil
+}
+
+func tornIfEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errTornWALRecord
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package rtree
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// memLog is an in-memory LogStore.
+type memLog struct {
+	data []byte
+	pos  int
+}
+
+func (l *memLog) Read(p []byte) (int, error) {
+	if l.pos >= len(l.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, l.data[l.pos:])
+	l.pos += n
+	return n, nil
+}
+
+func (l *memLog) Write(p []byte) (int, error) {
+	for len(l.data) < l.pos {
+		l.data = append(l.data, 0)
+	}
+	n := copy(l.data[l.pos:], p)
+	l.data = append(l.data, p[n:]...)
+	l.pos += len(p)
+	return len(p), nil
+}
+
+func (l *memLog) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		l.pos = int(offset)
+	case io.SeekCurrent:
+		l.pos += int(offset)
+	case io.SeekEnd:
+		l.pos = len(l.data) + int(offset)
+	}
+	return int64(l.pos), nil
+}
+
+func (l *memLog) Truncate(size int64) error {
+	l.data = l.data[:size]
+	return nil
+}
+
+func (l *memLog) Sync() error { return nil }
+
+// failingLog is a LogStore whose writes or syncs fail once armed. A failed
+// write only writes the first half of the data.
+type failingLog struct {
+	memLog
+	failWrite, failSync bool
+}
+
+func (l *failingLog) Write(p []byte) (int, error) {
+	if l.failWrite {
+		n, _ := l.memLog.Write(p[:len(p)/2])
+		return n, errors.New("write failed")
+	}
+	return l.memLog.Write(p)
+}
+
+func (l *failingLog) Sync() error {
+	if l.failSync {
+		return errors.New("sync failed")
+	}
+	return nil
+}
+
+// failingPager is a Pager whose writes fail once armed.
+type failingPager struct {
+	MemPager
+	fail bool
+}
+
+func (p *failingPager) WritePage(id int, data []byte) error {
+	if p.fail {
+		return errors.New("write failed")
+	}
+	return p.MemPager.WritePage(id, data)
+}
+
+func TestPagedRTreeWALRecovery(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(0))
+	pager := new(failingPager)
+	log := new(memLog)
+	tr, err := NewPagedRTreeWithWAL(pager, log, ins)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boxes := make([]BBox, 100)
+	for i := range boxes {
+		boxes[i] = randomBox(rnd, 0.9, 0.1)
+		if err := tr.Insert(boxes[i], i); err != nil {
+			t.Fatal(err)
+		}
+		if i == 40 {
+			if err := tr.Checkpoint(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for i := 0; i < len(boxes); i += 4 {
+		if ok, err := tr.Delete(boxes[i], i); err != nil || !ok {
+			t.Fatalf("could not delete %d: %v", i, err)
+		}
+		boxes[i] = BBox{-2, -2, -1, -1}
+	}
+
+	// Simulate a crash part way through writing a log record, and while
+	// writing to the pager (so that the pager can't be relied upon).
+	log.Write([]byte{walPageRecord, 1, 2, 3})
+	pager.fail = true
+	if _, err := OpenPagedRTreeWithWAL(pager, log); err == nil {
+		t.Fatal("expected checkpoint during recovery to fail")
+	}
+	pager.fail = false
+
+	recovered, err := OpenPagedRTreeWithWAL(pager, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered.Len() != tr.Len() {
+		t.Errorf("recovered %d items, want %d", recovered.Len(), tr.Len())
+	}
+	if len(log.data) != 0 {
+		t.Errorf("expected log to be empty after recovery")
+	}
+	checkPagedSearch(t, recovered, boxes, rnd)
+}
+
+func TestPagedRTreeWALFailedCommit(t *testing.T) {
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, failSync := range []bool{false, true} {
+		rnd := rand.New(rand.NewSource(0))
+		pager := new(MemPager)
+		log := new(failingLog)
+		tr, err := NewPagedRTreeWithWAL(pager, log, ins)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var boxes []BBox
+		for i := 0; i < 60; i++ {
+			bb := randomBox(rnd, 0.9, 0.1)
+			if i%20 == 10 {
+				log.failWrite, log.failSync = !failSync, failSync
+				if err := tr.Insert(bb, -1); err == nil {
+					t.Fatal("expected insert to fail")
+				}
+				log.failWrite, log.failSync = false, false
+				continue
+			}
+			if err := tr.Insert(bb, len(boxes)); err != nil {
+				t.Fatal(err)
+			}
+			boxes = append(boxes, bb)
+		}
+
+		// The records of the failed commits must not stop later commits
+		// from being recovered, nor be recovered themselves.
+		recovered, err := OpenPagedRTreeWithWAL(pager, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recovered.Len() != len(boxes) {
+			t.Errorf("failSync=%t: recovered %d items, want %d", failSync, recovered.Len(), len(boxes))
+		}
+		checkPagedSearch(t, recovered, boxes, rnd)
+	}
+}
@@ -0,0 +1,84 @@
+package rtree
+
+// Walk visits the nodes of the tree in depth-first order, starting at the
+// root. The callback is called for each node with its level (0 for the root,
+// increasing towards the leaves), its bounding box, and whether it's a leaf.
+// The nodes under a non-leaf node are only visited if the callback returns
+// true for it.
+//
+// Like Search, the callback must not modify the tree.
+func (t *RTree) Walk(callback func(level int, nodeBB BBox, isLeaf bool) (descend bool)) {
+	if len(t.Nodes) == 0 {
+		return
+	}
+	gen := t.generation
+	var recurse func(n, level int, bb BBox)
+	recurse = func(n, level int, bb BBox) {
+		node := &t.Nodes[n]
+		descend := callback(level, bb, node.IsLeaf)
+		t.checkGeneration(gen)
+		if !descend || node.IsLeaf {
+			return
+		}
+		for _, e := range node.Entries {
+			recurse(e.Index, level+1, e.BBox)
+		}
+	}
+	recurse(t.RootIndex, 0, t.calculateBound(t.RootIndex))
+}
+
+// LevelNode describes a node visited by LevelOrder.
+type LevelNode struct {
+	// Level is 0 for the root, increasing towards the leaves.
+	Level int
+
+	// Index is the index of the node in the tree's Nodes slice.
+	Index int
+
+	// BBox is the smallest bounding box containing everything under the
+	// node.
+	BBox BBox
+
+	IsLeaf bool
+
+	// Entries is the number of entries in the node.
+	Entries int
+}
+
+// LevelOrder gives an iterator over the nodes of the tree in breadth-first
+// order: the root first, then all nodes one level below the root, and so on
+// down to the leaves. Each call to the returned function gives the next node,
+// or false once all nodes have been visited. The tree must not be modified
+// while iterating over it (the iterator panics if it detects that it was).
+func (t *RTree) LevelOrder() func() (LevelNode, bool) {
+	var queue []LevelNode
+	if len(t.Nodes) > 0 {
+		queue = append(queue, t.levelNode(t.RootIndex, 0, t.calculateBound(t.RootIndex)))
+	}
+	gen := t.generation
+	return func() (LevelNode, bool) {
+		if len(queue) == 0 {
+			return LevelNode{}, false
+		}
+		t.checkGeneration(gen)
+		ln := queue[0]
+		queue = queue[1:]
+		if !ln.IsLeaf {
+			for _, e := range t.Nodes[ln.Index].Entries {
+				queue = append(queue, t.levelNode(e.Index, ln.Level+1, e.BBox))
+			}
+		}
+		return ln, true
+	}
+}
+
+func (t *RTree) levelNode(n, level int, bb BBox) LevelNode {
+	node := &t.Nodes[n]
+	return LevelNode{
+		Level:   level,
+		Index:   n,
+		BBox:    bb,
+		IsLeaf:  node.IsLeaf,
+		Entries: len(node.Entries),
+	}
+}
@@ -0,0 +1,137 @@
+package rtree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	var empty RTree
+	empty.Walk(func(int, BBox, bool) bool {
+		t.Error("unexpected callback for empty tree")
+		return true
+	})
+
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	for i := 0; i < 200; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, ins)
+	}
+
+	// A full walk visits every node once, with leaves at the deepest level.
+	var visited, leafLevel int
+	rootBB := EmptyBBox
+	rt.Walk(func(level int, bb BBox, isLeaf bool) bool {
+		visited++
+		if level == 0 {
+			rootBB = bb
+		} else if !contains(rootBB, bb) {
+			t.Errorf("node bbox %v not inside root bbox %v", bb, rootBB)
+		}
+		if isLeaf {
+			if leafLevel != 0 && level != leafLevel {
+				t.Errorf("leaf at level %d, others at %d", level, leafLevel)
+			}
+			leafLevel = level
+		}
+		return true
+	})
+	if visited != len(rt.Nodes) {
+		t.Errorf("visited %d nodes, want %d", visited, len(rt.Nodes))
+	}
+	if rootBB != rt.calculateBound(rt.RootIndex) {
+		t.Errorf("got root bbox %v", rootBB)
+	}
+
+	// Pruning at the root only visits the root.
+	visited = 0
+	rt.Walk(func(level int, bb BBox, isLeaf bool) bool {
+		visited++
+		return false
+	})
+	if visited != 1 {
+		t.Errorf("visited %d nodes after pruning, want 1", visited)
+	}
+
+	// Pruning nodes outside a region skips their subtrees, but still finds
+	// all of the leaves overlapping with the region.
+	region := BBox{0, 0, 0.3, 0.3}
+	countLeaves := func(prune bool) (nodes, leaves int) {
+		rt.Walk(func(level int, bb BBox, isLeaf bool) bool {
+			nodes++
+			if isLeaf && overlap(bb, region) {
+				leaves++
+			}
+			return !prune || overlap(bb, region)
+		})
+		return nodes, leaves
+	}
+	allNodes, allLeaves := countLeaves(false)
+	prunedNodes, prunedLeaves := countLeaves(true)
+	if prunedNodes >= allNodes {
+		t.Errorf("expected pruning to visit fewer than %d nodes, got %d", allNodes, prunedNodes)
+	}
+	if allLeaves == 0 || prunedLeaves != allLeaves {
+		t.Errorf("found %d leaves in region when pruning, want %d", prunedLeaves, allLeaves)
+	}
+}
+
+func TestLevelOrder(t *testing.T) {
+	var empty RTree
+	if _, ok := empty.LevelOrder()(); ok {
+		t.Error("expected no nodes for empty tree")
+	}
+
+	rnd := rand.New(rand.NewSource(0))
+	ins, err := NewInsertionPolicy(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rt RTree
+	for i := 0; i < 200; i++ {
+		rt.Insert(randomBox(rnd, 0.9, 0.1), i, ins)
+	}
+
+	next := rt.LevelOrder()
+	seen := make(map[int]bool)
+	var prevLevel, items int
+	for {
+		ln, ok := next()
+		if !ok {
+			break
+		}
+		if ln.Level < prevLevel {
+			t.Fatalf("level went from %d to %d", prevLevel, ln.Level)
+		}
+		prevLevel = ln.Level
+		if seen[ln.Index] {
+			t.Fatalf("node %d visited twice", ln.Index)
+		}
+		seen[ln.Index] = true
+		if ln.BBox != rt.calculateBound(ln.Index) {
+			t.Fatalf("node %d has bbox %v", ln.Index, ln.BBox)
+		}
+		if ln.IsLeaf {
+			items += ln.Entries
+		}
+	}
+	if len(seen) != len(rt.Nodes) || items != 200 {
+		t.Errorf("visited %d nodes and %d items", len(seen), items)
+	}
+
+	next = rt.LevelOrder()
+	next()
+	rt.Insert(BBox{}, 200, ins)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic after modification")
+			}
+		}()
+		next()
+	}()
+}
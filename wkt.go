@@ -0,0 +1,236 @@
+package rtree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BBoxFromWKT gives the envelope of a geometry in Well Known Text format.
+// The geometry is only parsed far enough to find its coordinates, and any Z
+// and M values are ignored. An EWKT SRID prefix (e.g. "SRID=4326;") is
+// allowed. Empty geometries give EmptyBBox. Errors caused by malformed input
+// wrap ErrCorruptTree.
+func BBoxFromWKT(wkt string) (BBox, error) {
+	if strings.HasPrefix(strings.ToUpper(strings.TrimSpace(wkt)), "SRID=") {
+		i := strings.IndexByte(wkt, ';')
+		if i == -1 {
+			return BBox{}, fmt.Errorf("%w: WKT: SRID prefix not terminated by ';'", ErrCorruptTree)
+		}
+		wkt = wkt[i+1:]
+	}
+
+	bb := EmptyBBox
+	var tuple []float64
+	endTuple := func() error {
+		switch len(tuple) {
+		case 0:
+		case 1:
+			return fmt.Errorf("%w: WKT: coordinate has only 1 value", ErrCorruptTree)
+		default:
+			bb = combine(bb, BBox{tuple[0], tuple[1], tuple[0], tuple[1]})
+		}
+		tuple = tuple[:0]
+		return nil
+	}
+
+	for i := 0; i < len(wkt); {
+		c := wkt[i]
+		switch {
+		case c == '(' || c == ')' || c == ',':
+			if err := endTuple(); err != nil {
+				return BBox{}, err
+			}
+			i++
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(wkt) && strings.IndexByte("0123456789.eE+-", wkt[j]) != -1 {
+				j++
+			}
+			f, err := strconv.ParseFloat(wkt[i:j], 64)
+			if err != nil {
+				return BBox{}, fmt.Errorf("%w: WKT: invalid number %q", ErrCorruptTree, wkt[i:j])
+			}
+			tuple = append(tuple, f)
+			i = j
+		case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			// Geometry types, dimension markers (Z, M, ZM) and EMPTY
+			// don't affect the envelope.
+			if len(tuple) > 0 {
+				return BBox{}, fmt.Errorf("%w: WKT: unexpected word at offset %d", ErrCorruptTree, i)
+			}
+			for i < len(wkt) && ((wkt[i] >= 'a' && wkt[i] <= 'z') || (wkt[i] >= 'A' && wkt[i] <= 'Z')) {
+				i++
+			}
+		default:
+			return BBox{}, fmt.Errorf("%w: WKT: unexpected character %q", ErrCorruptTree, c)
+		}
+	}
+	if err := endTuple(); err != nil {
+		return BBox{}, err
+	}
+	return bb, nil
+}
+
+// BBoxFromWKB gives the envelope of a geometry in Well Known Binary format.
+// Both ISO WKB and PostGIS EWKB are supported. Any Z and M values are
+// ignored. Empty geometries give EmptyBBox. Errors caused by malformed input
+// wrap ErrCorruptTree.
+func BBoxFromWKB(wkb []byte) (BBox, error) {
+	p := wkbParser{data: wkb, bb: EmptyBBox}
+	if err := p.geometry(); err != nil {
+		return BBox{}, fmt.Errorf("%w: WKB: %w", ErrCorruptTree, err)
+	}
+	if p.pos != len(wkb) {
+		return BBox{}, fmt.Errorf("%w: WKB: unexpected trailing bytes", ErrCorruptTree)
+	}
+	return p.bb, nil
+}
+
+type wkbParser struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+	bb    BBox
+}
+
+var errWKBTruncated = errors.New("truncated")
+
+func (p *wkbParser) uint32() (uint32, error) {
+	if len(p.data)-p.pos < 4 {
+		return 0, errWKBTruncated
+	}
+	v := p.order.Uint32(p.data[p.pos:])
+	p.pos += 4
+	return v, nil
+}
+
+// point reads a point with the given number of values, extending the
+// envelope by its X and Y values. Points with NaN coordinates are empty.
+func (p *wkbParser) point(dims int) error {
+	if len(p.data)-p.pos < 8*dims {
+		return errWKBTruncated
+	}
+	x := math.Float64frombits(p.order.Uint64(p.data[p.pos:]))
+	y := math.Float64frombits(p.order.Uint64(p.data[p.pos+8:]))
+	p.pos += 8 * dims
+	if !math.IsNaN(x) && !math.IsNaN(y) {
+		p.bb = combine(p.bb, BBox{x, y, x, y})
+	}
+	return nil
+}
+
+func (p *wkbParser) geometry() error {
+	if p.pos >= len(p.data) {
+		return errWKBTruncated
+	}
+	switch p.data[p.pos] {
+	case 0:
+		p.order = binary.BigEndian
+	case 1:
+		p.order = binary.LittleEndian
+	default:
+		return fmt.Errorf("invalid byte order %d", p.data[p.pos])
+	}
+	p.pos++
+
+	typ, err := p.uint32()
+	if err != nil {
+		return err
+	}
+
+	// EWKB stores the dimensions and SRID as flags, whereas ISO WKB adds
+	// multiples of 1000 to the type.
+	dims := 2
+	if typ&0x80000000 != 0 {
+		dims++
+	}
+	if typ&0x40000000 != 0 {
+		dims++
+	}
+	if typ&0x20000000 != 0 {
+		if _, err := p.uint32(); err != nil { // SRID
+			return err
+		}
+	}
+	typ &= 0x0fffffff
+	switch typ / 1000 {
+	case 1, 2:
+		dims++
+	case 3:
+		dims += 2
+	}
+	typ %= 1000
+
+	count := func() (int, error) {
+		n, err := p.uint32()
+		if err == nil && int64(n) > int64(len(p.data)) {
+			err = errWKBTruncated
+		}
+		return int(n), err
+	}
+	switch typ {
+	case 1: // Point
+		return p.point(dims)
+	case 2: // LineString
+		n, err := count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := p.point(dims); err != nil {
+				return err
+			}
+		}
+		return nil
+	case 3: // Polygon
+		rings, err := count()
+		if err != nil {
+			return err
+		}
+		for r := 0; r < rings; r++ {
+			n, err := count()
+			if err != nil {
+				return err
+			}
+			for i := 0; i < n; i++ {
+				if err := p.point(dims); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case 4, 5, 6, 7: // MultiPoint, MultiLineString, MultiPolygon, GeometryCollection
+		n, err := count()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := p.geometry(); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported geometry type %d", typ)
+	}
+}
+
+// WKT gives the bounding box as a Well Known Text POLYGON. EmptyBBox gives
+// "POLYGON EMPTY".
+func (b BBox) WKT() string {
+	if b.IsEmpty() {
+		return "POLYGON EMPTY"
+	}
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	minX, minY, maxX, maxY := f(b.MinX), f(b.MinY), f(b.MaxX), f(b.MaxY)
+	return fmt.Sprintf("POLYGON((%s %s,%s %s,%s %s,%s %s,%s %s))",
+		minX, minY, maxX, minY, maxX, maxY, minX, maxY, minX, minY)
+}
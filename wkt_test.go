@@ -0,0 +1,129 @@
+package rtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestBBoxFromWKT(t *testing.T) {
+	for _, tc := range []struct {
+		wkt  string
+		want BBox
+	}{
+		{"POINT(1 2)", BBox{1, 2, 1, 2}},
+		{"POINT Z (1 2 3)", BBox{1, 2, 1, 2}},
+		{"point zm (-1.5 2e1 3 4)", BBox{-1.5, 20, -1.5, 20}},
+		{"LINESTRING(0 0,3 -4,1 1)", BBox{0, -4, 3, 1}},
+		{"POLYGON((0 0,4 0,4 3,0 0),(1 1,2 1,2 2,1 1))", BBox{0, 0, 4, 3}},
+		{"MULTIPOINT((1 2),(3 4))", BBox{1, 2, 3, 4}},
+		{"MULTIPOINT(1 2,3 4)", BBox{1, 2, 3, 4}},
+		{"GEOMETRYCOLLECTION(POINT(5 5),LINESTRING EMPTY,POINT(-1 7))", BBox{-1, 5, 5, 7}},
+		{"SRID=4326;POINT(1 2)", BBox{1, 2, 1, 2}},
+		{"POINT EMPTY", EmptyBBox},
+	} {
+		got, err := BBoxFromWKT(tc.wkt)
+		if err != nil {
+			t.Errorf("%s: %v", tc.wkt, err)
+		} else if got != tc.want {
+			t.Errorf("%s: got %v want %v", tc.wkt, got, tc.want)
+		}
+	}
+
+	for _, wkt := range []string{
+		"POINT(1)",
+		"POINT(1 2 foo)",
+		"POINT(1 2;)",
+		"POINT(1..2 3)",
+		"SRID=4326 POINT(1 2)",
+	} {
+		if _, err := BBoxFromWKT(wkt); !errors.Is(err, ErrCorruptTree) {
+			t.Errorf("%s: expected ErrCorruptTree, got %v", wkt, err)
+		}
+	}
+}
+
+func TestBBoxFromWKB(t *testing.T) {
+	for _, tc := range []struct {
+		hex  string
+		want BBox
+	}{
+		// POINT(1 2)
+		{"0101000000000000000000f03f0000000000000040", BBox{1, 2, 1, 2}},
+		// POINT(1 2), big endian
+		{"00000000013ff00000000000004000000000000000", BBox{1, 2, 1, 2}},
+		// LINESTRING(0 0,3 -4)
+		{"01020000000200000000000000000000000000000000000000000000000000084000000000000010c0", BBox{0, -4, 3, 0}},
+		// POINT Z (1 2 3), ISO
+		{"01e9030000000000000000f03f00000000000000400000000000000840", BBox{1, 2, 1, 2}},
+		// SRID=4326;POINT(1 2), EWKB
+		{"0101000020e6100000000000000000f03f0000000000000040", BBox{1, 2, 1, 2}},
+		// POINT EMPTY
+		{"0101000000000000000000f87f000000000000f87f", EmptyBBox},
+	} {
+		wkb, err := hex.DecodeString(tc.hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := BBoxFromWKB(wkb)
+		if err != nil {
+			t.Errorf("%s: %v", tc.hex, err)
+		} else if got != tc.want {
+			t.Errorf("%s: got %v want %v", tc.hex, got, tc.want)
+		}
+	}
+
+	// MULTIPOLYGON with a single polygon (with a single ring) built by hand.
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	buf.WriteByte(1)
+	binary.Write(&buf, le, uint32(6))
+	binary.Write(&buf, le, uint32(1))
+	buf.WriteByte(1)
+	binary.Write(&buf, le, uint32(3))
+	binary.Write(&buf, le, uint32(1))
+	binary.Write(&buf, le, uint32(4))
+	for _, xy := range [][2]float64{{0, 0}, {5, 0}, {5, 6}, {0, 0}} {
+		binary.Write(&buf, le, xy)
+	}
+	got, err := BBoxFromWKB(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (BBox{0, 0, 5, 6}); got != want {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	for _, bad := range []string{
+		"",
+		"02",
+		"0101000000000000000000f03f",
+		"0109000000",
+		"0102000000ffffff7f",
+		"0101000000000000000000f03f000000000000004000",
+	} {
+		wkb, _ := hex.DecodeString(bad)
+		if _, err := BBoxFromWKB(wkb); !errors.Is(err, ErrCorruptTree) {
+			t.Errorf("%s: expected ErrCorruptTree, got %v", bad, err)
+		}
+	}
+}
+
+func TestBBoxWKT(t *testing.T) {
+	bb := BBox{-1.5, 2, 3, 4e10}
+	wkt := bb.WKT()
+	if want := "POLYGON((-1.5 2,3 2,3 40000000000,-1.5 40000000000,-1.5 2))"; wkt != want {
+		t.Errorf("got %s want %s", wkt, want)
+	}
+	if got, err := BBoxFromWKT(wkt); err != nil || got != bb {
+		t.Errorf("round trip gave %v %v", got, err)
+	}
+	if got := EmptyBBox.WKT(); got != "POLYGON EMPTY" {
+		t.Errorf("got %s", got)
+	}
+	if got, err := BBoxFromWKT(EmptyBBox.WKT()); err != nil || !got.IsEmpty() {
+		t.Errorf("round trip of empty gave %v %v", got, err)
+	}
+}